@@ -0,0 +1,167 @@
+// backtest runs a scripted strategy over historical OHLC bars with the bot's fee and margin rules
+// and prints a report with P&L, margin calls and stop-outs.
+//
+//	go run ./cmd/backtest -data cmd/backtest/example_bars.csv -script cmd/backtest/example_strategy.txt -equity equity.csv
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/backtest"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+)
+
+func main() {
+	var dataPath, scriptPath, equityPath string
+	var balance float64
+	flag.StringVar(&dataPath, "data", "", "CSV file with bars: time,ticker,open,high,low,close")
+	flag.StringVar(&scriptPath, "script", "", "strategy script file")
+	flag.StringVar(&equityPath, "equity", "", "CSV file to write equity curve to")
+	flag.Float64Var(&balance, "balance", 250000, "initial available balance")
+	flag.Parse()
+
+	if dataPath == "" || scriptPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	bars, err := loadBars(dataPath)
+	if err != nil {
+		log.Fatal("failed to load bars", zap.String("path", dataPath), zap.Error(err))
+	}
+
+	script, err := loadScript(scriptPath)
+	if err != nil {
+		log.Fatal("failed to load script", zap.String("path", scriptPath), zap.Error(err))
+	}
+
+	report := backtest.Run(bars, script, balance)
+
+	printReport(os.Stdout, report)
+
+	if equityPath != "" {
+		if err := writeEquity(equityPath, report.Equity); err != nil {
+			log.Fatal("failed to write equity curve", zap.String("path", equityPath), zap.Error(err))
+		}
+	}
+}
+
+func loadBars(path string) ([]*backtest.Bar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return backtest.LoadBars(f)
+}
+
+func loadScript(path string) (*backtest.Script, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return backtest.ParseScript(f)
+}
+
+func printReport(w io.Writer, report *backtest.Report) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "EVENTS")
+	fmt.Fprintln(tw, "time\tevent\toperation\tticker\tcount\tprice\tavailable\tblocked")
+	for _, event := range report.Events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%.2f\t%.2f\n",
+			formatTime(event.Time), event.Type, event.OperationType, event.Ticker,
+			formatCount(event.Count), formatPrice(event.Price), event.Available, event.Blocked,
+		)
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "OPEN POSITIONS")
+	fmt.Fprintln(tw, "ticker\tcount\taverage price")
+	tickers := make([]string, 0, len(report.Positions))
+	for ticker := range report.Positions {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+	for _, ticker := range tickers {
+		position := report.Positions[ticker]
+		fmt.Fprintf(tw, "%s\t%d\t%.4f\n", ticker, position.Count, position.AvgPrice)
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "SUMMARY")
+	fmt.Fprintf(tw, "initial balance\t%.2f\n", report.InitialBalance)
+	fmt.Fprintf(tw, "final balance\t%.2f\n", report.FinalBalance)
+	fmt.Fprintf(tw, "P&L\t%.2f (%.2f%%)\n", report.PnL, report.PnLPercent)
+	fmt.Fprintf(tw, "max drawdown\t%.2f%%\n", report.MaxDrawdown)
+	fmt.Fprintf(tw, "fees\t%.2f\n", report.Fees)
+	fmt.Fprintf(tw, "trades\t%d\n", report.Trades)
+	fmt.Fprintf(tw, "rejected orders\t%d\n", report.Rejected)
+	fmt.Fprintf(tw, "margin calls\t%d\n", report.MarginCalls)
+	fmt.Fprintf(tw, "stop-outs\t%d\n", report.StopOuts)
+
+	tw.Flush()
+}
+
+func writeEquity(path string, equity []*backtest.EquityPoint) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"time", "available", "blocked", "total", "margin_call"})
+	for _, point := range equity {
+		w.Write([]string{
+			point.Time.Format(time.RFC3339),
+			strconv.FormatFloat(point.Available, 'f', 2, 64),
+			strconv.FormatFloat(point.Blocked, 'f', 2, 64),
+			strconv.FormatFloat(point.Total, 'f', 2, 64),
+			strconv.FormatBool(point.MarginCall),
+		})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func formatTime(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format(time.DateOnly)
+	}
+
+	return t.Format(time.DateTime)
+}
+
+func formatCount(count int64) string {
+	if count == 0 {
+		return "-"
+	}
+
+	return strconv.FormatInt(count, 10)
+}
+
+func formatPrice(price float64) string {
+	if price == 0 {
+		return "-"
+	}
+
+	return strconv.FormatFloat(price, 'f', 2, 64)
+}
@@ -0,0 +1,59 @@
+// balance-verify replays balance_events journal and compares it with users balances.
+// It exits with non-zero code if any user's balances differ from the journal or the journal chain is broken.
+//
+//	go run ./cmd/balance-verify -config debug.yaml
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leonid6372/success-bot/internal/common/config"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+)
+
+func main() {
+	var configPath string
+	flag.StringVar(&configPath, "config", "debug.yaml", "bot config path")
+	flag.Parse()
+
+	ctx := context.TODO()
+
+	cfg := config.GetConfig(configPath)
+
+	log.Info("init postgres...")
+	pool, err := pgxpool.New(ctx, cfg.GetPostgresURL())
+	if err != nil {
+		log.Fatal("postgres init failed", zap.Error(err))
+	}
+	defer pool.Close()
+
+	balanceEventsRepository := postgres.NewBalanceEventsRepository(pool)
+
+	mismatches, err := balanceEventsRepository.GetBalanceMismatches(ctx)
+	if err != nil {
+		log.Fatal("balanceEventsRepository.GetBalanceMismatches", zap.Error(err))
+	}
+
+	for _, mismatch := range mismatches {
+		log.Error("balance mismatch",
+			zap.Int64("user_id", mismatch.UserID),
+			zap.Float64("available_balance", mismatch.AvailableBalance),
+			zap.Float64("journal_available", mismatch.JournalAvailable),
+			zap.Float64("blocked_balance", mismatch.BlockedBalance),
+			zap.Float64("journal_blocked", mismatch.JournalBlocked),
+			zap.Int64("broken_event_id", mismatch.BrokenEventID),
+		)
+	}
+
+	if len(mismatches) > 0 {
+		log.Error("balances verification failed", zap.Int("mismatches", len(mismatches)))
+		os.Exit(1)
+	}
+
+	log.Info("balances match the journal")
+}
@@ -3,21 +3,35 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leonid6372/success-bot/internal/api"
 	"github.com/leonid6372/success-bot/internal/bot"
+	"github.com/leonid6372/success-bot/internal/common/clients/fake"
 	"github.com/leonid6372/success-bot/internal/common/clients/finam"
 	"github.com/leonid6372/success-bot/internal/common/config"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/events"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
 	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+	"github.com/leonid6372/success-bot/pkg/cache"
 	"github.com/leonid6372/success-bot/pkg/dictionary"
 	"github.com/leonid6372/success-bot/pkg/goosemigrate"
+	"github.com/leonid6372/success-bot/pkg/leader"
 	"github.com/leonid6372/success-bot/pkg/log"
+	"github.com/leonid6372/success-bot/pkg/tracing"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
+// leaderLockKey is a Postgres advisory lock key of periodic jobs leader.
+const leaderLockKey = 7_340_001
+
 func main() {
 	var configPath string
 	flag.StringVar(&configPath, "config", "prod.yaml", "bot config path")
@@ -30,13 +44,28 @@ func main() {
 	log.Info("bot starting...")
 
 	log.Info("init dictionary...")
-	dictionary, err := dictionary.New()
+	dictionary, err := dictionary.New(cfg.Bot.Dictionary.Path)
 	if err != nil {
 		log.Fatal("dictionary init failed", zap.Error(err))
 	}
 
+	log.Info("init tracing...")
+	shutdownTracing, err := tracing.Init(ctx,
+		cfg.Tracing.ServiceName, cfg.Tracing.Exporter, cfg.Tracing.OTLPEndpoint, cfg.Tracing.SampleRatio,
+	)
+	if err != nil {
+		log.Fatal("tracing init failed", zap.Error(err))
+	}
+
 	log.Info("init postgres...")
-	pool, err := pgxpool.New(ctx, cfg.GetPostgresURL())
+	poolCfg, err := pgxpool.ParseConfig(cfg.GetPostgresURL())
+	if err != nil {
+		log.Fatal("postgres config parsing failed", zap.Error(err))
+	}
+
+	poolCfg.ConnConfig.Tracer = tracing.NewQueryTracer()
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		log.Fatal("postgres init failed", zap.Error(err))
 	}
@@ -50,32 +79,112 @@ func main() {
 	promocodesRepository := postgres.NewPromocodesRepository(pool)
 	operationsRepository := postgres.NewOperationsRepository(pool)
 	portfoliosRepository := postgres.NewPortfolioRepository(pool)
+	tokensRepository := postgres.NewTokensRepository(pool)
+	candlesRepository := postgres.NewCandlesRepository(pool)
+	achievementsRepository := postgres.NewAchievementsRepository(pool)
+	followsRepository := postgres.NewFollowsRepository(pool)
+	groupsRepository := postgres.NewGroupsRepository(pool)
+	outboxRepository := postgres.NewOutboxRepository(pool)
 
-	log.Info("init finam...")
-	finam, err := finam.NewClient(ctx, cfg.Finam.Token, cfg.Finam.AccountID)
+	log.Info("init cache...")
+	cacheBackend, cacheCheck, err := newCacheBackend(ctx, &cfg.Cache)
 	if err != nil {
-		log.Fatal("finam init failed", zap.Error(err))
+		log.Fatal("cache init failed", zap.Error(err))
 	}
 
+	// elector has own context to release the lock before pool closing
+	electorCtx, electorCancel := context.WithCancel(ctx)
+	electorDone := make(chan struct{})
+	elector := leader.NewElector(pool, leaderLockKey)
+
+	go func() {
+		defer close(electorDone)
+		elector.Run(electorCtx, 10*time.Second)
+	}()
+
+	log.Info("init market data provider...")
+	marketData, err := newMarketDataProvider(ctx, &cfg.Finam)
+	if err != nil {
+		log.Fatal("market data provider init failed", zap.Error(err))
+	}
+
+	bus := events.NewBus(outboxRepository)
+
 	log.Info("init telebot...")
 	bot, err := bot.New(ctx,
 		&cfg.Bot,
-		finam,
+		marketData,
 		dictionary,
 		userRepository,
 		instrumentsRepository,
 		promocodesRepository,
 		operationsRepository,
 		portfoliosRepository,
+		tokensRepository,
+		candlesRepository,
+		achievementsRepository,
+		followsRepository,
+		groupsRepository,
+		cacheBackend,
+		elector,
+		bus,
 	)
 	if err != nil {
 		log.Fatal("bot starting failed", zap.Error(err))
 	}
 
+	// subscribers are registered by the bot, events published before restart are delivered on start
+	busCtx, busCancel := context.WithCancel(ctx)
+	busDone := make(chan struct{})
+
+	go func() {
+		defer close(busDone)
+		bus.Run(busCtx)
+	}()
+
 	go func() {
 		bot.Start()
 	}()
 
+	var apiServer *api.Server
+	if cfg.API.Listen != "" {
+		log.Info("init api...")
+		apiServer = api.New(
+			&cfg.API,
+			&cfg.Bot,
+			marketData,
+			bot,
+			bus,
+			userRepository,
+			instrumentsRepository,
+			operationsRepository,
+			portfoliosRepository,
+			tokensRepository,
+		)
+
+		go func() {
+			apiServer.Start()
+		}()
+	}
+
+	var metricsServer *metrics.Server
+	if cfg.Metrics.Listen != "" {
+		log.Info("init metrics...")
+		checks := map[string]metrics.HealthCheck{
+			"postgres": pool.Ping,
+		}
+
+		if cacheCheck != nil {
+			checks["cache"] = cacheCheck
+		}
+
+		metricsServer = metrics.NewServer(&cfg.Metrics, checks)
+
+		go func() {
+			metricsServer.Start()
+		}()
+	}
+
 	log.Info("bot starting complete")
 
 	done := make(chan os.Signal, 1)
@@ -84,9 +193,37 @@ func main() {
 	<-done
 	log.Info("bot shutting down...")
 
+	if apiServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(ctx, cfg.API.Timeout)
+		if err := apiServer.Stop(shutdownCtx); err != nil {
+			log.Error("api server shutdown failed", zap.Error(err))
+		}
+		shutdownCancel()
+	}
+
+	if metricsServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := metricsServer.Stop(shutdownCtx); err != nil {
+			log.Error("metrics server shutdown failed", zap.Error(err))
+		}
+		shutdownCancel()
+	}
+
+	busCancel()
+	<-busDone
+
+	electorCancel()
+	<-electorDone
+
 	pool.Close()
 	bot.Stop()
 
+	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 5*time.Second)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error("tracing shutdown failed", zap.Error(err))
+	}
+	shutdownCancel()
+
 	if err := log.Sync(); err != nil {
 		log.Error("log sync failed", zap.Error(err))
 	}
@@ -95,3 +232,41 @@ func main() {
 
 	log.Info("bot shut down complete")
 }
+
+// newCacheBackend returns cache backend by config and its health check.
+func newCacheBackend(ctx context.Context, cfg *config.Cache) (cache.Backend, metrics.HealthCheck, error) {
+	switch cfg.Backend {
+	case config.CacheBackendRedis:
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		})
+
+		if err := client.Ping(ctx).Err(); err != nil {
+			return nil, nil, fmt.Errorf("failed to ping redis: %v", err)
+		}
+
+		check := func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		}
+
+		return cache.NewRedisBackend(client, cfg.RedisPrefix), check, nil
+
+	case config.CacheBackendMemory, "":
+		return cache.NewMemoryBackend(1 * time.Minute), nil, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown cache backend: %s", cfg.Backend)
+	}
+}
+
+// newMarketDataProvider returns Finam client or the fake provider if it is enabled in config.
+func newMarketDataProvider(ctx context.Context, cfg *config.Finam) (domain.MarketDataProvider, error) {
+	if cfg.Fake {
+		log.Warn("fake market data provider is used")
+		return fake.NewProvider(), nil
+	}
+
+	return finam.NewClient(ctx, cfg.Token, cfg.AccountID)
+}
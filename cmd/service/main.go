@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"flag"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/leonid6372/success-bot/internal/common/config"
+	"github.com/leonid6372/success-bot/internal/common/domain"
 	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+	"github.com/leonid6372/success-bot/pkg/dictionary"
 	"github.com/leonid6372/success-bot/pkg/log"
 	"go.uber.org/zap"
 	"gopkg.in/telebot.v4"
@@ -23,6 +26,12 @@ func main() {
 
 	log.Info("bot starting...")
 
+	// dictionary provides time zones of users who haven't chosen one
+	dict, err := dictionary.New(cfg.Bot.Dictionary.Path)
+	if err != nil {
+		log.Fatal("dictionary init failed", zap.Error(err))
+	}
+
 	log.Info("init postgres...")
 	pool, err := pgxpool.New(ctx, cfg.GetPostgresURL())
 	if err != nil {
@@ -46,7 +55,14 @@ func main() {
 		log.Fatal("userRepository.GetAllUsers", zap.Error(err))
 	}
 
+	now := time.Now()
+
 	for _, user := range users {
+		location := user.Settings.Location(dict.Locale(user.LanguageCode).Location())
+		if !user.Settings.CanNotify(domain.NotificationBroadcasts, now, location) {
+			continue
+		}
+
 		markup := dailyRewardKeyboard()
 
 		if _, err := b.Send(&telebot.User{ID: user.ID},
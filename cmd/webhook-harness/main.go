@@ -0,0 +1,126 @@
+// webhook-harness posts fake Telegram updates to the bot webhook endpoint for local testing.
+//
+// Every line of the script is sent as a separate update: a plain line is sent as a text message,
+// a line with "callback:" prefix is sent as a callback query with the rest of the line as data.
+// Empty lines and lines starting with "#" are skipped.
+//
+//	go run ./cmd/webhook-harness -url http://localhost:8443 -script updates.txt
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+	"gopkg.in/telebot.v4"
+)
+
+const callbackPrefix = "callback:"
+
+func main() {
+	var url, secretToken, scriptPath, username string
+	var userID int64
+	var delay time.Duration
+
+	flag.StringVar(&url, "url", "http://localhost:8443", "bot webhook url")
+	flag.StringVar(&secretToken, "secret", "", "webhook secret token")
+	flag.StringVar(&scriptPath, "script", "", "updates script path, stdin is used if empty")
+	flag.Int64Var(&userID, "user-id", 1, "fake sender telegram id")
+	flag.StringVar(&username, "username", "harness", "fake sender username")
+	flag.DurationVar(&delay, "delay", 500*time.Millisecond, "delay between updates")
+	flag.Parse()
+
+	var script io.Reader = os.Stdin
+	if scriptPath != "" {
+		file, err := os.Open(scriptPath)
+		if err != nil {
+			log.Fatal("failed to open script", zap.Error(err))
+		}
+		defer file.Close()
+
+		script = file
+	}
+
+	sender := &telebot.User{ID: userID, Username: username, FirstName: username}
+	chat := &telebot.Chat{ID: userID, Type: telebot.ChatPrivate}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	updateID := int(time.Now().Unix())
+	scanner := bufio.NewScanner(script)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		updateID++
+		update := newUpdate(updateID, sender, chat, line)
+
+		body, err := json.Marshal(update)
+		if err != nil {
+			log.Fatal("failed to marshal update", zap.Error(err))
+		}
+
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			log.Fatal("failed to create request", zap.Error(err))
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		if secretToken != "" {
+			req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secretToken)
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			log.Error("failed to post update", zap.String("line", line), zap.Error(err))
+			continue
+		}
+		res.Body.Close()
+
+		log.Info("update posted", zap.Int("update_id", updateID), zap.String("line", line), zap.Int("status", res.StatusCode))
+
+		time.Sleep(delay)
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatal("failed to read script", zap.Error(err))
+	}
+}
+
+func newUpdate(id int, sender *telebot.User, chat *telebot.Chat, line string) *telebot.Update {
+	message := &telebot.Message{
+		ID:       id,
+		Sender:   sender,
+		Chat:     chat,
+		Unixtime: time.Now().Unix(),
+	}
+
+	if data, ok := strings.CutPrefix(line, callbackPrefix); ok {
+		message.Text = "callback source"
+
+		return &telebot.Update{
+			ID: id,
+			Callback: &telebot.Callback{
+				ID:      "harness",
+				Sender:  sender,
+				Message: message,
+				Data:    "\f" + data, // telebot prefix for unique callback buttons
+			},
+		}
+	}
+
+	message.Text = line
+
+	return &telebot.Update{ID: id, Message: message}
+}
@@ -0,0 +1,434 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+)
+
+const (
+	maxStreamTickers     = 20
+	quotesStreamInterval = 2 * time.Second
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+
+	if _, err := w.Write(openAPISpec); err != nil {
+		log.Error("failed to write response", zap.Error(err))
+	}
+}
+
+func (s *Server) meHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := s.deps.usersRepository.GetUserByID(r.Context(), mustUserID(r))
+	if err != nil {
+		log.Error("failed to get user by id", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+func (s *Server) portfolioHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := mustUserID(r)
+
+	currentPage, err := getPage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := s.deps.usersRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Error("failed to get user by id", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	pagesCount, err := s.deps.portfoliosRepository.GetUserPortfolioPagesCount(ctx, userID)
+	if err != nil {
+		log.Error("failed to get portfolio pages count", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	instruments, err := s.deps.portfoliosRepository.GetUserPortfolioByPage(ctx, userID, currentPage)
+	if err != nil {
+		log.Error("failed to get user portfolio by page", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	currencyBalances, err := s.deps.portfoliosRepository.GetUserCurrencyBalances(ctx, userID)
+	if err != nil {
+		log.Error("failed to get user currency balances", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	res := &portfolioResponse{
+		AvailableBalance: user.AvailableBalance,
+		BlockedBalance:   user.BlockedBalance,
+		MarginCall:       user.MarginCall,
+		CurrencyBalances: make(map[string]float64, len(currencyBalances)),
+		pageResponse: pageResponse[*positionResponse]{
+			CurrentPage: currentPage,
+			PagesCount:  pagesCount,
+			Items:       make([]*positionResponse, 0, len(instruments)),
+		},
+	}
+
+	for _, balance := range currencyBalances {
+		res.CurrencyBalances[balance.Currency] = balance.Amount
+	}
+
+	for _, instrument := range instruments {
+		prices, err := s.deps.market.InstrumentUnitPrices(ctx, instrument.Ticker)
+		if err != nil {
+			log.Error("failed to get instrument prices", zap.String("ticker", instrument.Ticker), zap.Error(err))
+			writeError(w, http.StatusInternalServerError, errInternal)
+			return
+		}
+
+		instrument.InstrumentPrices = prices
+
+		res.Items = append(res.Items, newPositionResponse(instrument))
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) operationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := mustUserID(r)
+
+	currentPage, err := getPage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	pagesCount, err := s.deps.operationsRepository.GetOperationsPagesCount(ctx, userID)
+	if err != nil {
+		log.Error("failed to get operations pages count", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	operations, err := s.deps.operationsRepository.GetOperationsByPage(ctx, userID, currentPage)
+	if err != nil {
+		log.Error("failed to get operations by page", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	res := &pageResponse[*operationResponse]{
+		CurrentPage: currentPage,
+		PagesCount:  pagesCount,
+		Items:       make([]*operationResponse, 0, len(operations)),
+	}
+
+	for _, operation := range operations {
+		res.Items = append(res.Items, newOperationResponse(operation))
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) instrumentsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	currentPage, err := getPage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && !slices.Contains(domain.InstrumentKinds, kind) {
+		writeError(w, http.StatusBadRequest, errInvalidKind)
+		return
+	}
+
+	pagesCount, err := s.deps.instrumentsRepository.GetInstrumentsPagesCount(ctx, kind)
+	if err != nil {
+		log.Error("failed to get instruments pages count", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	instruments, err := s.deps.instrumentsRepository.GetInstrumentsByPage(ctx, kind, currentPage)
+	if err != nil {
+		log.Error("failed to get instruments by page", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	res := &pageResponse[*instrumentResponse]{
+		CurrentPage: currentPage,
+		PagesCount:  pagesCount,
+		Items:       make([]*instrumentResponse, 0, len(instruments)),
+	}
+
+	now := time.Now()
+
+	for _, instrument := range instruments {
+		res.Items = append(res.Items, newInstrumentResponse(instrument, now))
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) instrumentHandler(w http.ResponseWriter, r *http.Request) {
+	instrument, err := s.deps.instrumentsRepository.GetInstrumentByTicker(r.Context(), normalizeTicker(r.PathValue("ticker")))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, errNotFound)
+			return
+		}
+
+		log.Error("failed to get instrument by ticker", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newInstrumentResponse(instrument, time.Now()))
+}
+
+func (s *Server) quoteHandler(w http.ResponseWriter, r *http.Request) {
+	ticker := normalizeTicker(r.PathValue("ticker"))
+
+	instrument, err := s.deps.marketData.GetInstrumentPrices(r.Context(), ticker)
+	if err != nil {
+		log.Error("failed to get instrument prices from finam", zap.String("ticker", ticker), zap.Error(err))
+		writeError(w, http.StatusBadGateway, errInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newQuoteResponse(ticker, instrument))
+}
+
+func (s *Server) topUsersHandler(w http.ResponseWriter, r *http.Request) {
+	currentPage, err := getPage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	topUsers, err := s.deps.market.TopUsers(r.Context())
+	if err != nil {
+		log.Error("failed to get top users", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	res := &pageResponse[*topUserResponse]{
+		CurrentPage: currentPage,
+		PagesCount:  (int64(len(topUsers)) + domain.UsersPerPage - 1) / domain.UsersPerPage,
+		Items:       []*topUserResponse{},
+	}
+
+	for i := domain.UsersPerPage * (currentPage - 1); i < min(domain.UsersPerPage*currentPage, int64(len(topUsers))); i++ {
+		res.Items = append(res.Items, &topUserResponse{
+			Place:        i + 1,
+			Username:     topUsers[i].Username,
+			TotalBalance: topUsers[i].TotalBalance,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// orderHandler executes market order at the best price: ask for buy and bid for sell.
+func (s *Server) orderHandler(w http.ResponseWriter, r *http.Request) {
+	userID := mustUserID(r)
+
+	req := &orderRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidBody)
+		return
+	}
+
+	if req.Side != domain.OperationTypeBuy && req.Side != domain.OperationTypeSell {
+		writeError(w, http.StatusBadRequest, errInvalidSide)
+		return
+	}
+
+	if req.Count <= 0 {
+		writeError(w, http.StatusBadRequest, errInvalidCount)
+		return
+	}
+
+	ticker := normalizeTicker(req.Ticker)
+
+	ctx := domain.ContextWithActor(r.Context(), domain.ActorAPI)
+
+	price, err := s.executeOrder(ctx, userID, ticker, req.Side, req.Count)
+	switch {
+	case errors.Is(err, boterrs.ErrInstrumentNotFound):
+		writeError(w, http.StatusNotFound, errNotFound)
+	case errors.Is(err, boterrs.ErrClosedExchange):
+		writeError(w, http.StatusConflict, errClosedExchange)
+	case errors.Is(err, boterrs.ErrInsufficientFunds):
+		writeError(w, http.StatusUnprocessableEntity, errInsufficientFunds)
+	case errors.Is(err, boterrs.ErrInvalidLotCount):
+		writeError(w, http.StatusUnprocessableEntity, errInvalidLotCount)
+	case errors.Is(err, boterrs.ErrInvalidPriceStep):
+		writeError(w, http.StatusUnprocessableEntity, errInvalidPriceStep)
+	case errors.Is(err, boterrs.ErrForeignShort):
+		writeError(w, http.StatusUnprocessableEntity, errForeignShort)
+	case errors.Is(err, boterrs.ErrInactiveInstrument):
+		writeError(w, http.StatusUnprocessableEntity, errInactiveInstrument)
+	case err == nil:
+		writeJSON(w, http.StatusOK, &orderResponse{
+			Ticker: ticker,
+			Side:   req.Side,
+			Count:  req.Count,
+			Price:  price,
+		})
+	default:
+		log.Error("failed to execute order", zap.Int64("user_id", userID), zap.String("ticker", ticker), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, errInternal)
+	}
+}
+
+func (s *Server) executeOrder(ctx context.Context, userID int64, ticker, side string, count int64) (float64, error) {
+	instrument, err := s.deps.instrumentsRepository.GetInstrumentByTicker(ctx, ticker)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, boterrs.ErrInstrumentNotFound
+		}
+
+		return 0, fmt.Errorf("failed to get instrument by ticker: %w", err)
+	}
+
+	prices, err := s.deps.marketData.GetInstrumentPrices(ctx, ticker)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get instrument prices from finam: %w", err)
+	}
+
+	if prices.Ask == 0 && prices.Bid == 0 {
+		return 0, boterrs.ErrClosedExchange
+	}
+
+	// orders are executed by unit prices, bonds quotes are in percents of nominal
+	prices.InstrumentPrices = instrument.UnitPrices(prices.InstrumentPrices, time.Now())
+
+	if side == domain.OperationTypeBuy {
+		if _, err := s.deps.portfoliosRepository.BuyInstrument(ctx, userID, instrument.ID, count, prices.Ask); err != nil {
+			return 0, err
+		}
+
+		s.deps.bus.Notify()
+
+		return prices.Ask, nil
+	}
+
+	if _, err := s.deps.portfoliosRepository.SellInstrument(ctx, userID, instrument.ID, count, prices.Bid); err != nil {
+		return 0, err
+	}
+
+	s.deps.bus.Notify()
+
+	return prices.Bid, nil
+}
+
+// normalizeTicker converts user's ticker to Finam symbol format, e.g. "sber" -> "SBER@MISX".
+func normalizeTicker(ticker string) string {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+
+	if !strings.Contains(ticker, "@") {
+		ticker += "@MISX"
+	}
+
+	return ticker
+}
+
+// quotesStreamHandler pushes quotes of requested tickers from the bot quotes cache as Server-Sent Events.
+// Event is sent only when the last price changes.
+func (s *Server) quotesStreamHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var tickers []string
+	for _, ticker := range strings.Split(r.URL.Query().Get("tickers"), ",") {
+		if strings.TrimSpace(ticker) != "" {
+			tickers = append(tickers, normalizeTicker(ticker))
+		}
+	}
+
+	if len(tickers) == 0 || len(tickers) > maxStreamTickers {
+		writeError(w, http.StatusBadRequest, errInvalidTickers)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+
+	log.Info("quotes stream started", zap.Int64("user_id", mustUserID(r)), zap.Strings("tickers", tickers))
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	prevPrices := make(map[string]float64, len(tickers))
+
+	t := time.NewTicker(quotesStreamInterval)
+	defer t.Stop()
+
+	for {
+		for _, ticker := range tickers {
+			instrument, err := s.deps.market.InstrumentPrices(ctx, ticker)
+			if err != nil {
+				log.Error("failed to get instrument prices", zap.String("ticker", ticker), zap.Error(err))
+				continue
+			}
+
+			if prevPrices[ticker] == instrument.Last {
+				continue
+			}
+			prevPrices[ticker] = instrument.Last
+
+			data, err := json.Marshal(newQuoteResponse(ticker, instrument))
+			if err != nil {
+				log.Error("failed to marshal quote", zap.Error(err))
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: quote\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+
+		select {
+		case <-ctx.Done():
+			log.Info("quotes stream finished", zap.Int64("user_id", mustUserID(r)))
+			return
+
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"github.com/leonid6372/success-bot/pkg/token"
+	"github.com/leonid6372/success-bot/pkg/tracing"
+	"github.com/leonid6372/success-bot/pkg/webapp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type ctxKey string
+
+const ctxUserID ctxKey = "user_id"
+
+const webAppInitDataTTL = 24 * time.Hour
+
+func (s *Server) recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Error("recovered from panic",
+					zap.String("path", r.URL.Path),
+					zap.Any("panic", rec),
+					zap.Stack("stack"),
+				)
+
+				writeError(w, http.StatusInternalServerError, errInternal)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// metricsMiddleware records request duration by matched mux pattern, it must wrap the mux.
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		next.ServeHTTP(w, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+
+		metrics.HandlerDuration.WithLabelValues(metrics.TransportAPI, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (s *Server) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Info("api request", zap.String("method", r.Method), zap.String("path", r.URL.Path))
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.cfg.Timeout)
+		defer cancel()
+
+		ctx, span := tracing.Start(ctx, "api.request", attribute.String("route", r.Pattern))
+		defer span.End()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authMiddleware resolves user by "Authorization: Bearer <token>" header.
+func (s *Server) authMiddleware(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || rawToken == "" {
+			writeError(w, http.StatusUnauthorized, errUnauthorized)
+			return
+		}
+
+		userID, err := s.deps.tokensRepository.GetUserIDByToken(r.Context(), token.Hash(rawToken))
+		if err != nil {
+			if errors.Is(err, boterrs.ErrInvalidToken) {
+				writeError(w, http.StatusUnauthorized, errUnauthorized)
+				return
+			}
+
+			log.Error("failed to get user by token", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, errInternal)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), ctxUserID, userID)))
+	})
+}
+
+// webAppAuthMiddleware resolves user by "Authorization: tma <initData>" header signed by Telegram.
+func (s *Server) webAppAuthMiddleware(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		initData, ok := strings.CutPrefix(r.Header.Get("Authorization"), "tma ")
+		if !ok || initData == "" {
+			writeError(w, http.StatusUnauthorized, errUnauthorized)
+			return
+		}
+
+		data, err := webapp.Validate(initData, s.botCfg.APIKey, webAppInitDataTTL)
+		if err != nil {
+			log.Warn("invalid webapp init data", zap.Error(err))
+			writeError(w, http.StatusUnauthorized, errUnauthorized)
+			return
+		}
+
+		// user must start the bot before using WebApp
+		if _, err := s.deps.usersRepository.GetUserByID(r.Context(), data.User.ID); err != nil {
+			if errors.Is(err, boterrs.ErrUserNotFound) {
+				writeError(w, http.StatusUnauthorized, errUnauthorized)
+				return
+			}
+
+			log.Error("failed to get user by id", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, errInternal)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), ctxUserID, data.User.ID)))
+	})
+}
+
+// corsMiddleware allows WebApp frontend origin to call WebApp routes.
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	origin := ""
+	if u, err := url.Parse(s.botCfg.WebAppURL); err == nil && u.Host != "" {
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin != "" && strings.HasPrefix(r.URL.Path, "/webapp/") && r.Header.Get("Origin") == origin {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func mustUserID(r *http.Request) int64 {
+	return r.Context().Value(ctxUserID).(int64)
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+)
+
+var (
+	errInternal           = errors.New("internal error")
+	errUnauthorized       = errors.New("unauthorized")
+	errInvalidPage        = errors.New("invalid page")
+	errInvalidBody        = errors.New("invalid request body")
+	errInvalidSide        = errors.New("side must be buy or sell")
+	errInvalidCount       = errors.New("count must be positive")
+	errInvalidLotCount    = errors.New("count must be a multiple of lot size")
+	errInvalidPriceStep   = errors.New("price must be a multiple of min price step")
+	errForeignShort       = errors.New("instruments in foreign currencies can't be sold short")
+	errInactiveInstrument = errors.New("instrument isn't traded, positions can only be closed")
+	errNotFound           = errors.New("not found")
+	errInsufficientFunds  = errors.New("insufficient funds")
+	errClosedExchange     = errors.New("exchange is closed")
+	errInvalidTickers     = errors.New("tickers must contain from 1 to 20 comma-separated tickers")
+	errInvalidKind        = errors.New("kind must be share, bond, etf or currency")
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type userResponse struct {
+	ID                int64     `json:"id"`
+	Username          string    `json:"username"`
+	FirstName         string    `json:"first_name"`
+	LastName          string    `json:"last_name"`
+	LanguageCode      string    `json:"language_code"`
+	AvailableBalance  float64   `json:"available_balance"`
+	BlockedBalance    float64   `json:"blocked_balance"`
+	MarginCall        bool      `json:"margin_call"`
+	DailyReward       bool      `json:"daily_reward"`
+	DailyRewardStreak int       `json:"daily_reward_streak"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+func newUserResponse(u *domain.User) *userResponse {
+	return &userResponse{
+		ID:                u.ID,
+		Username:          u.Username,
+		FirstName:         u.FirstName,
+		LastName:          u.LastName,
+		LanguageCode:      u.LanguageCode,
+		AvailableBalance:  u.AvailableBalance,
+		BlockedBalance:    u.BlockedBalance,
+		MarginCall:        u.MarginCall,
+		DailyReward:       u.DailyReward,
+		DailyRewardStreak: u.DailyRewardStreak,
+		CreatedAt:         u.CreatedAt,
+	}
+}
+
+type pageResponse[T any] struct {
+	CurrentPage int64 `json:"current_page"`
+	PagesCount  int64 `json:"pages_count"`
+	Items       []T   `json:"items"`
+}
+
+type portfolioResponse struct {
+	AvailableBalance float64            `json:"available_balance"`
+	BlockedBalance   float64            `json:"blocked_balance"`
+	MarginCall       bool               `json:"margin_call"`
+	CurrencyBalances map[string]float64 `json:"currency_balances"` // currency -> cash balance, L$ isn't included
+
+	pageResponse[*positionResponse]
+}
+
+type positionResponse struct {
+	Ticker   string  `json:"ticker"`
+	Name     string  `json:"name"`
+	Currency string  `json:"currency"`
+	Count    int64   `json:"count"`
+	AvgPrice float64 `json:"avg_price"`
+	Last     float64 `json:"last"`
+}
+
+func newPositionResponse(ui *domain.UserInstrument) *positionResponse {
+	return &positionResponse{
+		Ticker:   ui.Ticker,
+		Name:     ui.Name,
+		Currency: ui.Currency,
+		Count:    ui.Count,
+		AvgPrice: ui.AvgPrice,
+		Last:     ui.Last,
+	}
+}
+
+type operationResponse struct {
+	ID          int64     `json:"id"`
+	ParentID    int64     `json:"parent_id,omitempty"`
+	Type        string    `json:"type"`
+	Name        string    `json:"name"`
+	Count       int64     `json:"count"`
+	TotalAmount float64   `json:"total_amount"`
+	Currency    string    `json:"currency"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	CopiedOperationID int64  `json:"copied_operation_id,omitempty"`
+	CopiedFrom        string `json:"copied_from,omitempty"`
+}
+
+func newOperationResponse(o *domain.Operation) *operationResponse {
+	return &operationResponse{
+		ID:          o.ID,
+		ParentID:    o.ParentID,
+		Type:        o.Type,
+		Name:        o.InstrumentName,
+		Count:       o.Count,
+		TotalAmount: o.TotalAmount,
+		Currency:    o.Currency,
+		CreatedAt:   o.CreatedAt,
+
+		CopiedOperationID: o.CopiedOperationID,
+		CopiedFrom:        o.CopiedFrom,
+	}
+}
+
+type instrumentResponse struct {
+	Ticker          string  `json:"ticker"`
+	Name            string  `json:"name"`
+	Kind            string  `json:"kind"`
+	Sector          string  `json:"sector"`
+	LotSize         int64   `json:"lot_size"`
+	MinStep         float64 `json:"min_step"`
+	Currency        string  `json:"currency"`
+	Decimals        int32   `json:"decimals"`
+	Active          bool    `json:"active"`
+	Nominal         float64 `json:"nominal,omitempty"`
+	AccruedInterest float64 `json:"accrued_interest,omitempty"`
+}
+
+func newInstrumentResponse(i *domain.Instrument, now time.Time) *instrumentResponse {
+	return &instrumentResponse{
+		Ticker:          i.Ticker,
+		Name:            i.Name,
+		Kind:            i.Kind,
+		Sector:          i.Sector,
+		LotSize:         i.LotSize,
+		MinStep:         i.MinStep,
+		Currency:        i.Currency,
+		Decimals:        i.Decimals,
+		Active:          i.Active,
+		Nominal:         i.Nominal,
+		AccruedInterest: i.AccruedInterest(now),
+	}
+}
+
+type quoteResponse struct {
+	Ticker string  `json:"ticker"`
+	Last   float64 `json:"last"`
+	Bid    float64 `json:"bid"`
+	Ask    float64 `json:"ask"`
+	Change float64 `json:"change"`
+}
+
+func newQuoteResponse(ticker string, i *domain.Instrument) *quoteResponse {
+	return &quoteResponse{
+		Ticker: ticker,
+		Last:   i.Last,
+		Bid:    i.Bid,
+		Ask:    i.Ask,
+		Change: i.Change,
+	}
+}
+
+type topUserResponse struct {
+	Place        int64   `json:"place"`
+	Username     string  `json:"username"`
+	TotalBalance float64 `json:"total_balance"`
+}
+
+type orderRequest struct {
+	Ticker string `json:"ticker"`
+	Side   string `json:"side"`
+	Count  int64  `json:"count"`
+}
+
+type orderResponse struct {
+	Ticker string  `json:"ticker"`
+	Side   string  `json:"side"`
+	Count  int64   `json:"count"`
+	Price  float64 `json:"price"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error("failed to write response", zap.Error(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &errorResponse{Error: err.Error()})
+}
+
+// getPage parses "page" query param. Returns 1 if param is empty.
+func getPage(r *http.Request) (int64, error) {
+	rawPage := r.URL.Query().Get("page")
+	if rawPage == "" {
+		return 1, nil
+	}
+
+	page, err := strconv.ParseInt(rawPage, 10, 64)
+	if err != nil || page < 1 {
+		return 0, errInvalidPage
+	}
+
+	return page, nil
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/leonid6372/success-bot/internal/common/config"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/events"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+)
+
+// Market provides data which is calculated by the bot in runtime.
+type Market interface {
+	// TopUsers returns users sorted by total balance descending.
+	TopUsers(ctx context.Context) ([]*domain.TopUser, error)
+	// InstrumentPrices returns cached instrument prices.
+	InstrumentPrices(ctx context.Context, ticker string) (*domain.Instrument, error)
+	// InstrumentUnitPrices returns cached prices of one instrument unit, e.g. bonds are priced with accrued interest.
+	InstrumentUnitPrices(ctx context.Context, ticker string) (domain.InstrumentPrices, error)
+}
+
+type Server struct {
+	http *http.Server
+
+	cfg    *config.API
+	botCfg *config.Bot
+
+	deps *Dependencies
+}
+
+type Dependencies struct {
+	marketData domain.MarketDataProvider
+	market     Market
+	bus        *events.Bus // side effects of API trades are processed by the bot subscribers
+
+	usersRepository       domain.UsersRepository
+	instrumentsRepository domain.InstrumentsRepository
+	operationsRepository  domain.OperationsRepository
+	portfoliosRepository  domain.PortfolioRepository
+	tokensRepository      domain.TokensRepository
+}
+
+func New(
+	cfg *config.API,
+	botCfg *config.Bot,
+	marketData domain.MarketDataProvider,
+	market Market,
+	bus *events.Bus,
+	usersRepository domain.UsersRepository,
+	instrumentsRepository domain.InstrumentsRepository,
+	operationsRepository domain.OperationsRepository,
+	portfoliosRepository domain.PortfolioRepository,
+	tokensRepository domain.TokensRepository,
+) *Server {
+	s := &Server{
+		cfg:    cfg,
+		botCfg: botCfg,
+		deps: &Dependencies{
+			marketData:            marketData,
+			market:                market,
+			bus:                   bus,
+			usersRepository:       usersRepository,
+			instrumentsRepository: instrumentsRepository,
+			operationsRepository:  operationsRepository,
+			portfoliosRepository:  portfoliosRepository,
+			tokensRepository:      tokensRepository,
+		},
+	}
+
+	s.http = &http.Server{
+		Addr:    cfg.Listen,
+		Handler: s.routes(),
+	}
+
+	return s
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("GET /api/v1/openapi.yaml", s.timeoutMiddleware(http.HandlerFunc(s.openAPIHandler)))
+
+	// The same handlers are served for API token clients and for Telegram WebApp.
+	for prefix, auth := range map[string]func(http.HandlerFunc) http.Handler{
+		"/api/v1":    s.authMiddleware,
+		"/webapp/v1": s.webAppAuthMiddleware,
+	} {
+		mux.Handle("GET "+prefix+"/me", s.timeoutMiddleware(auth(s.meHandler)))
+		mux.Handle("GET "+prefix+"/portfolio", s.timeoutMiddleware(auth(s.portfolioHandler)))
+		mux.Handle("GET "+prefix+"/operations", s.timeoutMiddleware(auth(s.operationsHandler)))
+		mux.Handle("GET "+prefix+"/instruments", s.timeoutMiddleware(auth(s.instrumentsHandler)))
+		mux.Handle("GET "+prefix+"/instruments/{ticker}", s.timeoutMiddleware(auth(s.instrumentHandler)))
+		mux.Handle("GET "+prefix+"/instruments/{ticker}/quote", s.timeoutMiddleware(auth(s.quoteHandler)))
+		mux.Handle("GET "+prefix+"/top", s.timeoutMiddleware(auth(s.topUsersHandler)))
+		mux.Handle("POST "+prefix+"/orders", s.timeoutMiddleware(auth(s.orderHandler)))
+
+		// long-lived stream, so it is not limited by timeout
+		mux.Handle("GET "+prefix+"/quotes/stream", auth(s.quotesStreamHandler))
+	}
+
+	mux.HandleFunc("OPTIONS /webapp/v1/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return s.recoveryMiddleware(s.metricsMiddleware(s.corsMiddleware(mux)))
+}
+
+func (s *Server) Start() {
+	if err := s.http.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("api server stopped", zap.Error(err))
+	}
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	if err := s.http.Shutdown(ctx); err != nil {
+		return fmt.Errorf("http.Shutdown: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+// Package backtest simulates an account trading over historical bars with the bot's fee, guarantee coverage,
+// margin call and stop-out rules from the trading package.
+package backtest
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/trading"
+)
+
+const (
+	EventTrade          = "trade"
+	EventRejected       = "rejected" // insufficient funds
+	EventMarginCall     = "margin_call"
+	EventMarginCallExit = "margin_call_exit"
+	EventStopOut        = "stop_out"
+)
+
+type Event struct {
+	Time          time.Time
+	Type          string
+	Ticker        string
+	OperationType string
+	Count         int64
+	Price         float64
+	Available     float64 // available balance after the event
+	Blocked       float64 // blocked balance after the event
+}
+
+type EquityPoint struct {
+	Time       time.Time
+	Available  float64
+	Blocked    float64
+	Total      float64 // as the leaderboard counts it: shorts result is realized on closing only
+	MarginCall bool
+}
+
+type Report struct {
+	InitialBalance float64
+	FinalBalance   float64
+	PnL            float64
+	PnLPercent     float64
+	MaxDrawdown    float64 // in percents of the equity peak
+	Fees           float64
+
+	Trades      int
+	Rejected    int
+	MarginCalls int
+	StopOuts    int
+
+	Positions map[string]trading.Position // open positions at the end
+	Equity    []*EquityPoint
+	Events    []*Event
+}
+
+type simulator struct {
+	balances   trading.Balances
+	positions  map[string]trading.Position
+	last       map[string]float64
+	marginCall bool
+
+	report *Report
+}
+
+// Run executes strategy orders by bar close prices. After all bars of the same time the account is revalued
+// as the cache updater does, and the most expensive short is partially bought back if the account is in margin call,
+// as the daily stop-out does.
+func Run(bars []*Bar, strategy Strategy, initialBalance float64) *Report {
+	s := &simulator{
+		balances:  trading.Balances{Available: initialBalance},
+		positions: make(map[string]trading.Position),
+		last:      make(map[string]float64),
+		report: &Report{
+			InitialBalance: initialBalance,
+			FinalBalance:   initialBalance,
+			Equity:         []*EquityPoint{},
+			Events:         []*Event{},
+		},
+	}
+
+	for i := 0; i < len(bars); {
+		t := bars[i].Time
+
+		for ; i < len(bars) && bars[i].Time.Equal(t); i++ {
+			bar := bars[i]
+			s.last[bar.Ticker] = bar.Close
+
+			for _, order := range strategy.Orders(bar) {
+				if _, ok := s.last[order.Ticker]; !ok {
+					continue // no price yet
+				}
+
+				s.execute(t, EventTrade, order.Type, order.Ticker, order.Count)
+			}
+		}
+
+		s.revalue(t)
+
+		if s.marginCall {
+			s.stopOut(t)
+			s.revalue(t)
+		}
+	}
+
+	s.finish()
+
+	return s.report
+}
+
+func (s *simulator) execute(t time.Time, eventType, operationType, ticker string, count int64) {
+	price := s.last[ticker]
+	position := s.positions[ticker]
+
+	var trade *trading.Trade
+	if operationType == domain.OperationTypeBuy {
+		trade = trading.Buy(position, count, price)
+	} else {
+		trade = trading.Sell(position, count, price)
+	}
+
+	event := &Event{
+		Time:          t,
+		Type:          eventType,
+		Ticker:        ticker,
+		OperationType: operationType,
+		Count:         count,
+		Price:         price,
+	}
+
+	if trade.OpenCount > 0 && s.balances.Available+trade.CloseAvailableDelta < -trade.OpenAvailableDelta {
+		event.Type = EventRejected
+		s.report.Rejected++
+	} else {
+		s.balances.Available += trade.CloseAvailableDelta + trade.OpenAvailableDelta
+		s.balances.Blocked += trade.OpenBlockedDelta
+
+		if trade.Position.Count == 0 {
+			delete(s.positions, ticker)
+		} else {
+			s.positions[ticker] = trade.Position
+		}
+
+		s.report.Fees += trading.Fee(count, price)
+		s.report.Trades++
+	}
+
+	event.Available = s.balances.Available
+	event.Blocked = s.balances.Blocked
+	s.report.Events = append(s.report.Events, event)
+}
+
+func (s *simulator) revalue(t time.Time) {
+	positions := make([]trading.PricedPosition, 0, len(s.positions))
+	for ticker, position := range s.positions {
+		positions = append(positions, trading.PricedPosition{Position: position, Last: s.last[ticker]})
+	}
+
+	rev := trading.Revalue(s.balances, positions)
+	s.balances = rev.Balances
+
+	if rev.MarginCall != s.marginCall {
+		event := &Event{Time: t, Type: EventMarginCall, Available: s.balances.Available, Blocked: s.balances.Blocked}
+		if rev.MarginCall {
+			s.report.MarginCalls++
+		} else {
+			event.Type = EventMarginCallExit
+		}
+
+		s.report.Events = append(s.report.Events, event)
+		s.marginCall = rev.MarginCall
+	}
+
+	point := &EquityPoint{
+		Time:       t,
+		Available:  s.balances.Available,
+		Blocked:    s.balances.Blocked,
+		Total:      rev.Total,
+		MarginCall: s.marginCall,
+	}
+
+	// keep one point per time, the last revaluation wins
+	if n := len(s.report.Equity); n > 0 && s.report.Equity[n-1].Time.Equal(t) {
+		s.report.Equity[n-1] = point
+	} else {
+		s.report.Equity = append(s.report.Equity, point)
+	}
+}
+
+func (s *simulator) stopOut(t time.Time) {
+	tickers := make([]string, 0, len(s.positions))
+	for ticker, position := range s.positions {
+		if position.Count < 0 {
+			tickers = append(tickers, ticker)
+		}
+	}
+
+	if len(tickers) == 0 {
+		return
+	}
+
+	// the most expensive short by average price as GetUserMostExpensiveShort selects it
+	sort.Slice(tickers, func(i, j int) bool {
+		a, b := s.positions[tickers[i]], s.positions[tickers[j]]
+		return a.AvgPrice*float64(-a.Count) > b.AvgPrice*float64(-b.Count)
+	})
+
+	// bars don't carry lot sizes, so every share is traded as a lot
+	short := s.positions[tickers[0]]
+	closeCount := trading.StopOutCount(short, s.last[tickers[0]], s.balances.Available, 1)
+
+	s.execute(t, EventStopOut, domain.OperationTypeBuy, tickers[0], closeCount)
+	s.report.StopOuts++
+}
+
+func (s *simulator) finish() {
+	report := s.report
+	report.Positions = s.positions
+
+	var peak float64
+	for _, point := range report.Equity {
+		peak = math.Max(peak, point.Total)
+
+		if peak > 0 {
+			report.MaxDrawdown = math.Max(report.MaxDrawdown, (peak-point.Total)/peak*100)
+		}
+	}
+
+	if n := len(report.Equity); n > 0 {
+		report.FinalBalance = report.Equity[n-1].Total
+	}
+
+	report.PnL = report.FinalBalance - report.InitialBalance
+	if report.InitialBalance != 0 {
+		report.PnLPercent = report.PnL / report.InitialBalance * 100
+	}
+}
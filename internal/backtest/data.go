@@ -0,0 +1,96 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bar is an OHLC bar of an instrument.
+type Bar struct {
+	Time   time.Time
+	Ticker string
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+}
+
+// LoadBars reads bars from CSV with header "time,ticker,open,high,low,close". Time is either RFC3339
+// or a date in 2006-01-02 format. Bars are sorted by time, bars of the same time keep file order.
+func LoadBars(r io.Reader) ([]*Bar, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"time", "ticker", "open", "high", "low", "close"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("column %q not found", name)
+		}
+	}
+
+	bars := []*Bar{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read record: %v", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		bar := &Bar{Ticker: strings.ToUpper(record[columns["ticker"]])}
+
+		if bar.Time, err = parseTime(record[columns["time"]]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		prices := []*float64{&bar.Open, &bar.High, &bar.Low, &bar.Close}
+		for i, name := range []string{"open", "high", "low", "close"} {
+			if *prices[i], err = strconv.ParseFloat(record[columns[name]], 64); err != nil {
+				return nil, fmt.Errorf("line %d: failed to parse %s: %v", line, name, err)
+			}
+		}
+
+		if bar.Close <= 0 {
+			return nil, fmt.Errorf("line %d: close price must be positive", line)
+		}
+
+		bars = append(bars, bar)
+	}
+
+	sort.SliceStable(bars, func(i, j int) bool {
+		return bars[i].Time.Before(bars[j].Time)
+	})
+
+	return bars, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse time %q", s)
+	}
+
+	return t, nil
+}
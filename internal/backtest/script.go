@@ -0,0 +1,175 @@
+package backtest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+)
+
+// Order is a market order executed by the bar close price.
+type Order struct {
+	Type   string // domain.OperationTypeBuy or domain.OperationTypeSell
+	Ticker string
+	Count  int64
+}
+
+// Strategy returns orders for the bar. It is called once per bar in time order.
+type Strategy interface {
+	Orders(bar *Bar) []*Order
+}
+
+// Script is a strategy of scripted orders and rules. Each line is one of:
+//
+//	at <time> buy|sell <TICKER> <count>
+//	when <TICKER> close <|<=|>|>= <price> buy|sell <count>
+//
+// "at" orders are executed by the first bar of the ticker at or after the time.
+// "when" rules are executed each time the condition becomes true (it was false on the previous bar of the ticker).
+// Empty lines and lines starting with # are ignored.
+type Script struct {
+	scheduled []*scheduledOrder
+	rules     []*rule
+}
+
+type scheduledOrder struct {
+	at   time.Time
+	done bool
+	*Order
+}
+
+type rule struct {
+	operator string
+	price    float64
+	matched  bool
+	*Order
+}
+
+func ParseScript(r io.Reader) (*Script, error) {
+	script := &Script{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if err := script.parseLine(fields); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read script: %v", err)
+	}
+
+	return script, nil
+}
+
+func (s *Script) parseLine(fields []string) error {
+	switch strings.ToLower(fields[0]) {
+	case "at":
+		if len(fields) != 5 {
+			return fmt.Errorf("expected: at <time> buy|sell <TICKER> <count>")
+		}
+
+		at, err := parseTime(fields[1])
+		if err != nil {
+			return err
+		}
+
+		order, err := parseOrder(fields[2], fields[3], fields[4])
+		if err != nil {
+			return err
+		}
+
+		s.scheduled = append(s.scheduled, &scheduledOrder{at: at, Order: order})
+
+	case "when":
+		if len(fields) != 7 || strings.ToLower(fields[2]) != "close" {
+			return fmt.Errorf("expected: when <TICKER> close <|<=|>|>= <price> buy|sell <count>")
+		}
+
+		switch fields[3] {
+		case "<", "<=", ">", ">=":
+		default:
+			return fmt.Errorf("unknown operator %q", fields[3])
+		}
+
+		price, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse price: %v", err)
+		}
+
+		order, err := parseOrder(fields[5], fields[1], fields[6])
+		if err != nil {
+			return err
+		}
+
+		s.rules = append(s.rules, &rule{operator: fields[3], price: price, Order: order})
+
+	default:
+		return fmt.Errorf("unknown statement %q", fields[0])
+	}
+
+	return nil
+}
+
+func parseOrder(operationType, ticker, count string) (*Order, error) {
+	order := &Order{Type: strings.ToLower(operationType), Ticker: strings.ToUpper(ticker)}
+
+	if order.Type != domain.OperationTypeBuy && order.Type != domain.OperationTypeSell {
+		return nil, fmt.Errorf("unknown order type %q", operationType)
+	}
+
+	var err error
+	if order.Count, err = strconv.ParseInt(count, 10, 64); err != nil || order.Count <= 0 {
+		return nil, fmt.Errorf("count must be a positive integer: %q", count)
+	}
+
+	return order, nil
+}
+
+func (s *Script) Orders(bar *Bar) []*Order {
+	orders := []*Order{}
+
+	for _, scheduled := range s.scheduled {
+		if !scheduled.done && scheduled.Ticker == bar.Ticker && !bar.Time.Before(scheduled.at) {
+			scheduled.done = true
+			orders = append(orders, scheduled.Order)
+		}
+	}
+
+	for _, rule := range s.rules {
+		if rule.Ticker != bar.Ticker {
+			continue
+		}
+
+		matched := rule.match(bar.Close)
+		if matched && !rule.matched {
+			orders = append(orders, rule.Order)
+		}
+
+		rule.matched = matched
+	}
+
+	return orders
+}
+
+func (r *rule) match(price float64) bool {
+	switch r.operator {
+	case "<":
+		return price < r.price
+	case "<=":
+		return price <= r.price
+	case ">":
+		return price > r.price
+	default:
+		return price >= r.price
+	}
+}
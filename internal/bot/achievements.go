@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"github.com/leonid6372/success-bot/pkg/tracing"
+	"go.uber.org/zap"
+	"gopkg.in/telebot.v4"
+)
+
+// achievementKeys maps achievements to names and descriptions texts.
+var achievementKeys = map[string]struct{ name, description string }{
+	domain.AchievementFirstTrade:         {msgAchievementFirstTrade, msgAchievementFirstTradeDescription},
+	domain.AchievementFirstShort:         {msgAchievementFirstShort, msgAchievementFirstShortDescription},
+	domain.AchievementProfitableTrades:   {msgAchievementProfitableTrades, msgAchievementProfitableTradesDescription},
+	domain.AchievementMarginCallSurvivor: {msgAchievementMarginCallSurvivor, msgAchievementMarginCallSurvivorDescription},
+	domain.AchievementTop10:              {msgAchievementTop10, msgAchievementTop10Description},
+	domain.AchievementRewardStreak:       {msgAchievementRewardStreak, msgAchievementRewardStreakDescription},
+}
+
+func (b *Bot) profileHandler(c telebot.Context) error {
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	user.Metadata.InputType = ""
+	user.Metadata.InstrumentOperation = ""
+
+	if err := b.closeInstrument(c, user); err != nil {
+		return errs.NewStack(err)
+	}
+
+	userAchievements, err := b.deps.achievementsRepository.GetUserAchievements(ctx, user.ID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get user achievements: %v", err))
+	}
+
+	topUsers, err := b.getTopUsers(ctx)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get top users: %v", err))
+	}
+
+	// users who haven't got into the top yet are shown without rank
+	var rank any = "—"
+	totalBalance := user.AvailableBalance + user.BlockedBalance
+	for i, topUser := range topUsers {
+		if topUser.ID == user.ID {
+			rank, totalBalance = i+1, topUser.TotalBalance
+			break
+		}
+	}
+
+	progress := make(map[string]*domain.UserAchievement, len(userAchievements))
+	for _, userAchievement := range userAchievements {
+		progress[userAchievement.AchievementID] = userAchievement
+	}
+
+	var (
+		achievementsList strings.Builder
+		unlockedIDs      []string
+	)
+	for _, achievement := range domain.Achievements {
+		keys := achievementKeys[achievement.ID]
+		userAchievement, ok := progress[achievement.ID]
+
+		mark := "🔒"
+		if ok && userAchievement.UnlockedAt != nil {
+			mark = "✅"
+			unlockedIDs = append(unlockedIDs, achievement.ID)
+		}
+
+		fmt.Fprintf(&achievementsList, "\n%s %s %s — %s",
+			mark,
+			achievement.Badge,
+			b.deps.dictionary.Text(user.LanguageCode, keys.name),
+			b.deps.dictionary.Text(user.LanguageCode, keys.description),
+		)
+
+		if achievement.Target > 1 && (!ok || userAchievement.UnlockedAt == nil) {
+			var current int
+			if ok {
+				current = userAchievement.Progress
+			}
+
+			fmt.Fprintf(&achievementsList, " (%d/%d)", current, achievement.Target)
+		}
+	}
+
+	text := b.deps.dictionary.Text(user.LanguageCode, msgProfile, map[string]any{
+		"Username":          user.Username,
+		"Badges":            domain.Badges(unlockedIDs),
+		"TotalBalance":      money(totalBalance, domain.BaseCurrency),
+		"Rank":              rank,
+		"DailyRewardStreak": user.DailyRewardStreak,
+		"UnlockedCount":     len(unlockedIDs),
+		"AchievementsCount": len(domain.Achievements),
+		"Achievements":      achievementsList.String(),
+	})
+
+	if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+// evaluateAchievements unlocks user's achievements matched by the event and notifies the user about them.
+func (b *Bot) evaluateAchievements(ctx context.Context, userID int64, event domain.Event) error {
+	unlocked, err := b.achievements.Evaluate(ctx, userID, event)
+	if err != nil {
+		err = errs.NewStack(fmt.Errorf("failed to evaluate achievements: %v", err))
+	}
+
+	if len(unlocked) == 0 {
+		return err
+	}
+
+	user, userErr := b.deps.usersRepository.GetUserByID(ctx, userID)
+	if userErr != nil {
+		return errs.NewStack(fmt.Errorf("failed to get user: %v", userErr))
+	}
+
+	for _, u := range unlocked {
+		values := map[string]any{
+			"Badge": u.Achievement.Badge,
+			"Name":  b.deps.dictionary.Text(user.LanguageCode, achievementKeys[u.Achievement.ID].name),
+		}
+
+		if u.Bonus > 0 {
+			values["Bonus"] = money(u.Bonus, domain.BaseCurrency)
+		}
+
+		if err := b.notify(user, domain.NotificationAchievements,
+			b.deps.dictionary.Text(user.LanguageCode, msgAchievementUnlocked, values),
+			&telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		); err != nil {
+			log.Error("failed to notify about achievement",
+				zap.String("username", user.Username),
+				zap.String("achievement", u.Achievement.ID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return err
+}
+
+// processTopFinish publishes finish of the trading day for users in the top.
+func (b *Bot) processTopFinish() {
+	ctx, span := tracing.Start(b.ctx, "bot.processTopFinish")
+	defer span.End()
+
+	topUsers, err := b.getTopUsers(ctx)
+	if err != nil {
+		log.Error("failed to get top users", zap.Error(err))
+		return
+	}
+
+	for i, topUser := range topUsers[:min(domain.TopAchievementRank, len(topUsers))] {
+		b.publish(ctx, domain.TopFinished{UserID: topUser.ID, Rank: i + 1})
+	}
+}
+
+// topUserName returns username with badges of unlocked achievements.
+func topUserName(topUser *domain.TopUser) string {
+	if topUser.Badges == "" {
+		return topUser.Username
+	}
+
+	return topUser.Username + " " + topUser.Badges
+}
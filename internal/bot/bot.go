@@ -3,16 +3,20 @@ package bot
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
-	"github.com/leonid6372/success-bot/internal/common/clients/finam"
+	"github.com/leonid6372/success-bot/internal/common/achievements"
 	"github.com/leonid6372/success-bot/internal/common/config"
 	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/events"
 	"github.com/leonid6372/success-bot/pkg/cache"
 	"github.com/leonid6372/success-bot/pkg/dictionary"
 	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/leader"
 	"github.com/leonid6372/success-bot/pkg/log"
+	"github.com/leonid6372/success-bot/pkg/tracing"
 	"go.uber.org/zap"
 	"gopkg.in/telebot.v4"
 )
@@ -23,59 +27,115 @@ type Bot struct {
 	cfg *config.Bot
 	ctx context.Context
 
-	users            *cache.Cache[int64]  // tgID -> *domain.User
-	usersInstruments *cache.Cache[string] // ticker -> *domain.Instrument (only with prices data)
+	dailyRewardTiers domain.DailyRewardTiers // daily rewards by claims streak, built from cfg
+	achievements     *achievements.Engine
 
-	topUsers []*domain.TopUser // sorted by live-balance descending
-	mu       sync.RWMutex
+	// cache keeps users sessions, instruments prices and top users, it may be shared between instances:
+	// "user:<tgID>" -> *domain.User, "instrument:<ticker>" -> *domain.Instrument (only with prices data),
+	// "instrument_info:<ticker>" -> *domain.Instrument (from repository without prices),
+	// "top_users" -> []*domain.TopUser (sorted by live-balance descending)
+	cache cache.Backend
+	// leader allows to process periodic jobs by exactly one instance
+	leader *leader.Elector
+	// bus delivers domain events to subscribers of side effects, e.g. notifications and achievements
+	bus *events.Bus
+
+	instrumentWatchers map[int64]chan struct{} // tgID -> done channel of instrument price watcher
+	messageRoutes      map[string]string       // command or button text -> route name used in metrics
+	mu                 sync.Mutex
 
 	deps *Dependencies
 }
 
 type Dependencies struct {
-	finam      *finam.Client
+	marketData domain.MarketDataProvider
 	dictionary *dictionary.Dictionary
 
-	usersRepository       domain.UsersRepository
-	instrumentsRepository domain.InstrumentsRepository
-	promocodesRepository  domain.PromocodesRepository
-	operationsRepository  domain.OperationsRepository
-	portfoliosRepository  domain.PortfolioRepository
+	usersRepository        domain.UsersRepository
+	instrumentsRepository  domain.InstrumentsRepository
+	promocodesRepository   domain.PromocodesRepository
+	operationsRepository   domain.OperationsRepository
+	portfoliosRepository   domain.PortfolioRepository
+	tokensRepository       domain.TokensRepository
+	candlesRepository      domain.CandlesRepository
+	achievementsRepository domain.AchievementsRepository
+	followsRepository      domain.FollowsRepository
+	groupsRepository       domain.GroupsRepository
 }
 
 func New(ctx context.Context,
 	cfg *config.Bot,
-	finam *finam.Client,
+	marketData domain.MarketDataProvider,
 	dictionary *dictionary.Dictionary,
 	usersRepository domain.UsersRepository,
 	instrumentsRepository domain.InstrumentsRepository,
 	promocodesRepository domain.PromocodesRepository,
 	operationsRepository domain.OperationsRepository,
 	portfoliosRepository domain.PortfolioRepository,
+	tokensRepository domain.TokensRepository,
+	candlesRepository domain.CandlesRepository,
+	achievementsRepository domain.AchievementsRepository,
+	followsRepository domain.FollowsRepository,
+	groupsRepository domain.GroupsRepository,
+	cacheBackend cache.Backend,
+	elector *leader.Elector,
+	bus *events.Bus,
 ) (*Bot, error) {
+	tiers := make([]domain.DailyRewardTier, 0, len(cfg.DailyRewardTiers))
+	for _, tier := range cfg.DailyRewardTiers {
+		tiers = append(tiers, domain.DailyRewardTier{MinStreak: tier.MinStreak, Amount: tier.Amount})
+	}
+
+	bot := &Bot{
+		cfg:                cfg,
+		ctx:                ctx,
+		dailyRewardTiers:   domain.NewDailyRewardTiers(cfg.DailyReward, tiers),
+		achievements:       achievements.New(achievementsRepository, cfg.AchievementBonuses),
+		cache:              cacheBackend,
+		leader:             elector,
+		bus:                bus,
+		instrumentWatchers: make(map[int64]chan struct{}),
+		messageRoutes:      make(map[string]string),
+		deps: &Dependencies{
+			marketData:             marketData,
+			dictionary:             dictionary,
+			usersRepository:        usersRepository,
+			instrumentsRepository:  instrumentsRepository,
+			promocodesRepository:   promocodesRepository,
+			operationsRepository:   operationsRepository,
+			portfoliosRepository:   portfoliosRepository,
+			tokensRepository:       tokensRepository,
+			candlesRepository:      candlesRepository,
+			achievementsRepository: achievementsRepository,
+			followsRepository:      followsRepository,
+			groupsRepository:       groupsRepository,
+		},
+	}
+
+	if err := bot.validateDictionary(); err != nil {
+		return nil, fmt.Errorf("bot.validateDictionary: %w", err)
+	}
+
 	b, err := telebot.NewBot(telebot.Settings{
+		URL:    cfg.APIURL,
 		Token:  cfg.APIKey,
-		Poller: &telebot.LongPoller{Timeout: cfg.Timeout},
+		Poller: bot.newPoller(),
+		Client: &http.Client{
+			Timeout: time.Minute,
+			// long polling requests are not traced to avoid a span every poller timeout
+			Transport: tracing.NewTransport(http.DefaultTransport, "telegram", "getUpdates"),
+		},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("telebot.NewBot: %w", err)
 	}
 
-	bot := &Bot{
-		Telebot:          b,
-		cfg:              cfg,
-		ctx:              ctx,
-		users:            cache.New[int64](16*time.Minute, 8*time.Minute),
-		usersInstruments: cache.New[string](1*time.Minute, 30*time.Second),
-		deps: &Dependencies{
-			finam:                 finam,
-			dictionary:            dictionary,
-			usersRepository:       usersRepository,
-			instrumentsRepository: instrumentsRepository,
-			promocodesRepository:  promocodesRepository,
-			operationsRepository:  operationsRepository,
-			portfoliosRepository:  portfoliosRepository,
-		},
+	bot.Telebot = b
+
+	if cfg.Webhook.Listen == "" {
+		if err := bot.removeWebhook(); err != nil {
+			return nil, fmt.Errorf("bot.removeWebhook: %w", err)
+		}
 	}
 
 	if err := bot.setCommands(); err != nil {
@@ -85,10 +145,19 @@ func New(ctx context.Context,
 	bot.setupMiddlewares()
 	bot.setupMessageRoutes()
 	bot.setupCallbackRoutes()
+	bot.setupEventSubscribers()
 
 	go bot.setupCacheUpdater()
 	go bot.setupDailyProcessor()
 
+	if cfg.InstrumentsSync.Interval > 0 {
+		go bot.setupInstrumentsSync()
+	}
+
+	if cfg.Dictionary.ReloadInterval > 0 {
+		go bot.setupDictionaryReloader()
+	}
+
 	return bot, nil
 }
 
@@ -96,20 +165,37 @@ func (b *Bot) setCommands() error {
 	commands := []telebot.Command{
 		{Text: "start", Description: "📈 Get started"},
 		{Text: "language", Description: "🌎 Choose language"},
+		{Text: "token", Description: "🔑 Get API token"},
+		{Text: "chart", Description: "📈 Instrument chart"},
+		{Text: "profile", Description: "👤 Profile and achievements"},
+		{Text: "trader", Description: "👥 Trader public profile"},
 	}
 
 	if err := b.Telebot.SetCommands(commands); err != nil {
 		return errs.NewStack(err)
 	}
 
+	groupMenu := []telebot.Command{
+		{Text: "join", Description: "🏁 Join the group league"},
+		{Text: "leave", Description: "🚪 Leave the group league"},
+		{Text: "top", Description: "🏆 Group league"},
+		{Text: "portfolio", Description: "💼 Portfolio of a league member"},
+	}
+
+	if err := b.Telebot.SetCommands(groupMenu, telebot.CommandScope{Type: telebot.CommandScopeAllGroupChats}); err != nil {
+		return errs.NewStack(err)
+	}
+
 	return nil
 }
 
 func (b *Bot) setupMiddlewares() {
 	b.Telebot.Use(
 		b.recoveryMiddleware,
+		b.metricsMiddleware,
 		b.defaultErrorMiddleware,
 		b.timeoutMiddleware,
+		b.groupMiddleware,
 		b.updateUserInfoMiddleware,
 		b.selectUserMiddleware,
 		b.subscribeMiddleware,
@@ -119,22 +205,58 @@ func (b *Bot) setupMiddlewares() {
 func (b *Bot) setupMessageRoutes() {
 	message := b.Telebot.Group()
 
-	message.Handle("/start", b.startHandler)
-	message.Handle("/language", b.selectLanguageHandler)
+	commands := map[string]telebot.HandlerFunc{
+		"/start":     b.startHandler,
+		"/language":  b.selectLanguageHandler,
+		"/token":     b.apiTokenHandler,
+		"/chart":     b.chartHandler,
+		"/profile":   b.profileHandler,
+		"/trader":    b.traderHandler,
+		"/top":       b.topCommandHandler,
+		"/portfolio": b.portfolioCommandHandler,
+		"/join":      b.joinHandler,
+		"/leave":     b.leaveHandler,
+
+		// admin commands aren't shown in the commands menu
+		"/sync_instruments":  b.syncInstrumentsHandler,
+		"/list_instrument":   b.listInstrumentHandler,
+		"/unlist_instrument": b.unlistInstrumentHandler,
+		"/reload_dictionary": b.reloadDictionaryHandler,
+	}
+
+	for command, handler := range commands {
+		message.Handle(command, handler)
+		b.messageRoutes[command] = command
+	}
+
 	message.Handle(telebot.OnText, b.textHandler)
+	message.Handle(telebot.OnMyChatMember, b.groupBotMemberHandler)
+	message.Handle(telebot.OnUserLeft, b.groupMemberLeftHandler)
+	message.Handle(telebot.OnQuery, b.inlineQueryHandler)
+
+	buttons := map[string]telebot.HandlerFunc{
+		btnMainMenu:         b.mainMenuHandler,
+		btnPortfolio:        b.portfolioHandler,
+		btnOperations:       b.operationsHandler,
+		btnInstrumentsList:  b.instrumentsListHandler,
+		btnInstrumentSearch: b.instrumentSearchHandler,
+		btnEnterPromocode:   b.enterPromocodeHandler,
+		btnFAQ:              b.faqHandler,
+		btnTopUsers:         b.topUsersHandler,
+		btnBuy:              b.buyHandler,
+		btnSell:             b.sellHandler,
+		btnDailyReward:      b.dailyRewardHandler,
+		btnSettings:         b.settingsHandler,
+		btnProfile:          b.profileHandler,
+	}
 
 	for _, lang := range b.cfg.Languages {
-		message.Handle(&telebot.Btn{Text: b.deps.dictionary.Text(lang, btnMainMenu)}, b.mainMenuHandler)
-		message.Handle(&telebot.Btn{Text: b.deps.dictionary.Text(lang, btnPortfolio)}, b.portfolioHandler)
-		message.Handle(&telebot.Btn{Text: b.deps.dictionary.Text(lang, btnOperations)}, b.operationsHandler)
-		message.Handle(&telebot.Btn{Text: b.deps.dictionary.Text(lang, btnInstrumentsList)}, b.instrumentsListHandler)
-		message.Handle(&telebot.Btn{Text: b.deps.dictionary.Text(lang, btnInstrumentSearch)}, b.instrumentSearchHandler)
-		message.Handle(&telebot.Btn{Text: b.deps.dictionary.Text(lang, btnEnterPromocode)}, b.enterPromocodeHandler)
-		message.Handle(&telebot.Btn{Text: b.deps.dictionary.Text(lang, btnFAQ)}, b.faqHandler)
-		message.Handle(&telebot.Btn{Text: b.deps.dictionary.Text(lang, btnTopUsers)}, b.topUsersHandler)
-		message.Handle(&telebot.Btn{Text: b.deps.dictionary.Text(lang, btnBuy)}, b.buyHandler)
-		message.Handle(&telebot.Btn{Text: b.deps.dictionary.Text(lang, btnSell)}, b.sellHandler)
-		message.Handle(&telebot.Btn{Text: b.deps.dictionary.Text(lang, btnDailyReward)}, b.dailyRewardHandler)
+		for key, handler := range buttons {
+			text := b.deps.dictionary.Text(lang, key)
+
+			message.Handle(&telebot.Btn{Text: text}, handler)
+			b.messageRoutes[text] = key
+		}
 	}
 }
 
@@ -148,6 +270,17 @@ func (b *Bot) setupCallbackRoutes() {
 	callback.Handle(&telebot.Btn{Unique: cbkInstrument}, b.instrumentHandler)
 	callback.Handle(&telebot.Btn{Unique: cbkTopUsersPage}, b.topUsersHandler)
 	callback.Handle(&telebot.Btn{Unique: cbkOperationsPage}, b.operationsHandler)
+	callback.Handle(&telebot.Btn{Unique: cbkChart}, b.chartHandler)
+	callback.Handle(&telebot.Btn{Unique: cbkSettings}, b.settingsHandler)
+	callback.Handle(&telebot.Btn{Unique: cbkSettingsTimeZones}, b.timeZonesHandler)
+	callback.Handle(&telebot.Btn{Unique: cbkSettingsTimeZone}, b.setTimeZoneHandler)
+	callback.Handle(&telebot.Btn{Unique: cbkSettingsNotification}, b.toggleNotificationHandler)
+	callback.Handle(&telebot.Btn{Unique: cbkSettingsQuietHours}, b.switchQuietHoursHandler)
+	callback.Handle(&telebot.Btn{Unique: cbkSettingsPublicProfile}, b.togglePublicProfileHandler)
+	callback.Handle(&telebot.Btn{Unique: cbkTrader}, b.traderHandler)
+	callback.Handle(&telebot.Btn{Unique: cbkFollow}, b.followHandler)
+	callback.Handle(&telebot.Btn{Unique: cbkUnfollow}, b.unfollowHandler)
+	callback.Handle(&telebot.Btn{Unique: cbkCopy}, b.copyHandler)
 }
 
 func (b *Bot) Start() {
@@ -159,28 +292,25 @@ func (b *Bot) Stop() {
 }
 
 func (b *Bot) mustUser(c telebot.Context) *domain.User {
-	tgID := c.Sender().ID
-
-	user, ok := b.users.Get(tgID)
+	user, ok := c.Get(ctxUser).(*domain.User)
 	if !ok {
-		log.Fatal("user not found in cache", zap.String("username", c.Sender().Username))
+		log.Fatal("user not found in context", zap.String("username", c.Sender().Username))
 	}
 
-	return user.(*domain.User)
+	return user
 }
 
-func (b *Bot) getUserInstrumentPrices(ctx context.Context, ticker string) (*domain.Instrument, error) {
-	instrument, ok := b.usersInstruments.Get(ticker)
-	if ok {
-		return instrument.(*domain.Instrument), nil
-	}
-
-	instrument, err := b.deps.finam.GetInstrumentPrices(ctx, ticker)
-	if err != nil {
-		return nil, errs.NewStack(err)
-	}
+// TopUsers returns actual top users list sorted by total balance descending.
+func (b *Bot) TopUsers(ctx context.Context) ([]*domain.TopUser, error) {
+	return b.getTopUsers(ctx)
+}
 
-	b.usersInstruments.SetDefault(ticker, instrument)
+// InstrumentPrices returns instrument prices from instruments cache. Prices are requested from Finam on cache miss.
+func (b *Bot) InstrumentPrices(ctx context.Context, ticker string) (*domain.Instrument, error) {
+	return b.getUserInstrumentPrices(ctx, ticker)
+}
 
-	return instrument.(*domain.Instrument), nil
+// InstrumentUnitPrices returns cached prices of one instrument unit, bonds are priced in L$ with accrued interest.
+func (b *Bot) InstrumentUnitPrices(ctx context.Context, ticker string) (domain.InstrumentPrices, error) {
+	return b.getUserInstrumentUnitPrices(ctx, ticker)
 }
@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+)
+
+const (
+	userTTL           = 16 * time.Minute
+	instrumentTTL     = 1 * time.Minute
+	instrumentInfoTTL = 10 * time.Minute
+
+	topUsersKey = "top_users"
+)
+
+func userKey(tgID int64) string {
+	return fmt.Sprintf("user:%d", tgID)
+}
+
+func instrumentKey(ticker string) string {
+	return "instrument:" + ticker
+}
+
+func instrumentInfoKey(ticker string) string {
+	return "instrument_info:" + ticker
+}
+
+func (b *Bot) getCachedUser(ctx context.Context, tgID int64) (*domain.User, bool, error) {
+	var user *domain.User
+
+	ok, err := b.cache.Get(ctx, userKey(tgID), &user)
+	if err != nil {
+		return nil, false, errs.NewStack(err)
+	}
+
+	return user, ok && user != nil, nil
+}
+
+// saveUser stores user in cache and prolongs its expiration.
+func (b *Bot) saveUser(ctx context.Context, user *domain.User) error {
+	if err := b.cache.Set(ctx, userKey(user.ID), user, userTTL); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+// saveUserSession stores user changed by handler in cache. Balances and margin call may be changed during
+// handling by the cache updater and by orders, so they are re-read from repository instead of the user snapshot.
+func (b *Bot) saveUserSession(ctx context.Context, user *domain.User) error {
+	dbUser, err := b.deps.usersRepository.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	user.AvailableBalance = dbUser.AvailableBalance
+	user.BlockedBalance = dbUser.BlockedBalance
+	user.MarginCall = dbUser.MarginCall
+
+	return b.saveUser(ctx, user)
+}
+
+func (b *Bot) getUserInstrumentPrices(ctx context.Context, ticker string) (*domain.Instrument, error) {
+	var instrument *domain.Instrument
+
+	ok, err := b.cache.Get(ctx, instrumentKey(ticker), &instrument)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	if ok {
+		return instrument, nil
+	}
+
+	instrument, err = b.deps.marketData.GetInstrumentPrices(ctx, ticker)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	if err := b.setInstrumentPrices(ctx, ticker, instrument); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return instrument, nil
+}
+
+func (b *Bot) setInstrumentPrices(ctx context.Context, ticker string, instrument *domain.Instrument) error {
+	if err := b.cache.Set(ctx, instrumentKey(ticker), instrument, instrumentTTL); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+// getInstrumentInfo returns instrument from repository with kind, nominal and coupons used for unit prices.
+func (b *Bot) getInstrumentInfo(ctx context.Context, ticker string) (*domain.Instrument, error) {
+	var instrument *domain.Instrument
+
+	ok, err := b.cache.Get(ctx, instrumentInfoKey(ticker), &instrument)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	if ok {
+		return instrument, nil
+	}
+
+	instrument, err = b.deps.instrumentsRepository.GetInstrumentByTicker(ctx, ticker)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	if err := b.cache.Set(ctx, instrumentInfoKey(ticker), instrument, instrumentInfoTTL); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return instrument, nil
+}
+
+// getUserInstrumentUnitPrices returns cached instrument prices of one unit, e.g. bond prices in L$ instead
+// of percents of nominal. Positions are valued and traded by these prices.
+func (b *Bot) getUserInstrumentUnitPrices(ctx context.Context, ticker string) (domain.InstrumentPrices, error) {
+	quotes, err := b.getUserInstrumentPrices(ctx, ticker)
+	if err != nil {
+		return domain.InstrumentPrices{}, errs.NewStack(err)
+	}
+
+	instrument, err := b.getInstrumentInfo(ctx, ticker)
+	if err != nil {
+		return domain.InstrumentPrices{}, errs.NewStack(err)
+	}
+
+	return instrument.UnitPrices(quotes.InstrumentPrices, time.Now()), nil
+}
+
+// getCurrencyRate returns price of one currency unit in the base currency by the cached currency instrument price.
+func (b *Bot) getCurrencyRate(ctx context.Context, currency string) (float64, error) {
+	if currency == "" || currency == domain.BaseCurrency {
+		return 1, nil
+	}
+
+	ticker, ok := domain.CurrencyTickers[currency]
+	if !ok {
+		return 0, errs.NewStack(fmt.Errorf("unknown currency %q", currency))
+	}
+
+	prices, err := b.getUserInstrumentPrices(ctx, ticker)
+	if err != nil {
+		return 0, errs.NewStack(err)
+	}
+
+	return prices.Last, nil
+}
+
+// getTopUsers returns top users list sorted by live-balance descending.
+func (b *Bot) getTopUsers(ctx context.Context) ([]*domain.TopUser, error) {
+	topUsers := []*domain.TopUser{}
+
+	if _, err := b.cache.Get(ctx, topUsersKey, &topUsers); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return topUsers, nil
+}
+
+// startInstrumentWatcher registers done channel of a new user's instrument price watcher.
+func (b *Bot) startInstrumentWatcher(tgID int64) chan struct{} {
+	doneCh := make(chan struct{})
+
+	b.mu.Lock()
+	b.instrumentWatchers[tgID] = doneCh
+	b.mu.Unlock()
+
+	return doneCh
+}
+
+// stopInstrumentWatcher stops user's instrument price watcher. Returns false if there is no watcher.
+// Watchers are local for the instance, so a watcher started by another instance expires by timeout.
+func (b *Bot) stopInstrumentWatcher(tgID int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	doneCh, ok := b.instrumentWatchers[tgID]
+	if !ok {
+		return false
+	}
+
+	close(doneCh)
+	delete(b.instrumentWatchers, tgID)
+
+	return true
+}
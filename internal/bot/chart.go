@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/chart"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/format"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+	"gopkg.in/telebot.v4"
+)
+
+const defaultChartTimeframe = domain.Timeframe1h
+
+// chartWindows is a period shown on the chart of the timeframe.
+var chartWindows = map[string]time.Duration{
+	domain.Timeframe1m: 2 * time.Hour,
+	domain.Timeframe1h: 7 * 24 * time.Hour,
+	domain.Timeframe1d: 180 * 24 * time.Hour,
+}
+
+// chartTimeLayouts is a time axis labels format of the timeframe.
+var chartTimeLayouts = map[string]string{
+	domain.Timeframe1m: "15:04",
+	domain.Timeframe1h: "02.01 15:04",
+	domain.Timeframe1d: "02.01.06",
+}
+
+var timeframeKeys = map[string]string{
+	domain.Timeframe1m: msgTimeframe1m,
+	domain.Timeframe1h: msgTimeframe1h,
+	domain.Timeframe1d: msgTimeframe1d,
+}
+
+// getCandles returns instrument candles for the chart window of the timeframe. Candles are stored in Postgres,
+// only candles newer than the last stored one are requested from Finam. The last stored candle is requested again
+// because it may be incomplete. Stored candles are returned if Finam is unavailable.
+func (b *Bot) getCandles(ctx context.Context, instrument *domain.Instrument, timeframe string) ([]*domain.Candle, error) {
+	to := time.Now()
+	from := to.Add(-chartWindows[timeframe])
+
+	candles, err := b.deps.candlesRepository.GetCandles(ctx, instrument.ID, timeframe, from, to)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	fetchFrom := from
+	if len(candles) > 0 {
+		fetchFrom = candles[len(candles)-1].Time
+	}
+
+	newCandles, err := b.deps.marketData.GetCandles(ctx, instrument.Ticker, timeframe, fetchFrom, to)
+	if err != nil {
+		log.Error("failed to get candles from finam",
+			zap.String("ticker", instrument.Ticker),
+			zap.String("timeframe", timeframe),
+			zap.Error(err),
+		)
+
+		return candles, nil
+	}
+
+	if len(newCandles) == 0 {
+		return candles, nil
+	}
+
+	if err := b.deps.candlesRepository.SaveCandles(ctx, instrument.ID, timeframe, newCandles); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	candles, err = b.deps.candlesRepository.GetCandles(ctx, instrument.ID, timeframe, from, to)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return candles, nil
+}
+
+// chartPhoto renders candlestick chart of the instrument to a photo with caption.
+// It returns nil photo if there are no candles for the chart window.
+func (b *Bot) chartPhoto(
+	ctx context.Context, lang string, instrument *domain.Instrument, timeframe string,
+) (*telebot.Photo, error) {
+	candles, err := b.getCandles(ctx, instrument, timeframe)
+	if err != nil {
+		return nil, errs.NewStack(fmt.Errorf("failed to get candles: %v", err))
+	}
+
+	if len(candles) == 0 {
+		return nil, nil
+	}
+
+	chartCandles := make([]chart.Candle, 0, len(candles))
+	for _, candle := range candles {
+		chartCandles = append(chartCandles, chart.Candle{
+			Time:   candle.Time,
+			Open:   candle.Open,
+			High:   candle.High,
+			Low:    candle.Low,
+			Close:  candle.Close,
+			Volume: candle.Volume,
+		})
+	}
+
+	moscow, _ := time.LoadLocation("Europe/Moscow")
+
+	png, err := chart.Candlestick(
+		fmt.Sprintf("%s %s", instrument.Ticker, timeframe), chartCandles, chartTimeLayouts[timeframe], moscow,
+	)
+	if err != nil {
+		return nil, errs.NewStack(fmt.Errorf("failed to render chart: %v", err))
+	}
+
+	first, last := candles[0], candles[len(candles)-1]
+
+	caption := b.deps.dictionary.Text(lang, msgChart, map[string]any{
+		"InstrumentName":    instrument.Name,
+		"Timeframe":         b.deps.dictionary.Text(lang, timeframeKeys[timeframe]),
+		"Price":             format.Price{Value: last.Close, Decimals: instrument.Decimals},
+		"PercentDifference": last.Close/first.Open*100 - 100,
+		"Unit":              quoteUnit(instrument),
+	})
+
+	return &telebot.Photo{
+		File:    telebot.FromReader(bytes.NewReader(png)),
+		Caption: caption,
+	}, nil
+}
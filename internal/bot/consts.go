@@ -8,52 +8,135 @@ const (
 	cbkInstrumentsPage   = "instruments_page"
 	cbkTopUsersPage      = "top_users_page"
 	cbkOperationsPage    = "operations_page"
+	cbkChart             = "chart"
+
+	cbkSettings             = "settings"
+	cbkSettingsTimeZones    = "settings_time_zones"
+	cbkSettingsTimeZone     = "settings_time_zone"
+	cbkSettingsNotification = "settings_notification"
+	cbkSettingsQuietHours   = "settings_quiet_hours"
+
+	cbkSettingsPublicProfile = "settings_public_profile"
+	cbkTrader                = "trader"
+	cbkFollow                = "follow"
+	cbkUnfollow              = "unfollow"
+	cbkCopy                  = "copy"
 )
 
 const (
-	msgDefaultError           = "unknown_error"
-	msgNeedSubscribe          = "need_subscribe"
-	msgSubscriptionSuccess    = "subscription_success"
-	msgSubscriptionFailed     = "subscription_failed"
-	msgStart                  = "start"
-	msgLanguage               = "select_language"
-	msgMainMenu               = "main_menu"
-	msgInstrumentsList        = "instruments_list"
-	msgInstrument             = "instrument"
-	msgLastPricePlug          = "last_price_plug"
-	msgLastPrice              = "last_price"
-	msgInstrumentExit         = "instrument_exit"
-	msgFAQ                    = "faq"
-	msgTopUsersFirstPage      = "top_users_first_page"
-	msgTopUsers               = "top_users"
-	msgEnterPromocode         = "enter_promocode"
-	msgEnterTicker            = "enter_ticker"
-	msgInstrumentNotFound     = "instrument_not_found"
-	msgInstrumentFound        = "instrument_found"
-	msgEnterCountToBuy        = "enter_count_to_buy"
-	msgSuccessfulBuy          = "successful_buy"
-	msgEnterCountToSell       = "enter_count_to_sell"
-	msgSuccessfulSell         = "successful_sell"
-	msgInvalidCount           = "invalid_count"
-	msgInsufficientFunds      = "insufficient_funds"
-	msgSuccessfulPromocode    = "successful_promocode"
-	msgPromocodeAlreadyUsed   = "promocode_already_used"
-	msgInvalidPromocode       = "invalid_promocode"
-	msgOperations             = "operations"
-	msgNoOperations           = "no_operations"
-	msgOperationBuy           = "operation_buy"
-	msgOperationSell          = "operation_sell"
-	msgOperationFee           = "operation_fee"
-	msgOperationPromocode     = "operation_promocode"
-	msgOperationDailyReward   = "operation_daily_reward"
-	msgOperationDevAssistance = "operation_dev_assistance"
-	msgPortfolio              = "portfolio"
-	msgEmptyPortfolio         = "empty_portfolio"
-	msgMarginCall             = "margin_call"
-	msgMarginCallWarning      = "margin_call_warning"
-	msgClosedExchange         = "closed_exchange"
-	msgDailyReward            = "daily_reward"
-	msgDailyRewardClaimed     = "daily_reward_claimed"
+	msgDefaultError               = "unknown_error"
+	msgNeedSubscribe              = "need_subscribe"
+	msgSubscriptionSuccess        = "subscription_success"
+	msgSubscriptionFailed         = "subscription_failed"
+	msgStart                      = "start"
+	msgLanguage                   = "select_language"
+	msgMainMenu                   = "main_menu"
+	msgInstrumentsList            = "instruments_list"
+	msgInstrument                 = "instrument"
+	msgLastPricePlug              = "last_price_plug"
+	msgQuote                      = "quote"
+	msgOrderBook                  = "order_book"
+	msgBondInfo                   = "bond_info"
+	msgBondNextCoupon             = "bond_next_coupon"
+	msgInstrumentExit             = "instrument_exit"
+	msgFAQ                        = "faq"
+	msgTopUsersFirstPage          = "top_users_first_page"
+	msgTopUsers                   = "top_users"
+	msgEnterPromocode             = "enter_promocode"
+	msgEnterTicker                = "enter_ticker"
+	msgInstrumentNotFound         = "instrument_not_found"
+	msgInstrumentFound            = "instrument_found"
+	msgEnterCountToBuy            = "enter_count_to_buy"
+	msgSuccessfulBuy              = "successful_buy"
+	msgEnterCountToSell           = "enter_count_to_sell"
+	msgSuccessfulSell             = "successful_sell"
+	msgInvalidCount               = "invalid_count"
+	msgInvalidLotCount            = "invalid_lot_count"
+	msgForeignShort               = "foreign_short"
+	msgInactiveInstrument         = "inactive_instrument"
+	msgInsufficientFunds          = "insufficient_funds"
+	msgSuccessfulPromocode        = "successful_promocode"
+	msgPromocodeAlreadyUsed       = "promocode_already_used"
+	msgInvalidPromocode           = "invalid_promocode"
+	msgOperations                 = "operations"
+	msgNoOperations               = "no_operations"
+	msgOperationBuy               = "operation_buy"
+	msgOperationSell              = "operation_sell"
+	msgOperationFee               = "operation_fee"
+	msgOperationFXBuy             = "operation_fx_buy"
+	msgOperationFXSell            = "operation_fx_sell"
+	msgOperationPromocode         = "operation_promocode"
+	msgOperationDailyReward       = "operation_daily_reward"
+	msgOperationDevAssistance     = "operation_dev_assistance"
+	msgOperationCoupon            = "operation_coupon"
+	msgPortfolio                  = "portfolio"
+	msgEmptyPortfolio             = "empty_portfolio"
+	msgCurrencyBalance            = "currency_balance"
+	msgMarginCall                 = "margin_call"
+	msgMarginCallWarning          = "margin_call_warning"
+	msgClosedExchange             = "closed_exchange"
+	msgDailyReward                = "daily_reward"
+	msgDailyRewardClaimed         = "daily_reward_claimed"
+	msgAPIToken                   = "api_token"
+	msgChart                      = "chart"
+	msgChartNoData                = "chart_no_data"
+	msgChartUsage                 = "chart_usage"
+	msgTimeframe1m                = "timeframe_1m"
+	msgTimeframe1h                = "timeframe_1h"
+	msgTimeframe1d                = "timeframe_1d"
+	msgInstrumentsSynced          = "instruments_synced"
+	msgInstrumentListed           = "instrument_listed"
+	msgInstrumentUnlisted         = "instrument_unlisted"
+	msgInstrumentListedUsage      = "instrument_listed_usage"
+	msgDictionaryReloaded         = "dictionary_reloaded"
+	msgAdminUserRegistered        = "admin_user_registered"
+	msgAdminPromocodeApplied      = "admin_promocode_applied"
+	msgDictionaryReloadFailed     = "dictionary_reload_failed"
+	msgSettings                   = "settings"
+	msgSettingsTimeZones          = "settings_time_zones"
+	msgQuietHoursOff              = "quiet_hours_off"
+	msgProfile                    = "profile"
+	msgAchievementUnlocked        = "achievement_unlocked"
+	msgOperationAchievement       = "operation_achievement"
+	msgTrader                     = "trader"
+	msgTraderPosition             = "trader_position"
+	msgTraderEmptyPortfolio       = "trader_empty_portfolio"
+	msgTraderNoOperations         = "trader_no_operations"
+	msgTraderNotFound             = "trader_not_found"
+	msgTraderUsage                = "trader_usage"
+	msgFollowedTrade              = "followed_trade"
+	msgCopiedTrade                = "copied_trade"
+	msgCopyTradeFailed            = "copy_trade_failed"
+	msgCopyLimitExceeded          = "copy_limit_exceeded"
+	msgGroupWelcome               = "group_welcome"
+	msgGroupOnly                  = "group_only"
+	msgGroupNotRegistered         = "group_not_registered"
+	msgGroupJoined                = "group_joined"
+	msgGroupLeft                  = "group_left"
+	msgGroupNotMember             = "group_not_member"
+	msgGroupTop                   = "group_top"
+	msgGroupPortfolio             = "group_portfolio"
+	msgGroupMemberNotFound        = "group_member_not_found"
+	msgGroupTrade                 = "group_trade"
+	msgInlineQuote                = "inline_quote"
+	msgInlineQuoteDescription     = "inline_quote_description"
+	msgInlinePortfolio            = "inline_portfolio"
+	msgInlinePortfolioTitle       = "inline_portfolio_title"
+	msgInlinePortfolioDescription = "inline_portfolio_description"
+	msgInlineStart                = "inline_start"
+
+	msgAchievementFirstTrade                    = "achievement_first_trade"
+	msgAchievementFirstTradeDescription         = "achievement_first_trade_description"
+	msgAchievementFirstShort                    = "achievement_first_short"
+	msgAchievementFirstShortDescription         = "achievement_first_short_description"
+	msgAchievementProfitableTrades              = "achievement_profitable_trades"
+	msgAchievementProfitableTradesDescription   = "achievement_profitable_trades_description"
+	msgAchievementMarginCallSurvivor            = "achievement_margin_call_survivor"
+	msgAchievementMarginCallSurvivorDescription = "achievement_margin_call_survivor_description"
+	msgAchievementTop10                         = "achievement_top_10"
+	msgAchievementTop10Description              = "achievement_top_10_description"
+	msgAchievementRewardStreak                  = "achievement_reward_streak"
+	msgAchievementRewardStreakDescription       = "achievement_reward_streak_description"
 )
 
 const (
@@ -74,4 +157,186 @@ const (
 	btnSell                = "button_sell"
 	btnPortfolioInstrument = "button_portfolio_instrument"
 	btnDailyReward         = "button_daily_reward"
+	btnWebApp              = "button_web_app"
+	btnChart               = "button_chart"
+	btnKindShare           = "button_kind_share"
+	btnKindBond            = "button_kind_bond"
+	btnKindETF             = "button_kind_etf"
+	btnKindCurrency        = "button_kind_currency"
+	btnSettings            = "button_settings"
+	btnTimeZone            = "button_time_zone"
+	btnDefaultTimeZone     = "button_default_time_zone"
+	btnQuietHours          = "button_quiet_hours"
+	btnBack                = "button_back"
+	btnProfile             = "button_profile"
+	btnPublicProfile       = "button_public_profile"
+	btnTrader              = "button_trader"
+	btnFollow              = "button_follow"
+	btnUnfollow            = "button_unfollow"
+	btnCopyPercent         = "button_copy_percent"
+	btnStopCopying         = "button_stop_copying"
+
+	btnNotificationDailyReward  = "button_notification_daily_reward"
+	btnNotificationMarginCall   = "button_notification_margin_call"
+	btnNotificationBroadcasts   = "button_notification_broadcasts"
+	btnNotificationAchievements = "button_notification_achievements"
+	btnNotificationFollows      = "button_notification_follows"
 )
+
+// dictionaryKeys are keys of all message and button texts, every configured language must have them.
+// TestDictionaryKeys checks that every msg* and btn* constant is listed.
+var dictionaryKeys = []string{
+	msgDefaultError,
+	msgNeedSubscribe,
+	msgSubscriptionSuccess,
+	msgSubscriptionFailed,
+	msgStart,
+	msgLanguage,
+	msgMainMenu,
+	msgInstrumentsList,
+	msgInstrument,
+	msgLastPricePlug,
+	msgQuote,
+	msgOrderBook,
+	msgBondInfo,
+	msgBondNextCoupon,
+	msgInstrumentExit,
+	msgFAQ,
+	msgTopUsersFirstPage,
+	msgTopUsers,
+	msgEnterPromocode,
+	msgEnterTicker,
+	msgInstrumentNotFound,
+	msgInstrumentFound,
+	msgEnterCountToBuy,
+	msgSuccessfulBuy,
+	msgEnterCountToSell,
+	msgSuccessfulSell,
+	msgInvalidCount,
+	msgInvalidLotCount,
+	msgForeignShort,
+	msgInactiveInstrument,
+	msgInsufficientFunds,
+	msgSuccessfulPromocode,
+	msgPromocodeAlreadyUsed,
+	msgInvalidPromocode,
+	msgOperations,
+	msgNoOperations,
+	msgOperationBuy,
+	msgOperationSell,
+	msgOperationFee,
+	msgOperationFXBuy,
+	msgOperationFXSell,
+	msgOperationPromocode,
+	msgOperationDailyReward,
+	msgOperationDevAssistance,
+	msgOperationCoupon,
+	msgPortfolio,
+	msgEmptyPortfolio,
+	msgCurrencyBalance,
+	msgMarginCall,
+	msgMarginCallWarning,
+	msgClosedExchange,
+	msgDailyReward,
+	msgDailyRewardClaimed,
+	msgAPIToken,
+	msgChart,
+	msgChartNoData,
+	msgChartUsage,
+	msgTimeframe1m,
+	msgTimeframe1h,
+	msgTimeframe1d,
+	msgInstrumentsSynced,
+	msgInstrumentListed,
+	msgInstrumentUnlisted,
+	msgInstrumentListedUsage,
+	msgDictionaryReloaded,
+	msgAdminUserRegistered,
+	msgAdminPromocodeApplied,
+	msgDictionaryReloadFailed,
+	msgSettings,
+	msgSettingsTimeZones,
+	msgQuietHoursOff,
+	msgProfile,
+	msgAchievementUnlocked,
+	msgOperationAchievement,
+	msgTrader,
+	msgTraderPosition,
+	msgTraderEmptyPortfolio,
+	msgTraderNoOperations,
+	msgTraderNotFound,
+	msgTraderUsage,
+	msgFollowedTrade,
+	msgCopiedTrade,
+	msgCopyTradeFailed,
+	msgCopyLimitExceeded,
+	msgGroupWelcome,
+	msgGroupOnly,
+	msgGroupNotRegistered,
+	msgGroupJoined,
+	msgGroupLeft,
+	msgGroupNotMember,
+	msgGroupTop,
+	msgGroupPortfolio,
+	msgGroupMemberNotFound,
+	msgGroupTrade,
+	msgInlineQuote,
+	msgInlineQuoteDescription,
+	msgInlinePortfolio,
+	msgInlinePortfolioTitle,
+	msgInlinePortfolioDescription,
+	msgInlineStart,
+	msgAchievementFirstTrade,
+	msgAchievementFirstTradeDescription,
+	msgAchievementFirstShort,
+	msgAchievementFirstShortDescription,
+	msgAchievementProfitableTrades,
+	msgAchievementProfitableTradesDescription,
+	msgAchievementMarginCallSurvivor,
+	msgAchievementMarginCallSurvivorDescription,
+	msgAchievementTop10,
+	msgAchievementTop10Description,
+	msgAchievementRewardStreak,
+	msgAchievementRewardStreakDescription,
+
+	btnSubscribe,
+	btnSubscribed,
+	btnLanguage,
+	btnPortfolio,
+	btnOperations,
+	btnInstrumentsList,
+	btnInstrumentSearch,
+	btnEnterPromocode,
+	btnFAQ,
+	btnTopUsers,
+	btnMainMenu,
+	btnNext,
+	btnPrevious,
+	btnBuy,
+	btnSell,
+	btnPortfolioInstrument,
+	btnDailyReward,
+	btnWebApp,
+	btnChart,
+	btnKindShare,
+	btnKindBond,
+	btnKindETF,
+	btnKindCurrency,
+	btnSettings,
+	btnTimeZone,
+	btnDefaultTimeZone,
+	btnQuietHours,
+	btnBack,
+	btnProfile,
+	btnPublicProfile,
+	btnTrader,
+	btnFollow,
+	btnUnfollow,
+	btnCopyPercent,
+	btnStopCopying,
+	btnNotificationDailyReward,
+	btnNotificationMarginCall,
+	btnNotificationBroadcasts,
+	btnNotificationAchievements,
+	btnNotificationFollows,
+}
@@ -0,0 +1,379 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
+	"github.com/leonid6372/success-bot/internal/common/trading"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/format"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+	"gopkg.in/telebot.v4"
+)
+
+// copyHandler sets percent of user's total balance copying the trader, zero percent stops copying.
+// Callback data is "copy|<user ID>|<percent>".
+func (b *Bot) copyHandler(c telebot.Context) error {
+	user := b.mustUser(c)
+
+	// the trader id is parsed by changeFollowing
+	args := c.Args()
+	if len(args) != 2 {
+		return errs.NewStack(fmt.Errorf("failed to parse data: params trader id and percent not found"))
+	}
+
+	percent, err := strconv.Atoi(args[1])
+	if err != nil || (percent != 0 && !slices.Contains(domain.CopyPercents, percent)) {
+		return errs.NewStack(fmt.Errorf("invalid copy percent: %s", args[1]))
+	}
+
+	return b.changeFollowing(c, func(ctx context.Context, followerID, traderID int64) error {
+		err := b.deps.followsRepository.SetCopyPercent(ctx, followerID, traderID, percent)
+		if !errors.Is(err, boterrs.ErrCopyLimitExceeded) {
+			return err
+		}
+
+		// the limit is checked by the repository, so concurrent changes can't exceed it together
+		followedTraders, err := b.deps.followsRepository.GetFollowedTraders(ctx, followerID)
+		if err != nil {
+			return errs.NewStack(fmt.Errorf("failed to get followed traders: %v", err))
+		}
+
+		allocated := 0
+		for id, traderPercent := range followedTraders {
+			if id != traderID {
+				allocated += traderPercent
+			}
+		}
+
+		if err := c.Respond(&telebot.CallbackResponse{
+			Text: b.deps.dictionary.Text(user.LanguageCode, msgCopyLimitExceeded, map[string]any{
+				"Available": max(domain.MaxCopyPercent-allocated, 0),
+			}),
+			ShowAlert: true,
+		}); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to respond: %v", err))
+		}
+
+		return nil
+	})
+}
+
+// copyTrades replicates the trader's order to the followers copying the trader. Order count is scaled by
+// the ratio of follower's allocated balance to trader's total balance, closing orders close the same part
+// of follower's position. Every follower is processed independently and is notified about failures.
+func (b *Bot) copyTrades(ctx context.Context, event domain.TradeExecuted) error {
+	// copies aren't copied again, so traders copying each other don't loop, forced closing isn't a decision
+	if event.Source == metrics.SourceCopy || event.Source == metrics.SourceStopOut {
+		return nil
+	}
+
+	// currency exchange doesn't change positions
+	if event.Trade.Type != domain.OperationTypeBuy && event.Trade.Type != domain.OperationTypeSell {
+		return nil
+	}
+
+	copiers, err := b.deps.followsRepository.GetCopiers(ctx, event.UserID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get copiers: %v", err))
+	}
+
+	if len(copiers) == 0 {
+		return nil
+	}
+
+	trader, err := b.deps.usersRepository.GetUserByID(ctx, event.UserID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get trader: %v", err))
+	}
+
+	// closed profiles aren't copied until they are opened again
+	if !trader.Settings.PublicProfile {
+		return nil
+	}
+
+	topUsers, err := b.getTopUsers(ctx)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get top users: %v", err))
+	}
+
+	totalBalances := make(map[int64]float64, len(topUsers))
+	for _, topUser := range topUsers {
+		totalBalances[topUser.ID] = topUser.TotalBalance
+	}
+
+	// users registered after the top users cache update are valued by their balances in the database
+	totalBalance := func(userID int64) (float64, error) {
+		if balance, ok := totalBalances[userID]; ok {
+			return balance, nil
+		}
+
+		return b.userTotalBalance(ctx, userID)
+	}
+
+	traderBalance, err := totalBalance(trader.ID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get trader total balance: %v", err))
+	}
+
+	if traderBalance <= 0 {
+		return errs.NewStack(fmt.Errorf("trader %d has no positive total balance", trader.ID))
+	}
+
+	instrument, err := b.getInstrumentInfo(ctx, event.Trade.Ticker)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get instrument info: %v", err))
+	}
+
+	// the event may be delivered with a delay, so copies are executed at the current market price
+	quotes, err := b.deps.marketData.GetInstrumentPrices(ctx, instrument.Ticker)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get instrument prices: %v", err))
+	}
+
+	if quotes.Ask == 0 && quotes.Bid == 0 {
+		return errs.NewStack(boterrs.ErrClosedExchange)
+	}
+
+	// orders are executed by unit prices, bonds quotes are in percents of nominal
+	prices := instrument.UnitPrices(quotes.InstrumentPrices, time.Now())
+
+	ctx = domain.ContextWithCopiedOperation(domain.ContextWithActor(ctx, domain.ActorCopyTrading), event.Trade.OperationID)
+
+	for _, copier := range copiers {
+		followerBalance, err := totalBalance(copier.FollowerID)
+		if err != nil {
+			metrics.CopyTradeErrors.Inc()
+
+			log.Error("failed to get follower total balance",
+				zap.Int64("follower_id", copier.FollowerID),
+				zap.Int64("operation_id", event.Trade.OperationID),
+				zap.Error(err),
+			)
+
+			continue
+		}
+
+		ratio := float64(copier.Percent) / 100 * followerBalance / traderBalance
+
+		if err := b.copyTrade(ctx, trader, instrument, prices, event.Trade, copier.FollowerID, ratio); err != nil {
+			metrics.CopyTradeErrors.Inc()
+
+			log.Error("failed to copy trade",
+				zap.Int64("trader_id", trader.ID),
+				zap.Int64("follower_id", copier.FollowerID),
+				zap.Int64("operation_id", event.Trade.OperationID),
+				zap.Error(err),
+			)
+
+			b.notifyCopyTradeFailed(ctx, trader, instrument, copier.FollowerID, err)
+		}
+	}
+
+	return nil
+}
+
+// userTotalBalance values the user's balances, positions and currency balances in the base currency the same
+// way as the top users cache updater.
+func (b *Bot) userTotalBalance(ctx context.Context, userID int64) (float64, error) {
+	user, err := b.deps.usersRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, errs.NewStack(fmt.Errorf("failed to get user: %v", err))
+	}
+
+	userPositions, err := b.deps.portfoliosRepository.GetUserPortfolio(ctx, userID)
+	if err != nil {
+		return 0, errs.NewStack(fmt.Errorf("failed to get user portfolio: %v", err))
+	}
+
+	positions := make([]trading.PricedPosition, 0, len(userPositions))
+	for _, position := range userPositions {
+		prices, err := b.getUserInstrumentUnitPrices(ctx, position.Ticker)
+		if err != nil {
+			return 0, errs.NewStack(fmt.Errorf("failed to get instrument prices: %v", err))
+		}
+
+		rate, err := b.getCurrencyRate(ctx, position.Currency)
+		if err != nil {
+			return 0, errs.NewStack(fmt.Errorf("failed to get currency rate: %v", err))
+		}
+
+		positions = append(positions, trading.PricedPosition{
+			Position: trading.Position{Count: position.Count},
+			Last:     prices.Last * rate,
+		})
+	}
+
+	total := trading.Revalue(trading.Balances{
+		Available: user.AvailableBalance,
+		Blocked:   user.BlockedBalance,
+	}, positions).Total
+
+	currencyBalances, err := b.deps.portfoliosRepository.GetUserCurrencyBalances(ctx, userID)
+	if err != nil {
+		return 0, errs.NewStack(fmt.Errorf("failed to get currency balances: %v", err))
+	}
+
+	for _, balance := range currencyBalances {
+		rate, err := b.getCurrencyRate(ctx, balance.Currency)
+		if err != nil {
+			return 0, errs.NewStack(fmt.Errorf("failed to get currency rate: %v", err))
+		}
+
+		total += balance.Amount * rate
+	}
+
+	return total, nil
+}
+
+// copyTrade executes the copy of the trader's order for the follower by one order at the ask or bid price:
+// closing part of the trade closes the same part of follower's opposite position, opening part is scaled
+// by the ratio and isn't copied during follower's margin call. Trade events are delivered at least once,
+// so the trade copied by the follower is skipped.
+func (b *Bot) copyTrade(
+	ctx context.Context,
+	trader *domain.User,
+	instrument *domain.Instrument,
+	prices domain.InstrumentPrices,
+	trade *domain.TradeResult,
+	followerID int64,
+	ratio float64,
+) error {
+	copied, err := b.deps.operationsRepository.IsOperationCopied(ctx, followerID, trade.OperationID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to check copied operation: %v", err))
+	}
+
+	if copied {
+		return nil
+	}
+
+	follower, err := b.deps.usersRepository.GetUserByID(ctx, followerID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get follower: %v", err))
+	}
+
+	positions, err := b.deps.portfoliosRepository.GetUserPortfolio(ctx, follower.ID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get follower portfolio: %v", err))
+	}
+
+	var position int64
+	for _, p := range positions {
+		if p.Ticker == instrument.Ticker {
+			position = p.Count
+		}
+	}
+
+	buy := trade.Type == domain.OperationTypeBuy
+	order := b.deps.portfoliosRepository.SellInstrument
+	maxCount := b.deps.portfoliosRepository.GetMaxInstrumentCountToSell
+	price := prices.Bid
+	if buy {
+		order = b.deps.portfoliosRepository.BuyInstrument
+		maxCount = b.deps.portfoliosRepository.GetMaxInstrumentCountToBuy
+		price = prices.Ask
+	}
+
+	var closeCount int64
+	if trade.ClosedCount > 0 && ((buy && position < 0) || (!buy && position > 0)) {
+		delta := trade.Count
+		if !buy {
+			delta = -delta
+		}
+
+		traderPosition := max(trade.Position-delta, delta-trade.Position)
+		followerPosition := max(position, -position)
+
+		// the whole position is closed even if it isn't a multiple of lot size
+		closeCount = followerPosition
+		if trade.ClosedCount < traderPosition {
+			closeCount = trading.RoundDownToLot(
+				int64(float64(followerPosition)*float64(trade.ClosedCount)/float64(traderPosition)), instrument.LotSize,
+			)
+		}
+	}
+
+	var openCount int64
+	if !follower.MarginCall {
+		openCount = trading.RoundDownToLot(int64(float64(trade.Count-trade.ClosedCount)*ratio), instrument.LotSize)
+	}
+
+	// position which isn't a multiple of lot size is closed without opening
+	count := closeCount + openCount
+	if trading.RoundDownToLot(count, instrument.LotSize) != count {
+		count = closeCount
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	// follower's margin limits are the same as for own orders, closing isn't limited
+	if count > closeCount {
+		limit, err := maxCount(ctx, follower.ID, instrument.Ticker, price)
+		if err != nil {
+			return errs.NewStack(fmt.Errorf("failed to get max count: %v", err))
+		}
+
+		// errors which are shown to the follower aren't wrapped with stack, it hides them from errors.Is
+		if limit < count {
+			return fmt.Errorf("%w: copy count %d, max count %d", boterrs.ErrInsufficientFunds, count, limit)
+		}
+	}
+
+	result, err := order(ctx, follower.ID, instrument.ID, count, price)
+	if errors.Is(err, boterrs.ErrOperationCopied) {
+		return nil // copied by another delivery of the event
+	}
+	if err != nil {
+		return fmt.Errorf("failed to execute order: %w", err)
+	}
+
+	b.bus.Notify()
+
+	if err := b.notify(follower, domain.NotificationFollows,
+		b.deps.dictionary.Text(follower.LanguageCode, msgCopiedTrade, map[string]any{
+			"Trader":         trader.Username,
+			"Buy":            buy,
+			"Count":          result.Count,
+			"InstrumentName": instrument.Name,
+			"Price":          format.Price{Value: price, Decimals: instrument.Decimals},
+			"Unit":           currencySign(instrument.Currency),
+		}),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML},
+	); err != nil {
+		log.Error("failed to notify about copied trade", zap.String("username", follower.Username), zap.Error(err))
+	}
+
+	return nil
+}
+
+func (b *Bot) notifyCopyTradeFailed(
+	ctx context.Context, trader *domain.User, instrument *domain.Instrument, followerID int64, copyErr error,
+) {
+	follower, err := b.deps.usersRepository.GetUserByID(ctx, followerID)
+	if err != nil {
+		log.Error("failed to get follower", zap.Int64("user_id", followerID), zap.Error(err))
+		return
+	}
+
+	if err := b.notify(follower, domain.NotificationFollows,
+		b.deps.dictionary.Text(follower.LanguageCode, msgCopyTradeFailed, map[string]any{
+			"Trader":            trader.Username,
+			"InstrumentName":    instrument.Name,
+			"InsufficientFunds": errors.Is(copyErr, boterrs.ErrInsufficientFunds),
+		}),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML},
+	); err != nil {
+		log.Error("failed to notify about failed copy", zap.String("username", follower.Username), zap.Error(err))
+	}
+}
@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+	"gopkg.in/telebot.v4"
+)
+
+// validateDictionary checks that every message and button text exists in every configured language.
+// The requirement is kept by dictionary, so reloads with missing texts are rejected too.
+func (b *Bot) validateDictionary() error {
+	if err := b.deps.dictionary.Require(b.cfg.Languages, dictionaryKeys); err != nil {
+		return errs.NewStack(fmt.Errorf("invalid dictionary: %v", err))
+	}
+
+	return nil
+}
+
+// setupDictionaryReloader setups a goroutine that reloads dictionary when its file changes.
+// Every instance reloads own dictionary. Buttons texts are routed at startup, so changed buttons need restart.
+func (b *Bot) setupDictionaryReloader() {
+	ticker := time.NewTicker(b.cfg.Dictionary.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			log.Info("dictionary reloader shutting down...")
+			return
+
+		case <-ticker.C:
+			reloaded, err := b.deps.dictionary.ReloadIfChanged()
+			if err != nil {
+				log.Error("failed to reload dictionary", zap.Error(err))
+				continue
+			}
+
+			if reloaded {
+				log.Info("dictionary reloaded")
+			}
+		}
+	}
+}
+
+// reloadDictionaryHandler reloads dictionary on admin's demand, other users' commands are ignored.
+func (b *Bot) reloadDictionaryHandler(c telebot.Context) error {
+	user := b.mustUser(c)
+
+	if !b.isAdmin(user.ID) {
+		return nil
+	}
+
+	var text string
+	if err := b.deps.dictionary.Reload(); err != nil {
+		log.Error("failed to reload dictionary", zap.Error(err))
+
+		text = b.deps.dictionary.Text(user.LanguageCode, msgDictionaryReloadFailed, map[string]any{
+			"Error": err.Error(),
+		})
+	} else {
+		text = b.deps.dictionary.Text(user.LanguageCode, msgDictionaryReloaded)
+	}
+
+	if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
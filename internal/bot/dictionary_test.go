@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/leonid6372/success-bot/pkg/dictionary"
+)
+
+// TestDictionaryKeys checks that dictionaryKeys lists every msg* and btn* constant of consts.go, so a new text
+// can't be missed by validateDictionary.
+func TestDictionaryKeys(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "consts.go", nil, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+
+	var consts, listed []string
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			valueSpec, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+
+			for i, name := range valueSpec.Names {
+				switch {
+				case genDecl.Tok == token.CONST &&
+					(strings.HasPrefix(name.Name, "msg") || strings.HasPrefix(name.Name, "btn")):
+					consts = append(consts, name.Name)
+
+				case genDecl.Tok == token.VAR && name.Name == "dictionaryKeys" && i < len(valueSpec.Values):
+					lit, ok := valueSpec.Values[i].(*ast.CompositeLit)
+					if !ok {
+						t.Fatal("dictionaryKeys isn't a composite literal")
+					}
+
+					for _, elt := range lit.Elts {
+						ident, ok := elt.(*ast.Ident)
+						if !ok {
+							t.Fatalf("dictionaryKeys element %T isn't a constant", elt)
+						}
+
+						listed = append(listed, ident.Name)
+					}
+				}
+			}
+		}
+	}
+
+	for _, name := range consts {
+		if !slices.Contains(listed, name) {
+			t.Errorf("dictionaryKeys has no %s", name)
+		}
+	}
+
+	if len(listed) != len(consts) {
+		t.Errorf("dictionaryKeys has %d keys, want %d", len(listed), len(consts))
+	}
+}
+
+// TestDictionary checks the dictionary shipped with the bot the same way as validateDictionary at startup.
+func TestDictionary(t *testing.T) {
+	dict, err := dictionary.New("../../dictionary.json")
+	if err != nil {
+		t.Fatalf("dictionary.New: %v", err)
+	}
+
+	if err := dict.Require([]string{"ru", "en"}, dictionaryKeys); err != nil {
+		t.Errorf("Require: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		langs   []string
+		keys    []string
+		wantErr bool
+	}{
+		{
+			name:    "missing key",
+			langs:   []string{"ru", "en"},
+			keys:    append(slices.Clone(dictionaryKeys), "msg_missing"),
+			wantErr: true,
+		},
+		{name: "missing language", langs: []string{"ru", "de"}, keys: dictionaryKeys, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := dict.Require(tt.langs, tt.keys); (err != nil) != tt.wantErr {
+				t.Errorf("Require() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
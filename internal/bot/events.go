@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/events"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+	"gopkg.in/telebot.v4"
+)
+
+// Subscribers names, they are used in logs and metrics.
+const (
+	subscriberMetrics       = "metrics"
+	subscriberNotifications = "notifications"
+	subscriberAchievements  = "achievements"
+	subscriberFollows       = "follows"
+	subscriberCopyTrading   = "copy_trading"
+	subscriberGroups        = "groups"
+)
+
+// setupEventSubscribers registers side effects of domain events. Events are published by the bot and by API.
+func (b *Bot) setupEventSubscribers() {
+	events.Subscribe(b.bus, subscriberMetrics, func(_ context.Context, e domain.TradeExecuted) error {
+		metrics.TradesExecuted.WithLabelValues(e.Trade.Type, e.Source).Inc()
+		return nil
+	})
+	events.Subscribe(b.bus, subscriberMetrics, func(_ context.Context, _ domain.MarginCallEntered) error {
+		metrics.MarginCalls.Inc()
+		return nil
+	})
+	events.Subscribe(b.bus, subscriberMetrics, func(_ context.Context, e domain.UserRegistered) error {
+		metrics.UsersRegistered.WithLabelValues(e.LanguageCode).Inc()
+		return nil
+	})
+	events.Subscribe(b.bus, subscriberMetrics, func(_ context.Context, e domain.RewardClaimed) error {
+		metrics.DailyRewardsClaimed.WithLabelValues(strconv.Itoa(e.Claim.Tier)).Inc()
+		return nil
+	})
+	events.Subscribe(b.bus, subscriberMetrics, func(_ context.Context, _ domain.PromocodeApplied) error {
+		metrics.PromocodesApplied.Inc()
+		return nil
+	})
+
+	events.Subscribe(b.bus, subscriberNotifications, func(ctx context.Context, e domain.MarginCallEntered) error {
+		return b.notifyMarginCall(ctx, e.UserID)
+	})
+	events.Subscribe(b.bus, subscriberNotifications, b.notifyAdminsUserRegistered)
+	events.Subscribe(b.bus, subscriberNotifications, b.notifyAdminsPromocodeApplied)
+
+	events.Subscribe(b.bus, subscriberFollows, b.notifyFollowers)
+	events.Subscribe(b.bus, subscriberCopyTrading, b.copyTrades)
+	events.Subscribe(b.bus, subscriberGroups, b.announceGroupTrade)
+
+	events.Subscribe(b.bus, subscriberAchievements, func(ctx context.Context, e domain.TradeExecuted) error {
+		// forced closing of a short isn't an achievement of the user
+		if e.Source == metrics.SourceStopOut {
+			return nil
+		}
+
+		return b.evaluateAchievements(ctx, e.UserID, e)
+	})
+	events.Subscribe(b.bus, subscriberAchievements, func(ctx context.Context, e domain.MarginCallCleared) error {
+		return b.evaluateAchievements(ctx, e.UserID, e)
+	})
+	events.Subscribe(b.bus, subscriberAchievements, func(ctx context.Context, e domain.TopFinished) error {
+		return b.evaluateAchievements(ctx, e.UserID, e)
+	})
+	events.Subscribe(b.bus, subscriberAchievements, func(ctx context.Context, e domain.RewardClaimed) error {
+		return b.evaluateAchievements(ctx, e.UserID, e)
+	})
+}
+
+func (b *Bot) notifyAdminsUserRegistered(ctx context.Context, event domain.UserRegistered) error {
+	user, err := b.deps.usersRepository.GetUserByID(ctx, event.UserID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get user: %v", err))
+	}
+
+	b.notifyAdmins(ctx, msgAdminUserRegistered, map[string]any{
+		"Username": user.Username,
+		"Language": event.LanguageCode,
+	})
+
+	return nil
+}
+
+func (b *Bot) notifyAdminsPromocodeApplied(ctx context.Context, event domain.PromocodeApplied) error {
+	user, err := b.deps.usersRepository.GetUserByID(ctx, event.UserID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get user: %v", err))
+	}
+
+	b.notifyAdmins(ctx, msgAdminPromocodeApplied, map[string]any{
+		"Username":  user.Username,
+		"Promocode": event.Promocode,
+		"Bonus":     money(event.BonusAmount, domain.BaseCurrency),
+	})
+
+	return nil
+}
+
+// notifyAdmins sends the text to admins in their languages. Errors are logged only, so admins who got the text
+// don't get it again on retry of the event.
+func (b *Bot) notifyAdmins(ctx context.Context, key string, values map[string]any) {
+	for _, adminID := range b.cfg.Admins {
+		admin, err := b.deps.usersRepository.GetUserByID(ctx, adminID)
+		if err != nil {
+			log.Error("failed to get admin", zap.Int64("admin_id", adminID), zap.Error(err))
+			continue
+		}
+
+		if _, err := b.Telebot.Send(&telebot.User{ID: admin.ID},
+			b.deps.dictionary.Text(admin.LanguageCode, key, values),
+			&telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		); err != nil {
+			log.Error("failed to notify admin", zap.Int64("admin_id", adminID), zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+// publish publishes the event which isn't caused by a change in a repository, events of repositories are
+// stored by them. It is called after the action which caused the event is done, so errors are logged only.
+func (b *Bot) publish(ctx context.Context, event domain.Event) {
+	if err := b.bus.Publish(ctx, event); err != nil {
+		log.Error("failed to publish event", zap.String("type", event.EventType()), zap.Error(err))
+	}
+}
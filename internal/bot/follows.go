@@ -0,0 +1,345 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/format"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+	"gopkg.in/telebot.v4"
+)
+
+// traderHandler shows public profile of a trader: rank, portfolio composition and recent trades.
+// Callback data is "trader|<user ID>", the command is "/trader <username>".
+func (b *Bot) traderHandler(c telebot.Context) error {
+	defer c.Respond()
+
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	user.Metadata.InputType = ""
+	user.Metadata.InstrumentOperation = ""
+
+	if err := b.closeInstrument(c, user); err != nil {
+		return errs.NewStack(err)
+	}
+
+	args := c.Args()
+	if len(args) != 1 {
+		text := b.deps.dictionary.Text(user.LanguageCode, msgTraderUsage)
+
+		if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+		}
+
+		return nil
+	}
+
+	topUsers, err := b.getTopUsers(ctx)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get top users: %v", err))
+	}
+
+	match := func(topUser *domain.TopUser) bool {
+		return strings.EqualFold(topUser.Username, strings.TrimPrefix(args[0], "@"))
+	}
+
+	if c.Callback() != nil {
+		traderID, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return errs.NewStack(fmt.Errorf("failed to parse trader id: %v", err))
+		}
+
+		match = func(topUser *domain.TopUser) bool {
+			return topUser.ID == traderID
+		}
+	}
+
+	for i, topUser := range topUsers {
+		if match(topUser) {
+			return b.sendTrader(c, user, topUser, i+1)
+		}
+	}
+
+	return b.sendTraderNotFound(c, user)
+}
+
+// followHandler and unfollowHandler change following of the trader from the trader's profile,
+// callback data is "follow|<user ID>".
+func (b *Bot) followHandler(c telebot.Context) error {
+	return b.changeFollowing(c, b.deps.followsRepository.Follow)
+}
+
+func (b *Bot) unfollowHandler(c telebot.Context) error {
+	return b.changeFollowing(c, b.deps.followsRepository.Unfollow)
+}
+
+func (b *Bot) changeFollowing(c telebot.Context, change func(ctx context.Context, followerID, traderID int64) error) error {
+	defer c.Respond()
+
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	// the trader id is the first param, the rest belongs to the change
+	args := c.Args()
+	if len(args) == 0 {
+		return errs.NewStack(fmt.Errorf("failed to parse data: param trader id not found"))
+	}
+
+	traderID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to parse trader id: %v", err))
+	}
+
+	topUsers, err := b.getTopUsers(ctx)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get top users: %v", err))
+	}
+
+	for i, topUser := range topUsers {
+		if topUser.ID != traderID {
+			continue
+		}
+
+		trader, err := b.deps.usersRepository.GetUserByID(ctx, traderID)
+		if err != nil {
+			return errs.NewStack(fmt.Errorf("failed to get trader: %v", err))
+		}
+
+		if !trader.Settings.PublicProfile || trader.ID == user.ID {
+			break
+		}
+
+		if err := change(ctx, user.ID, trader.ID); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to change following: %v", err))
+		}
+
+		return b.sendTrader(c, user, topUser, i+1)
+	}
+
+	return b.sendTraderNotFound(c, user)
+}
+
+// sendTrader shows public profile of the trader with the rank, the follow buttons edit the message in place.
+// Private profiles aren't shown except own one.
+func (b *Bot) sendTrader(c telebot.Context, user *domain.User, trader *domain.TopUser, rank int) error {
+	ctx := c.Get(ctxContext).(context.Context)
+
+	// the profile could be opened or closed after the top users cache update
+	dbTrader, err := b.deps.usersRepository.GetUserByID(ctx, trader.ID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get trader: %v", err))
+	}
+
+	if !dbTrader.Settings.PublicProfile && trader.ID != user.ID {
+		return b.sendTraderNotFound(c, user)
+	}
+
+	portfolio, err := b.portfolioSummary(ctx, user.LanguageCode, trader.ID)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	operations, err := b.deps.operationsRepository.GetOperationsByPage(ctx, trader.ID, 1)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get trader operations: %v", err))
+	}
+
+	location := b.userLocation(user)
+
+	// only trades are public, balance operations aren't shown
+	var trades strings.Builder
+	tradesCount := 0
+	for _, op := range operations {
+		if tradesCount == domain.TraderOperationsCount {
+			break
+		}
+
+		key := msgOperationBuy
+		switch op.Type {
+		case domain.OperationTypeBuy:
+		case domain.OperationTypeSell:
+			key = msgOperationSell
+		default:
+			continue
+		}
+
+		trades.WriteString(b.deps.dictionary.Text(user.LanguageCode, key, map[string]any{
+			"OperationID": op.ID,
+			"Count":       op.Count,
+			"Name":        op.InstrumentName[strings.Index(op.InstrumentName, " ")+1:], // cut instrument emoji
+			"Amount":      money(op.TotalAmount, op.Currency),
+			"Date":        op.CreatedAt.In(location),
+		}))
+		tradesCount++
+	}
+
+	if tradesCount == 0 {
+		trades.WriteString(b.deps.dictionary.Text(user.LanguageCode, msgTraderNoOperations))
+	}
+
+	followers, err := b.deps.followsRepository.GetFollowers(ctx, trader.ID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get trader followers: %v", err))
+	}
+
+	followedTraders, err := b.deps.followsRepository.GetFollowedTraders(ctx, user.ID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get followed traders: %v", err))
+	}
+
+	copyPercent, following := followedTraders[trader.ID]
+
+	text := b.deps.dictionary.Text(user.LanguageCode, msgTrader, map[string]any{
+		"Username":       trader.Username,
+		"Badges":         trader.Badges,
+		"TotalBalance":   money(trader.TotalBalance, domain.BaseCurrency),
+		"Rank":           rank,
+		"FollowersCount": len(followers),
+		"Portfolio":      portfolio,
+		"Operations":     trades.String(),
+	})
+
+	opts := &telebot.SendOptions{
+		ReplyMarkup: b.traderKeyboard(user.LanguageCode, trader.ID, trader.ID == user.ID, following, copyPercent),
+		ParseMode:   telebot.ModeHTML,
+	}
+
+	if c.Callback() != nil {
+		if err := c.Edit(text, opts); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to edit message: %v", err))
+		}
+
+		return nil
+	}
+
+	if err := c.Send(text, opts); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+// portfolioSummary returns the user's positions with their returns as shown to other users.
+func (b *Bot) portfolioSummary(ctx context.Context, lang string, userID int64) (string, error) {
+	positions, err := b.deps.portfoliosRepository.GetUserPortfolio(ctx, userID)
+	if err != nil {
+		return "", errs.NewStack(fmt.Errorf("failed to get user portfolio: %v", err))
+	}
+
+	var portfolio strings.Builder
+	for _, position := range positions {
+		prices, err := b.getUserInstrumentUnitPrices(ctx, position.Ticker)
+		if err != nil {
+			return "", errs.NewStack(fmt.Errorf("failed to get instrument prices: %v", err))
+		}
+
+		values := map[string]any{
+			"Short":  position.Count < 0,
+			"Ticker": shortTicker(position.Ticker),
+			"Name":   position.Name,
+			"Count":  max(position.Count, -position.Count),
+		}
+
+		// return of a position without average price, e.g. received for free, is unknown
+		if position.AvgPrice != 0 {
+			diff := prices.Last/position.AvgPrice*100 - 100
+			if position.Count < 0 {
+				diff = -diff
+			}
+
+			values["PercentDifference"] = diff
+		}
+
+		portfolio.WriteString(b.deps.dictionary.Text(lang, msgTraderPosition, values))
+	}
+
+	if len(positions) == 0 {
+		portfolio.WriteString(b.deps.dictionary.Text(lang, msgTraderEmptyPortfolio))
+	}
+
+	return portfolio.String(), nil
+}
+
+// sendTraderNotFound doesn't distinguish private profiles from unknown users.
+func (b *Bot) sendTraderNotFound(c telebot.Context, user *domain.User) error {
+	text := b.deps.dictionary.Text(user.LanguageCode, msgTraderNotFound)
+
+	if c.Callback() != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: text})
+	}
+
+	if err := c.Send(text); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+// notifyFollowers notifies followers of the trader with public profile about the opened or closed position.
+func (b *Bot) notifyFollowers(ctx context.Context, event domain.TradeExecuted) error {
+	// currency exchange doesn't change positions
+	if event.Trade.Type != domain.OperationTypeBuy && event.Trade.Type != domain.OperationTypeSell {
+		return nil
+	}
+
+	trader, err := b.deps.usersRepository.GetUserByID(ctx, event.UserID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get trader: %v", err))
+	}
+
+	if !trader.Settings.PublicProfile {
+		return nil
+	}
+
+	followers, err := b.deps.followsRepository.GetFollowers(ctx, trader.ID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get followers: %v", err))
+	}
+
+	if len(followers) == 0 {
+		return nil
+	}
+
+	instrument, err := b.getInstrumentInfo(ctx, event.Trade.Ticker)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get instrument info: %v", err))
+	}
+
+	for _, followerID := range followers {
+		follower, err := b.deps.usersRepository.GetUserByID(ctx, followerID)
+		if err != nil {
+			log.Error("failed to get follower", zap.Int64("user_id", followerID), zap.Error(err))
+			continue
+		}
+
+		text := b.deps.dictionary.Text(follower.LanguageCode, msgFollowedTrade, map[string]any{
+			"Trader":         trader.Username,
+			"Buy":            event.Trade.Type == domain.OperationTypeBuy,
+			"Count":          event.Trade.Count,
+			"InstrumentName": instrument.Name,
+			"Price":          format.Price{Value: event.Trade.Price, Decimals: instrument.Decimals},
+			"Unit":           currencySign(instrument.Currency),
+			"Closed":         event.Trade.ClosedCount,
+			"Opened":         event.Trade.Count - event.Trade.ClosedCount,
+		})
+
+		if err := b.notify(follower, domain.NotificationFollows, text, &telebot.SendOptions{
+			ReplyMarkup: b.followedTradeKeyboard(follower.LanguageCode, trader),
+			ParseMode:   telebot.ModeHTML,
+		}); err != nil {
+			log.Error("failed to notify about followed trade",
+				zap.String("username", follower.Username),
+				zap.Int64("trader_id", trader.ID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,404 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
+	"github.com/leonid6372/success-bot/pkg/dictionary"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/format"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+	"gopkg.in/telebot.v4"
+)
+
+// groupCommands are the only commands handled in group chats, other group messages are ignored.
+var groupCommands = map[string]bool{
+	"/join":      true,
+	"/leave":     true,
+	"/top":       true,
+	"/portfolio": true,
+}
+
+func isGroupChat(chat *telebot.Chat) bool {
+	return chat != nil && (chat.Type == telebot.ChatGroup || chat.Type == telebot.ChatSuperGroup)
+}
+
+// groupBotMemberHandler registers the group with the language of the user who added the bot and forgets
+// the group with its league when the bot is removed.
+func (b *Bot) groupBotMemberHandler(c telebot.Context) error {
+	ctx := c.Get(ctxContext).(context.Context)
+	update := c.ChatMember()
+
+	if !isGroupChat(update.Chat) {
+		return nil
+	}
+
+	switch update.NewChatMember.Role {
+	case telebot.Left, telebot.Kicked:
+		if err := b.deps.groupsRepository.DeleteGroup(ctx, update.Chat.ID); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to delete group: %v", err))
+		}
+
+		return nil
+	}
+
+	// promotion to administrator isn't adding
+	if update.OldChatMember.Role != telebot.Left && update.OldChatMember.Role != telebot.Kicked {
+		return nil
+	}
+
+	lang := dictionary.DefaultLanguage
+	if inviter, err := b.deps.usersRepository.GetUserByID(ctx, update.Sender.ID); err == nil {
+		lang = inviter.LanguageCode
+	}
+
+	group, err := b.deps.groupsRepository.SaveGroup(ctx, &domain.Group{
+		ID:           update.Chat.ID,
+		Title:        update.Chat.Title,
+		LanguageCode: lang,
+	})
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to save group: %v", err))
+	}
+
+	text := b.deps.dictionary.Text(group.LanguageCode, msgGroupWelcome, map[string]any{
+		"BotUsername": b.Telebot.Me.Username,
+	})
+
+	if _, err := b.Telebot.Send(update.Chat, text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+// groupMemberLeftHandler removes the member who left the group from the group league.
+func (b *Bot) groupMemberLeftHandler(c telebot.Context) error {
+	ctx := c.Get(ctxContext).(context.Context)
+	member := c.Message().UserLeft
+
+	if member.ID == b.Telebot.Me.ID {
+		if err := b.deps.groupsRepository.DeleteGroup(ctx, c.Chat().ID); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to delete group: %v", err))
+		}
+
+		return nil
+	}
+
+	if _, err := b.deps.groupsRepository.LeaveGroup(ctx, c.Chat().ID, member.ID); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to leave group: %v", err))
+	}
+
+	return nil
+}
+
+// joinHandler enrolls the sender in the league of the group, the command is "/join".
+func (b *Bot) joinHandler(c telebot.Context) error {
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	if !isGroupChat(c.Chat()) {
+		return b.sendGroupOnly(c, user)
+	}
+
+	group, err := b.saveGroup(ctx, c.Chat(), user)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	if err := b.deps.groupsRepository.JoinGroup(ctx, group.ID, user.ID); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to join group: %v", err))
+	}
+
+	text := b.deps.dictionary.Text(group.LanguageCode, msgGroupJoined, map[string]any{
+		"Username": user.Username,
+		"Title":    group.Title,
+	})
+
+	if err := c.Reply(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+// leaveHandler removes the sender from the league of the group, the command is "/leave".
+func (b *Bot) leaveHandler(c telebot.Context) error {
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	if !isGroupChat(c.Chat()) {
+		return b.sendGroupOnly(c, user)
+	}
+
+	group, err := b.saveGroup(ctx, c.Chat(), user)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	left, err := b.deps.groupsRepository.LeaveGroup(ctx, group.ID, user.ID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to leave group: %v", err))
+	}
+
+	key := msgGroupLeft
+	if !left {
+		key = msgGroupNotMember
+	}
+
+	text := b.deps.dictionary.Text(group.LanguageCode, key, map[string]any{
+		"Username": user.Username,
+	})
+
+	if err := c.Reply(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+// topCommandHandler shows the league of the group in group chats and the top users in private chat,
+// the command is "/top".
+func (b *Bot) topCommandHandler(c telebot.Context) error {
+	if !isGroupChat(c.Chat()) {
+		return b.topUsersHandler(c)
+	}
+
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	group, err := b.saveGroup(ctx, c.Chat(), user)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	members, err := b.getGroupTopUsers(ctx, group.ID)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	locale := b.deps.dictionary.Locale(group.LanguageCode)
+
+	var usersList string
+	for i, member := range members[:min(domain.GroupTopUsersCount, len(members))] {
+		usersList += fmt.Sprintf("\n%d. %s %s",
+			i+1,
+			topUserName(member),
+			locale.Money(money(member.TotalBalance, domain.BaseCurrency)),
+		)
+	}
+
+	text := b.deps.dictionary.Text(group.LanguageCode, msgGroupTop, map[string]any{
+		"Title":        group.Title,
+		"MembersCount": len(members),
+		"UsersList":    usersList,
+	})
+
+	if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+// portfolioCommandHandler shows portfolio of the group league member in group chats, the command is
+// "/portfolio [@username]", the sender's portfolio is shown without username. In private chat it shows own
+// portfolio or public profile of the trader.
+func (b *Bot) portfolioCommandHandler(c telebot.Context) error {
+	if !isGroupChat(c.Chat()) {
+		if len(c.Args()) > 0 {
+			return b.traderHandler(c)
+		}
+
+		return b.portfolioHandler(c)
+	}
+
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	group, err := b.saveGroup(ctx, c.Chat(), user)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	members, err := b.getGroupTopUsers(ctx, group.ID)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	match := func(member *domain.TopUser) bool {
+		return member.ID == user.ID
+	}
+
+	if args := c.Args(); len(args) > 0 {
+		match = func(member *domain.TopUser) bool {
+			return strings.EqualFold(member.Username, strings.TrimPrefix(args[0], "@"))
+		}
+	}
+
+	for i, member := range members {
+		if !match(member) {
+			continue
+		}
+
+		portfolio, err := b.portfolioSummary(ctx, group.LanguageCode, member.ID)
+		if err != nil {
+			return errs.NewStack(err)
+		}
+
+		text := b.deps.dictionary.Text(group.LanguageCode, msgGroupPortfolio, map[string]any{
+			"Username":     member.Username,
+			"Badges":       member.Badges,
+			"TotalBalance": money(member.TotalBalance, domain.BaseCurrency),
+			"Rank":         i + 1,
+			"Portfolio":    portfolio,
+		})
+
+		if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+		}
+
+		return nil
+	}
+
+	// portfolios of users outside of the league aren't shown in the group
+	text := b.deps.dictionary.Text(group.LanguageCode, msgGroupMemberNotFound)
+
+	if err := c.Reply(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+// announceGroupTrade posts the trade of the league member to the groups the member joined.
+func (b *Bot) announceGroupTrade(ctx context.Context, event domain.TradeExecuted) error {
+	// currency exchange doesn't change positions
+	if event.Trade.Type != domain.OperationTypeBuy && event.Trade.Type != domain.OperationTypeSell {
+		return nil
+	}
+
+	groups, err := b.deps.groupsRepository.GetUserGroups(ctx, event.UserID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get user groups: %v", err))
+	}
+
+	if len(groups) == 0 {
+		return nil
+	}
+
+	trader, err := b.deps.usersRepository.GetUserByID(ctx, event.UserID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get trader: %v", err))
+	}
+
+	instrument, err := b.getInstrumentInfo(ctx, event.Trade.Ticker)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get instrument info: %v", err))
+	}
+
+	for _, group := range groups {
+		text := b.deps.dictionary.Text(group.LanguageCode, msgGroupTrade, map[string]any{
+			"Trader":         trader.Username,
+			"Buy":            event.Trade.Type == domain.OperationTypeBuy,
+			"Count":          event.Trade.Count,
+			"InstrumentName": instrument.Name,
+			"Price":          format.Price{Value: event.Trade.Price, Decimals: instrument.Decimals},
+			"Unit":           currencySign(instrument.Currency),
+			"Closed":         event.Trade.ClosedCount,
+			"Opened":         event.Trade.Count - event.Trade.ClosedCount,
+			"Copied":         event.Source == metrics.SourceCopy,
+			"StopOut":        event.Source == metrics.SourceStopOut,
+		})
+
+		_, err := b.Telebot.Send(&telebot.Chat{ID: group.ID}, text, &telebot.SendOptions{ParseMode: telebot.ModeHTML})
+		if err == nil {
+			continue
+		}
+
+		// the bot could be removed while it was offline
+		if errors.Is(err, telebot.ErrKickedFromGroup) || errors.Is(err, telebot.ErrChatNotFound) {
+			if err := b.deps.groupsRepository.DeleteGroup(ctx, group.ID); err != nil {
+				log.Error("failed to delete group", zap.Int64("group_id", group.ID), zap.Error(err))
+			}
+
+			continue
+		}
+
+		log.Error("failed to announce trade to group",
+			zap.Int64("group_id", group.ID),
+			zap.String("username", trader.Username),
+			zap.Error(err),
+		)
+	}
+
+	return nil
+}
+
+// saveGroup registers the group if the bot was added before groups support and keeps the title actual.
+func (b *Bot) saveGroup(ctx context.Context, chat *telebot.Chat, user *domain.User) (*domain.Group, error) {
+	group, err := b.deps.groupsRepository.SaveGroup(ctx, &domain.Group{
+		ID:           chat.ID,
+		Title:        chat.Title,
+		LanguageCode: user.LanguageCode,
+	})
+	if err != nil {
+		return nil, errs.NewStack(fmt.Errorf("failed to save group: %v", err))
+	}
+
+	return group, nil
+}
+
+// getGroupTopUsers returns top users of the group league sorted by total balance descending.
+func (b *Bot) getGroupTopUsers(ctx context.Context, groupID int64) ([]*domain.TopUser, error) {
+	memberIDs, err := b.deps.groupsRepository.GetGroupMembers(ctx, groupID)
+	if err != nil {
+		return nil, errs.NewStack(fmt.Errorf("failed to get group members: %v", err))
+	}
+
+	isMember := make(map[int64]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		isMember[id] = true
+	}
+
+	topUsers, err := b.getTopUsers(ctx)
+	if err != nil {
+		return nil, errs.NewStack(fmt.Errorf("failed to get top users: %v", err))
+	}
+
+	members := make([]*domain.TopUser, 0, len(memberIDs))
+	for _, topUser := range topUsers {
+		if isMember[topUser.ID] {
+			members = append(members, topUser)
+		}
+	}
+
+	return members, nil
+}
+
+func (b *Bot) sendGroupOnly(c telebot.Context, user *domain.User) error {
+	text := b.deps.dictionary.Text(user.LanguageCode, msgGroupOnly)
+
+	if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+func (b *Bot) sendGroupNotRegistered(c telebot.Context) error {
+	text := b.deps.dictionary.Text(dictionary.DefaultLanguage, msgGroupNotRegistered, map[string]any{
+		"BotUsername": b.Telebot.Me.Username,
+	})
+
+	if err := c.Reply(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
@@ -8,14 +8,16 @@ import (
 	"strings"
 	"time"
 
-	"github.com/Ruvad39/go-finam-rest"
 	"github.com/jackc/pgx/v5"
 	"github.com/leonid6372/success-bot/internal/boterrs"
 	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
+	"github.com/leonid6372/success-bot/internal/common/trading"
 	"github.com/leonid6372/success-bot/pkg/dictionary"
 	"github.com/leonid6372/success-bot/pkg/errs"
 	"github.com/leonid6372/success-bot/pkg/format"
 	"github.com/leonid6372/success-bot/pkg/log"
+	"github.com/leonid6372/success-bot/pkg/token"
 	"go.uber.org/zap"
 	"gopkg.in/telebot.v4"
 )
@@ -56,11 +58,13 @@ func (b *Bot) startHandler(c telebot.Context) error {
 			AvailableBalance: 250000,
 		}
 
-		if err := b.deps.usersRepository.CreateUser(ctx, user); err != nil {
+		if err := b.deps.usersRepository.CreateUser(ctx, user, c.Sender().LanguageCode); err != nil {
 			return errs.NewStack(err)
 		}
 
-		b.users.SetDefault(user.ID, user)
+		b.bus.Notify()
+
+		c.Set(ctxUser, user)
 
 		return b.selectLanguageHandler(c)
 	}
@@ -230,11 +234,24 @@ func (b *Bot) portfolioHandler(c telebot.Context) error {
 		return errs.NewStack(fmt.Errorf("failed to get user by id: %v", err))
 	}
 
+	currencyBalances, err := b.deps.portfoliosRepository.GetUserCurrencyBalances(ctx, user.ID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get user currency balances: %v", err))
+	}
+
+	var cash strings.Builder
+	for _, balance := range currencyBalances {
+		cash.WriteString(b.deps.dictionary.Text(user.LanguageCode, msgCurrencyBalance, map[string]any{
+			"Amount": money(balance.Amount, balance.Currency),
+		}))
+	}
+
 	var text string
 
 	if len(instruments) == 0 {
 		text = b.deps.dictionary.Text(user.LanguageCode, msgEmptyPortfolio, map[string]any{
-			"AvailableBalance": dbUser.AvailableBalance,
+			"AvailableBalance": money(dbUser.AvailableBalance, domain.BaseCurrency),
+			"CurrencyBalances": cash.String(),
 		})
 	} else {
 		var warning string
@@ -246,18 +263,19 @@ func (b *Bot) portfolioHandler(c telebot.Context) error {
 			"Warning":          warning,
 			"CurrentPage":      currentPage,
 			"PagesCount":       pagesCount,
-			"AvailableBalance": dbUser.AvailableBalance,
-			"BlockedBalance":   dbUser.BlockedBalance,
+			"AvailableBalance": money(dbUser.AvailableBalance, domain.BaseCurrency),
+			"BlockedBalance":   money(dbUser.BlockedBalance, domain.BaseCurrency),
+			"CurrencyBalances": cash.String(),
 		})
 	}
 
 	for _, instrument := range instruments {
-		prices, err := b.getUserInstrumentPrices(ctx, instrument.Ticker)
+		prices, err := b.getUserInstrumentUnitPrices(ctx, instrument.Ticker)
 		if err != nil {
 			return errs.NewStack(fmt.Errorf("failed to get instrument prices: %v", err))
 		}
 
-		instrument.InstrumentPrices = prices.InstrumentPrices
+		instrument.InstrumentPrices = prices
 	}
 
 	markup := b.portfolioInstrumentsListByPageKeyboard(
@@ -287,17 +305,23 @@ func (b *Bot) instrumentsListHandler(c telebot.Context) error {
 		return errs.NewStack(err)
 	}
 
-	currentPage, err := b.getCurrentPage(c)
-	if err != nil {
-		return errs.NewStack(err)
+	// callback data is "instruments_page|<kind>|<page>", the button opens the first page of shares
+	kind, currentPage := domain.InstrumentKindShare, int64(1)
+	if args := c.Args(); len(args) == 2 {
+		page, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errs.NewStack(fmt.Errorf("failed to parse current page: %v", err))
+		}
+
+		kind, currentPage = args[0], page
 	}
 
-	pagesCount, err := b.deps.instrumentsRepository.GetInstrumentsPagesCount(ctx)
+	pagesCount, err := b.deps.instrumentsRepository.GetInstrumentsPagesCount(ctx, kind)
 	if err != nil {
 		return errs.NewStack(fmt.Errorf("failed to get instruments pages count: %v", err))
 	}
 
-	instruments, err := b.deps.instrumentsRepository.GetInstrumentsByPage(ctx, currentPage)
+	instruments, err := b.deps.instrumentsRepository.GetInstrumentsByPage(ctx, kind, currentPage)
 	if err != nil {
 		return errs.NewStack(fmt.Errorf("failed to get instruments by page: %v", err))
 	}
@@ -309,7 +333,7 @@ func (b *Bot) instrumentsListHandler(c telebot.Context) error {
 	})
 
 	markup := b.instrumentsListByPageKeyboard(
-		user.LanguageCode, instruments, currentPage, pagesCount,
+		user.LanguageCode, kind, instruments, currentPage, pagesCount,
 	)
 
 	if err := c.Send(text, &telebot.SendOptions{
@@ -325,6 +349,7 @@ func (b *Bot) instrumentsListHandler(c telebot.Context) error {
 func (b *Bot) instrumentHandler(c telebot.Context) error {
 	defer c.Respond()
 
+	ctx := c.Get(ctxContext).(context.Context)
 	user := b.mustUser(c)
 
 	user.Metadata.InputType = ""
@@ -342,15 +367,19 @@ func (b *Bot) instrumentHandler(c telebot.Context) error {
 
 	ticker := args[0]
 
-	instrument, err := b.deps.instrumentsRepository.GetInstrumentByTicker(b.ctx, ticker)
+	instrument, err := b.deps.instrumentsRepository.GetInstrumentByTicker(ctx, ticker)
 	if err != nil {
 		return errs.NewStack(fmt.Errorf("failed to get instrument by ticker: %v", err))
 	}
 
-	doneCh := make(chan struct{})
-	user.Metadata.InstrumentDone = &doneCh
+	user.Metadata.InstrumentTicker = ticker
+
+	doneCh := b.startInstrumentWatcher(user.ID)
 
 	go func(c telebot.Context, user *domain.User) {
+		metrics.InstrumentWatchers.Inc()
+		defer metrics.InstrumentWatchers.Dec()
+
 		defer func() {
 			if err := b.closeInstrument(c, user); err != nil {
 				log.Error("failed to close instrument", zap.String("username", user.Username), zap.Error(err))
@@ -360,7 +389,7 @@ func (b *Bot) instrumentHandler(c telebot.Context) error {
 		ctx, cancel := context.WithTimeout(b.ctx, 5*time.Minute)
 		defer cancel()
 
-		instrumentPrices, err := b.deps.finam.GetInstrumentPrices(b.ctx, ticker)
+		instrumentPrices, err := b.deps.marketData.GetInstrumentPrices(ctx, ticker)
 		if err != nil {
 			log.Error(
 				"failed to get instrument prices from finam", zap.String("username", user.Username), zap.Error(err),
@@ -368,27 +397,37 @@ func (b *Bot) instrumentHandler(c telebot.Context) error {
 			return
 		}
 
-		user.Metadata.InstrumentTicker = ticker
-		user.Metadata.InstrumentBuyPrice = instrumentPrices.Ask
-		user.Metadata.InstrumentSellPrice = instrumentPrices.Bid
+		// watcher keeps instrument prices in cache fresh, buy and sell handlers take prices from there
+		if err := b.setInstrumentPrices(ctx, ticker, instrumentPrices); err != nil {
+			log.Error("failed to set instrument prices", zap.String("ticker", ticker), zap.Error(err))
+		}
 
 		markup := b.instrumentKeyboard(user.LanguageCode)
 
 		text := b.deps.dictionary.Text(user.LanguageCode, msgInstrument, map[string]any{
 			"InstrumentName":   instrument.Name,
-			"InstrumentTicker": instrument.Ticker[:strings.Index(instrument.Ticker, "@")],
+			"InstrumentTicker": shortTicker(instrument.Ticker),
+			"LotSize":          instrument.LotSize,
 		})
 
+		if instrument.Kind == domain.InstrumentKindBond {
+			text += b.bondInfoText(user.LanguageCode, instrument, time.Now())
+		}
+
 		if err := c.Send(text, &telebot.SendOptions{ReplyMarkup: markup, ParseMode: telebot.ModeHTML}); err != nil {
 			log.Error("failed to send message", zap.String("username", user.Username), zap.Error(err))
 			return
 		}
 
 		var prevPrice float64
+		var prevText string
+		color := "🟢"
 
 		text = b.deps.dictionary.Text(user.LanguageCode, msgLastPricePlug)
 
-		msg, err := b.Telebot.Send(c.Recipient(), text)
+		chartMarkup := b.chartButtonKeyboard(user.LanguageCode, ticker)
+
+		msg, err := b.Telebot.Send(c.Recipient(), text, chartMarkup)
 		if err != nil {
 			log.Error("failed to send message", zap.String("username", user.Username), zap.Error(err))
 		}
@@ -405,7 +444,7 @@ func (b *Bot) instrumentHandler(c telebot.Context) error {
 				return
 
 			default:
-				instrumentPrices, err := b.deps.finam.GetInstrumentPrices(b.ctx, ticker)
+				instrumentPrices, err := b.deps.marketData.GetInstrumentPrices(ctx, ticker)
 				if err != nil {
 					log.Error(
 						"failed to get instrument prices from finam", zap.String("username", user.Username), zap.Error(err),
@@ -413,26 +452,16 @@ func (b *Bot) instrumentHandler(c telebot.Context) error {
 					continue
 				}
 
-				// Skip if price didn't change
-				if prevPrice == instrumentPrices.Last {
-					continue
-				}
-
-				var color string
-
-				if instrumentPrices.Last > prevPrice {
+				switch {
+				case instrumentPrices.Last > prevPrice:
 					color = "🟢"
-				} else {
+				case instrumentPrices.Last < prevPrice:
 					color = "🔴"
 				}
 
-				text := b.deps.dictionary.Text(user.LanguageCode, msgLastPrice, map[string]any{
-					"Color": color,
-					"Price": format.PrettyNumber(instrumentPrices.Last, " ", ",", true),
-				})
-
 				if instrumentPrices.Ask == 0 && instrumentPrices.Bid == 0 {
-					text += "\n\n" + b.deps.dictionary.Text(user.LanguageCode, msgClosedExchange)
+					text := b.quoteText(user.LanguageCode, color, instrumentPrices, nil, instrument) +
+						"\n\n" + b.deps.dictionary.Text(user.LanguageCode, msgClosedExchange)
 
 					if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
 						log.Error("failed to send message", zap.String("username", user.Username), zap.Error(err))
@@ -441,12 +470,33 @@ func (b *Bot) instrumentHandler(c telebot.Context) error {
 					return
 				}
 
+				var orderBook *domain.OrderBook
+				if b.cfg.OrderBookDepth > 0 {
+					orderBook, err = b.deps.marketData.GetOrderBook(ctx, ticker, b.cfg.OrderBookDepth)
+					if err != nil {
+						log.Error("failed to get order book", zap.String("ticker", ticker), zap.Error(err))
+					}
+				}
+
 				prevPrice = instrumentPrices.Last
 
-				user.Metadata.InstrumentBuyPrice = instrumentPrices.Ask
-				user.Metadata.InstrumentSellPrice = instrumentPrices.Bid
+				if err := b.setInstrumentPrices(ctx, ticker, instrumentPrices); err != nil {
+					log.Error("failed to set instrument prices", zap.String("ticker", ticker), zap.Error(err))
+				}
+
+				text := b.quoteText(user.LanguageCode, color, instrumentPrices, orderBook, instrument)
 
-				_, err = b.Telebot.Edit(msg, text)
+				// Skip if neither prices nor order book changed
+				if text == prevText {
+					continue
+				}
+
+				prevText = text
+
+				_, err = b.Telebot.Edit(msg, text, &telebot.SendOptions{
+					ReplyMarkup: chartMarkup,
+					ParseMode:   telebot.ModeHTML,
+				})
 				if err != nil {
 					log.Error("failed to edit message", zap.String("username", user.Username), zap.Error(err))
 				}
@@ -491,6 +541,7 @@ func (b *Bot) faqHandler(c telebot.Context) error {
 func (b *Bot) topUsersHandler(c telebot.Context) error {
 	defer c.Respond()
 
+	ctx := c.Get(ctxContext).(context.Context)
 	user := b.mustUser(c)
 
 	user.Metadata.InputType = ""
@@ -501,8 +552,13 @@ func (b *Bot) topUsersHandler(c telebot.Context) error {
 		return errs.NewStack(err)
 	}
 
-	b.mu.RLock()
-	pagesCount := int64(len(b.topUsers)/domain.UsersPerPage) + 1
+	topUsers, err := b.getTopUsers(ctx)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get top users: %v", err))
+	}
+
+	pagesCount := int64(len(topUsers)/domain.UsersPerPage) + 1
+	locale := b.deps.dictionary.Locale(user.LanguageCode)
 
 	var text, usersList string
 
@@ -510,69 +566,59 @@ func (b *Bot) topUsersHandler(c telebot.Context) error {
 		var top1Username, top2Username, top3Username string
 		var top1Balance, top2Balance, top3Balance float64
 
-		switch len(b.topUsers) {
+		switch len(topUsers) {
 		case 0:
-			b.mu.RUnlock()
 		case 1:
-			top1Username = b.topUsers[0].Username
-			top1Balance = b.topUsers[0].TotalBalance
-
-			b.mu.RUnlock()
+			top1Username = topUserName(topUsers[0])
+			top1Balance = topUsers[0].TotalBalance
 		case 2:
-			top1Username = b.topUsers[0].Username
-			top1Balance = b.topUsers[0].TotalBalance
-			top2Username = b.topUsers[1].Username
-			top2Balance = b.topUsers[1].TotalBalance
-
-			b.mu.RUnlock()
+			top1Username = topUserName(topUsers[0])
+			top1Balance = topUsers[0].TotalBalance
+			top2Username = topUserName(topUsers[1])
+			top2Balance = topUsers[1].TotalBalance
 		case 3:
-			top1Username = b.topUsers[0].Username
-			top1Balance = b.topUsers[0].TotalBalance
-			top2Username = b.topUsers[1].Username
-			top2Balance = b.topUsers[1].TotalBalance
-			top3Username = b.topUsers[2].Username
-			top3Balance = b.topUsers[2].TotalBalance
-
-			b.mu.RUnlock()
+			top1Username = topUserName(topUsers[0])
+			top1Balance = topUsers[0].TotalBalance
+			top2Username = topUserName(topUsers[1])
+			top2Balance = topUsers[1].TotalBalance
+			top3Username = topUserName(topUsers[2])
+			top3Balance = topUsers[2].TotalBalance
 		default:
-			top1Username = b.topUsers[0].Username
-			top1Balance = b.topUsers[0].TotalBalance
-			top2Username = b.topUsers[1].Username
-			top2Balance = b.topUsers[1].TotalBalance
-			top3Username = b.topUsers[2].Username
-			top3Balance = b.topUsers[2].TotalBalance
-
-			for i := 3; i < min(domain.UsersPerPage, len(b.topUsers)); i++ {
-				usersList += fmt.Sprintf("\n%d. %s %s L$",
+			top1Username = topUserName(topUsers[0])
+			top1Balance = topUsers[0].TotalBalance
+			top2Username = topUserName(topUsers[1])
+			top2Balance = topUsers[1].TotalBalance
+			top3Username = topUserName(topUsers[2])
+			top3Balance = topUsers[2].TotalBalance
+
+			for i := 3; i < min(domain.UsersPerPage, len(topUsers)); i++ {
+				usersList += fmt.Sprintf("\n%d. %s %s",
 					i+1,
-					b.topUsers[i].Username,
-					format.PrettyNumber(b.topUsers[i].TotalBalance, " ", ",", false),
+					topUserName(topUsers[i]),
+					locale.Money(money(topUsers[i].TotalBalance, domain.BaseCurrency)),
 				)
 			}
-
-			b.mu.RUnlock()
 		}
 
 		text = b.deps.dictionary.Text(user.LanguageCode, msgTopUsersFirstPage, map[string]any{
 			"CurrentPage":  currentPage,
 			"PagesCount":   pagesCount,
 			"Top1Username": top1Username,
-			"Top1Balance":  top1Balance,
+			"Top1Balance":  money(top1Balance, domain.BaseCurrency),
 			"Top2Username": top2Username,
-			"Top2Balance":  top2Balance,
+			"Top2Balance":  money(top2Balance, domain.BaseCurrency),
 			"Top3Username": top3Username,
-			"Top3Balance":  top3Balance,
+			"Top3Balance":  money(top3Balance, domain.BaseCurrency),
 			"UsersList":    usersList,
 		})
 	} else {
-		for i := domain.UsersPerPage * (currentPage - 1); i < min(domain.UsersPerPage*currentPage, int64(len(b.topUsers))); i++ {
-			usersList += fmt.Sprintf("\n%d. %s %s L$",
+		for i := domain.UsersPerPage * (currentPage - 1); i < min(domain.UsersPerPage*currentPage, int64(len(topUsers))); i++ {
+			usersList += fmt.Sprintf("\n%d. %s %s",
 				i+1,
-				b.topUsers[i].Username,
-				format.PrettyNumber(b.topUsers[i].TotalBalance, " ", ",", false),
+				topUserName(topUsers[i]),
+				locale.Money(money(topUsers[i].TotalBalance, domain.BaseCurrency)),
 			)
 		}
-		b.mu.RUnlock()
 
 		text = b.deps.dictionary.Text(user.LanguageCode, msgTopUsers, map[string]any{
 			"CurrentPage": currentPage,
@@ -581,7 +627,9 @@ func (b *Bot) topUsersHandler(c telebot.Context) error {
 		})
 	}
 
-	markup := b.paginationKeyboard(user.LanguageCode, cbkTopUsersPage, currentPage, pagesCount)
+	pageStart := min(domain.UsersPerPage*(currentPage-1), int64(len(topUsers)))
+	pageEnd := min(domain.UsersPerPage*currentPage, int64(len(topUsers)))
+	markup := b.topUsersKeyboard(user.LanguageCode, topUsers[pageStart:pageEnd], currentPage, pagesCount)
 
 	if err := c.Send(text, &telebot.SendOptions{
 		ReplyMarkup: markup,
@@ -643,7 +691,7 @@ func (b *Bot) inputPromocode(c telebot.Context) error {
 		text = b.deps.dictionary.Text(user.LanguageCode, msgPromocodeAlreadyUsed)
 	case err == nil:
 		text = b.deps.dictionary.Text(user.LanguageCode, msgSuccessfulPromocode, map[string]any{
-			"Amount": promocode.BonusAmount,
+			"Amount": money(promocode.BonusAmount, domain.BaseCurrency),
 		})
 	default:
 		return errs.NewStack(fmt.Errorf("failed to apply promocode: %v", err))
@@ -655,6 +703,10 @@ func (b *Bot) inputPromocode(c telebot.Context) error {
 		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
 	}
 
+	if promocode != nil {
+		b.bus.Notify()
+	}
+
 	return nil
 }
 
@@ -665,7 +717,7 @@ func (b *Bot) inputCountToBuy(c telebot.Context) error {
 	txtCount := c.Text()
 
 	count, err := strconv.ParseInt(txtCount, 10, 64)
-	if err != nil {
+	if err != nil || count <= 0 {
 		text := b.deps.dictionary.Text(user.LanguageCode, msgInvalidCount)
 
 		if err := c.Send(text); err != nil {
@@ -682,15 +734,22 @@ func (b *Bot) inputCountToBuy(c telebot.Context) error {
 
 	var text string
 
-	err = b.deps.portfoliosRepository.BuyInstrument(ctx, user.ID, instrument.ID, count, user.Metadata.InstrumentBuyPrice)
+	trade, err := b.deps.portfoliosRepository.BuyInstrument(ctx, user.ID, instrument.ID, count, user.Metadata.InstrumentBuyPrice)
 	switch {
 	case errors.Is(err, boterrs.ErrInsufficientFunds):
 		text = b.deps.dictionary.Text(user.LanguageCode, msgInsufficientFunds)
+	case errors.Is(err, boterrs.ErrInvalidLotCount):
+		text = b.deps.dictionary.Text(user.LanguageCode, msgInvalidLotCount, map[string]any{
+			"LotSize": instrument.LotSize,
+		})
+	case errors.Is(err, boterrs.ErrInactiveInstrument):
+		text = b.deps.dictionary.Text(user.LanguageCode, msgInactiveInstrument)
 	case err == nil:
 		text = b.deps.dictionary.Text(user.LanguageCode, msgSuccessfulBuy, map[string]any{
 			"Count":          count,
 			"InstrumentName": instrument.Name,
-			"Price":          user.Metadata.InstrumentBuyPrice,
+			"Price":          format.Price{Value: user.Metadata.InstrumentBuyPrice, Decimals: instrument.Decimals},
+			"Unit":           currencySign(instrument.Currency),
 		})
 	default:
 		return errs.NewStack(fmt.Errorf("failed to buy instrument: %v", err))
@@ -704,6 +763,10 @@ func (b *Bot) inputCountToBuy(c telebot.Context) error {
 		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
 	}
 
+	if trade != nil {
+		b.bus.Notify()
+	}
+
 	return nil
 }
 
@@ -731,15 +794,26 @@ func (b *Bot) inputCountToSell(c telebot.Context) error {
 
 	var text string
 
-	err = b.deps.portfoliosRepository.SellInstrument(ctx, user.ID, instrument.ID, count, user.Metadata.InstrumentSellPrice)
+	trade, err := b.deps.portfoliosRepository.SellInstrument(ctx, user.ID, instrument.ID, count, user.Metadata.InstrumentSellPrice)
 	switch {
 	case errors.Is(err, boterrs.ErrInsufficientFunds):
 		text = b.deps.dictionary.Text(user.LanguageCode, msgInsufficientFunds)
+	case errors.Is(err, boterrs.ErrInvalidLotCount):
+		text = b.deps.dictionary.Text(user.LanguageCode, msgInvalidLotCount, map[string]any{
+			"LotSize": instrument.LotSize,
+		})
+	case errors.Is(err, boterrs.ErrInactiveInstrument):
+		text = b.deps.dictionary.Text(user.LanguageCode, msgInactiveInstrument)
+	case errors.Is(err, boterrs.ErrForeignShort):
+		text = b.deps.dictionary.Text(user.LanguageCode, msgForeignShort, map[string]any{
+			"Currency": instrument.Currency,
+		})
 	case err == nil:
 		text = b.deps.dictionary.Text(user.LanguageCode, msgSuccessfulSell, map[string]any{
 			"Count":          count,
 			"InstrumentName": instrument.Name,
-			"Price":          user.Metadata.InstrumentSellPrice,
+			"Price":          format.Price{Value: user.Metadata.InstrumentSellPrice, Decimals: instrument.Decimals},
+			"Unit":           currencySign(instrument.Currency),
 		})
 	default:
 		return errs.NewStack(fmt.Errorf("failed sell instrument: %v", err))
@@ -753,6 +827,10 @@ func (b *Bot) inputCountToSell(c telebot.Context) error {
 		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
 	}
 
+	if trade != nil {
+		b.bus.Notify()
+	}
+
 	return nil
 }
 
@@ -766,10 +844,19 @@ func (b *Bot) inputTicker(c telebot.Context) error {
 	ticker := fmt.Sprintf("%s@MISX", strings.ToUpper(c.Text()))
 
 	instrument, err := b.deps.instrumentsRepository.GetInstrumentByTicker(ctx, ticker)
+	if err == nil && !instrument.Active {
+		text := b.deps.dictionary.Text(user.LanguageCode, msgInstrumentNotFound)
+
+		if err := c.Send(text); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+		}
+
+		return nil
+	}
 	if err == nil {
 		text := b.deps.dictionary.Text(user.LanguageCode, msgInstrumentFound)
 
-		markup := b.instrumentsListByPageKeyboard(user.LanguageCode, []*domain.Instrument{instrument}, 1, 1)
+		markup := b.instrumentsListByPageKeyboard(user.LanguageCode, "", []*domain.Instrument{instrument}, 1, 1)
 
 		if err := c.Send(text, &telebot.SendOptions{ReplyMarkup: markup}); err != nil {
 			return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
@@ -782,9 +869,9 @@ func (b *Bot) inputTicker(c telebot.Context) error {
 	}
 
 	// search in finam if ErrNoRows from repository
-	info, err := b.deps.finam.GetInstrumentInfo(ctx, ticker)
+	info, err := b.deps.marketData.GetInstrumentInfo(ctx, ticker)
 	if err != nil {
-		if errors.Is(err, finam.ErrNotFound) {
+		if errors.Is(err, boterrs.ErrInstrumentNotFound) {
 			text := b.deps.dictionary.Text(user.LanguageCode, msgInstrumentNotFound)
 
 			if err := c.Send(text); err != nil {
@@ -797,15 +884,19 @@ func (b *Bot) inputTicker(c telebot.Context) error {
 		return errs.NewStack(fmt.Errorf("failed to get instrument info: %v", err))
 	}
 
-	instrumentName := fmt.Sprintf("❔ %s", info.Name)
-	instrument, err = b.deps.instrumentsRepository.CreateInstrument(ctx, ticker, instrumentName)
+	info.Ticker = ticker
+	if info.Currency == "" {
+		info.Currency = domain.CurrencyRUB
+	}
+
+	instrument, err = b.deps.instrumentsRepository.CreateInstrument(ctx, info)
 	if err != nil {
 		return errs.NewStack(fmt.Errorf("failed to create instrument: %v", err))
 	}
 
 	text := b.deps.dictionary.Text(user.LanguageCode, msgInstrumentFound)
 
-	markup := b.instrumentsListByPageKeyboard(user.LanguageCode, []*domain.Instrument{instrument}, 1, 1)
+	markup := b.instrumentsListByPageKeyboard(user.LanguageCode, "", []*domain.Instrument{instrument}, 1, 1)
 
 	if err := c.Send(text, &telebot.SendOptions{ReplyMarkup: markup}); err != nil {
 		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
@@ -838,6 +929,8 @@ func (b *Bot) operationsHandler(c telebot.Context) error {
 		return errs.NewStack(fmt.Errorf("failed to get operations by page: %v", err))
 	}
 
+	location := b.userLocation(user)
+
 	var text strings.Builder
 
 	if len(operations) == 0 {
@@ -853,40 +946,82 @@ func (b *Bot) operationsHandler(c telebot.Context) error {
 		switch op.Type {
 		case domain.OperationTypeBuy:
 			text.WriteString(b.deps.dictionary.Text(user.LanguageCode, msgOperationBuy, map[string]any{
-				"OperationID": op.ID,
-				"Count":       op.Count,
-				"Name":        op.InstrumentName[strings.Index(op.InstrumentName, " ")+1:], // cut instrument emoji
-				"Amount":      op.TotalAmount,
+				"OperationID":       op.ID,
+				"Count":             op.Count,
+				"Name":              op.InstrumentName[strings.Index(op.InstrumentName, " ")+1:], // cut instrument emoji
+				"Amount":            money(op.TotalAmount, op.Currency),
+				"Date":              op.CreatedAt.In(location),
+				"CopiedOperationID": op.CopiedOperationID,
+				"CopiedFrom":        op.CopiedFrom,
 			}))
 
 		case domain.OperationTypeSell:
 			text.WriteString(b.deps.dictionary.Text(user.LanguageCode, msgOperationSell, map[string]any{
+				"OperationID":       op.ID,
+				"Count":             op.Count,
+				"Name":              op.InstrumentName[strings.Index(op.InstrumentName, " ")+1:], // cut instrument emoji
+				"Amount":            money(op.TotalAmount, op.Currency),
+				"Date":              op.CreatedAt.In(location),
+				"CopiedOperationID": op.CopiedOperationID,
+				"CopiedFrom":        op.CopiedFrom,
+			}))
+
+		case domain.OperationTypeFXBuy, domain.OperationTypeFXSell:
+			key := msgOperationFXBuy
+			if op.Type == domain.OperationTypeFXSell {
+				key = msgOperationFXSell
+			}
+
+			text.WriteString(b.deps.dictionary.Text(user.LanguageCode, key, map[string]any{
 				"OperationID": op.ID,
 				"Count":       op.Count,
 				"Name":        op.InstrumentName[strings.Index(op.InstrumentName, " ")+1:], // cut instrument emoji
-				"Amount":      op.TotalAmount,
+				"Amount":      money(op.TotalAmount, domain.BaseCurrency),
+				"Date":        op.CreatedAt.In(location),
 			}))
 
 		case domain.OperationTypeFee:
 			text.WriteString(b.deps.dictionary.Text(user.LanguageCode, msgOperationFee, map[string]any{
 				"OperationID": op.ParentID,
-				"Amount":      op.TotalAmount,
+				"Amount":      money(op.TotalAmount, op.Currency),
 			}))
 
 		case domain.OperationTypePromocode:
 			text.WriteString(b.deps.dictionary.Text(user.LanguageCode, msgOperationPromocode, map[string]any{
 				"Name":   op.InstrumentName,
-				"Amount": op.TotalAmount,
+				"Amount": money(op.TotalAmount, op.Currency),
+				"Date":   op.CreatedAt.In(location),
 			}))
 
 		case domain.OperationTypeDailyReward:
 			text.WriteString(b.deps.dictionary.Text(user.LanguageCode, msgOperationDailyReward, map[string]any{
-				"Amount": op.TotalAmount,
+				"Tier":   op.Count, // count of daily reward operation is the reward tier
+				"Amount": money(op.TotalAmount, op.Currency),
+				"Date":   op.CreatedAt.In(location),
+			}))
+
+		case domain.OperationTypeAchievement:
+			// name of achievement operation is the achievement ID
+			text.WriteString(b.deps.dictionary.Text(user.LanguageCode, msgOperationAchievement, map[string]any{
+				"Name":   b.deps.dictionary.Text(user.LanguageCode, achievementKeys[op.InstrumentName].name),
+				"Amount": money(op.TotalAmount, op.Currency),
+				"Date":   op.CreatedAt.In(location),
+			}))
+
+		case domain.OperationTypeCoupon:
+			// total of coupon operation is negative for shorts
+			text.WriteString(b.deps.dictionary.Text(user.LanguageCode, msgOperationCoupon, map[string]any{
+				"Name":   op.InstrumentName[strings.Index(op.InstrumentName, " ")+1:], // cut instrument emoji
+				"Count":  op.Count,
+				"Short":  op.TotalAmount < 0,
+				"Amount": money(op.TotalAmount, op.Currency),
+				"Date":   op.CreatedAt.In(location),
 			}))
 
 		case domain.OperationTypeDevAssistance:
 			text.WriteString(b.deps.dictionary.Text(user.LanguageCode, msgOperationDevAssistance, map[string]any{
-				"Amount": op.TotalAmount,
+				"Amount": money(op.TotalAmount, op.Currency),
+				"Date":   op.CreatedAt.In(location),
 			}))
 
 		default:
@@ -907,6 +1042,7 @@ func (b *Bot) operationsHandler(c telebot.Context) error {
 }
 
 func (b *Bot) buyHandler(c telebot.Context) error {
+	ctx := c.Get(ctxContext).(context.Context)
 	user := b.mustUser(c)
 
 	if user.Metadata.InstrumentTicker == "" {
@@ -917,19 +1053,34 @@ func (b *Bot) buyHandler(c telebot.Context) error {
 		return errs.NewStack(err)
 	}
 
+	instrument, err := b.deps.instrumentsRepository.GetInstrumentByTicker(ctx, user.Metadata.InstrumentTicker)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get instrument by ticker: %v", err))
+	}
+
+	instrumentPrices, err := b.getUserInstrumentPrices(ctx, user.Metadata.InstrumentTicker)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get instrument prices: %v", err))
+	}
+
 	user.Metadata.InputType = domain.InputTypeCount
 	user.Metadata.InstrumentOperation = domain.OperationTypeBuy
+	user.Metadata.InstrumentBuyPrice = instrument.UnitPrice(
+		trading.RoundToStep(instrumentPrices.Ask, instrument.MinStep), time.Now(),
+	)
 
 	maxCount, err := b.deps.portfoliosRepository.GetMaxInstrumentCountToBuy(
-		b.ctx, user.ID, user.Metadata.InstrumentTicker, user.Metadata.InstrumentBuyPrice,
+		ctx, user.ID, user.Metadata.InstrumentTicker, user.Metadata.InstrumentBuyPrice,
 	)
 	if err != nil {
 		return errs.NewStack(fmt.Errorf("failed to get max count to buy: %v", err))
 	}
 
 	text := b.deps.dictionary.Text(user.LanguageCode, msgEnterCountToBuy, map[string]any{
-		"Price":    user.Metadata.InstrumentBuyPrice,
+		"Price":    format.Price{Value: user.Metadata.InstrumentBuyPrice, Decimals: instrument.Decimals},
 		"MaxCount": maxCount,
+		"LotSize":  instrument.LotSize,
+		"Unit":     currencySign(instrument.Currency),
 	})
 
 	if err := c.Send(text); err != nil {
@@ -940,6 +1091,7 @@ func (b *Bot) buyHandler(c telebot.Context) error {
 }
 
 func (b *Bot) sellHandler(c telebot.Context) error {
+	ctx := c.Get(ctxContext).(context.Context)
 	user := b.mustUser(c)
 
 	if user.Metadata.InstrumentTicker == "" {
@@ -950,19 +1102,34 @@ func (b *Bot) sellHandler(c telebot.Context) error {
 		return errs.NewStack(err)
 	}
 
+	instrument, err := b.deps.instrumentsRepository.GetInstrumentByTicker(ctx, user.Metadata.InstrumentTicker)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get instrument by ticker: %v", err))
+	}
+
+	instrumentPrices, err := b.getUserInstrumentPrices(ctx, user.Metadata.InstrumentTicker)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get instrument prices: %v", err))
+	}
+
 	user.Metadata.InputType = domain.InputTypeCount
 	user.Metadata.InstrumentOperation = domain.OperationTypeSell
+	user.Metadata.InstrumentSellPrice = instrument.UnitPrice(
+		trading.RoundToStep(instrumentPrices.Bid, instrument.MinStep), time.Now(),
+	)
 
 	maxCount, err := b.deps.portfoliosRepository.GetMaxInstrumentCountToSell(
-		b.ctx, user.ID, user.Metadata.InstrumentTicker, user.Metadata.InstrumentSellPrice,
+		ctx, user.ID, user.Metadata.InstrumentTicker, user.Metadata.InstrumentSellPrice,
 	)
 	if err != nil {
 		return errs.NewStack(fmt.Errorf("failed to get max count to sell: %v", err))
 	}
 
 	text := b.deps.dictionary.Text(user.LanguageCode, msgEnterCountToSell, map[string]any{
-		"Price":    user.Metadata.InstrumentSellPrice,
+		"Price":    format.Price{Value: user.Metadata.InstrumentSellPrice, Decimals: instrument.Decimals},
 		"MaxCount": maxCount,
+		"LotSize":  instrument.LotSize,
+		"Unit":     currencySign(instrument.Currency),
 	})
 
 	if err := c.Send(text); err != nil {
@@ -973,6 +1140,7 @@ func (b *Bot) sellHandler(c telebot.Context) error {
 }
 
 func (b *Bot) dailyRewardHandler(c telebot.Context) error {
+	ctx := c.Get(ctxContext).(context.Context)
 	user := b.mustUser(c)
 
 	user.Metadata.InputType = ""
@@ -983,15 +1151,23 @@ func (b *Bot) dailyRewardHandler(c telebot.Context) error {
 	}
 
 	// update postgres data
-	if err := b.deps.usersRepository.ClaimDailyReward(b.ctx, user.ID, b.cfg.DailyReward); err != nil {
+	claim, err := b.deps.usersRepository.ClaimDailyReward(ctx, user.ID, b.dailyRewardTiers)
+	if err != nil {
 		return errs.NewStack(fmt.Errorf("failed to claim daily reward: %v", err))
 	}
 
 	// update cache data
-	user.AvailableBalance += b.cfg.DailyReward
+	user.AvailableBalance += claim.Amount
+	user.DailyReward = false
+	user.DailyRewardStreak = claim.Streak
+
+	b.bus.Notify()
 
 	text := b.deps.dictionary.Text(user.LanguageCode, msgDailyRewardClaimed, map[string]any{
-		"AvailableBalance": user.AvailableBalance,
+		"Amount":           money(claim.Amount, domain.BaseCurrency),
+		"Streak":           claim.Streak,
+		"Tier":             claim.Tier,
+		"AvailableBalance": money(user.AvailableBalance, domain.BaseCurrency),
 	})
 
 	markup := b.mainMenuKeyboard(user.LanguageCode)
@@ -1016,3 +1192,148 @@ func (b *Bot) instrumentSearchHandler(c telebot.Context) error {
 
 	return nil
 }
+
+func (b *Bot) apiTokenHandler(c telebot.Context) error {
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	user.Metadata.InputType = ""
+	user.Metadata.InstrumentOperation = ""
+
+	if err := b.closeInstrument(c, user); err != nil {
+		return errs.NewStack(err)
+	}
+
+	apiToken, tokenHash, err := token.Generate()
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to generate api token: %v", err))
+	}
+
+	if err := b.deps.tokensRepository.SetUserToken(ctx, user.ID, tokenHash); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to set user token: %v", err))
+	}
+
+	text := b.deps.dictionary.Text(user.LanguageCode, msgAPIToken, map[string]any{
+		"Token": apiToken,
+	})
+
+	if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+func (b *Bot) chartHandler(c telebot.Context) error {
+	defer c.Respond()
+
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	args := c.Args()
+
+	timeframe := defaultChartTimeframe
+	if len(args) == 2 {
+		timeframe = strings.ToLower(args[1])
+	}
+
+	if _, ok := chartWindows[timeframe]; !ok || len(args) == 0 || len(args) > 2 {
+		text := b.deps.dictionary.Text(user.LanguageCode, msgChartUsage)
+
+		if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+		}
+
+		return nil
+	}
+
+	ticker := strings.ToUpper(args[0])
+	if !strings.Contains(ticker, "@") {
+		ticker += "@MISX"
+	}
+
+	instrument, err := b.deps.instrumentsRepository.GetInstrumentByTicker(ctx, ticker)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			text := b.deps.dictionary.Text(user.LanguageCode, msgInstrumentNotFound)
+
+			if err := c.Send(text); err != nil {
+				return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+			}
+
+			return nil
+		}
+
+		return errs.NewStack(fmt.Errorf("failed to get instrument by ticker: %v", err))
+	}
+
+	photo, err := b.chartPhoto(ctx, user.LanguageCode, instrument, timeframe)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	if photo == nil {
+		text := b.deps.dictionary.Text(user.LanguageCode, msgChartNoData)
+
+		if err := c.Send(text); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+		}
+
+		return nil
+	}
+
+	opts := &telebot.SendOptions{
+		ReplyMarkup: b.chartKeyboard(user.LanguageCode, ticker, timeframe),
+		ParseMode:   telebot.ModeHTML,
+	}
+
+	// timeframe buttons of the chart replace the photo in place
+	if c.Callback() != nil && c.Message() != nil && c.Message().Photo != nil {
+		if err := c.Edit(photo, opts); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to edit message: %v", err))
+		}
+
+		return nil
+	}
+
+	if err := c.Send(photo, opts); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+// syncInstrumentsHandler syncs instruments on admin's demand, other users' commands are ignored.
+func (b *Bot) syncInstrumentsHandler(c telebot.Context) error {
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	if !b.isAdmin(user.ID) {
+		return nil
+	}
+
+	result, err := b.syncInstruments(ctx)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	text := b.deps.dictionary.Text(user.LanguageCode, msgInstrumentsSynced, map[string]any{
+		"Added":       result.Added,
+		"Updated":     result.Updated,
+		"Deactivated": result.Deactivated,
+	})
+
+	if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+func (b *Bot) listInstrumentHandler(c telebot.Context) error {
+	return b.setInstrumentListed(c, true)
+}
+
+func (b *Bot) unlistInstrumentHandler(c telebot.Context) error {
+	return b.setInstrumentListed(c, false)
+}
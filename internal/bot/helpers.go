@@ -1,22 +1,34 @@
 package bot
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "time/tzdata"
 
+	"github.com/leonid6372/success-bot/internal/boterrs"
 	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
+	"github.com/leonid6372/success-bot/internal/common/trading"
 	"github.com/leonid6372/success-bot/pkg/errs"
 	"github.com/leonid6372/success-bot/pkg/log"
+	"github.com/leonid6372/success-bot/pkg/tracing"
 	"go.uber.org/zap"
 	"gopkg.in/telebot.v4"
 )
 
+// dailyRewardCheckInterval is a period of daily reward checks, offsets of all time zones are its multiples.
+const dailyRewardCheckInterval = 15 * time.Minute
+
 // setupCacheUpdater setups a goroutine that updates instruments cache every minute.
 // Also updates user's blocked balances and top users list using actual instrument prices.
+// Update is processed by the leader instance only.
 func (b *Bot) setupCacheUpdater() {
 	for {
 		select {
@@ -25,241 +37,449 @@ func (b *Bot) setupCacheUpdater() {
 			return
 
 		default:
-			// update instuments cache
-			tickers, err := b.deps.portfoliosRepository.GetUsersInstrumentTickers(b.ctx)
-			if err != nil {
-				log.Error("failed to get users instrument tickers", zap.Error(err))
-				continue
+			if b.leader.IsLeader() {
+				start := time.Now()
+				b.updateCache()
+				metrics.CacheUpdaterDuration.Observe(time.Since(start).Seconds())
 			}
+		}
 
-			for _, ticker := range tickers {
-				instrument, err := b.deps.finam.GetInstrumentPrices(b.ctx, ticker)
-				if err != nil {
-					log.Error("failed to get instrument prices from finam", zap.String("ticker", ticker), zap.Error(err))
-					continue
-				}
+		time.Sleep(1 * time.Minute)
+	}
+}
 
-				b.usersInstruments.SetDefault(ticker, instrument)
-			}
+func (b *Bot) updateCache() {
+	ctx, span := tracing.Start(domain.ContextWithActor(b.ctx, domain.ActorCacheUpdater), "bot.updateCache")
+	defer span.End()
 
-			usersCount, err := b.deps.usersRepository.GetUsersCount(b.ctx)
-			if err != nil {
-				log.Error("failed to get users count", zap.Error(err))
-				continue
-			}
+	// update instuments cache
+	tickers, err := b.deps.portfoliosRepository.GetUsersInstrumentTickers(ctx)
+	if err != nil {
+		log.Error("failed to get users instrument tickers", zap.Error(err))
+		return
+	}
 
-			topUsersData, err := b.deps.usersRepository.GetTopUsersData(b.ctx)
-			if err != nil {
-				log.Error("failed to get top users data", zap.Error(err))
-				continue
-			}
+	for _, ticker := range tickers {
+		instrument, err := b.deps.marketData.GetInstrumentPrices(ctx, ticker)
+		if err != nil {
+			log.Error("failed to get instrument prices from finam", zap.String("ticker", ticker), zap.Error(err))
+			continue
+		}
 
-			mapTopUsers := make(map[string]*domain.TopUser, usersCount)
-
-			for _, data := range topUsersData {
-				if _, ok := mapTopUsers[data.Username]; !ok {
-					mapTopUsers[data.Username] = &domain.TopUser{
-						ID:                 data.ID,
-						Username:           data.Username,
-						LanguageCode:       data.LanguageCode,
-						AvailableBalance:   data.AvailableBalance,
-						BlockedBalance:     data.BlockedBalance,
-						BlockedBalanceDiff: data.BlockedBalance,
-						MarginCall:         data.MarginCall,
-					}
-				}
-
-				if data.Ticker == "" {
-					continue
-				}
-
-				instrument, err := b.getUserInstrumentPrices(b.ctx, data.Ticker)
-				if err != nil {
-					log.Error("failed to get instrument prices", zap.String("ticker", data.Ticker), zap.Error(err))
-					continue
-				}
-
-				if data.Count >= 0 {
-					mapTopUsers[data.Username].TotalBalance += instrument.Last * float64(data.Count)
-					continue
-				}
-
-				mapTopUsers[data.Username].BlockedBalanceDiff +=
-					instrument.Last * float64(data.Count) * 0.5 // 50% guarantee coverage
-			}
+		if err := b.setInstrumentPrices(ctx, ticker, instrument); err != nil {
+			log.Error("failed to set instrument prices", zap.String("ticker", ticker), zap.Error(err))
+		}
+	}
+
+	usersCount, err := b.deps.usersRepository.GetUsersCount(ctx)
+	if err != nil {
+		log.Error("failed to get users count", zap.Error(err))
+		return
+	}
+
+	topUsersData, err := b.deps.usersRepository.GetTopUsersData(ctx)
+	if err != nil {
+		log.Error("failed to get top users data", zap.Error(err))
+		return
+	}
 
-			topUsers := make([]*domain.TopUser, 0, len(mapTopUsers))
-			for _, topUser := range mapTopUsers {
-				topUser.AvailableBalance += topUser.BlockedBalanceDiff
-				topUser.BlockedBalance -= topUser.BlockedBalanceDiff
-
-				if !topUser.MarginCall && topUser.AvailableBalance < 0 {
-					topUser.MarginCall = true
-
-					b.Telebot.Send(
-						&telebot.User{ID: topUser.ID},
-						b.deps.dictionary.Text(topUser.LanguageCode, msgMarginCall),
-						&telebot.SendOptions{ParseMode: telebot.ModeHTML},
-					)
-				}
-
-				if topUser.MarginCall && topUser.AvailableBalance >= 0 {
-					topUser.MarginCall = false
-				}
-
-				topUser.TotalBalance += topUser.AvailableBalance + topUser.BlockedBalance
-
-				topUsers = append(topUsers, topUser)
-
-				// Update data in repository
-				if err := b.deps.usersRepository.UpdateUserBalancesAndMarginCall(
-					b.ctx, topUser.ID, topUser.AvailableBalance, &topUser.BlockedBalanceDiff, &topUser.MarginCall,
-				); err != nil {
-					log.Error("failed to update user balances and margin call", zap.Int64("user_id", topUser.ID), zap.Error(err))
-				}
-
-				// Update data in cache
-				rawUser, ok := b.users.Get(topUser.ID)
-				if ok {
-					user := rawUser.(*domain.User)
-					user.AvailableBalance = topUser.AvailableBalance
-					user.BlockedBalance = topUser.BlockedBalance
-					user.MarginCall = topUser.MarginCall
-				}
+	mapTopUsers := make(map[string]*domain.TopUser, usersCount)
+	positions := make(map[string][]trading.PricedPosition, usersCount)
+
+	for _, data := range topUsersData {
+		if _, ok := mapTopUsers[data.Username]; !ok {
+			mapTopUsers[data.Username] = &domain.TopUser{
+				ID:               data.ID,
+				Username:         data.Username,
+				LanguageCode:     data.LanguageCode,
+				AvailableBalance: data.AvailableBalance,
+				BlockedBalance:   data.BlockedBalance,
+				MarginCall:       data.MarginCall,
 			}
+		}
 
-			// Sort by balance descending
-			sort.Slice(topUsers, func(i, j int) bool {
-				return topUsers[i].TotalBalance > topUsers[j].TotalBalance
-			})
+		if data.Ticker == "" {
+			continue
+		}
 
-			b.mu.Lock()
-			b.topUsers = make([]*domain.TopUser, len(topUsers))
-			copy(b.topUsers, topUsers)
-			b.mu.Unlock()
+		prices, err := b.getUserInstrumentUnitPrices(ctx, data.Ticker)
+		if err != nil {
+			log.Error("failed to get instrument prices", zap.String("ticker", data.Ticker), zap.Error(err))
+			continue
 		}
 
-		time.Sleep(1 * time.Minute)
+		// positions are valued in the base currency
+		rate, err := b.getCurrencyRate(ctx, data.Currency)
+		if err != nil {
+			log.Error("failed to get currency rate", zap.String("currency", data.Currency), zap.Error(err))
+			continue
+		}
+
+		positions[data.Username] = append(positions[data.Username], trading.PricedPosition{
+			Position: trading.Position{Count: data.Count},
+			Last:     prices.Last * rate,
+		})
+	}
+
+	currencyBalances, err := b.deps.portfoliosRepository.GetCurrencyBalances(ctx)
+	if err != nil {
+		log.Error("failed to get currency balances", zap.Error(err))
+		return
+	}
+
+	cash := make(map[int64]float64, len(currencyBalances)) // user ID -> currency balances value in base currency
+	for _, balance := range currencyBalances {
+		rate, err := b.getCurrencyRate(ctx, balance.Currency)
+		if err != nil {
+			log.Error("failed to get currency rate", zap.String("currency", balance.Currency), zap.Error(err))
+			continue
+		}
+
+		cash[balance.UserID] += balance.Amount * rate
+	}
+
+	// badges are shown next to names in top users list
+	unlockedAchievements, err := b.deps.achievementsRepository.GetUnlockedAchievements(ctx)
+	if err != nil {
+		log.Error("failed to get unlocked achievements", zap.Error(err))
+	}
+
+	topUsers := make([]*domain.TopUser, 0, len(mapTopUsers))
+	for _, topUser := range mapTopUsers {
+		rev := trading.Revalue(trading.Balances{
+			Available: topUser.AvailableBalance,
+			Blocked:   topUser.BlockedBalance,
+		}, positions[topUser.Username])
+
+		topUser.AvailableBalance = rev.Available
+		topUser.BlockedBalance = rev.Blocked
+		topUser.BlockedBalanceDiff = rev.BlockedDiff
+		topUser.TotalBalance = rev.Total + cash[topUser.ID]
+		topUser.MarginCall = rev.MarginCall
+
+		topUser.Badges = domain.Badges(unlockedAchievements[topUser.ID])
+
+		topUsers = append(topUsers, topUser)
+
+		// Update data in repository by the blocked balance diff, so changes committed after the snapshot are kept,
+		// changes of margin call are published by repository
+		updated, err := b.deps.usersRepository.UpdateUserBalancesAndMarginCall(ctx, topUser.ID, rev.BlockedDiff)
+		if err != nil {
+			log.Error("failed to update user balances and margin call", zap.Int64("user_id", topUser.ID), zap.Error(err))
+		} else {
+			topUser.AvailableBalance = updated.AvailableBalance
+			topUser.BlockedBalance = updated.BlockedBalance
+			topUser.MarginCall = updated.MarginCall
+		}
+
+		// Update data in cache keeping user's session expiration
+		user, ok, err := b.getCachedUser(ctx, topUser.ID)
+		if err != nil {
+			log.Error("failed to get user from cache", zap.Int64("user_id", topUser.ID), zap.Error(err))
+		}
+
+		if ok {
+			user.AvailableBalance = topUser.AvailableBalance
+			user.BlockedBalance = topUser.BlockedBalance
+			user.MarginCall = topUser.MarginCall
+
+			if _, err := b.cache.Update(ctx, userKey(user.ID), user); err != nil {
+				log.Error("failed to update user in cache", zap.Int64("user_id", topUser.ID), zap.Error(err))
+			}
+		}
+	}
+
+	// Sort by balance descending
+	sort.Slice(topUsers, func(i, j int) bool {
+		return topUsers[i].TotalBalance > topUsers[j].TotalBalance
+	})
+
+	if err := b.cache.Set(ctx, topUsersKey, topUsers, 0); err != nil {
+		log.Error("failed to set top users", zap.Error(err))
 	}
 }
 
-// setupDailyProcessor setups a goroutine that processes daily tasks at 23:45 Moscow time.
-// It processes stop-out for users with margin call and daily reward messages.
+// setupDailyProcessor setups a goroutine that processes daily tasks: stop-out for users with margin call
+// at 23:45 Moscow time and daily rewards at domain.DailyRewardHour in users' time zones, which are checked
+// every dailyRewardCheckInterval. Tasks are processed by the leader instance only.
 func (b *Bot) setupDailyProcessor() {
 	moscow, _ := time.LoadLocation("Europe/Moscow")
-	t := time.Now().In(moscow)
-
-	dailyRewardT := t.Add(24 * time.Hour) // daily reawrd start tomorrow
-	stopOutT := t
+	stopOutT := time.Now().In(moscow)
 
 	for {
-		dailyRewardAt := time.Date(dailyRewardT.Year(), dailyRewardT.Month(), dailyRewardT.Day(), 8, 0, 0, 0, moscow)
-		dailyRewardCh := time.NewTimer(time.Until(dailyRewardAt))
+		// checks are aligned to the interval, so they match local hours in every time zone
+		now := time.Now()
+		dailyRewardAt := now.Truncate(dailyRewardCheckInterval).Add(dailyRewardCheckInterval)
+		dailyRewardCh := time.NewTimer(dailyRewardAt.Sub(now))
 
 		stopOutAt := time.Date(stopOutT.Year(), stopOutT.Month(), stopOutT.Day(), 23, 45, 0, 0, moscow)
 		stopOutCh := time.NewTimer(time.Until(stopOutAt))
 
-	outerLoop:
-		for {
-			select {
-			case <-b.ctx.Done():
-				log.Info("stop-out processor shutting down...")
-				return
-
-			case <-dailyRewardCh.C:
-				users, err := b.deps.usersRepository.GetUsersClaimedDailyReward(b.ctx)
-				if err != nil {
-					log.Error("failed to get users claimed daily reward", zap.Error(err))
-					continue
-				}
-
-				if err := b.deps.usersRepository.ResetDailyReward(b.ctx); err != nil {
-					log.Error("failed to reset daily reward for all users", zap.Error(err))
-					continue
-				}
-
-				for _, user := range users {
-					text := b.deps.dictionary.Text(user.LanguageCode, msgDailyReward, map[string]any{
-						"Amount": b.cfg.DailyReward,
-					})
-
-					markup := b.dailyRewardKeyboard(user.LanguageCode)
-
-					if _, err := b.Telebot.Send(&telebot.User{ID: user.ID},
-						text,
-						&telebot.SendOptions{ReplyMarkup: markup, ParseMode: telebot.ModeHTML},
-					); err != nil {
-						log.Error("failed to send message", zap.String("username", user.Username), zap.Error(err))
-					}
-				}
-
-				dailyRewardT = dailyRewardT.Add(24 * time.Hour)
-
-				break outerLoop
-
-			case <-stopOutCh.C:
-				b.mu.RLock()
-				for _, topUser := range b.topUsers {
-					if topUser.MarginCall {
-						userShort, err := b.deps.portfoliosRepository.GetUserMostExpensiveShort(b.ctx, topUser.ID)
-						if err != nil {
-							log.Error("failed to get user most expensive short",
-								zap.String("username", topUser.Username),
-								zap.Error(err),
-							)
-
-							continue
-						}
-
-						instrument, err := b.deps.finam.GetInstrumentPrices(b.ctx, userShort.Ticker)
-						if err != nil {
-							log.Error("failed to get instrument prices",
-								zap.String("ticker", userShort.Ticker),
-								zap.Error(err),
-							)
-
-							continue
-						}
-
-						closeCount := int64(0)
-						for i := int64(1); i <= -userShort.Count; i++ {
-							if instrument.Last*0.497*float64(i)-((instrument.Last-userShort.AvgPrice)*float64(i)) >= -topUser.AvailableBalance { // 50% guarantee coverage and 0,3% fee for buying
-								closeCount = i
-								break
-							}
-						}
-
-						if err := b.deps.portfoliosRepository.BuyInstrument(
-							b.ctx, topUser.ID, userShort.ID, closeCount, instrument.Last,
-						); err != nil {
-							log.Error("failed to buy instrument",
-								zap.String("username", topUser.Username),
-								zap.String("ticker", userShort.Ticker),
-								zap.Error(err),
-							)
-
-							continue
-						}
-					}
-				}
-
-				b.mu.RUnlock()
-
-				stopOutT = stopOutT.Add(24 * time.Hour)
-
-				break outerLoop
+		select {
+		case <-b.ctx.Done():
+			log.Info("stop-out processor shutting down...")
+			return
+
+		case <-dailyRewardCh.C:
+			if b.leader.IsLeader() {
+				b.processDailyReward(dailyRewardAt)
+				b.processDeferredMarginCallWarnings(dailyRewardAt)
+				b.processCouponPayout(dailyRewardAt)
+			}
+
+		case <-stopOutCh.C:
+			if b.leader.IsLeader() {
+				b.processStopOut()
+				b.processTopFinish()
 			}
+
+			stopOutT = stopOutT.Add(24 * time.Hour)
 		}
+
+		dailyRewardCh.Stop()
+		stopOutCh.Stop()
 	}
 }
 
-func (b *Bot) closeInstrument(c telebot.Context, user *domain.User) error {
-	if user.Metadata.InstrumentDone == nil {
+// couponPayoutLookback limits the payout to recent coupons, so past coupons of newly synced bonds and coupons
+// dated before the payout was introduced aren't paid to current holders.
+const couponPayoutLookback = 7 * 24 * time.Hour
+
+// processCouponPayout pays coupons of bonds dated up to t. Accrued interest of a bond drops to zero on the coupon
+// date, so holders get the coupon as cash instead.
+func (b *Bot) processCouponPayout(t time.Time) {
+	ctx, span := tracing.Start(domain.ContextWithActor(b.ctx, domain.ActorCouponPayout), "bot.processCouponPayout")
+	defer span.End()
+
+	// coupon dates are UTC days, see domain.Instrument.AccruedInterest
+	t = t.UTC()
+
+	paid, err := b.deps.portfoliosRepository.PayCoupons(ctx, t.Add(-couponPayoutLookback), t)
+	if err != nil {
+		log.Error("failed to pay coupons", zap.Error(err))
+		return
+	}
+
+	if paid > 0 {
+		log.Info("coupons paid", zap.Int64("count", paid))
+	}
+}
+
+// processDailyReward resets daily rewards of users whose local time at t is the reward hour, streaks of
+// users who missed the reward are reset too. Users who claimed the reward are reminded about the next one.
+// The reward hour is shifted to the end of user's quiet hours, so the reminder isn't lost. Every user is reset
+// once per local day, even if the time zone or quiet hours are changed after the reset.
+func (b *Bot) processDailyReward(t time.Time) {
+	ctx, span := tracing.Start(b.ctx, "bot.processDailyReward")
+	defer span.End()
+
+	users, err := b.deps.usersRepository.GetAllUsers(ctx)
+	if err != nil {
+		log.Error("failed to get users", zap.Error(err))
+		return
+	}
+
+	due := make(map[int64]*domain.User)
+	days := make(map[int64]time.Time)
+	for _, user := range users {
+		local := t.In(b.userLocation(user))
+
+		if local.Hour() == user.Settings.NotificationHour(domain.DailyRewardHour) &&
+			local.Minute() < int(dailyRewardCheckInterval.Minutes()) {
+			due[user.ID] = user
+			days[user.ID] = local
+		}
+	}
+
+	if len(due) == 0 {
+		return
+	}
+
+	// users already reset on their local day aren't returned
+	resetIDs, err := b.deps.usersRepository.ResetDailyReward(ctx, days)
+	if err != nil {
+		log.Error("failed to reset daily reward", zap.Error(err))
+		return
+	}
+
+	for _, userID := range resetIDs {
+		user := due[userID]
+
+		// the reward wasn't claimed, so the streak is lost
+		if user.DailyReward {
+			continue
+		}
+
+		streak := user.DailyRewardStreak + 1
+		_, amount := b.dailyRewardTiers.Reward(streak)
+
+		text := b.deps.dictionary.Text(user.LanguageCode, msgDailyReward, map[string]any{
+			"Amount": money(amount, domain.BaseCurrency),
+			"Streak": streak,
+		})
+
+		markup := b.dailyRewardKeyboard(user.LanguageCode)
+
+		if err := b.notify(user, domain.NotificationDailyReward, text,
+			&telebot.SendOptions{ReplyMarkup: markup, ParseMode: telebot.ModeHTML},
+		); err != nil {
+			log.Error("failed to notify about daily reward", zap.String("username", user.Username), zap.Error(err))
+		}
+	}
+}
+
+// processDeferredMarginCallWarnings sends margin call warnings deferred by quiet hours to users whose quiet hours
+// are over at t. Warnings of cleared margin calls are dropped by the repository.
+func (b *Bot) processDeferredMarginCallWarnings(t time.Time) {
+	ctx, span := tracing.Start(b.ctx, "bot.processDeferredMarginCallWarnings")
+	defer span.End()
+
+	users, err := b.deps.usersRepository.GetAllUsers(ctx)
+	if err != nil {
+		log.Error("failed to get users", zap.Error(err))
+		return
+	}
+
+	due := make(map[int64]*domain.User)
+	userIDs := []int64{}
+	for _, user := range users {
+		if user.MarginCall && !user.Settings.IsQuietHour(t.In(b.userLocation(user)).Hour()) {
+			due[user.ID] = user
+			userIDs = append(userIDs, user.ID)
+		}
+	}
+
+	if len(userIDs) == 0 {
+		return
+	}
+
+	takenIDs, err := b.deps.usersRepository.TakeDeferredMarginCallWarnings(ctx, userIDs)
+	if err != nil {
+		log.Error("failed to take deferred margin call warnings", zap.Error(err))
+		return
+	}
+
+	for _, userID := range takenIDs {
+		user := due[userID]
+
+		if err := b.notify(user, domain.NotificationMarginCall,
+			b.deps.dictionary.Text(user.LanguageCode, msgMarginCall),
+			&telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		); err != nil {
+			log.Error("failed to notify about margin call", zap.String("username", user.Username), zap.Error(err))
+		}
+	}
+}
+
+// notifyMarginCall warns the user about margin call if the user allows it. The warning of quiet hours is deferred
+// to their end like the daily reward and is dropped if the margin call is cleared before.
+func (b *Bot) notifyMarginCall(ctx context.Context, userID int64) error {
+	user, err := b.deps.usersRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get user: %v", err))
+	}
+
+	if !user.Settings.IsMuted(domain.NotificationMarginCall) &&
+		user.Settings.IsQuietHour(time.Now().In(b.userLocation(user)).Hour()) {
+		if err := b.deps.usersRepository.DeferMarginCallWarning(ctx, user.ID); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to defer margin call warning: %v", err))
+		}
+
+		return nil
+	}
+
+	if err := b.notify(user, domain.NotificationMarginCall,
+		b.deps.dictionary.Text(user.LanguageCode, msgMarginCall),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML},
+	); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to notify about margin call: %v", err))
+	}
+
+	return nil
+}
+
+// notify sends a message the user didn't request. The message of a muted category or during user's quiet
+// hours isn't sent.
+func (b *Bot) notify(user *domain.User, category, text string, opts *telebot.SendOptions) error {
+	if !user.Settings.CanNotify(category, time.Now(), b.userLocation(user)) {
 		return nil
 	}
 
-	close(*user.Metadata.InstrumentDone)
-	user.Metadata.InstrumentDone = nil
+	if _, err := b.Telebot.Send(&telebot.User{ID: user.ID}, text, opts); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+func (b *Bot) processStopOut() {
+	ctx, span := tracing.Start(domain.ContextWithActor(b.ctx, domain.ActorStopOut), "bot.processStopOut")
+	defer span.End()
+
+	topUsers, err := b.getTopUsers(ctx)
+	if err != nil {
+		log.Error("failed to get top users", zap.Error(err))
+		return
+	}
+
+	for _, topUser := range topUsers {
+		if topUser.MarginCall {
+			userShort, err := b.deps.portfoliosRepository.GetUserMostExpensiveShort(ctx, topUser.ID)
+			if err != nil {
+				log.Error("failed to get user most expensive short",
+					zap.String("username", topUser.Username),
+					zap.Error(err),
+				)
+
+				continue
+			}
+
+			quotes, err := b.deps.marketData.GetInstrumentPrices(ctx, userShort.Ticker)
+			if err != nil {
+				log.Error("failed to get instrument prices",
+					zap.String("ticker", userShort.Ticker),
+					zap.Error(err),
+				)
+
+				continue
+			}
+
+			instrument, err := b.getInstrumentInfo(ctx, userShort.Ticker)
+			if err != nil {
+				log.Error("failed to get instrument info",
+					zap.String("ticker", userShort.Ticker),
+					zap.Error(err),
+				)
+
+				continue
+			}
+
+			last := instrument.UnitPrice(quotes.Last, time.Now())
+
+			closeCount := trading.StopOutCount(trading.Position{
+				Count:    userShort.Count,
+				AvgPrice: userShort.AvgPrice,
+			}, last, topUser.AvailableBalance, userShort.LotSize)
+
+			if _, err := b.deps.portfoliosRepository.BuyInstrument(ctx, topUser.ID, userShort.ID, closeCount, last); err != nil {
+				log.Error("failed to buy instrument",
+					zap.String("username", topUser.Username),
+					zap.String("ticker", userShort.Ticker),
+					zap.Error(err),
+				)
+
+				continue
+			}
+
+			b.bus.Notify()
+		}
+	}
+}
+
+func (b *Bot) closeInstrument(c telebot.Context, user *domain.User) error {
+	if !b.stopInstrumentWatcher(user.ID) {
+		return nil
+	}
 
 	text := b.deps.dictionary.Text(user.LanguageCode, msgInstrumentExit)
 
@@ -329,3 +549,68 @@ func (b *Bot) addPaginationCbkButtons(
 
 	return rows
 }
+
+// userLocation returns user's time zone, time zone of user's language is used if the user hasn't chosen one.
+func (b *Bot) userLocation(user *domain.User) *time.Location {
+	return user.Settings.Location(b.deps.dictionary.Locale(user.LanguageCode).Location())
+}
+
+// shortTicker returns the ticker without its exchange, e.g. SBER for SBER@MISX.
+func shortTicker(ticker string) string {
+	symbol, _, _ := strings.Cut(ticker, "@")
+	return symbol
+}
+
+func (b *Bot) isAdmin(tgID int64) bool {
+	return slices.Contains(b.cfg.Admins, tgID)
+}
+
+// setInstrumentListed shows or hides the instrument from command argument in instruments list.
+// Commands of users who are not admins are ignored.
+func (b *Bot) setInstrumentListed(c telebot.Context, listed bool) error {
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	if !b.isAdmin(user.ID) {
+		return nil
+	}
+
+	args := c.Args()
+	if len(args) != 1 {
+		text := b.deps.dictionary.Text(user.LanguageCode, msgInstrumentListedUsage)
+
+		if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+		}
+
+		return nil
+	}
+
+	ticker := strings.ToUpper(args[0])
+	if !strings.Contains(ticker, "@") {
+		ticker += "@MISX"
+	}
+
+	key := msgInstrumentUnlisted
+	if listed {
+		key = msgInstrumentListed
+	}
+
+	err := b.deps.instrumentsRepository.SetInstrumentListed(ctx, ticker, listed)
+	switch {
+	case errors.Is(err, boterrs.ErrInstrumentNotFound):
+		key = msgInstrumentNotFound
+	case err != nil:
+		return errs.NewStack(fmt.Errorf("failed to set instrument listed: %v", err))
+	}
+
+	text := b.deps.dictionary.Text(user.LanguageCode, key, map[string]any{
+		"Ticker": ticker,
+	})
+
+	if err := c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/dictionary"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/format"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	inlineQueryPortfolio = "portfolio"
+	// inlineQuotesCount limits quotes of the user's positions suggested for empty inline query
+	inlineQuotesCount = 10
+	// inlineCacheTime is seconds Telegram caches inline results, quotes are cached by the bot for a minute
+	inlineCacheTime = 10
+	// inlineStartParameter is the /start payload of the button shown to unregistered users
+	inlineStartParameter = "inline"
+)
+
+// inlineQueryHandler answers inline queries in any chat: "@bot SBER" with the instrument quote and
+// "@bot portfolio" with the summary card of the user's performance. Empty query suggests the card
+// and quotes of the user's positions. Unknown tickers get no results.
+func (b *Bot) inlineQueryHandler(c telebot.Context) error {
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	query := strings.TrimSpace(c.Query().Text)
+
+	results := telebot.Results{}
+
+	switch {
+	case query == "":
+		card, err := b.inlinePortfolioResult(ctx, user)
+		if err != nil {
+			return errs.NewStack(err)
+		}
+
+		results = append(results, card)
+
+		positions, err := b.deps.portfoliosRepository.GetUserPortfolio(ctx, user.ID)
+		if err != nil {
+			return errs.NewStack(fmt.Errorf("failed to get user portfolio: %v", err))
+		}
+
+		for _, position := range positions[:min(inlineQuotesCount, len(positions))] {
+			result, err := b.inlineQuoteResult(ctx, user, position.Ticker)
+			if err != nil {
+				// the card is shared even if a quote isn't available
+				log.Error("failed to get inline quote", zap.String("ticker", position.Ticker), zap.Error(err))
+				continue
+			}
+
+			if result != nil {
+				results = append(results, result)
+			}
+		}
+
+	case strings.EqualFold(query, inlineQueryPortfolio):
+		card, err := b.inlinePortfolioResult(ctx, user)
+		if err != nil {
+			return errs.NewStack(err)
+		}
+
+		results = append(results, card)
+
+	default:
+		ticker := strings.ToUpper(query)
+		if !strings.Contains(ticker, "@") {
+			ticker += "@MISX"
+		}
+
+		result, err := b.inlineQuoteResult(ctx, user, ticker)
+		if err != nil {
+			return errs.NewStack(err)
+		}
+
+		if result != nil {
+			results = append(results, result)
+		}
+	}
+
+	if err := c.Answer(&telebot.QueryResponse{
+		Results:    results,
+		CacheTime:  inlineCacheTime,
+		IsPersonal: true,
+	}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to answer inline query: %v", err))
+	}
+
+	return nil
+}
+
+// inlineQuoteResult returns the quote of the active instrument by prices from cache, nil if the instrument
+// isn't found.
+func (b *Bot) inlineQuoteResult(ctx context.Context, user *domain.User, ticker string) (telebot.Result, error) {
+	instrument, err := b.deps.instrumentsRepository.GetInstrumentByTicker(ctx, ticker)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errs.NewStack(fmt.Errorf("failed to get instrument: %v", err))
+	}
+
+	if !instrument.Active {
+		return nil, nil
+	}
+
+	prices, err := b.getUserInstrumentPrices(ctx, ticker)
+	if err != nil {
+		return nil, errs.NewStack(fmt.Errorf("failed to get instrument prices: %v", err))
+	}
+
+	color := ""
+	switch {
+	case prices.Change > 0:
+		color = "🟢"
+	case prices.Change < 0:
+		color = "🔴"
+	}
+
+	var changePercent float64
+	if prevClose := prices.Last - prices.Change; prevClose != 0 {
+		changePercent = prices.Change / prevClose * 100
+	}
+
+	symbol := shortTicker(ticker)
+
+	text := b.deps.dictionary.Text(user.LanguageCode, msgInlineQuote, map[string]any{
+		"Name":   instrument.Name,
+		"Ticker": symbol,
+		"Quote":  b.quoteText(user.LanguageCode, color, prices, nil, instrument),
+	})
+
+	result := &telebot.ArticleResult{
+		Title: fmt.Sprintf("%s (%s)", instrument.Name, symbol),
+		Description: b.deps.dictionary.Text(user.LanguageCode, msgInlineQuoteDescription, map[string]any{
+			"Price":  format.Price{Value: prices.Last, Decimals: instrument.Decimals},
+			"Unit":   quoteUnit(instrument),
+			"Change": changePercent,
+		}),
+	}
+	result.SetResultID("quote:" + ticker)
+	result.SetContent(&telebot.InputTextMessageContent{Text: text, ParseMode: telebot.ModeHTML})
+
+	return result, nil
+}
+
+// inlinePortfolioResult returns the card with the user's total balance, rank and positions with their returns.
+func (b *Bot) inlinePortfolioResult(ctx context.Context, user *domain.User) (telebot.Result, error) {
+	topUsers, err := b.getTopUsers(ctx)
+	if err != nil {
+		return nil, errs.NewStack(fmt.Errorf("failed to get top users: %v", err))
+	}
+
+	// users who haven't got into the top yet are shown without rank
+	var rank any = "—"
+	badges := ""
+	totalBalance := user.AvailableBalance + user.BlockedBalance
+	for i, topUser := range topUsers {
+		if topUser.ID == user.ID {
+			rank, totalBalance, badges = i+1, topUser.TotalBalance, topUser.Badges
+			break
+		}
+	}
+
+	portfolio, err := b.portfolioSummary(ctx, user.LanguageCode, user.ID)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	values := map[string]any{
+		"Username":     user.Username,
+		"Badges":       badges,
+		"TotalBalance": money(totalBalance, domain.BaseCurrency),
+		"Rank":         rank,
+		"Portfolio":    portfolio,
+	}
+
+	result := &telebot.ArticleResult{
+		Title:       b.deps.dictionary.Text(user.LanguageCode, msgInlinePortfolioTitle),
+		Description: b.deps.dictionary.Text(user.LanguageCode, msgInlinePortfolioDescription, values),
+	}
+	result.SetResultID(inlineQueryPortfolio)
+	result.SetContent(&telebot.InputTextMessageContent{
+		Text:      b.deps.dictionary.Text(user.LanguageCode, msgInlinePortfolio, values),
+		ParseMode: telebot.ModeHTML,
+	})
+
+	return result, nil
+}
+
+// sendInlineStart answers inline query of the user who can't use the bot yet with the button opening
+// private chat with the bot.
+func (b *Bot) sendInlineStart(c telebot.Context) error {
+	if err := c.Answer(&telebot.QueryResponse{
+		Results:    telebot.Results{},
+		IsPersonal: true,
+		Button: &telebot.QueryResponseButton{
+			Text:  b.deps.dictionary.Text(dictionary.DefaultLanguage, msgInlineStart),
+			Start: inlineStartParameter,
+		},
+	}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to answer inline query: %v", err))
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"github.com/leonid6372/success-bot/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+// setupInstrumentsSync setups a goroutine that syncs tradable instruments by configured interval.
+// Sync is processed by the leader instance only.
+func (b *Bot) setupInstrumentsSync() {
+	ticker := time.NewTicker(b.cfg.InstrumentsSync.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			log.Info("instruments sync shutting down...")
+			return
+
+		case <-ticker.C:
+			if !b.leader.IsLeader() {
+				continue
+			}
+
+			if _, err := b.syncInstruments(b.ctx); err != nil {
+				log.Error("failed to sync instruments", zap.Error(err))
+			}
+		}
+	}
+}
+
+// syncInstruments stores tradable instruments and marks delisted ones inactive.
+func (b *Bot) syncInstruments(ctx context.Context) (*domain.InstrumentsSyncResult, error) {
+	ctx, span := tracing.Start(ctx, "bot.syncInstruments")
+	defer span.End()
+
+	instruments, err := b.loadInstruments(ctx)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	// empty list is most likely a provider failure, it mustn't deactivate all instruments
+	if len(instruments) == 0 {
+		return nil, errs.NewStack(fmt.Errorf("empty instruments list"))
+	}
+
+	result, err := b.deps.instrumentsRepository.SyncInstruments(ctx, instruments)
+	if err != nil {
+		return nil, errs.NewStack(fmt.Errorf("failed to sync instruments: %v", err))
+	}
+
+	log.Info("instruments synced",
+		zap.Int64("added", result.Added),
+		zap.Int64("updated", result.Updated),
+		zap.Int64("deactivated", result.Deactivated),
+	)
+
+	return result, nil
+}
+
+func (b *Bot) loadInstruments(ctx context.Context) ([]*domain.Instrument, error) {
+	if b.cfg.InstrumentsSync.File == "" {
+		instruments, err := b.deps.marketData.GetInstruments(ctx)
+		if err != nil {
+			return nil, errs.NewStack(fmt.Errorf("failed to get instruments: %v", err))
+		}
+
+		if err := b.loadInstrumentsInfo(ctx, instruments); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		return instruments, nil
+	}
+
+	data, err := os.ReadFile(b.cfg.InstrumentsSync.File)
+	if err != nil {
+		return nil, errs.NewStack(fmt.Errorf("failed to read instruments file: %v", err))
+	}
+
+	var instruments []*domain.Instrument
+	if err := json.Unmarshal(data, &instruments); err != nil {
+		return nil, errs.NewStack(fmt.Errorf("failed to parse instruments file: %v", err))
+	}
+
+	return instruments, nil
+}
+
+// loadInstrumentsInfo requests lot sizes, min price steps and decimals of new instruments, instruments which kind
+// is changed and stored instruments without min price step by bot.instruments_sync.info_workers concurrent requests.
+// Other instruments and instruments which info request fails are synced with zero values keeping the stored ones.
+func (b *Bot) loadInstrumentsInfo(ctx context.Context, instruments []*domain.Instrument) error {
+	stored, err := b.deps.instrumentsRepository.GetInstruments(ctx)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to get stored instruments: %v", err))
+	}
+
+	known := make(map[string]*domain.Instrument, len(stored))
+	for _, instrument := range stored {
+		known[instrument.Ticker] = instrument
+	}
+
+	var (
+		wg      sync.WaitGroup
+		workers = make(chan struct{}, max(b.cfg.InstrumentsSync.InfoWorkers, 1))
+	)
+
+	for _, instrument := range instruments {
+		if s, ok := known[instrument.Ticker]; ok && s.Kind == instrument.Kind && s.MinStep > 0 {
+			continue
+		}
+
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return errs.NewStack(ctx.Err())
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+
+			info, err := b.deps.marketData.GetInstrumentInfo(ctx, instrument.Ticker)
+			if err != nil {
+				log.Warn("failed to get instrument info", zap.String("ticker", instrument.Ticker), zap.Error(err))
+				return
+			}
+
+			instrument.LotSize = info.LotSize
+			instrument.MinStep = info.MinStep
+			instrument.Decimals = info.Decimals
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
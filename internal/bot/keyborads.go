@@ -2,12 +2,19 @@ package bot
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/leonid6372/success-bot/internal/common/domain"
 	"gopkg.in/telebot.v4"
 )
 
+// instrumentKindKeys maps instrument kinds to instruments list tabs texts.
+var instrumentKindKeys = map[string]string{
+	domain.InstrumentKindShare:    btnKindShare,
+	domain.InstrumentKindBond:     btnKindBond,
+	domain.InstrumentKindETF:      btnKindETF,
+	domain.InstrumentKindCurrency: btnKindCurrency,
+}
+
 func (b *Bot) mainMenuKeyboard(lang string) *telebot.ReplyMarkup {
 	markup := &telebot.ReplyMarkup{}
 
@@ -18,12 +25,23 @@ func (b *Bot) mainMenuKeyboard(lang string) *telebot.ReplyMarkup {
 	btnEnterPromocode := telebot.Btn{Text: b.deps.dictionary.Text(lang, btnEnterPromocode)}
 	btnFAQ := telebot.Btn{Text: b.deps.dictionary.Text(lang, btnFAQ)}
 	btnTopUsers := telebot.Btn{Text: b.deps.dictionary.Text(lang, btnTopUsers)}
+	btnSettings := telebot.Btn{Text: b.deps.dictionary.Text(lang, btnSettings)}
+	btnProfile := telebot.Btn{Text: b.deps.dictionary.Text(lang, btnProfile)}
 
 	rows := []telebot.Row{
 		{btnPortfolio, btnOperations},
 		{btnInstrumentsList, btnInstrumentsSearch},
 		{btnEnterPromocode, btnFAQ},
-		{btnTopUsers},
+		{btnProfile, btnTopUsers, btnSettings},
+	}
+
+	if b.cfg.WebAppURL != "" {
+		btnWebApp := telebot.Btn{
+			Text:   b.deps.dictionary.Text(lang, btnWebApp),
+			WebApp: &telebot.WebApp{URL: b.cfg.WebAppURL},
+		}
+
+		rows = append(rows, telebot.Row{btnWebApp})
 	}
 
 	markup.Reply(rows...)
@@ -79,10 +97,11 @@ func (b *Bot) portfolioInstrumentsListByPageKeyboard(
 		}
 
 		text := b.deps.dictionary.Text(lang, btnPortfolioInstrument, map[string]any{
-			"Ticker":            instrument.Ticker[:strings.Index(instrument.Ticker, "@")],
+			"Ticker":            shortTicker(instrument.Ticker),
 			"Count":             instrument.Count,
 			"AvgPrice":          instrument.AvgPrice,
 			"PercentDifference": diff,
+			"Unit":              currencySign(instrument.Currency),
 		})
 		callbackData := fmt.Sprintf("%s|%s", cbkInstrument, instrument.Ticker)
 
@@ -94,13 +113,29 @@ func (b *Bot) portfolioInstrumentsListByPageKeyboard(
 	return markup
 }
 
+// instrumentsListByPageKeyboard shows instrument kinds tabs if kind isn't empty.
 func (b *Bot) instrumentsListByPageKeyboard(
-	lang string, instruments []*domain.Instrument, currentPage, pagesCount int64,
+	lang, kind string, instruments []*domain.Instrument, currentPage, pagesCount int64,
 ) *telebot.ReplyMarkup {
 	markup := &telebot.ReplyMarkup{}
 	var rows []telebot.Row
 
-	rows = b.addPaginationCbkButtons(rows, lang, cbkInstrumentsPage, currentPage, pagesCount)
+	if kind != "" {
+		var tabs telebot.Row
+		for _, tabKind := range domain.InstrumentKinds {
+			text := b.deps.dictionary.Text(lang, instrumentKindKeys[tabKind])
+			if tabKind == kind {
+				text = "✅ " + text
+			}
+
+			callbackData := fmt.Sprintf("%s|%s|%d", cbkInstrumentsPage, tabKind, 1)
+			tabs = append(tabs, markup.Data(text, callbackData))
+		}
+
+		rows = append(rows, tabs)
+	}
+
+	rows = b.addPaginationCbkButtons(rows, lang, cbkInstrumentsPage+"|"+kind, currentPage, pagesCount)
 
 	for i := 0; i < len(instruments); i += 2 {
 		end := min(i+2, len(instruments))
@@ -140,6 +175,35 @@ func (b *Bot) instrumentKeyboard(lang string) *telebot.ReplyMarkup {
 	return markup
 }
 
+// chartButtonKeyboard is attached to the instrument live price message.
+func (b *Bot) chartButtonKeyboard(lang, ticker string) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+
+	text := b.deps.dictionary.Text(lang, btnChart)
+	callbackData := fmt.Sprintf("%s|%s|%s", cbkChart, ticker, defaultChartTimeframe)
+
+	markup.Inline(telebot.Row{markup.Data(text, callbackData)})
+	return markup
+}
+
+func (b *Bot) chartKeyboard(lang, ticker, currentTimeframe string) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	var row telebot.Row
+
+	for _, timeframe := range domain.Timeframes {
+		text := b.deps.dictionary.Text(lang, timeframeKeys[timeframe])
+		if timeframe == currentTimeframe {
+			text = "✅ " + text
+		}
+
+		callbackData := fmt.Sprintf("%s|%s|%s", cbkChart, ticker, timeframe)
+		row = append(row, markup.Data(text, callbackData))
+	}
+
+	markup.Inline(row)
+	return markup
+}
+
 func (b *Bot) paginationKeyboard(lang string, callback string, currentPage, pagesCount int64) *telebot.ReplyMarkup {
 	markup := &telebot.ReplyMarkup{}
 	var rows []telebot.Row
@@ -161,3 +225,147 @@ func (b *Bot) dailyRewardKeyboard(lang string) *telebot.ReplyMarkup {
 	markup.ResizeKeyboard = true
 	return markup
 }
+
+// settingsKeyboard shows user's time zone, notification categories with their state and quiet hours.
+func (b *Bot) settingsKeyboard(user *domain.User) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	lang := user.LanguageCode
+
+	rows := []telebot.Row{{
+		markup.Data(b.deps.dictionary.Text(lang, btnTimeZone, map[string]any{
+			"TimeZone": b.timeZoneName(user),
+		}), cbkSettingsTimeZones),
+	}}
+
+	for _, category := range domain.NotificationCategories {
+		text := "✅ " + b.deps.dictionary.Text(lang, notificationKeys[category])
+		if user.Settings.IsMuted(category) {
+			text = "🔕 " + b.deps.dictionary.Text(lang, notificationKeys[category])
+		}
+
+		callbackData := fmt.Sprintf("%s|%s", cbkSettingsNotification, category)
+		rows = append(rows, telebot.Row{markup.Data(text, callbackData)})
+	}
+
+	rows = append(rows, telebot.Row{
+		markup.Data(b.deps.dictionary.Text(lang, btnQuietHours, map[string]any{
+			"QuietHours": b.quietHoursText(user),
+		}), cbkSettingsQuietHours),
+	}, telebot.Row{
+		markup.Data(b.deps.dictionary.Text(lang, btnPublicProfile, map[string]any{
+			"Public": user.Settings.PublicProfile,
+		}), cbkSettingsPublicProfile),
+	})
+
+	markup.Inline(rows...)
+	return markup
+}
+
+// topUsersKeyboard shows buttons of public profiles on the page above pagination.
+func (b *Bot) topUsersKeyboard(lang string, pageUsers []*domain.TopUser, currentPage, pagesCount int64) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+
+	var row telebot.Row
+	for _, topUser := range pageUsers {
+		if !topUser.PublicProfile {
+			continue
+		}
+
+		text := b.deps.dictionary.Text(lang, btnTrader, map[string]any{"Username": topUser.Username})
+		row = append(row, markup.Data(text, fmt.Sprintf("%s|%d", cbkTrader, topUser.ID)))
+
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	rows = b.addPaginationCbkButtons(rows, lang, cbkTopUsersPage, currentPage, pagesCount)
+
+	markup.Inline(rows...)
+	return markup
+}
+
+// traderKeyboard shows follow or unfollow button and copy percents with the current one marked,
+// own profile can't be followed.
+func (b *Bot) traderKeyboard(lang string, traderID int64, own, following bool, copyPercent int) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+
+	switch {
+	case own:
+	case following:
+		rows = append(rows, telebot.Row{markup.Data(b.deps.dictionary.Text(lang, btnUnfollow),
+			fmt.Sprintf("%s|%d", cbkUnfollow, traderID))})
+	default:
+		rows = append(rows, telebot.Row{markup.Data(b.deps.dictionary.Text(lang, btnFollow),
+			fmt.Sprintf("%s|%d", cbkFollow, traderID))})
+	}
+
+	if !own {
+		var row telebot.Row
+		for _, percent := range domain.CopyPercents {
+			text := b.deps.dictionary.Text(lang, btnCopyPercent, map[string]any{"Percent": percent})
+			if percent == copyPercent {
+				text = "✅ " + text
+			}
+
+			row = append(row, markup.Data(text, fmt.Sprintf("%s|%d|%d", cbkCopy, traderID, percent)))
+		}
+
+		rows = append(rows, row)
+
+		if copyPercent > 0 {
+			rows = append(rows, telebot.Row{markup.Data(b.deps.dictionary.Text(lang, btnStopCopying),
+				fmt.Sprintf("%s|%d|0", cbkCopy, traderID))})
+		}
+	}
+
+	rows = append(rows, telebot.Row{markup.Data(b.deps.dictionary.Text(lang, btnTopUsers), cbkTopUsersPage+"|1")})
+
+	markup.Inline(rows...)
+	return markup
+}
+
+func (b *Bot) followedTradeKeyboard(lang string, trader *domain.User) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+
+	text := b.deps.dictionary.Text(lang, btnTrader, map[string]any{"Username": trader.Username})
+	markup.Inline(telebot.Row{markup.Data(text, fmt.Sprintf("%s|%d", cbkTrader, trader.ID))})
+
+	return markup
+}
+
+func (b *Bot) timeZonesKeyboard(lang string) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+
+	rows := []telebot.Row{{
+		markup.Data(b.deps.dictionary.Text(lang, btnDefaultTimeZone, map[string]any{
+			"TimeZone": locationName(b.deps.dictionary.Locale(lang).Location()),
+		}), fmt.Sprintf("%s|%s", cbkSettingsTimeZone, defaultTimeZone)),
+	}}
+
+	var row telebot.Row
+	for _, timeZone := range settingsTimeZones {
+		row = append(row, markup.Data(timeZone, fmt.Sprintf("%s|%s", cbkSettingsTimeZone, timeZone)))
+
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	rows = append(rows, telebot.Row{markup.Data(b.deps.dictionary.Text(lang, btnBack), cbkSettings)})
+
+	markup.Inline(rows...)
+	return markup
+}
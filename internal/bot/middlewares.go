@@ -4,18 +4,23 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/leonid6372/success-bot/internal/boterrs"
 	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
 	"github.com/leonid6372/success-bot/pkg/dictionary"
 	"github.com/leonid6372/success-bot/pkg/errs"
 	"github.com/leonid6372/success-bot/pkg/log"
+	"github.com/leonid6372/success-bot/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 	"gopkg.in/telebot.v4"
 )
 
 const (
 	ctxContext        = "context"
+	ctxUser           = "user"
 	ctxUserSubscribed = "subscribed"
 )
 
@@ -35,6 +40,46 @@ func (b *Bot) recoveryMiddleware(next telebot.HandlerFunc) telebot.HandlerFunc {
 	}
 }
 
+// metricsMiddleware records handling duration by route: callback unique, command or button dictionary key.
+func (b *Bot) metricsMiddleware(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(c telebot.Context) error {
+		defer func(start time.Time) {
+			metrics.HandlerDuration.WithLabelValues(metrics.TransportBot, b.route(c)).
+				Observe(time.Since(start).Seconds())
+		}(time.Now())
+
+		return next(c)
+	}
+}
+
+func (b *Bot) route(c telebot.Context) string {
+	if callback := c.Callback(); callback != nil {
+		return "callback:" + callback.Unique
+	}
+
+	if c.Query() != nil {
+		return "inline"
+	}
+
+	if route, ok := b.messageRoutes[c.Text()]; ok {
+		return route
+	}
+
+	if route, ok := b.messageRoutes[command(c.Text())]; ok {
+		return route
+	}
+
+	return "text"
+}
+
+// command returns the command of the message text without payload and bot username used in group chats.
+func command(text string) string {
+	command, _, _ := strings.Cut(text, " ")
+	command, _, _ = strings.Cut(command, "@")
+
+	return command
+}
+
 func (b *Bot) timeoutMiddleware(next telebot.HandlerFunc) telebot.HandlerFunc {
 	return func(c telebot.Context) error {
 		log.Info("request", zap.String("username", c.Sender().Username), zap.String("text", c.Text()))
@@ -42,15 +87,54 @@ func (b *Bot) timeoutMiddleware(next telebot.HandlerFunc) telebot.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Timeout)
 		defer cancel()
 
+		ctx, span := tracing.Start(ctx, "bot.update",
+			attribute.String("route", b.route(c)),
+			attribute.Int64("user_id", c.Sender().ID),
+		)
+
 		c.Set(ctxContext, ctx)
 
+		err := next(c)
+		tracing.End(span, err)
+
+		return err
+	}
+}
+
+// groupMiddleware lets only group commands through in group chats, so group conversation doesn't change
+// the state of private dialogs. Membership updates are handled before user middlewares, because they aren't
+// sent by a player of the bot.
+func (b *Bot) groupMiddleware(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(c telebot.Context) error {
+		// the bot is blocked in private chat or added to or removed from a group
+		if c.Update().MyChatMember != nil {
+			return b.groupBotMemberHandler(c)
+		}
+
+		if !isGroupChat(c.Chat()) {
+			return next(c)
+		}
+
+		// group messages have no inline keyboards
+		if c.Callback() != nil {
+			return c.Respond()
+		}
+
+		if message := c.Message(); message != nil && message.UserLeft != nil {
+			return b.groupMemberLeftHandler(c)
+		}
+
+		if !groupCommands[command(c.Text())] {
+			return nil
+		}
+
 		return next(c)
 	}
 }
 
 func (b *Bot) updateUserInfoMiddleware(next telebot.HandlerFunc) telebot.HandlerFunc {
 	return func(c telebot.Context) error {
-		ctx := c.Get(ctxContext).(context.Context)
+		ctx, span := tracing.Start(c.Get(ctxContext).(context.Context), "bot.middleware.updateUserInfo")
 		sender := c.Sender()
 
 		user := &domain.User{
@@ -61,20 +145,27 @@ func (b *Bot) updateUserInfoMiddleware(next telebot.HandlerFunc) telebot.Handler
 			IsPremium: sender.IsPremium,
 		}
 
-		if err := b.deps.usersRepository.UpdateUserTGData(ctx, user); err != nil {
+		err := b.deps.usersRepository.UpdateUserTGData(ctx, user)
+		if err != nil {
 			log.Error("failed to update user info", zap.String("username", user.Username), zap.Error(err))
 		}
 
+		tracing.End(span, err)
+
 		return next(c)
 	}
 }
 
 func (b *Bot) subscribeMiddleware(next telebot.HandlerFunc) telebot.HandlerFunc {
 	return func(c telebot.Context) error {
-		message := c.Message()
+		// players subscribe in private chat before using the bot in groups
+		if isGroupChat(c.Chat()) {
+			return next(c)
+		}
 
-		if strings.Contains(message.Text, "/start") ||
-			strings.Contains(message.Text, cbkLanguage) {
+		// inline queries have no message
+		if strings.Contains(c.Text(), "/start") ||
+			strings.Contains(c.Text(), cbkLanguage) {
 			return next(c)
 		}
 
@@ -82,7 +173,10 @@ func (b *Bot) subscribeMiddleware(next telebot.HandlerFunc) telebot.HandlerFunc
 		var err error
 
 		if b.cfg.SubscribeChannelID != 0 {
-			subscribed, err = b.checkSubscription(b.cfg.SubscribeChannelID, message.Chat.ID)
+			_, span := tracing.Start(c.Get(ctxContext).(context.Context), "bot.middleware.subscribe")
+
+			subscribed, err = b.checkSubscription(b.cfg.SubscribeChannelID, c.Sender().ID)
+			tracing.End(span, err)
 			if err != nil {
 				return errs.NewStack(err)
 			}
@@ -91,6 +185,10 @@ func (b *Bot) subscribeMiddleware(next telebot.HandlerFunc) telebot.HandlerFunc
 		c.Set(ctxUserSubscribed, subscribed)
 
 		if !subscribed {
+			if c.Query() != nil {
+				return b.sendInlineStart(c)
+			}
+
 			return b.notSubscribedHandler(c)
 		}
 
@@ -116,30 +214,58 @@ func (b *Bot) checkSubscription(channelID int64, userID int64) (bool, error) {
 		member.Role == telebot.Member, nil
 }
 
+// selectUserMiddleware puts user from cache or repository into context and saves it back to cache after handling,
+// so changes made by handlers (metadata, language, settings) are shared between bot instances.
 func (b *Bot) selectUserMiddleware(next telebot.HandlerFunc) telebot.HandlerFunc {
 	return func(c telebot.Context) error {
 		ctx := c.Get(ctxContext).(context.Context)
 		tgID := c.Sender().ID
 
-		user, ok := b.users.Get(tgID)
-		if ok {
-			b.users.SetDefault(tgID, user)
+		spanCtx, span := tracing.Start(ctx, "bot.middleware.selectUser")
 
-			return next(c)
+		user, ok, err := b.getCachedUser(spanCtx, tgID)
+		if err != nil {
+			log.Error("failed to get user from cache", zap.Int64("user_id", tgID), zap.Error(err))
 		}
 
-		user, err := b.deps.usersRepository.GetUserByID(ctx, tgID)
-		if err != nil {
-			if errors.Is(err, boterrs.ErrUserNotFound) {
-				b.users.SetDefault(tgID, user)
+		span.SetAttributes(attribute.Bool("cache_hit", ok))
 
-				return b.startHandler(c)
+		if !ok {
+			user, err = b.deps.usersRepository.GetUserByID(spanCtx, tgID)
+			if err != nil && !errors.Is(err, boterrs.ErrUserNotFound) {
+				tracing.End(span, err)
+				return errs.NewStack(err)
 			}
-
-			return errs.NewStack(err)
 		}
 
-		b.users.SetDefault(tgID, user)
+		span.End()
+
+		c.Set(ctxUser, user)
+
+		defer func() {
+			// user may be created by start handler
+			user, _ := c.Get(ctxUser).(*domain.User)
+			if user == nil {
+				return
+			}
+
+			if err := b.saveUserSession(ctx, user); err != nil {
+				log.Error("failed to save user to cache", zap.Int64("user_id", tgID), zap.Error(err))
+			}
+		}()
+
+		if user == nil {
+			// registration is done in private chat only
+			if isGroupChat(c.Chat()) {
+				return b.sendGroupNotRegistered(c)
+			}
+
+			if c.Query() != nil {
+				return b.sendInlineStart(c)
+			}
+
+			return b.startHandler(c)
+		}
 
 		return next(c)
 	}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/leonid6372/success-bot/internal/common/config"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+	"gopkg.in/telebot.v4"
+)
+
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// newPoller returns webhook poller if webhook listen address is configured, otherwise long poller.
+func (b *Bot) newPoller() telebot.Poller {
+	if b.cfg.Webhook.Listen == "" {
+		return &telebot.LongPoller{Timeout: b.cfg.Timeout}
+	}
+
+	return &webhookPoller{
+		cfg: b.cfg.Webhook,
+		webhook: &telebot.Webhook{
+			SecretToken:      b.cfg.Webhook.SecretToken,
+			IgnoreSetWebhook: b.cfg.Webhook.PublicURL == "",
+			Endpoint: &telebot.WebhookEndpoint{
+				PublicURL: b.cfg.Webhook.PublicURL,
+				Cert:      b.cfg.Webhook.TLSCert,
+			},
+		},
+	}
+}
+
+// webhookPoller serves updates by own server instead of telebot.Webhook to reject requests with invalid
+// secret token explicitly. The server is started by Poll, so updates are never received before
+// the destination channel is known.
+type webhookPoller struct {
+	cfg     config.Webhook
+	webhook *telebot.Webhook
+}
+
+func (p *webhookPoller) Poll(b *telebot.Bot, dest chan telebot.Update, stop chan struct{}) {
+	if !p.webhook.IgnoreSetWebhook {
+		if err := b.SetWebhook(p.webhook); err != nil {
+			b.OnError(err, nil)
+			close(stop)
+			return
+		}
+	}
+
+	server := &http.Server{
+		Addr:    p.cfg.Listen,
+		Handler: p.handler(dest, stop),
+	}
+
+	go func() {
+		var err error
+
+		if p.cfg.TLSCert != "" && p.cfg.TLSKey != "" {
+			err = server.ListenAndServeTLS(p.cfg.TLSCert, p.cfg.TLSKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("webhook server stopped", zap.Error(err))
+		}
+	}()
+
+	<-stop
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		log.Error("webhook server shutdown failed", zap.Error(err))
+	}
+}
+
+func (p *webhookPoller) handler(dest chan telebot.Update, stop chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if p.cfg.SecretToken != "" && r.Header.Get(webhookSecretHeader) != p.cfg.SecretToken {
+			log.Warn("webhook request with invalid secret token", zap.String("remote_addr", r.RemoteAddr))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var update telebot.Update
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			log.Warn("failed to decode webhook update", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// updates received during shutdown aren't acknowledged, so Telegram delivers them again
+		select {
+		case dest <- update:
+		case <-stop:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// removeWebhook deletes previously registered webhook, so long polling can receive updates.
+// Pending updates are kept to switch modes without losing messages.
+func (b *Bot) removeWebhook() error {
+	info, err := b.Telebot.Webhook()
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	// telebot maps webhook URL from getWebhookInfo to Listen field
+	if info.Listen == "" {
+		return nil
+	}
+
+	log.Info("removing webhook to switch to long polling", zap.String("url", info.Listen))
+
+	if err := b.Telebot.RemoveWebhook(false); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/format"
+)
+
+// quoteText renders instrument live price message: last price with daily change, bid/ask, spread, daily volume
+// and order book ladder if it isn't nil. Prices are rounded to instrument's decimals, bonds are quoted
+// in percents of nominal.
+func (b *Bot) quoteText(
+	lang, color string, prices *domain.Instrument, orderBook *domain.OrderBook, instrument *domain.Instrument,
+) string {
+	decimals := instrument.Decimals
+	unit := quoteUnit(instrument)
+
+	var changePercent, spreadPercent float64
+
+	if prevClose := prices.Last - prices.Change; prevClose != 0 {
+		changePercent = prices.Change / prevClose * 100
+	}
+
+	spread := prices.Ask - prices.Bid
+	if prices.Bid != 0 && prices.Ask != 0 {
+		spreadPercent = spread / prices.Ask * 100
+	}
+
+	text := b.deps.dictionary.Text(lang, msgQuote, map[string]any{
+		"Color":         color,
+		"Price":         format.Price{Value: prices.Last, Decimals: decimals},
+		"Change":        changePercent,
+		"Bid":           format.Price{Value: prices.Bid, Decimals: decimals},
+		"Ask":           format.Price{Value: prices.Ask, Decimals: decimals},
+		"Spread":        format.Price{Value: spread, Decimals: decimals},
+		"SpreadPercent": spreadPercent,
+		"Volume":        int64(prices.Volume),
+		"Unit":          unit,
+	})
+
+	if orderBook == nil || len(orderBook.Bids)+len(orderBook.Asks) == 0 {
+		return text
+	}
+
+	return text + b.deps.dictionary.Text(lang, msgOrderBook, map[string]any{
+		"Ladder": orderBookLadder(b.deps.dictionary.Locale(lang), orderBook, decimals),
+	})
+}
+
+// quoteUnit returns unit of instrument quotes: bonds are quoted in percents of nominal, other instruments
+// in their currency.
+func quoteUnit(instrument *domain.Instrument) string {
+	if instrument.Kind == domain.InstrumentKindBond {
+		return "%"
+	}
+
+	return currencySign(instrument.Currency)
+}
+
+// currencySign returns L$ for the base currency and the currency code for others.
+func currencySign(currency string) string {
+	if currency == "" || currency == domain.BaseCurrency {
+		return "L$"
+	}
+
+	return currency
+}
+
+// money returns amount in the currency formatted by dictionary with the currency sign.
+func money(amount float64, currency string) format.Money {
+	return format.Money{Amount: amount, Currency: currencySign(currency)}
+}
+
+// bondInfoText renders bond nominal, accrued interest at t and the next coupon if it's known.
+func (b *Bot) bondInfoText(lang string, instrument *domain.Instrument, t time.Time) string {
+	text := b.deps.dictionary.Text(lang, msgBondInfo, map[string]any{
+		"Nominal":         money(instrument.Nominal, domain.BaseCurrency),
+		"AccruedInterest": money(instrument.AccruedInterest(t), domain.BaseCurrency),
+	})
+
+	coupon := instrument.NextCoupon(t)
+	if coupon == nil {
+		return text
+	}
+
+	return text + b.deps.dictionary.Text(lang, msgBondNextCoupon, map[string]any{
+		"CouponAmount": money(coupon.Amount, domain.BaseCurrency),
+		"CouponDate":   b.deps.dictionary.Locale(lang).Date(coupon.Date),
+	})
+}
+
+// orderBookLadder renders order book as a monospace ladder: asks from the worst to the best, then bids
+// from the best to the worst. Prices have the same decimals, so they are aligned by decimal separator.
+//
+//	285,10 │ 1 200
+//	285,00 │   540
+//	───────┼──────
+//	284,90 │ 3 100
+func orderBookLadder(locale format.Locale, orderBook *domain.OrderBook, decimals int32) string {
+	type line struct{ price, size string }
+
+	lines := make([]line, 0, len(orderBook.Asks)+len(orderBook.Bids))
+	for i := len(orderBook.Asks) - 1; i >= 0; i-- {
+		level := orderBook.Asks[i]
+		lines = append(lines, line{
+			price: locale.Price(format.Price{Value: level.Price, Decimals: decimals}),
+			size:  locale.Number(int64(level.Size)),
+		})
+	}
+	for _, level := range orderBook.Bids {
+		lines = append(lines, line{
+			price: locale.Price(format.Price{Value: level.Price, Decimals: decimals}),
+			size:  locale.Number(int64(level.Size)),
+		})
+	}
+
+	var priceWidth, sizeWidth int
+	for _, l := range lines {
+		priceWidth = max(priceWidth, utf8.RuneCountInString(l.price))
+		sizeWidth = max(sizeWidth, utf8.RuneCountInString(l.size))
+	}
+
+	ladder := &strings.Builder{}
+	for i, l := range lines {
+		if i == len(orderBook.Asks) && i > 0 {
+			fmt.Fprintf(ladder, "%s┼%s\n", strings.Repeat("─", priceWidth+1), strings.Repeat("─", sizeWidth+1))
+		}
+
+		fmt.Fprintf(ladder, "%*s │ %*s\n", priceWidth, l.price, sizeWidth, l.size)
+	}
+
+	return strings.TrimSuffix(ladder.String(), "\n")
+}
@@ -0,0 +1,189 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"gopkg.in/telebot.v4"
+)
+
+// defaultTimeZone is callback data of the time zone of user's language.
+const defaultTimeZone = "default"
+
+// settingsTimeZones are time zones users can choose in settings.
+var settingsTimeZones = []string{
+	"Europe/Kaliningrad", "Europe/Moscow", "Europe/Samara", "Asia/Yekaterinburg",
+	"Asia/Omsk", "Asia/Novosibirsk", "Asia/Krasnoyarsk", "Asia/Irkutsk",
+	"Asia/Yakutsk", "Asia/Vladivostok", "Asia/Magadan", "Asia/Kamchatka",
+	"Europe/London", "Europe/Berlin", "Asia/Dubai", "UTC",
+}
+
+// quietHoursPresets are quiet hours users switch between in settings, the first one disables quiet hours.
+var quietHoursPresets = [][2]int{{0, 0}, {22, 8}, {23, 9}, {0, 8}}
+
+// notificationKeys maps notification categories to settings buttons texts.
+var notificationKeys = map[string]string{
+	domain.NotificationDailyReward:  btnNotificationDailyReward,
+	domain.NotificationMarginCall:   btnNotificationMarginCall,
+	domain.NotificationBroadcasts:   btnNotificationBroadcasts,
+	domain.NotificationAchievements: btnNotificationAchievements,
+	domain.NotificationFollows:      btnNotificationFollows,
+}
+
+func (b *Bot) settingsHandler(c telebot.Context) error {
+	defer c.Respond()
+
+	user := b.mustUser(c)
+
+	user.Metadata.InputType = ""
+	user.Metadata.InstrumentOperation = ""
+
+	if err := b.closeInstrument(c, user); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return b.sendSettings(c, user)
+}
+
+// sendSettings shows user's settings, settings buttons edit the message in place.
+func (b *Bot) sendSettings(c telebot.Context, user *domain.User) error {
+	text := b.deps.dictionary.Text(user.LanguageCode, msgSettings, map[string]any{
+		"TimeZone":   b.timeZoneName(user),
+		"LocalTime":  time.Now().In(b.userLocation(user)),
+		"QuietHours": b.quietHoursText(user),
+	})
+
+	opts := &telebot.SendOptions{
+		ReplyMarkup: b.settingsKeyboard(user),
+		ParseMode:   telebot.ModeHTML,
+	}
+
+	if c.Callback() != nil {
+		if err := c.Edit(text, opts); err != nil {
+			return errs.NewStack(fmt.Errorf("failed to edit message: %v", err))
+		}
+
+		return nil
+	}
+
+	if err := c.Send(text, opts); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to send message: %v", err))
+	}
+
+	return nil
+}
+
+func (b *Bot) timeZonesHandler(c telebot.Context) error {
+	defer c.Respond()
+
+	user := b.mustUser(c)
+
+	text := b.deps.dictionary.Text(user.LanguageCode, msgSettingsTimeZones)
+
+	if err := c.Edit(text, &telebot.SendOptions{ReplyMarkup: b.timeZonesKeyboard(user.LanguageCode)}); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to edit message: %v", err))
+	}
+
+	return nil
+}
+
+func (b *Bot) setTimeZoneHandler(c telebot.Context) error {
+	args := c.Args()
+
+	if len(args) != 1 {
+		return errs.NewStack(fmt.Errorf("failed to parse data: param time zone not found"))
+	}
+
+	timeZone := args[0]
+	switch {
+	case timeZone == defaultTimeZone:
+		timeZone = ""
+	case !slices.Contains(settingsTimeZones, timeZone):
+		return errs.NewStack(fmt.Errorf("unknown time zone: %s", timeZone))
+	}
+
+	return b.updateSettings(c, func(settings *domain.UserSettings) {
+		settings.TimeZone = timeZone
+	})
+}
+
+func (b *Bot) toggleNotificationHandler(c telebot.Context) error {
+	args := c.Args()
+
+	if len(args) != 1 {
+		return errs.NewStack(fmt.Errorf("failed to parse data: param category not found"))
+	}
+
+	category := args[0]
+	if !slices.Contains(domain.NotificationCategories, category) {
+		return errs.NewStack(fmt.Errorf("unknown notification category: %s", category))
+	}
+
+	return b.updateSettings(c, func(settings *domain.UserSettings) {
+		if settings.IsMuted(category) {
+			settings.MutedNotifications = slices.DeleteFunc(slices.Clone(settings.MutedNotifications),
+				func(muted string) bool { return muted == category },
+			)
+		} else {
+			settings.MutedNotifications = append(slices.Clone(settings.MutedNotifications), category)
+		}
+	})
+}
+
+// switchQuietHoursHandler switches quiet hours to the next preset.
+func (b *Bot) switchQuietHoursHandler(c telebot.Context) error {
+	return b.updateSettings(c, func(settings *domain.UserSettings) {
+		current := slices.Index(quietHoursPresets, [2]int{settings.QuietHoursFrom, settings.QuietHoursTo})
+		next := quietHoursPresets[(current+1)%len(quietHoursPresets)]
+
+		settings.QuietHoursFrom, settings.QuietHoursTo = next[0], next[1]
+	})
+}
+
+// togglePublicProfileHandler opens or closes user's profile, followers of a closed profile aren't notified.
+func (b *Bot) togglePublicProfileHandler(c telebot.Context) error {
+	return b.updateSettings(c, func(settings *domain.UserSettings) {
+		settings.PublicProfile = !settings.PublicProfile
+	})
+}
+
+// updateSettings applies update to a copy of user's settings, stores them and shows updated settings.
+func (b *Bot) updateSettings(c telebot.Context, update func(settings *domain.UserSettings)) error {
+	defer c.Respond()
+
+	ctx := c.Get(ctxContext).(context.Context)
+	user := b.mustUser(c)
+
+	settings := user.Settings
+	update(&settings)
+
+	if err := b.deps.usersRepository.UpdateUserSettings(ctx, user.ID, &settings); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to update user settings: %v", err))
+	}
+
+	user.Settings = settings
+
+	return b.sendSettings(c, user)
+}
+
+// timeZoneName returns name of user's time zone with UTC offset, e.g. "Europe/Moscow (UTC+03:00)".
+func (b *Bot) timeZoneName(user *domain.User) string {
+	return locationName(b.userLocation(user))
+}
+
+func locationName(location *time.Location) string {
+	return fmt.Sprintf("%s (UTC%s)", location, time.Now().In(location).Format("-07:00"))
+}
+
+func (b *Bot) quietHoursText(user *domain.User) string {
+	settings := user.Settings
+	if settings.QuietHoursFrom == settings.QuietHoursTo {
+		return b.deps.dictionary.Text(user.LanguageCode, msgQuietHoursOff)
+	}
+
+	return fmt.Sprintf("%02d:00–%02d:00", settings.QuietHoursFrom, settings.QuietHoursTo)
+}
@@ -10,4 +10,13 @@ var (
 	ErrEmptyTickerToSell      = errors.New("empty ticker to sell")
 	ErrInsufficientFunds      = errors.New("insufficient funds")
 	ErrUnavailableDailyReward = errors.New("unavailable daily reward")
+	ErrInvalidToken           = errors.New("invalid token")
+	ErrInstrumentNotFound     = errors.New("instrument not found")
+	ErrClosedExchange         = errors.New("closed exchange")
+	ErrInvalidLotCount        = errors.New("count isn't a multiple of lot size")
+	ErrInvalidPriceStep       = errors.New("price isn't a multiple of min price step")
+	ErrForeignShort           = errors.New("short of instrument in foreign currency")
+	ErrInactiveInstrument     = errors.New("inactive instrument")
+	ErrOperationCopied        = errors.New("operation is already copied")
+	ErrCopyLimitExceeded      = errors.New("copy percents limit exceeded")
 )
@@ -0,0 +1,100 @@
+// Package achievements contains the achievements engine which evaluates achievements rules on domain events.
+package achievements
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+)
+
+// rules return progress of the achievement made by the event, zero if the event doesn't match the rule.
+var rules = map[string]func(event domain.Event) int{
+	domain.AchievementFirstTrade: func(event domain.Event) int {
+		if _, ok := event.(domain.TradeExecuted); ok {
+			return 1
+		}
+
+		return 0
+	},
+	domain.AchievementFirstShort: func(event domain.Event) int {
+		if e, ok := event.(domain.TradeExecuted); ok && e.Trade.OpenedShort {
+			return 1
+		}
+
+		return 0
+	},
+	domain.AchievementProfitableTrades: func(event domain.Event) int {
+		if e, ok := event.(domain.TradeExecuted); ok && e.Trade.CloseResult > 0 {
+			return 1
+		}
+
+		return 0
+	},
+	domain.AchievementMarginCallSurvivor: func(event domain.Event) int {
+		if e, ok := event.(domain.MarginCallCleared); ok && !e.StoppedOut {
+			return 1
+		}
+
+		return 0
+	},
+	domain.AchievementTop10: func(event domain.Event) int {
+		if e, ok := event.(domain.TopFinished); ok && e.Rank <= domain.TopAchievementRank {
+			return 1
+		}
+
+		return 0
+	},
+	domain.AchievementRewardStreak: func(event domain.Event) int {
+		if e, ok := event.(domain.RewardClaimed); ok && e.Claim.Streak >= domain.RewardStreakAchievementDays {
+			return 1
+		}
+
+		return 0
+	},
+}
+
+// Unlocked is an achievement unlocked by an event.
+type Unlocked struct {
+	Achievement *domain.Achievement
+	Bonus       float64 // paid L$ bonus, zero for no bonus
+}
+
+// Engine adds progress of achievements matched by domain events and unlocks them.
+type Engine struct {
+	repository domain.AchievementsRepository
+	bonuses    map[string]float64 // achievement ID -> L$ bonus paid on unlock
+}
+
+func New(repository domain.AchievementsRepository, bonuses map[string]float64) *Engine {
+	return &Engine{
+		repository: repository,
+		bonuses:    bonuses,
+	}
+}
+
+// Evaluate applies all rules to the user's event and returns achievements unlocked by it.
+func (e *Engine) Evaluate(ctx context.Context, userID int64, event domain.Event) ([]*Unlocked, error) {
+	var unlocked []*Unlocked
+
+	for _, achievement := range domain.Achievements {
+		progress := rules[achievement.ID](event)
+		if progress == 0 {
+			continue
+		}
+
+		bonus := e.bonuses[achievement.ID]
+
+		ok, err := e.repository.AddAchievementProgress(ctx, userID, achievement, progress, bonus)
+		if err != nil {
+			return unlocked, errs.NewStack(fmt.Errorf("failed to add %s achievement progress: %v", achievement.ID, err))
+		}
+
+		if ok {
+			unlocked = append(unlocked, &Unlocked{Achievement: achievement, Bonus: bonus})
+		}
+	}
+
+	return unlocked, nil
+}
@@ -0,0 +1,262 @@
+// Package fake provides deterministic market data for local runs and tests without Finam credentials.
+// Prices are a function of ticker and time, so all instances and restarts see the same quotes.
+package fake
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+)
+
+const (
+	tick = 0.01
+
+	maxCandles = 1000
+)
+
+// instruments is the fake tradable instruments list.
+var instruments = []domain.Instrument{
+	newInstrument("SBER@MISX", "Сбербанк", "Финансы", domain.InstrumentKindShare, 10),
+	newInstrument("GAZP@MISX", "Газпром", "Нефть и газ", domain.InstrumentKindShare, 10),
+	newInstrument("LKOH@MISX", "Лукойл", "Нефть и газ", domain.InstrumentKindShare, 1),
+	newInstrument("YDEX@MISX", "Яндекс", "IT", domain.InstrumentKindShare, 1),
+	newInstrument("GMKN@MISX", "Норникель", "Металлургия", domain.InstrumentKindShare, 10),
+	newInstrument("MTSS@MISX", "МТС", "Телекоммуникации", domain.InstrumentKindShare, 10),
+	newInstrument("MGNT@MISX", "Магнит", "Ритейл", domain.InstrumentKindShare, 1),
+	newInstrument("VTBR@MISX", "ВТБ", "Финансы", domain.InstrumentKindShare, 1),
+	newInstrument("SU26238RMFS4@MISX", "ОФЗ 26238", "Государственные облигации", domain.InstrumentKindBond, 1),
+	newInstrument("SU26243RMFS4@MISX", "ОФЗ 26243", "Государственные облигации", domain.InstrumentKindBond, 1),
+	newInstrument("TMOS@MISX", "Т-Капитал Индекс МосБиржи", "Фонды", domain.InstrumentKindETF, 1),
+	newInstrument("CNYRUB_TOM@MISX", "💴 Юань", "Валюта", domain.InstrumentKindCurrency, 1),
+	newInstrument("USD000UTSTOM@MISX", "💵 Доллар США", "Валюта", domain.InstrumentKindCurrency, 1),
+	inCurrency(newInstrument("FXUS@MISX", "FinEx Акции американских компаний", "Фонды", domain.InstrumentKindETF, 1),
+		domain.CurrencyUSD),
+}
+
+// currencyBases are base prices of currency instruments, other instruments' base prices are derived from tickers.
+var currencyBases = map[string]float64{
+	"CNYRUB_TOM@MISX":   12.5,
+	"USD000UTSTOM@MISX": 90,
+}
+
+func newInstrument(ticker, name, sector, kind string, lotSize int64) domain.Instrument {
+	instrument := domain.Instrument{
+		InstrumentIdentifiers: domain.InstrumentIdentifiers{Ticker: ticker, Name: name},
+		Sector:                sector,
+		Kind:                  kind,
+		LotSize:               lotSize,
+	}
+
+	if kind == domain.InstrumentKindBond {
+		instrument.Nominal = domain.DefaultBondNominal
+	}
+
+	return instrument
+}
+
+// inCurrency sets the currency of the fake instrument, instruments are in RUB by default.
+func inCurrency(instrument domain.Instrument, currency string) domain.Instrument {
+	instrument.Currency = currency
+	return instrument
+}
+
+const (
+	couponPeriod = 182 * 24 * time.Hour
+	couponAmount = 35.4
+)
+
+// couponsStart is the first coupon date of fake bonds, coupons are paid every couponPeriod up to 2035.
+var couponsStart = time.Date(2021, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+var tickerRegexp = regexp.MustCompile(`^[A-Z0-9_]{1,12}@[A-Z]+$`)
+
+var _ domain.MarketDataProvider = (*Provider)(nil)
+
+type Provider struct{}
+
+func NewProvider() *Provider {
+	return &Provider{}
+}
+
+func (p *Provider) GetInstrumentPrices(_ context.Context, ticker string) (*domain.Instrument, error) {
+	if !tickerRegexp.MatchString(ticker) {
+		return nil, errs.NewStack(boterrs.ErrInstrumentNotFound)
+	}
+
+	now := time.Now()
+	last := price(ticker, now)
+
+	year, month, day := now.Date()
+	dayStart := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+
+	return &domain.Instrument{
+		InstrumentIdentifiers: domain.InstrumentIdentifiers{
+			Ticker: ticker,
+		},
+		InstrumentPrices: domain.InstrumentPrices{
+			Last:   last,
+			Bid:    round(last - tick),
+			Ask:    round(last + tick),
+			Change: round(last - price(ticker, dayStart)),
+			Volume: float64(1000 * (1 + hash(ticker, now.Unix()/60, 0)%10_000)),
+		},
+	}, nil
+}
+
+func (p *Provider) GetInstrumentInfo(_ context.Context, ticker string) (*domain.Instrument, error) {
+	if !tickerRegexp.MatchString(ticker) {
+		return nil, boterrs.ErrInstrumentNotFound
+	}
+
+	for _, instrument := range instruments {
+		if instrument.Ticker == ticker {
+			return withDefaults(instrument), nil
+		}
+	}
+
+	return &domain.Instrument{
+		InstrumentIdentifiers: domain.InstrumentIdentifiers{
+			Ticker: ticker,
+			Name:   "Fake " + ticker[:strings.Index(ticker, "@")],
+		},
+		Decimals: 2,
+		LotSize:  1,
+		MinStep:  tick,
+		Kind:     domain.InstrumentKindShare,
+	}, nil
+}
+
+func (p *Provider) GetInstruments(_ context.Context) ([]*domain.Instrument, error) {
+	result := make([]*domain.Instrument, 0, len(instruments))
+	for _, instrument := range instruments {
+		result = append(result, withDefaults(instrument))
+	}
+
+	return result, nil
+}
+
+// withDefaults returns a copy of the fake instrument with currency, decimals, min price step and bond coupons.
+func withDefaults(instrument domain.Instrument) *domain.Instrument {
+	instrument.Currency = cmp.Or(instrument.Currency, domain.CurrencyRUB)
+	instrument.Decimals = 2
+	instrument.MinStep = tick
+
+	if instrument.Kind == domain.InstrumentKindBond {
+		instrument.Coupons = []*domain.Coupon{}
+		for date := couponsStart; date.Year() < 2035; date = date.Add(couponPeriod) {
+			instrument.Coupons = append(instrument.Coupons, &domain.Coupon{Date: date, Amount: couponAmount})
+		}
+	}
+
+	return &instrument
+}
+
+func (p *Provider) GetCandles(_ context.Context, ticker, timeframe string, from, to time.Time) ([]*domain.Candle, error) {
+	duration := domain.TimeframeDuration(timeframe)
+	if duration == 0 {
+		return nil, errs.NewStack(fmt.Errorf("unknown timeframe %q", timeframe))
+	}
+
+	candles := []*domain.Candle{}
+	for t := from.Truncate(duration); t.Before(to) && len(candles) < maxCandles; t = t.Add(duration) {
+		if t.Before(from) {
+			continue
+		}
+
+		// sample up to 60 prices inside the candle
+		step := max(duration/60, time.Minute)
+
+		candle := &domain.Candle{Time: t, Open: price(ticker, t), Low: math.MaxFloat64}
+		for s := t; s.Before(t.Add(duration)) && !s.After(to); s = s.Add(step) {
+			p := price(ticker, s)
+
+			candle.High = math.Max(candle.High, p)
+			candle.Low = math.Min(candle.Low, p)
+			candle.Close = p
+		}
+
+		candle.Volume = float64(100 * (1 + hash(ticker, t.Unix(), 1)%10_000))
+		candles = append(candles, candle)
+	}
+
+	return candles, nil
+}
+
+func (p *Provider) GetOrderBook(ctx context.Context, ticker string, depth int) (*domain.OrderBook, error) {
+	instrument, err := p.GetInstrumentPrices(ctx, ticker)
+	if err != nil {
+		return nil, err
+	}
+
+	minute := time.Now().Unix() / 60
+
+	orderBook := &domain.OrderBook{
+		Bids: make([]*domain.OrderBookLevel, 0, depth),
+		Asks: make([]*domain.OrderBookLevel, 0, depth),
+	}
+
+	for i := range depth {
+		orderBook.Bids = append(orderBook.Bids, &domain.OrderBookLevel{
+			Price: round(instrument.Bid - float64(i)*tick),
+			Size:  float64(10 * (1 + hash(ticker, minute, 2*i+2)%500)),
+		})
+		orderBook.Asks = append(orderBook.Asks, &domain.OrderBookLevel{
+			Price: round(instrument.Ask + float64(i)*tick),
+			Size:  float64(10 * (1 + hash(ticker, minute, 2*i+3)%500)),
+		})
+	}
+
+	return orderBook, nil
+}
+
+// price is a sum of slow and fast waves around ticker's base price with a per-minute noise.
+// Bonds are quoted around 95% of nominal, currencies around currencyBases.
+func price(ticker string, t time.Time) float64 {
+	base := 50 + float64(hash(ticker, 0, -1)%50_000)/100
+	if isBond(ticker) {
+		base = 90 + float64(hash(ticker, 0, -1)%1_000)/100
+	}
+	if currencyBase, ok := currencyBases[ticker]; ok {
+		base = currencyBase
+	}
+	minutes := float64(t.Unix()) / 60
+
+	noise := float64(hash(ticker, t.Unix()/60, -2)%2001)/1000 - 1 // [-1, 1]
+
+	return round(base * math.Exp(
+		0.08*math.Sin(2*math.Pi*minutes/(5*24*60))+
+			0.02*math.Sin(2*math.Pi*minutes/(3*60))+
+			0.002*noise,
+	))
+}
+
+func isBond(ticker string) bool {
+	for _, instrument := range instruments {
+		if instrument.Ticker == ticker {
+			return instrument.Kind == domain.InstrumentKindBond
+		}
+	}
+
+	return false
+}
+
+func hash(ticker string, n int64, salt int) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%d|%d", ticker, n, salt)
+
+	return h.Sum64()
+}
+
+// round rounds price to the tick, tick is 0,01.
+func round(price float64) float64 {
+	return math.Round(price*100) / 100
+}
@@ -3,12 +3,25 @@ package finam
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/Ruvad39/go-finam-rest"
+	"github.com/leonid6372/success-bot/internal/boterrs"
 	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
 	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// apiURL is Finam Trade API address, the library doesn't export it.
+const apiURL = "https://api.finam.ru"
+
+var _ domain.MarketDataProvider = (*Client)(nil)
+
 type Client struct {
 	*finam.Client
 	accountID string
@@ -31,7 +44,10 @@ func (c *Client) GetInstrumentPrices(ctx context.Context, ticker string) (*domai
 	var err error
 	res := &getInstrumentQuoteResponse{}
 
+	ctx, span := tracing.Start(ctx, "finam.quote", attribute.String("ticker", ticker))
+	start := time.Now()
 	res.QuoteResponse, err = c.Client.NewQuoteRequest(ticker).Do(ctx)
+	observeRequest(span, "quote", ticker, start, err)
 	if err != nil {
 		return nil, errs.NewStack(err)
 	}
@@ -39,15 +55,19 @@ func (c *Client) GetInstrumentPrices(ctx context.Context, ticker string) (*domai
 	return res.CreateDomain(), nil
 }
 
-// GetInstrumentInfo return domain.Instrument struct with actual Decimals count value.
+// GetInstrumentInfo return domain.Instrument struct with actual Decimals count, lot size and min price step values.
 func (c *Client) GetInstrumentInfo(ctx context.Context, ticker string) (*domain.Instrument, error) {
 	var err error
 	res := &getInstrumentInfoResponse{}
 
+	ctx, span := tracing.Start(ctx, "finam.asset_info", attribute.String("ticker", ticker))
+	start := time.Now()
 	res.AssetInfo, err = c.Client.NewAssetInfoRequest(ticker, c.accountID).Do(ctx)
+	// instruments info is requested for every instrument during sync, so the metrics aren't labeled by tickers
+	observeRequest(span, "asset_info", "", start, err)
 	if err != nil {
 		if errors.Is(err, finam.ErrNotFound) {
-			return nil, finam.ErrNotFound
+			return nil, boterrs.ErrInstrumentNotFound
 		}
 
 		return nil, errs.NewStack(err)
@@ -55,3 +75,126 @@ func (c *Client) GetInstrumentInfo(ctx context.Context, ticker string) (*domain.
 
 	return res.CreateDomain(), nil
 }
+
+// GetInstruments return tradable MOEX shares, bonds, ETFs and currencies by Finam Assets API. Lot size, min price
+// step and decimals aren't provided by the API, they are requested by GetInstrumentInfo. Finam doesn't provide
+// sectors and currencies, MOEX instruments are traded in rubles.
+func (c *Client) GetInstruments(ctx context.Context) ([]*domain.Instrument, error) {
+	var err error
+	res := &getInstrumentsResponse{}
+
+	ctx, span := tracing.Start(ctx, "finam.assets")
+	start := time.Now()
+	res.AssetsResponse, err = c.Client.NewAssetsRequest().Do(ctx)
+	observeRequest(span, "assets", "", start, err)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return res.CreateDomain(), nil
+}
+
+// timeframes maps domain candles timeframes to Finam ones.
+var timeframes = map[string]finam.Timeframe{
+	domain.Timeframe1m: finam.TimeframeM1,
+	domain.Timeframe1h: finam.TimeframeH1,
+	domain.Timeframe1d: finam.TimeframeD1,
+}
+
+// GetCandles return instrument candles of the timeframe for [from, to) period by Finam Bars API.
+func (c *Client) GetCandles(ctx context.Context, ticker, timeframe string, from, to time.Time) ([]*domain.Candle, error) {
+	finamTimeframe, ok := timeframes[timeframe]
+	if !ok {
+		return nil, errs.NewStack(fmt.Errorf("unknown timeframe %q", timeframe))
+	}
+
+	var err error
+	res := &getCandlesResponse{}
+
+	ctx, span := tracing.Start(ctx, "finam.bars",
+		attribute.String("ticker", ticker),
+		attribute.String("timeframe", timeframe),
+	)
+	start := time.Now()
+	res.BarsResponse, err = c.Client.NewBarsRequest().
+		Symbol(ticker).
+		Timeframe(finamTimeframe).
+		StartTime(from).
+		EndTime(to).
+		Do(ctx)
+	observeRequest(span, "bars", ticker, start, err)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return res.CreateDomain(), nil
+}
+
+// GetOrderBook return up to depth best levels of each side by Finam OrderBook API. The library doesn't wrap
+// the method, so the request is made with the library's authorized request helpers.
+func (c *Client) GetOrderBook(ctx context.Context, ticker string, depth int) (*domain.OrderBook, error) {
+	res := &getOrderBookResponse{}
+
+	ctx, span := tracing.Start(ctx, "finam.orderbook", attribute.String("ticker", ticker))
+	start := time.Now()
+	err := c.getOrderBook(ctx, ticker, res)
+	observeRequest(span, "orderbook", ticker, start, err)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return res.CreateDomain(depth), nil
+}
+
+// getOrderBook sends the request with ctx by the library's HTTP client, the library's SendRequest doesn't
+// take a context. API errors are converted the same way as by the library.
+func (c *Client) getOrderBook(ctx context.Context, ticker string, res *getOrderBookResponse) error {
+	req := finam.NewRequest(http.MethodGet, apiURL).URLJoin("v1/instruments", ticker, "orderbook")
+	if err := c.Client.WithAuthToken(req); err != nil {
+		return err
+	}
+
+	httpReq, err := req.NewHttpRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	httpResp, err := c.Client.HttpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+
+	resp, err := finam.NewResponse(httpResp)
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		if resp.StatusCode == http.StatusNotFound {
+			return finam.ErrNotFound
+		}
+
+		apiErr := finam.APIError{Status: resp.StatusCode}
+		if err := resp.DecodeJSON(&apiErr); err != nil {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+
+		return apiErr
+	}
+
+	return resp.DecodeJSON(res)
+}
+
+// observeRequest records Finam request duration and ends its span. Not found instrument isn't counted as an error.
+func observeRequest(span trace.Span, method, ticker string, start time.Time, err error) {
+	metrics.FinamRequestDuration.WithLabelValues(method, ticker).Observe(time.Since(start).Seconds())
+
+	if err != nil && !errors.Is(err, finam.ErrNotFound) {
+		metrics.FinamRequestErrors.WithLabelValues(method, ticker).Inc()
+		tracing.End(span, err)
+
+		return
+	}
+
+	span.End()
+}
@@ -1,6 +1,8 @@
 package finam
 
 import (
+	"sort"
+
 	"github.com/Ruvad39/go-finam-rest"
 	"github.com/leonid6372/success-bot/internal/common/domain"
 )
@@ -19,6 +21,7 @@ func (res *getInstrumentQuoteResponse) CreateDomain() *domain.Instrument {
 			Bid:    res.Quote.Bid.Float64(),
 			Ask:    res.Quote.Ask.Float64(),
 			Change: res.Quote.Change.Float64(),
+			Volume: res.Quote.Volume.Float64(),
 		},
 	}
 }
@@ -34,5 +37,121 @@ func (res *getInstrumentInfoResponse) CreateDomain() *domain.Instrument {
 			Name:   res.AssetInfo.Name,
 		},
 		Decimals: res.AssetInfo.Decimals,
+		LotSize:  int64(res.AssetInfo.LotSize),
+		MinStep:  res.AssetInfo.StepPrice(),
+		Kind:     kinds[res.AssetInfo.Type],
+		Nominal:  nominal(kinds[res.AssetInfo.Type]),
+	}
+}
+
+const moexMIC = "MISX"
+
+// kinds maps Finam asset types to domain instrument kinds, other types aren't supported.
+var kinds = map[string]string{
+	"EQUITIES":   domain.InstrumentKindShare,
+	"BONDS":      domain.InstrumentKindBond,
+	"FUNDS":      domain.InstrumentKindETF,
+	"CURRENCIES": domain.InstrumentKindCurrency,
+}
+
+// nominal returns default nominal for bonds, Finam doesn't provide bond nominals and coupons.
+func nominal(kind string) float64 {
+	if kind == domain.InstrumentKindBond {
+		return domain.DefaultBondNominal
+	}
+
+	return 0
+}
+
+type getInstrumentsResponse struct {
+	finam.AssetsResponse
+}
+
+// CreateDomain returns MOEX instruments of supported kinds only. Assets have no currency, so it's left empty
+// to keep the stored one.
+func (res *getInstrumentsResponse) CreateDomain() []*domain.Instrument {
+	instruments := []*domain.Instrument{}
+	for _, asset := range res.Assets {
+		kind, ok := kinds[asset.Type]
+		if asset.Mic != moexMIC || !ok {
+			continue
+		}
+
+		instruments = append(instruments, &domain.Instrument{
+			InstrumentIdentifiers: domain.InstrumentIdentifiers{
+				Ticker: asset.Symbol,
+				Name:   asset.Name,
+			},
+			Kind:    kind,
+			Nominal: nominal(kind),
+		})
 	}
+
+	return instruments
+}
+
+type getCandlesResponse struct {
+	finam.BarsResponse
+}
+
+func (res *getCandlesResponse) CreateDomain() []*domain.Candle {
+	candles := make([]*domain.Candle, 0, len(res.Bars))
+	for _, bar := range res.Bars {
+		candles = append(candles, &domain.Candle{
+			Time:   bar.Timestamp,
+			Open:   bar.Open.Float64(),
+			High:   bar.High.Float64(),
+			Low:    bar.Low.Float64(),
+			Close:  bar.Close.Float64(),
+			Volume: bar.Volume.Float64(),
+		})
+	}
+
+	return candles
+}
+
+// getOrderBookResponse is Finam OrderBook API response, levels of both sides are mixed in rows.
+type getOrderBookResponse struct {
+	Symbol    string `json:"symbol"`
+	OrderBook struct {
+		Rows []struct {
+			Price    finam.Decimal  `json:"price"`
+			SellSize *finam.Decimal `json:"sell_size,omitempty"`
+			BuySize  *finam.Decimal `json:"buy_size,omitempty"`
+		} `json:"rows"`
+	} `json:"orderbook"`
+}
+
+func (res *getOrderBookResponse) CreateDomain(depth int) *domain.OrderBook {
+	orderBook := &domain.OrderBook{
+		Bids: []*domain.OrderBookLevel{},
+		Asks: []*domain.OrderBookLevel{},
+	}
+
+	for _, row := range res.OrderBook.Rows {
+		switch {
+		case row.BuySize != nil:
+			orderBook.Bids = append(orderBook.Bids, &domain.OrderBookLevel{
+				Price: row.Price.Float64(),
+				Size:  row.BuySize.Float64(),
+			})
+		case row.SellSize != nil:
+			orderBook.Asks = append(orderBook.Asks, &domain.OrderBookLevel{
+				Price: row.Price.Float64(),
+				Size:  row.SellSize.Float64(),
+			})
+		}
+	}
+
+	sort.Slice(orderBook.Bids, func(i, j int) bool {
+		return orderBook.Bids[i].Price > orderBook.Bids[j].Price
+	})
+	sort.Slice(orderBook.Asks, func(i, j int) bool {
+		return orderBook.Asks[i].Price < orderBook.Asks[j].Price
+	})
+
+	orderBook.Bids = orderBook.Bids[:min(depth, len(orderBook.Bids))]
+	orderBook.Asks = orderBook.Asks[:min(depth, len(orderBook.Asks))]
+
+	return orderBook
 }
@@ -18,8 +18,12 @@ type Config struct {
 
 	Postgres Postgres `yaml:"postgres"`
 
-	Bot   Bot   `yaml:"bot"`
-	Finam Finam `yaml:"finam"`
+	Bot     Bot     `yaml:"bot"`
+	Finam   Finam   `yaml:"finam"`
+	API     API     `yaml:"api"`
+	Cache   Cache   `yaml:"cache"`
+	Metrics Metrics `yaml:"metrics"`
+	Tracing Tracing `yaml:"tracing"`
 }
 
 type Postgres struct {
@@ -32,17 +36,92 @@ type Postgres struct {
 }
 
 type Bot struct {
-	APIKey              string        `yaml:"api_key" env:"BOT_API_KEY" env-upd:""`
-	Timeout             time.Duration `yaml:"timeout" env:"BOT_TIMEOUT" env-upd:""`
-	Languages           []string      `yaml:"languages" env:"BOT_LANGUAGES" env-upd:""`
-	DailyReward         float64       `yaml:"daily_reward" env:"BOT_DAILY_REWARD" env-upd:""`
-	SubscribeChannelID  int64         `yaml:"subscribe_channel_id" env:"BOT_SUBSCRIBE_CHANNEL_ID" env-upd:""`
-	SubscribeChannelURL string        `yaml:"subscribe_channel_url" env:"BOT_SUBSCRIBE_CHANNEL_URL" env-upd:""`
+	APIKey              string             `yaml:"api_key" env:"BOT_API_KEY" env-upd:""`
+	Timeout             time.Duration      `yaml:"timeout" env:"BOT_TIMEOUT" env-upd:""`
+	Languages           []string           `yaml:"languages" env:"BOT_LANGUAGES" env-upd:""`
+	DailyReward         float64            `yaml:"daily_reward" env:"BOT_DAILY_REWARD" env-upd:""`
+	DailyRewardTiers    []DailyRewardTier  `yaml:"daily_reward_tiers"`  // empty value pays DailyReward every day
+	AchievementBonuses  map[string]float64 `yaml:"achievement_bonuses"` // achievement ID -> L$ bonus, missing IDs have no bonus
+	SubscribeChannelID  int64              `yaml:"subscribe_channel_id" env:"BOT_SUBSCRIBE_CHANNEL_ID" env-upd:""`
+	SubscribeChannelURL string             `yaml:"subscribe_channel_url" env:"BOT_SUBSCRIBE_CHANNEL_URL" env-upd:""`
+	WebAppURL           string             `yaml:"web_app_url" env:"BOT_WEB_APP_URL" env-upd:""`           // empty value hides WebApp button
+	APIURL              string             `yaml:"api_url" env:"BOT_API_URL" env-upd:""`                   // empty value uses api.telegram.org
+	OrderBookDepth      int                `yaml:"order_book_depth" env:"BOT_ORDER_BOOK_DEPTH" env-upd:""` // zero hides order book
+	Admins              []int64            `yaml:"admins" env:"BOT_ADMINS" env-upd:""`                     // Telegram IDs allowed to use admin commands
+	Webhook             Webhook            `yaml:"webhook"`
+	InstrumentsSync     InstrumentsSync    `yaml:"instruments_sync"`
+	Dictionary          Dictionary         `yaml:"dictionary"`
+}
+
+// DailyRewardTier is a daily reward paid from MinStreak consecutive days of claims instead of DailyReward.
+type DailyRewardTier struct {
+	MinStreak int     `yaml:"min_streak"`
+	Amount    float64 `yaml:"amount"`
+}
+
+// Dictionary configures bot texts file. The file is reloaded on change if ReloadInterval is set,
+// admins can also reload it with /reload_dictionary command.
+type Dictionary struct {
+	Path           string        `yaml:"path" env:"BOT_DICTIONARY_PATH" env-upd:""`                       // empty value uses dictionary.json
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"BOT_DICTIONARY_RELOAD_INTERVAL" env-upd:""` // zero disables file watching
+}
+
+// InstrumentsSync configures periodic sync of tradable instruments. Instruments are requested from
+// market data provider or read from JSON file with domain.Instrument array if File is set.
+type InstrumentsSync struct {
+	Interval time.Duration `yaml:"interval" env:"BOT_INSTRUMENTS_SYNC_INTERVAL" env-upd:""` // zero disables periodic sync
+	File     string        `yaml:"file" env:"BOT_INSTRUMENTS_SYNC_FILE" env-upd:""`
+	// InfoWorkers limits concurrent requests of lot sizes, min price steps and decimals of synced instruments
+	InfoWorkers int `yaml:"info_workers" env:"BOT_INSTRUMENTS_SYNC_INFO_WORKERS" env-upd:""`
+}
+
+// Webhook enables webhook mode instead of long polling if Listen is set.
+// Webhook isn't registered in Telegram if PublicURL is empty, it is useful for local testing.
+type Webhook struct {
+	Listen      string `yaml:"listen" env:"BOT_WEBHOOK_LISTEN" env-upd:""`
+	PublicURL   string `yaml:"public_url" env:"BOT_WEBHOOK_PUBLIC_URL" env-upd:""`
+	SecretToken string `yaml:"secret_token" env:"BOT_WEBHOOK_SECRET_TOKEN" env-upd:""`
+	TLSCert     string `yaml:"tls_cert" env:"BOT_WEBHOOK_TLS_CERT" env-upd:""` // optional, self-signed cert is uploaded to Telegram
+	TLSKey      string `yaml:"tls_key" env:"BOT_WEBHOOK_TLS_KEY" env-upd:""`
 }
 
 type Finam struct {
 	Token     string `yaml:"token" env:"FINAM_TOKEN" env-upd:""`
 	AccountID string `yaml:"account_id" env:"FINAM_ACCOUNT_ID" env-upd:""`
+	Fake      bool   `yaml:"fake" env:"FINAM_FAKE" env-upd:""` // deterministic fake market data instead of Finam
+}
+
+type API struct {
+	Listen  string        `yaml:"listen" env:"API_LISTEN" env-upd:""` // empty value disables API server
+	Timeout time.Duration `yaml:"timeout" env:"API_TIMEOUT" env-upd:""`
+}
+
+type Metrics struct {
+	Listen string `yaml:"listen" env:"METRICS_LISTEN" env-upd:""` // empty value disables /metrics and /healthz
+}
+
+// Tracing configures OpenTelemetry spans export. Empty exporter disables tracing, "otlp" exports
+// by OTLP/HTTP (standard OTEL_EXPORTER_OTLP_* variables are supported too), "stdout" prints spans.
+type Tracing struct {
+	Exporter     string  `yaml:"exporter" env:"TRACING_EXPORTER" env-upd:""`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT" env-upd:""`
+	ServiceName  string  `yaml:"service_name" env:"TRACING_SERVICE_NAME" env-upd:""`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-upd:""`
+}
+
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+)
+
+// Cache configures storage of users sessions, instruments prices and top users.
+// Redis backend is required to run several bot instances.
+type Cache struct {
+	Backend       string `yaml:"backend" env:"CACHE_BACKEND" env-upd:""`
+	RedisAddr     string `yaml:"redis_addr" env:"CACHE_REDIS_ADDR" env-upd:""`
+	RedisPassword string `yaml:"redis_password" env:"CACHE_REDIS_PASSWORD" env-upd:""`
+	RedisDB       int    `yaml:"redis_db" env:"CACHE_REDIS_DB" env-upd:""`
+	RedisPrefix   string `yaml:"redis_prefix" env:"CACHE_REDIS_PREFIX" env-upd:""`
 }
 
 func (c *Config) GetPostgresURL() string {
@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Achievements IDs.
+const (
+	AchievementFirstTrade         = "first_trade"
+	AchievementFirstShort         = "first_short"
+	AchievementProfitableTrades   = "profitable_trades"
+	AchievementMarginCallSurvivor = "margin_call_survivor"
+	AchievementTop10              = "top_10"
+	AchievementRewardStreak       = "reward_streak"
+)
+
+// TopAchievementRank is the lowest rank of the top users list unlocking AchievementTop10.
+const TopAchievementRank = 10
+
+// RewardStreakAchievementDays is the daily reward claims streak unlocking AchievementRewardStreak.
+const RewardStreakAchievementDays = 7
+
+type AchievementsRepository interface {
+	// AddAchievementProgress adds delta to user's progress of the achievement. The achievement is unlocked when
+	// progress reaches its target and non-zero bonus is paid by an achievement operation. It returns true only
+	// for the call which unlocked the achievement, progress of unlocked achievements isn't changed.
+	AddAchievementProgress(ctx context.Context, userID int64, achievement *Achievement, delta int, bonus float64) (bool, error)
+	GetUserAchievements(ctx context.Context, userID int64) ([]*UserAchievement, error)
+	// GetUnlockedAchievements returns IDs of unlocked achievements of all users in unlock order: user ID -> IDs.
+	GetUnlockedAchievements(ctx context.Context) (map[int64][]string, error)
+}
+
+// Achievement is a goal unlocked when user's progress reaches Target.
+type Achievement struct {
+	ID     string
+	Badge  string // shown next to user's name
+	Target int
+}
+
+// Achievements are all achievements in the order they are shown.
+var Achievements = []*Achievement{
+	{ID: AchievementFirstTrade, Badge: "🚀", Target: 1},
+	{ID: AchievementFirstShort, Badge: "🐻", Target: 1},
+	{ID: AchievementProfitableTrades, Badge: "💎", Target: 10},
+	{ID: AchievementMarginCallSurvivor, Badge: "🛡", Target: 1},
+	{ID: AchievementTop10, Badge: "🏆", Target: 1},
+	{ID: AchievementRewardStreak, Badge: "📅", Target: 1},
+}
+
+// AchievementByID returns achievement with the ID or nil if it isn't found.
+func AchievementByID(id string) *Achievement {
+	for _, achievement := range Achievements {
+		if achievement.ID == id {
+			return achievement
+		}
+	}
+
+	return nil
+}
+
+// Badges returns badges of achievements with the IDs, unknown IDs are skipped.
+func Badges(ids []string) string {
+	var badges string
+	for _, id := range ids {
+		if achievement := AchievementByID(id); achievement != nil {
+			badges += achievement.Badge
+		}
+	}
+
+	return badges
+}
+
+type UserAchievement struct {
+	AchievementID string     `json:"achievement_id"`
+	Progress      int        `json:"progress"`
+	UnlockedAt    *time.Time `json:"unlocked_at"` // nil while the achievement is locked
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Reasons of balance changes.
+const (
+	BalanceReasonSnapshot    = "snapshot"
+	BalanceReasonInitial     = "initial"
+	BalanceReasonBuy         = "buy"
+	BalanceReasonSell        = "sell"
+	BalanceReasonDailyReward = "daily_reward"
+	BalanceReasonPromocode   = "promocode"
+	BalanceReasonRevaluation = "revaluation"
+	BalanceReasonExchange    = "exchange"
+	BalanceReasonAchievement = "achievement"
+	BalanceReasonCoupon      = "coupon"
+)
+
+// Actors of balance changes.
+const (
+	ActorUser         = "user"
+	ActorAPI          = "api"
+	ActorCacheUpdater = "cache_updater"
+	ActorStopOut      = "stop_out"
+	ActorCopyTrading  = "copy_trading"
+	ActorCouponPayout = "coupon_payout"
+)
+
+type BalanceEventsRepository interface {
+	// GetBalanceMismatches replays balance events journal and returns users whose balances differ from it.
+	GetBalanceMismatches(ctx context.Context) ([]*BalanceMismatch, error)
+}
+
+type BalanceEvent struct {
+	ID     int64
+	UserID int64
+	Reason string
+	Actor  string
+
+	AvailableBefore float64
+	BlockedBefore   float64
+	AvailableAfter  float64
+	BlockedAfter    float64
+
+	CreatedAt time.Time
+}
+
+// BalanceMismatch describes user whose balances are not equal to replayed journal.
+// BrokenEventID is the first event which before balances don't match previous after balances, 0 if chain is valid.
+type BalanceMismatch struct {
+	UserID int64
+
+	AvailableBalance float64
+	BlockedBalance   float64
+	JournalAvailable float64
+	JournalBlocked   float64
+	BrokenEventID    int64
+}
+
+type ctxActorKey struct{}
+
+// ContextWithActor sets actor of balance changes made with ctx.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, ctxActorKey{}, actor)
+}
+
+// ActorFromContext returns actor of balance changes, ActorUser by default.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(ctxActorKey{}).(string); ok {
+		return actor
+	}
+
+	return ActorUser
+}
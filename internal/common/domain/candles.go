@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	Timeframe1m = "1m"
+	Timeframe1h = "1h"
+	Timeframe1d = "1d"
+)
+
+// Timeframes lists supported candles timeframes in display order.
+var Timeframes = []string{Timeframe1m, Timeframe1h, Timeframe1d}
+
+// TimeframeDuration returns duration of a single candle of the timeframe. Zero for unknown timeframe.
+func TimeframeDuration(timeframe string) time.Duration {
+	switch timeframe {
+	case Timeframe1m:
+		return time.Minute
+	case Timeframe1h:
+		return time.Hour
+	case Timeframe1d:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+type CandlesRepository interface {
+	// GetCandles returns instrument candles with time in [from, to] sorted by time ascending.
+	GetCandles(ctx context.Context, instrumentID int64, timeframe string, from, to time.Time) ([]*Candle, error)
+	// SaveCandles inserts candles or overwrites already stored ones, the last candle may be incomplete.
+	SaveCandles(ctx context.Context, instrumentID int64, timeframe string, candles []*Candle) error
+}
+
+type Candle struct {
+	Time   time.Time `json:"time"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume float64   `json:"volume"`
+}
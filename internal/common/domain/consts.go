@@ -12,4 +12,8 @@ const (
 	OperationTypePromocode     = "promocode"
 	OperationTypeDailyReward   = "daily_reward"
 	OperationTypeDevAssistance = "dev_assistance"
+	OperationTypeFXBuy         = "fx_buy"  // buying of currency for L$
+	OperationTypeFXSell        = "fx_sell" // selling of currency for L$
+	OperationTypeAchievement   = "achievement"
+	OperationTypeCoupon        = "coupon" // bond coupon, negative total for shorts
 )
@@ -0,0 +1,37 @@
+package domain
+
+const (
+	CurrencyUSD = "USD"
+	CurrencyCNY = "CNY"
+
+	// BaseCurrency is the currency of users' L$ balances, portfolios are valued in it.
+	BaseCurrency = CurrencyRUB
+)
+
+// Currencies is the order of users' cash balances.
+var Currencies = []string{BaseCurrency, CurrencyUSD, CurrencyCNY}
+
+// CurrencyTickers maps currencies of cash balances to currency instruments used for conversion:
+// buying and selling of these instruments exchanges L$ balance to the currency balance and back.
+var CurrencyTickers = map[string]string{
+	CurrencyUSD: "USD000UTSTOM@MISX",
+	CurrencyCNY: "CNYRUB_TOM@MISX",
+}
+
+// ExchangeCurrency returns currency exchanged by the currency instrument or empty string for other instruments.
+func ExchangeCurrency(ticker string) string {
+	for currency, currencyTicker := range CurrencyTickers {
+		if currencyTicker == ticker {
+			return currency
+		}
+	}
+
+	return ""
+}
+
+// CurrencyBalance is user's cash balance in a currency other than the base one.
+type CurrencyBalance struct {
+	UserID   int64   `json:"user_id"`
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
@@ -0,0 +1,47 @@
+package domain
+
+import "sort"
+
+// DailyRewardTier is a daily reward paid from MinStreak consecutive days of claims.
+type DailyRewardTier struct {
+	MinStreak int
+	Amount    float64
+}
+
+// DailyRewardTiers are daily rewards by claims streak sorted by MinStreak ascending.
+type DailyRewardTiers []DailyRewardTier
+
+// NewDailyRewardTiers sorts tiers and adds base tier paid from the first claim if tiers don't start from it.
+func NewDailyRewardTiers(baseAmount float64, tiers []DailyRewardTier) DailyRewardTiers {
+	sorted := make(DailyRewardTiers, 0, len(tiers)+1)
+	sorted = append(sorted, tiers...)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].MinStreak < sorted[j].MinStreak
+	})
+
+	if len(sorted) == 0 || sorted[0].MinStreak > 1 {
+		sorted = append(DailyRewardTiers{{MinStreak: 1, Amount: baseAmount}}, sorted...)
+	}
+
+	return sorted
+}
+
+// Reward returns 1-based tier and amount of the claim which makes the streak.
+func (t DailyRewardTiers) Reward(streak int) (int, float64) {
+	tier := 0
+	for i := range t {
+		if t[i].MinStreak <= streak {
+			tier = i
+		}
+	}
+
+	return tier + 1, t[tier].Amount
+}
+
+// DailyRewardClaim is a claimed daily reward.
+type DailyRewardClaim struct {
+	Streak int     `json:"streak"` // consecutive days of claims including this one
+	Tier   int     `json:"tier"`   // 1-based tier of the reward
+	Amount float64 `json:"amount"`
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Event types, they are stored in the outbox and must not be changed.
+const (
+	EventTypeUserRegistered    = "user_registered"
+	EventTypeTradeExecuted     = "trade_executed"
+	EventTypeMarginCallEntered = "margin_call_entered"
+	EventTypeMarginCallCleared = "margin_call_cleared"
+	EventTypeRewardClaimed     = "reward_claimed"
+	EventTypePromocodeApplied  = "promocode_applied"
+	EventTypeTopFinished       = "top_finished"
+)
+
+// Event is a domain event published to the event bus. Events are stored in the outbox as JSON.
+// Events caused by changes in repositories are stored by the repositories in the transaction of the change.
+type Event interface {
+	EventType() string
+}
+
+type OutboxRepository interface {
+	// AddEvent stores the event which isn't caused by a change in a repository.
+	AddEvent(ctx context.Context, eventType string, payload []byte) error
+	// ClaimEvents claims up to limit pending events for lease and returns them in publishing order. Events claimed
+	// by another instance are skipped until their lease expires.
+	ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEvent, error)
+	// ExtendClaims extends the lease of claimed events which are still being delivered.
+	ExtendClaims(ctx context.Context, ids []int64, lease time.Duration) error
+	// RetryEvent counts the failed delivery, stores subscribers which handled the event and releases it to be
+	// claimed again after delay.
+	RetryEvent(ctx context.Context, id int64, deliveredTo []string, delay time.Duration) error
+	// DeleteEvent deletes the delivered event.
+	DeleteEvent(ctx context.Context, id int64) error
+}
+
+// OutboxEvent is a stored event waiting for delivery to subscribers.
+type OutboxEvent struct {
+	ID          int64
+	Type        string
+	Payload     []byte
+	Attempts    int      // failed deliveries
+	DeliveredTo []string // subscribers which handled the event on previous attempts
+	CreatedAt   time.Time
+}
+
+// UserRegistered happens when a new user starts the bot.
+type UserRegistered struct {
+	UserID       int64  `json:"user_id"`
+	LanguageCode string `json:"language_code"`
+}
+
+// TradeExecuted is an executed order of a user.
+type TradeExecuted struct {
+	UserID int64        `json:"user_id"`
+	Trade  *TradeResult `json:"trade"`
+	Source string       `json:"source"` // bot, api, stop_out or copy
+}
+
+// MarginCallEntered happens when revaluation makes user's available balance negative.
+type MarginCallEntered struct {
+	UserID int64 `json:"user_id"`
+}
+
+// MarginCallCleared happens when revaluation clears user's margin call.
+type MarginCallCleared struct {
+	UserID     int64 `json:"user_id"`
+	StoppedOut bool  `json:"stopped_out"` // the margin call was cleared by stop-out
+}
+
+// RewardClaimed is a claimed daily reward.
+type RewardClaimed struct {
+	UserID int64            `json:"user_id"`
+	Claim  DailyRewardClaim `json:"claim"`
+}
+
+// PromocodeApplied is a promocode bonus paid to a user.
+type PromocodeApplied struct {
+	UserID      int64   `json:"user_id"`
+	Promocode   string  `json:"promocode"`
+	BonusAmount float64 `json:"bonus_amount"`
+}
+
+// TopFinished happens at the end of a trading day for every user of the top users list.
+type TopFinished struct {
+	UserID int64 `json:"user_id"`
+	Rank   int   `json:"rank"` // 1-based
+}
+
+func (UserRegistered) EventType() string    { return EventTypeUserRegistered }
+func (TradeExecuted) EventType() string     { return EventTypeTradeExecuted }
+func (MarginCallEntered) EventType() string { return EventTypeMarginCallEntered }
+func (MarginCallCleared) EventType() string { return EventTypeMarginCallCleared }
+func (RewardClaimed) EventType() string     { return EventTypeRewardClaimed }
+func (PromocodeApplied) EventType() string  { return EventTypePromocodeApplied }
+func (TopFinished) EventType() string       { return EventTypeTopFinished }
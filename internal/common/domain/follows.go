@@ -0,0 +1,49 @@
+package domain
+
+import "context"
+
+// TraderOperationsCount is the count of recent operations shown in a trader's public profile.
+const TraderOperationsCount = 5
+
+// CopyPercents are percents of follower's total balance users can allocate to copy a trader.
+var CopyPercents = []int{10, 25, 50}
+
+// MaxCopyPercent limits the sum of percents allocated to all copied traders.
+const MaxCopyPercent = 100
+
+type FollowsRepository interface {
+	// Follow subscribes the follower to trades of the trader, following twice isn't an error.
+	Follow(ctx context.Context, followerID, traderID int64) error
+	// Unfollow stops following and copying of the trader.
+	Unfollow(ctx context.Context, followerID, traderID int64) error
+	// GetFollowedTraders returns copy percents of the traders followed by the follower by trader IDs,
+	// traders which aren't copied have zero percent.
+	GetFollowedTraders(ctx context.Context, followerID int64) (map[int64]int, error)
+	// GetFollowers returns IDs of the users following the trader.
+	GetFollowers(ctx context.Context, traderID int64) ([]int64, error)
+	// SetCopyPercent follows the trader and copies the trader's trades with the percent of follower's total
+	// balance, zero percent stops copying but keeps following. It returns boterrs.ErrCopyLimitExceeded if the sum
+	// of percents of all copied traders would exceed MaxCopyPercent.
+	SetCopyPercent(ctx context.Context, followerID, traderID int64, percent int) error
+	// GetCopiers returns followers copying trades of the trader.
+	GetCopiers(ctx context.Context, traderID int64) ([]*Copier, error)
+}
+
+// Copier is a follower copying trades of a trader.
+type Copier struct {
+	FollowerID int64
+	Percent    int // percent of follower's total balance mirroring the trader
+}
+
+type ctxCopiedOperationKey struct{}
+
+// ContextWithCopiedOperation links orders executed with ctx to the copied operation of a trader.
+func ContextWithCopiedOperation(ctx context.Context, operationID int64) context.Context {
+	return context.WithValue(ctx, ctxCopiedOperationKey{}, operationID)
+}
+
+// CopiedOperationFromContext returns the copied operation ID, zero for own orders.
+func CopiedOperationFromContext(ctx context.Context) int64 {
+	operationID, _ := ctx.Value(ctxCopiedOperationKey{}).(int64)
+	return operationID
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// GroupTopUsersCount limits the count of members shown in the group league.
+const GroupTopUsersCount = 30
+
+// Group is a group chat with the bot, the group members joined by /join compete in the group league.
+type Group struct {
+	ID           int64 // telegram chat ID
+	Title        string
+	LanguageCode string // language of the group messages
+	CreatedAt    time.Time
+}
+
+type GroupsRepository interface {
+	// SaveGroup creates the group or updates the title of the existing one, language isn't changed.
+	SaveGroup(ctx context.Context, group *Group) (*Group, error)
+	// DeleteGroup deletes the group with its league, e.g. when the bot is removed from the group.
+	DeleteGroup(ctx context.Context, groupID int64) error
+	// JoinGroup adds the user to the group league, joining twice isn't an error.
+	JoinGroup(ctx context.Context, groupID, userID int64) error
+	// LeaveGroup removes the user from the group league, it returns false if the user wasn't a member.
+	LeaveGroup(ctx context.Context, groupID, userID int64) (bool, error)
+	// GetGroupMembers returns IDs of the group league members.
+	GetGroupMembers(ctx context.Context, groupID int64) ([]int64, error)
+	// GetUserGroups returns groups the user joined.
+	GetUserGroups(ctx context.Context, userID int64) ([]*Group, error)
+}
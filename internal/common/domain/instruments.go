@@ -1,12 +1,49 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
+
+// CurrencyRUB is the currency of MOEX shares and the default instrument currency.
+const CurrencyRUB = "RUB"
+
+const (
+	InstrumentKindShare    = "share"
+	InstrumentKindBond     = "bond" // quoted in percent of nominal
+	InstrumentKindETF      = "etf"
+	InstrumentKindCurrency = "currency" // currency pair, e.g. CNYRUB_TOM
+)
+
+// InstrumentKinds is the order of instruments list tabs.
+var InstrumentKinds = []string{InstrumentKindShare, InstrumentKindBond, InstrumentKindETF, InstrumentKindCurrency}
+
+// DefaultBondNominal is nominal of bonds when the source of instruments doesn't provide it.
+const DefaultBondNominal = 1000
 
 type InstrumentsRepository interface {
-	CreateInstrument(ctx context.Context, ticker, name string) (*Instrument, error)
+	// CreateInstrument creates an instrument hidden from instruments list until an admin lists it.
+	CreateInstrument(ctx context.Context, instrument *Instrument) (*Instrument, error)
 	GetInstrumentByTicker(ctx context.Context, ticker string) (*Instrument, error)
-	GetInstrumentsPagesCount(ctx context.Context) (int64, error)
-	GetInstrumentsByPage(ctx context.Context, page int64) ([]*Instrument, error)
+	// GetInstruments returns all instruments including inactive and hidden ones without coupon schedules.
+	GetInstruments(ctx context.Context) ([]*Instrument, error)
+	// GetInstrumentsPagesCount and GetInstrumentsByPage return only active listed instruments of the kind,
+	// empty kind means all kinds.
+	GetInstrumentsPagesCount(ctx context.Context, kind string) (int64, error)
+	GetInstrumentsByPage(ctx context.Context, kind string, page int64) ([]*Instrument, error)
+	// GetInstrumentByTicker returns bonds with coupon schedule.
+	// SyncInstruments upserts tradable instruments and marks instruments missing in the list inactive except
+	// currencies and instruments with open positions.
+	// Coupon schedule of a bond is replaced if the synced bond has coupons.
+	SyncInstruments(ctx context.Context, instruments []*Instrument) (*InstrumentsSyncResult, error)
+	// SetInstrumentListed shows or hides the instrument in instruments list.
+	SetInstrumentListed(ctx context.Context, ticker string, listed bool) error
+}
+
+type InstrumentsSyncResult struct {
+	Added       int64
+	Updated     int64
+	Deactivated int64
 }
 
 type InstrumentIdentifiers struct {
@@ -19,12 +56,100 @@ type InstrumentPrices struct {
 	Last   float64 `json:"last"`
 	Bid    float64 `json:"bid"`
 	Ask    float64 `json:"ask"`
-	Change float64 `json:"change"`
+	Change float64 `json:"change"` // last price minus previous day close
+	Volume float64 `json:"volume"` // daily volume
 }
 
 type Instrument struct {
 	InstrumentIdentifiers
 	InstrumentPrices
 
-	Decimals int32 `json:"decimals"`
+	Decimals int32   `json:"decimals"`
+	LotSize  int64   `json:"lot_size"` // shares in a lot, counts of orders must be multiples of it
+	MinStep  float64 `json:"min_step"` // min price step, prices of orders must be multiples of it
+	Sector   string  `json:"sector"`
+	Currency string  `json:"currency"`
+	Active   bool    `json:"active"` // false for delisted instruments
+	Listed   bool    `json:"listed"` // shown in instruments list, curated by admins
+
+	Kind    string    `json:"kind"`
+	Nominal float64   `json:"nominal,omitempty"` // bond nominal
+	Coupons []*Coupon `json:"coupons,omitempty"` // bond coupon schedule sorted by date
+}
+
+// Coupon is a bond coupon payment per bond.
+type Coupon struct {
+	Date   time.Time `json:"date"`
+	Amount float64   `json:"amount"`
+}
+
+// AccruedInterest returns accrued coupon income of a bond at t: the part of the next coupon proportional to days
+// passed since the previous coupon. The first coupon period is assumed to be equal to the next one.
+func (i *Instrument) AccruedInterest(t time.Time) float64 {
+	if i.Kind != InstrumentKindBond {
+		return 0
+	}
+
+	for n, coupon := range i.Coupons {
+		if !coupon.Date.After(t) {
+			continue
+		}
+
+		var periodStart time.Time
+		switch {
+		case n > 0:
+			periodStart = i.Coupons[n-1].Date
+		case len(i.Coupons) > 1:
+			periodStart = coupon.Date.Add(-i.Coupons[1].Date.Sub(coupon.Date))
+		default:
+			return 0
+		}
+
+		period := coupon.Date.Sub(periodStart)
+		if period <= 0 || t.Before(periodStart) {
+			return 0
+		}
+
+		return coupon.Amount * float64(t.Sub(periodStart)) / float64(period)
+	}
+
+	return 0
+}
+
+// NextCoupon returns the first coupon of the bond after t or nil.
+func (i *Instrument) NextCoupon(t time.Time) *Coupon {
+	for _, coupon := range i.Coupons {
+		if coupon.Date.After(t) {
+			return coupon
+		}
+	}
+
+	return nil
+}
+
+// UnitPrice returns price of one instrument unit by its quote at t. Bonds are quoted in percent of nominal,
+// so their price is the part of nominal plus accrued interest. Zero quote (no trades) is kept zero.
+func (i *Instrument) UnitPrice(quote float64, t time.Time) float64 {
+	if i.Kind != InstrumentKindBond || quote == 0 {
+		return quote
+	}
+
+	return quote/100*i.Nominal + i.AccruedInterest(t)
+}
+
+// UnitPrices converts quotes to prices of one instrument unit at t, see UnitPrice.
+func (i *Instrument) UnitPrices(quotes InstrumentPrices, t time.Time) InstrumentPrices {
+	prices := InstrumentPrices{
+		Last:   i.UnitPrice(quotes.Last, t),
+		Bid:    i.UnitPrice(quotes.Bid, t),
+		Ask:    i.UnitPrice(quotes.Ask, t),
+		Change: quotes.Change,
+		Volume: quotes.Volume,
+	}
+
+	if i.Kind == InstrumentKindBond {
+		prices.Change = quotes.Change / 100 * i.Nominal
+	}
+
+	return prices
 }
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// MarketDataProvider is a source of quotes, candles and order books, e.g. Finam or a fake provider for local runs.
+type MarketDataProvider interface {
+	GetInstrumentPrices(ctx context.Context, ticker string) (*Instrument, error)
+	// GetInstrumentInfo returns boterrs.ErrInstrumentNotFound for unknown ticker.
+	GetInstrumentInfo(ctx context.Context, ticker string) (*Instrument, error)
+	// GetInstruments returns tradable MOEX instruments with kinds. Lot sizes, min price steps and decimals may be
+	// zero, they are returned by GetInstrumentInfo.
+	GetInstruments(ctx context.Context) ([]*Instrument, error)
+	GetCandles(ctx context.Context, ticker, timeframe string, from, to time.Time) ([]*Candle, error)
+	// GetOrderBook returns up to depth best levels of each side.
+	GetOrderBook(ctx context.Context, ticker string, depth int) (*OrderBook, error)
+}
+
+type OrderBookLevel struct {
+	Price float64 `json:"price"`
+	Size  float64 `json:"size"`
+}
+
+// OrderBook keeps levels of each side ordered from the best price.
+type OrderBook struct {
+	Bids []*OrderBookLevel `json:"bids"`
+	Asks []*OrderBookLevel `json:"asks"`
+}
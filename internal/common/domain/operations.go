@@ -8,6 +8,8 @@ import (
 type OperationsRepository interface {
 	GetOperationsPagesCount(ctx context.Context, userID int64) (int64, error)
 	GetOperationsByPage(ctx context.Context, userID, page int64) ([]*Operation, error)
+	// IsOperationCopied reports whether the user has already copied the operation of a trader.
+	IsOperationCopied(ctx context.Context, userID, operationID int64) (bool, error)
 }
 
 type Operation struct {
@@ -18,6 +20,10 @@ type Operation struct {
 	InstrumentName string  `json:"instrument_name"`
 	Count          int64   `json:"count"`
 	TotalAmount    float64 `json:"total_amount"`
+	Currency       string  `json:"currency"` // currency of total amount
+
+	CopiedOperationID int64  `json:"copied_operation_id"` // trader's operation copied by the order, 0 for own orders
+	CopiedFrom        string `json:"copied_from"`         // username of the copied trader
 
 	CreatedAt time.Time `json:"created_at"`
 }
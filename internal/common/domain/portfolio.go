@@ -10,11 +10,43 @@ type PortfolioRepository interface {
 	GetUsersInstrumentTickers(ctx context.Context) ([]string, error)
 	GetUserPortfolioPagesCount(ctx context.Context, userID int64) (int64, error)
 	GetUserPortfolioByPage(ctx context.Context, userID int64, currentPage int64) ([]*UserInstrument, error)
+	// GetUserPortfolio returns all user's positions sorted by instrument name.
+	GetUserPortfolio(ctx context.Context, userID int64) ([]*UserInstrument, error)
 	GetUserMostExpensiveShort(ctx context.Context, userID int64) (*UserInstrument, error)
+	// GetMaxInstrumentCountToBuy and GetMaxInstrumentCountToSell return counts rounded down to lot size.
 	GetMaxInstrumentCountToBuy(ctx context.Context, userID int64, ticker string, price float64) (int64, error)
-	BuyInstrument(ctx context.Context, userID, instrumentID, countToBuy int64, price float64) error
+	// BuyInstrument and SellInstrument return boterrs.ErrInvalidLotCount if count isn't a multiple of lot size
+	// and doesn't close the whole position, boterrs.ErrInvalidPriceStep if price isn't on min price step.
+	// Instruments in other currencies are settled with the currency cash balance and can't be sold short
+	// (boterrs.ErrForeignShort). Positions in inactive instruments can be closed only (boterrs.ErrInactiveInstrument).
+	// Currency instruments of CurrencyTickers exchange L$ to the currency balance. Executed orders store
+	// TradeExecuted event with the source by actor of ctx. Copy of the operation which is already copied
+	// by the user returns boterrs.ErrOperationCopied.
+	BuyInstrument(ctx context.Context, userID, instrumentID, countToBuy int64, price float64) (*TradeResult, error)
 	GetMaxInstrumentCountToSell(ctx context.Context, userID int64, ticker string, price float64) (int64, error)
-	SellInstrument(ctx context.Context, userID, instrumentID, countToSell int64, price float64) error
+	SellInstrument(ctx context.Context, userID, instrumentID, countToSell int64, price float64) (*TradeResult, error)
+	// GetUserCurrencyBalances returns user's non-zero cash balances in currencies other than the base one.
+	GetUserCurrencyBalances(ctx context.Context, userID int64) ([]*CurrencyBalance, error)
+	// GetCurrencyBalances returns non-zero cash balances of all users in currencies other than the base one.
+	GetCurrencyBalances(ctx context.Context) ([]*CurrencyBalance, error)
+	// PayCoupons pays unpaid coupons of bonds dated after since and not after until: coupon amount per bond
+	// is credited to holders of longs and debited from holders of shorts. Payments are recorded as coupon
+	// operations, every coupon is paid once. It returns count of paid coupons.
+	PayCoupons(ctx context.Context, since, until time.Time) (int64, error)
+}
+
+// TradeResult describes an executed order.
+type TradeResult struct {
+	OperationID  int64   `json:"operation_id"`
+	InstrumentID int64   `json:"instrument_id"`
+	Ticker       string  `json:"ticker"`
+	Type         string  `json:"type"` // buy, sell, fx_buy or fx_sell
+	Count        int64   `json:"count"`
+	Price        float64 `json:"price"`
+	ClosedCount  int64   `json:"closed_count"` // count of the closed opposite position
+	CloseResult  float64 `json:"close_result"` // result of closing with fee, negative for loss
+	OpenedShort  bool    `json:"opened_short"` // true if the order opened or increased a short
+	Position     int64   `json:"position"`     // position count after the order, zero for currency exchange
 }
 
 type UserInstrument struct {
@@ -26,6 +58,8 @@ type UserInstrument struct {
 	Count      int64   `json:"count"`
 	AvgPrice   float64 `json:"avg_price"`
 	BlockPrice float64 `json:"block_price"`
+	LotSize    int64   `json:"lot_size"`
+	Currency   string  `json:"currency"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
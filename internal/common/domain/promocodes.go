@@ -6,6 +6,7 @@ import (
 )
 
 type PromocodesRepository interface {
+	// ApplyPromocode pays the promocode bonus to the user and stores PromocodeApplied event.
 	ApplyPromocode(ctx context.Context, value string, userID int64) (*Promocode, error)
 }
 
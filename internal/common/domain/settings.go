@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// Notification categories of messages sent by the bot on its own, users can mute each of them.
+const (
+	NotificationDailyReward  = "daily_reward"
+	NotificationMarginCall   = "margin_call"
+	NotificationBroadcasts   = "broadcasts" // sent by cmd/service
+	NotificationAchievements = "achievements"
+	NotificationFollows      = "follows"
+)
+
+// NotificationCategories is the order of notification categories in settings.
+var NotificationCategories = []string{
+	NotificationDailyReward,
+	NotificationMarginCall,
+	NotificationBroadcasts,
+	NotificationAchievements,
+	NotificationFollows,
+}
+
+// DailyRewardHour is the hour in user's time zone when claimed daily reward becomes available again.
+const DailyRewardHour = 8
+
+// UserSettings are user's time zone and notifications preferences. Zero value notifies about everything
+// at any time in the time zone of user's language.
+type UserSettings struct {
+	TimeZone           string   `json:"time_zone"`           // IANA time zone, empty value uses language time zone
+	MutedNotifications []string `json:"muted_notifications"` // muted notification categories
+
+	// Notifications aren't sent from QuietHoursFrom to QuietHoursTo hour in user's time zone,
+	// equal values disable quiet hours
+	QuietHoursFrom int `json:"quiet_hours_from"`
+	QuietHoursTo   int `json:"quiet_hours_to"`
+
+	PublicProfile bool `json:"public_profile"` // others can view user's portfolio and follow user's trades
+}
+
+// Location returns user's time zone or fallback if it isn't set or unknown.
+func (s *UserSettings) Location(fallback *time.Location) *time.Location {
+	if s.TimeZone == "" {
+		return fallback
+	}
+
+	location, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return fallback
+	}
+
+	return location
+}
+
+func (s *UserSettings) IsMuted(category string) bool {
+	return slices.Contains(s.MutedNotifications, category)
+}
+
+// IsQuietHour reports whether the hour of user's time zone is in quiet hours.
+func (s *UserSettings) IsQuietHour(hour int) bool {
+	switch {
+	case s.QuietHoursFrom == s.QuietHoursTo:
+		return false
+	case s.QuietHoursFrom < s.QuietHoursTo:
+		return hour >= s.QuietHoursFrom && hour < s.QuietHoursTo
+	default: // quiet hours pass midnight
+		return hour >= s.QuietHoursFrom || hour < s.QuietHoursTo
+	}
+}
+
+// CanNotify reports whether a notification of the category may be sent at t in user's location.
+func (s *UserSettings) CanNotify(category string, t time.Time, location *time.Location) bool {
+	return !s.IsMuted(category) && !s.IsQuietHour(t.In(location).Hour())
+}
+
+// NotificationHour returns the hour itself or the end of quiet hours if the hour is quiet.
+func (s *UserSettings) NotificationHour(hour int) int {
+	if s.IsQuietHour(hour) {
+		return s.QuietHoursTo
+	}
+
+	return hour
+}
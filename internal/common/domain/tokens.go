@@ -0,0 +1,9 @@
+package domain
+
+import "context"
+
+type TokensRepository interface {
+	// SetUserToken stores hash of a new user's API token. Previous user's token becomes invalid.
+	SetUserToken(ctx context.Context, userID int64, tokenHash string) error
+	GetUserIDByToken(ctx context.Context, tokenHash string) (int64, error)
+}
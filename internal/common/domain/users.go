@@ -12,26 +12,37 @@ const (
 )
 
 type UsersRepository interface {
-	CreateUser(ctx context.Context, user *User) error
+	// CreateUser creates the user with initial balances and stores UserRegistered event with Telegram language
+	// of the user.
+	CreateUser(ctx context.Context, user *User, languageCode string) error
 	GetUserByID(ctx context.Context, id int64) (*User, error)
 	GetUsersCount(ctx context.Context) (int64, error)
 	GetAllUsers(ctx context.Context) ([]*User, error)
 	GetTopUsersData(ctx context.Context) ([]*TopUserData, error)
-	GetUsersClaimedDailyReward(ctx context.Context) ([]*User, error)
-	ResetDailyReward(ctx context.Context) error
+	// ResetDailyReward makes daily reward available again for the users on their local days (user ID -> day),
+	// streaks of the users who haven't claimed the current reward are reset. A user is reset once per local day,
+	// so changed time zone or quiet hours don't reset the reward again. It returns IDs of the reset users.
+	ResetDailyReward(ctx context.Context, days map[int64]time.Time) ([]int64, error)
 	// UpdateUserTGData updates username, first name, last name and is_premium fields of the user.
 	UpdateUserTGData(ctx context.Context, user *User) error
 	UpdateUserLanguage(ctx context.Context, userID int64, languageCode string) error
-	// UpdateUserBalancesAndMarginCall updates available_balance and margin_call by gotten values.
-	// Set blocked_balance = blocked_balance - blockedBalanceDelta. Nil values will be ignored to update.
-	UpdateUserBalancesAndMarginCall(
-		ctx context.Context, userID int64, availableBalance float64, blockedBalanceDelta *float64, marginCall *bool,
-	) error
-	ClaimDailyReward(ctx context.Context, userID int64, amount float64) error
+	UpdateUserSettings(ctx context.Context, userID int64, settings *UserSettings) error
+	// UpdateUserBalancesAndMarginCall moves blockedDiff from blocked to available balance (negative blockedDiff
+	// blocks more funds) and sets margin call if available balance becomes negative. Balances are changed by delta,
+	// so orders and rewards committed after the revaluation snapshot aren't overwritten. Change of margin call
+	// stores MarginCallEntered or MarginCallCleared event, the latter tells whether the user was stopped out. It returns the user with updated balances and margin call.
+	UpdateUserBalancesAndMarginCall(ctx context.Context, userID int64, blockedDiff float64) (*User, error)
+	// DeferMarginCallWarning marks the user's margin call warning to be sent after quiet hours. The mark is
+	// dropped with any change of margin call.
+	DeferMarginCallWarning(ctx context.Context, userID int64) error
+	// TakeDeferredMarginCallWarnings drops deferred margin call warnings of the users and returns IDs of the users
+	// who had them.
+	TakeDeferredMarginCallWarnings(ctx context.Context, userIDs []int64) ([]int64, error)
+	// ClaimDailyReward pays the reward of the tier reached by the new claims streak and stores RewardClaimed event.
+	ClaimDailyReward(ctx context.Context, userID int64, tiers DailyRewardTiers) (*DailyRewardClaim, error)
 }
 
 type Metadata struct {
-	InstrumentDone      *chan struct{}
 	InstrumentTicker    string
 	InstrumentBuyPrice  float64
 	InstrumentSellPrice float64
@@ -53,9 +64,11 @@ type User struct {
 	BlockedBalance   float64 `json:"blocked_balance"`
 	MarginCall       bool    `json:"margin_call"`
 
-	DailyReward bool `json:"daily_reward"`
+	DailyReward       bool `json:"daily_reward"`        // true if the reward can be claimed
+	DailyRewardStreak int  `json:"daily_reward_streak"` // consecutive days of claims
 
-	Metadata Metadata `json:"metadata"`
+	Settings UserSettings `json:"settings"`
+	Metadata Metadata     `json:"metadata"`
 
 	UpdatedAt time.Time `json:"updated_at"`
 	CreatedAt time.Time `json:"created_at"`
@@ -71,11 +84,15 @@ type TopUser struct {
 	BlockedBalanceDiff float64 `json:"blocked_balance_diff"`
 	TotalBalance       float64 `json:"total_balance"`
 	MarginCall         bool    `json:"margin_call"`
+
+	Badges        string `json:"badges"`         // badges of unlocked achievements
+	PublicProfile bool   `json:"public_profile"` // the user can be viewed and followed
 }
 
 type TopUserData struct {
 	TopUser
 
-	Ticker string `json:"ticker"`
-	Count  int64  `json:"count"`
+	Ticker   string `json:"ticker"`
+	Currency string `json:"currency"`
+	Count    int64  `json:"count"`
 }
@@ -0,0 +1,265 @@
+// Package events contains in-process publish/subscribe event bus for domain events.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// PollInterval is a period of outbox checks for events left by other instances or before a crash.
+	// Events published by the instance are delivered immediately.
+	PollInterval = 5 * time.Second
+
+	batchSize = 100
+	// claimTimeout is a lease of claimed events, events of an instance which crashed are delivered
+	// again by any instance after it. The lease of a batch is extended while the batch is being delivered.
+	claimTimeout = 5 * time.Minute
+
+	// failed events are delivered again after retryBackoff doubled for every failed attempt up to
+	// maxRetryBackoff, events failed maxAttempts times are dropped
+	retryBackoff    = 10 * time.Second
+	maxRetryBackoff = time.Hour
+	maxAttempts     = 10
+)
+
+type subscriber struct {
+	name   string
+	handle func(ctx context.Context, payload []byte) error
+}
+
+// Bus is an in-process publish/subscribe event bus. Published events are stored in the outbox first and
+// delivered to subscribers by Run, so events published before a crash are delivered after restart.
+// Events caused by changes in repositories are stored by the repositories, Notify wakes up their delivery.
+// Delivery is at least once: events claimed by an instance which crashed are delivered again, events failed
+// by a subscriber are delivered again after a backoff only to subscribers which haven't handled them yet.
+type Bus struct {
+	outbox domain.OutboxRepository
+
+	mu          sync.RWMutex
+	subscribers map[string][]*subscriber // event type -> subscribers
+
+	published chan struct{} // wakes up Run after publishing
+}
+
+func NewBus(outbox domain.OutboxRepository) *Bus {
+	return &Bus{
+		outbox:      outbox,
+		subscribers: make(map[string][]*subscriber),
+		published:   make(chan struct{}, 1),
+	}
+}
+
+// Subscribe registers handler of events of type E, name is used in logs and metrics. Names of subscribers of
+// an event type must be unique, they are stored with failed events to skip subscribers which handled them.
+func Subscribe[E domain.Event](b *Bus, name string, handler func(ctx context.Context, event E) error) {
+	var zero E
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[zero.EventType()] = append(b.subscribers[zero.EventType()], &subscriber{
+		name: name,
+		handle: func(ctx context.Context, payload []byte) error {
+			var event E
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return fmt.Errorf("failed to unmarshal event: %v", err)
+			}
+
+			return handler(ctx, event)
+		},
+	})
+}
+
+// Publish stores the event which isn't caused by a change in a repository in the outbox, it is delivered
+// to subscribers asynchronously.
+func (b *Bus) Publish(ctx context.Context, event domain.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to marshal event: %v", err))
+	}
+
+	if err := b.outbox.AddEvent(ctx, event.EventType(), payload); err != nil {
+		return errs.NewStack(fmt.Errorf("failed to add event to outbox: %v", err))
+	}
+
+	b.Notify()
+
+	return nil
+}
+
+// Notify wakes up delivery of events stored in the outbox by the instance, other events are delivered
+// after PollInterval.
+func (b *Bus) Notify() {
+	select {
+	case b.published <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers stored events to subscribers until ctx is done.
+func (b *Bus) Run(ctx context.Context) {
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+
+	b.deliverPending(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("event bus shutting down...")
+			return
+
+		case <-ticker.C:
+		case <-b.published:
+		}
+
+		b.deliverPending(ctx)
+	}
+}
+
+// deliverPending delivers batches of stored events until the outbox is empty. Every event is deleted or
+// released for retry right after its delivery, so no transaction is kept open while subscribers handle events.
+func (b *Bus) deliverPending(ctx context.Context) {
+	for {
+		events, err := b.outbox.ClaimEvents(ctx, batchSize, claimTimeout)
+		if err != nil {
+			log.Error("failed to claim outbox events", zap.Error(err))
+			return
+		}
+
+		b.deliverBatch(ctx, events)
+
+		if len(events) < batchSize {
+			return
+		}
+	}
+}
+
+// deliverBatch delivers claimed events in order and extends the lease of undelivered ones until all of them
+// are delivered, so a slow batch isn't claimed by another instance.
+func (b *Bus) deliverBatch(ctx context.Context, events []*domain.OutboxEvent) {
+	var (
+		delivered atomic.Int64 // count of delivered events from the start of the batch
+		wg        sync.WaitGroup
+	)
+
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(claimTimeout / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			ids := make([]int64, 0, len(events))
+			for _, event := range events[delivered.Load():] {
+				ids = append(ids, event.ID)
+			}
+
+			if err := b.outbox.ExtendClaims(ctx, ids, claimTimeout); err != nil {
+				log.Error("failed to extend claims of outbox events", zap.Error(err))
+			}
+		}
+	}()
+
+	for i, event := range events {
+		deliveredTo, failed := b.deliver(ctx, event)
+		b.complete(ctx, event, deliveredTo, failed)
+		delivered.Store(int64(i + 1))
+	}
+}
+
+// complete deletes the event handled by all subscribers or releases it for retry after a backoff.
+func (b *Bus) complete(ctx context.Context, event *domain.OutboxEvent, deliveredTo []string, failed bool) {
+	if failed && event.Attempts+1 < maxAttempts {
+		if err := b.outbox.RetryEvent(ctx, event.ID, deliveredTo, retryDelay(event.Attempts)); err != nil {
+			log.Error("failed to release event for retry", zap.Int64("event_id", event.ID), zap.Error(err))
+		}
+
+		return
+	}
+
+	if failed {
+		log.Error("event is dropped after failed attempts",
+			zap.Int64("event_id", event.ID),
+			zap.String("type", event.Type),
+			zap.Int("attempts", event.Attempts+1),
+			zap.Strings("delivered_to", deliveredTo),
+		)
+	}
+
+	if err := b.outbox.DeleteEvent(ctx, event.ID); err != nil {
+		log.Error("failed to delete delivered event", zap.Int64("event_id", event.ID), zap.Error(err))
+	}
+}
+
+// retryDelay returns backoff of the retry after attempts previous failed attempts, attempts are less than
+// maxAttempts.
+func retryDelay(attempts int) time.Duration {
+	return min(retryBackoff<<attempts, maxRetryBackoff)
+}
+
+// deliver passes the event to its subscribers which haven't handled it on previous attempts. It returns names of
+// all subscribers which have handled the event and whether any subscriber failed. Errors of subscribers don't stop
+// delivery to other subscribers.
+func (b *Bus) deliver(ctx context.Context, event *domain.OutboxEvent) ([]string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	metrics.Events.WithLabelValues(event.Type).Inc()
+
+	var (
+		deliveredTo = slices.Clone(event.DeliveredTo)
+		failed      bool
+	)
+	for _, s := range b.subscribers[event.Type] {
+		if slices.Contains(event.DeliveredTo, s.name) {
+			continue
+		}
+
+		if err := s.handle(ctx, event.Payload); err != nil {
+			failed = true
+			metrics.EventSubscriberErrors.WithLabelValues(s.name).Inc()
+
+			log.Error("failed to handle event",
+				zap.Int64("event_id", event.ID),
+				zap.String("type", event.Type),
+				zap.String("subscriber", s.name),
+				zap.Int("attempt", event.Attempts+1),
+				zap.Error(err),
+			)
+
+			continue
+		}
+
+		deliveredTo = append(deliveredTo, s.name)
+	}
+
+	return deliveredTo, failed
+}
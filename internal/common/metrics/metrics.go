@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "success_bot"
+
+// Transports of handlers latency.
+const (
+	TransportBot = "bot"
+	TransportAPI = "api"
+)
+
+var (
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "handler_duration_seconds",
+		Help:      "Duration of bot updates and API requests handling by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"transport", "route"})
+
+	FinamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "finam_request_duration_seconds",
+		Help:      "Duration of Finam API requests by method and ticker.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "ticker"})
+
+	FinamRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "finam_request_errors_total",
+		Help:      "Count of failed Finam API requests by method and ticker.",
+	}, []string{"method", "ticker"})
+
+	TradesExecuted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trades_executed_total",
+		Help:      "Count of executed trades by operation type and source.",
+	}, []string{"operation", "source"})
+
+	MarginCalls = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "margin_calls_total",
+		Help:      "Count of triggered margin calls.",
+	})
+
+	UsersRegistered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "users_registered_total",
+		Help:      "Count of registered users by Telegram language.",
+	}, []string{"language"})
+
+	DailyRewardsClaimed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "daily_rewards_claimed_total",
+		Help:      "Count of claimed daily rewards by tier.",
+	}, []string{"tier"})
+
+	PromocodesApplied = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "promocodes_applied_total",
+		Help:      "Count of applied promocodes.",
+	})
+
+	Events = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_total",
+		Help:      "Count of delivered domain events by type.",
+	}, []string{"type"})
+
+	EventSubscriberErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_subscriber_errors_total",
+		Help:      "Count of failed handlings of domain events by subscriber.",
+	}, []string{"subscriber"})
+
+	CopyTradeErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "copy_trade_errors_total",
+		Help:      "Count of trades which weren't copied to a follower.",
+	})
+
+	InstrumentWatchers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "instrument_watchers",
+		Help:      "Count of active instrument price watcher goroutines.",
+	})
+
+	CacheUpdaterDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cache_updater_cycle_duration_seconds",
+		Help:      "Duration of balances and top users updater cycle.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60},
+	})
+)
+
+// Sources of trades.
+const (
+	SourceBot     = "bot"
+	SourceAPI     = "api"
+	SourceStopOut = "stop_out"
+	SourceCopy    = "copy"
+)
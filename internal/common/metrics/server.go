@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/common/config"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+const healthCheckTimeout = 3 * time.Second
+
+// HealthCheck returns error if checked dependency is unavailable.
+type HealthCheck func(ctx context.Context) error
+
+// Server serves /metrics for Prometheus and /healthz for liveness and readiness probes.
+type Server struct {
+	http *http.Server
+
+	checks map[string]HealthCheck
+}
+
+func NewServer(cfg *config.Metrics, checks map[string]HealthCheck) *Server {
+	s := &Server{
+		checks: checks,
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", s.healthzHandler)
+
+	s.http = &http.Server{
+		Addr:    cfg.Listen,
+		Handler: mux,
+	}
+
+	return s
+}
+
+type healthzResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	res := &healthzResponse{
+		Status: "ok",
+		Checks: make(map[string]string, len(s.checks)),
+	}
+	code := http.StatusOK
+
+	for name, check := range s.checks {
+		if err := check(ctx); err != nil {
+			log.Error("health check failed", zap.String("check", name), zap.Error(err))
+
+			res.Checks[name] = err.Error()
+			res.Status = "fail"
+			code = http.StatusServiceUnavailable
+
+			continue
+		}
+
+		res.Checks[name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Error("failed to write healthz response", zap.Error(err))
+	}
+}
+
+func (s *Server) Start() {
+	if err := s.http.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("metrics server stopped", zap.Error(err))
+	}
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	if err := s.http.Shutdown(ctx); err != nil {
+		return fmt.Errorf("http.Shutdown: %w", err)
+	}
+
+	return nil
+}
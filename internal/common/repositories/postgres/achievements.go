@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+)
+
+type achievementsRepository struct {
+	psql *pgxpool.Pool
+}
+
+func NewAchievementsRepository(pool *pgxpool.Pool) domain.AchievementsRepository {
+	return &achievementsRepository{
+		psql: pool,
+	}
+}
+
+// AddAchievementProgress adds delta to user's progress of the achievement. The achievement is unlocked when
+// progress reaches its target and non-zero bonus is paid by an achievement operation. It returns true only
+// for the call which unlocked the achievement, progress of unlocked achievements isn't changed.
+func (ar *achievementsRepository) AddAchievementProgress(
+	ctx context.Context, userID int64, achievement *domain.Achievement, delta int, bonus float64,
+) (bool, error) {
+	tx, err := ar.psql.Begin(ctx)
+	if err != nil {
+		return false, errs.NewStack(err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	// the row is locked by upsert, so concurrent calls can't unlock the achievement twice
+	query := `INSERT INTO success_bot.users_achievements(user_id, achievement, progress)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, achievement) DO UPDATE
+			SET progress = success_bot.users_achievements.progress + excluded.progress
+			WHERE success_bot.users_achievements.unlocked_at IS NULL
+		RETURNING id, progress`
+	var (
+		id       int64
+		progress int
+	)
+	if err := tx.QueryRow(ctx, query, userID, achievement.ID, delta).Scan(&id, &progress); err != nil {
+		// nothing is returned for unlocked achievement
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, errs.NewStack(err)
+	}
+
+	unlocked := progress >= achievement.Target
+	if unlocked {
+		query = `UPDATE success_bot.users_achievements SET unlocked_at = now() WHERE id = $1`
+		if _, err := tx.Exec(ctx, query, id); err != nil {
+			return false, errs.NewStack(err)
+		}
+	}
+
+	if unlocked && bonus > 0 {
+		if err = changeBalances(ctx, tx, &balanceChange{
+			userID:         userID,
+			reason:         domain.BalanceReasonAchievement,
+			availableDelta: bonus,
+		}); err != nil {
+			return false, errs.NewStack(err)
+		}
+
+		// instrument_id of achievement operation is the users_achievements row id
+		query = `INSERT INTO success_bot.operations(user_id, instrument_id, type, count, price, total_amount)
+			VALUES ($1, $2, 'achievement', 1, $3, $3)`
+		if _, err = tx.Exec(ctx, query, userID, id, bonus); err != nil {
+			return false, errs.NewStack(err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, errs.NewStack(err)
+	}
+
+	return unlocked, nil
+}
+
+func (ar *achievementsRepository) GetUserAchievements(ctx context.Context, userID int64) ([]*domain.UserAchievement, error) {
+	query := `SELECT achievement, progress, unlocked_at
+		FROM success_bot.users_achievements
+		WHERE user_id = $1`
+	rows, err := ar.psql.Query(ctx, query, userID)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	achievements := []*domain.UserAchievement{}
+	for rows.Next() {
+		achievement := &domain.UserAchievement{}
+		if err := rows.Scan(&achievement.AchievementID, &achievement.Progress, &achievement.UnlockedAt); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		achievements = append(achievements, achievement)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return achievements, nil
+}
+
+// GetUnlockedAchievements returns IDs of unlocked achievements of all users in unlock order: user ID -> IDs.
+func (ar *achievementsRepository) GetUnlockedAchievements(ctx context.Context) (map[int64][]string, error) {
+	query := `SELECT user_id, achievement
+		FROM success_bot.users_achievements
+		WHERE unlocked_at IS NOT NULL
+		ORDER BY unlocked_at, id`
+	rows, err := ar.psql.Query(ctx, query)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	achievements := make(map[int64][]string)
+	for rows.Next() {
+		var (
+			userID      int64
+			achievement string
+		)
+		if err := rows.Scan(&userID, &achievement); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		achievements[userID] = append(achievements[userID], achievement)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return achievements, nil
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+)
+
+type balanceEventsRepository struct {
+	psql *pgxpool.Pool
+}
+
+func NewBalanceEventsRepository(pool *pgxpool.Pool) domain.BalanceEventsRepository {
+	return &balanceEventsRepository{
+		psql: pool,
+	}
+}
+
+// GetBalanceMismatches checks that every event starts from the previous event balances (zero for the first one)
+// and that the last event balances are equal to the users row.
+func (br *balanceEventsRepository) GetBalanceMismatches(ctx context.Context) ([]*domain.BalanceMismatch, error) {
+	query := `WITH chain AS (
+			SELECT id, user_id, available_before, blocked_before, available_after, blocked_after,
+				LAG(available_after, 1, 0::numeric) OVER w AS prev_available,
+				LAG(blocked_after, 1, 0::numeric) OVER w AS prev_blocked,
+				ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY id DESC) AS rn
+			FROM success_bot.balance_events
+			WINDOW w AS (PARTITION BY user_id ORDER BY id)
+		), broken AS (
+			SELECT user_id, MIN(id) AS event_id
+			FROM chain
+			WHERE available_before <> prev_available OR blocked_before <> prev_blocked
+			GROUP BY user_id
+		), last AS (
+			SELECT user_id, available_after, blocked_after FROM chain WHERE rn = 1
+		)
+		SELECT u.id, u.available_balance, u.blocked_balance,
+			COALESCE(l.available_after, 0), COALESCE(l.blocked_after, 0), COALESCE(b.event_id, 0)
+		FROM success_bot.users u
+		LEFT JOIN last l ON l.user_id = u.id
+		LEFT JOIN broken b ON b.user_id = u.id
+		WHERE b.event_id IS NOT NULL
+			OR u.available_balance <> COALESCE(l.available_after, 0)
+			OR u.blocked_balance <> COALESCE(l.blocked_after, 0)
+		ORDER BY u.id`
+	rows, err := br.psql.Query(ctx, query)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	mismatches := []*domain.BalanceMismatch{}
+	for rows.Next() {
+		mismatch := &domain.BalanceMismatch{}
+		if err := rows.Scan(
+			&mismatch.UserID,
+			&mismatch.AvailableBalance,
+			&mismatch.BlockedBalance,
+			&mismatch.JournalAvailable,
+			&mismatch.JournalBlocked,
+			&mismatch.BrokenEventID,
+		); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		mismatches = append(mismatches, mismatch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return mismatches, nil
+}
+
+type balanceChange struct {
+	userID int64
+	reason string
+
+	availableDelta float64
+	blockedDelta   float64
+}
+
+// changeBalances updates user's balances and journals the change in tx. Actor is taken from ctx.
+// Nothing is journaled if balances are not changed.
+func changeBalances(ctx context.Context, tx pgx.Tx, change *balanceChange) error {
+	query := `WITH prev AS (
+			SELECT available_balance, blocked_balance FROM success_bot.users WHERE id = $1 FOR UPDATE
+		), changed AS (
+			UPDATE success_bot.users u
+			SET available_balance = u.available_balance + $2,
+				blocked_balance = u.blocked_balance + $3
+			FROM prev
+			WHERE u.id = $1
+			RETURNING prev.available_balance AS available_before, prev.blocked_balance AS blocked_before,
+				u.available_balance AS available_after, u.blocked_balance AS blocked_after
+		), journal AS (
+			INSERT INTO success_bot.balance_events(
+				user_id, reason, actor, available_before, blocked_before, available_after, blocked_after
+			)
+			SELECT $1, $4, $5, available_before, blocked_before, available_after, blocked_after
+			FROM changed
+			WHERE available_before <> available_after OR blocked_before <> blocked_after
+		)
+		SELECT COUNT(*) FROM changed`
+	var updated int64
+	if err := tx.QueryRow(ctx, query,
+		change.userID,
+		change.availableDelta,
+		change.blockedDelta,
+		change.reason,
+		domain.ActorFromContext(ctx),
+	).Scan(&updated); err != nil {
+		return errs.NewStack(err)
+	}
+
+	if updated == 0 {
+		return errs.NewStack(boterrs.ErrUserNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+)
+
+type candlesRepository struct {
+	psql *pgxpool.Pool
+}
+
+func NewCandlesRepository(pool *pgxpool.Pool) domain.CandlesRepository {
+	return &candlesRepository{
+		psql: pool,
+	}
+}
+
+func (cr *candlesRepository) GetCandles(
+	ctx context.Context, instrumentID int64, timeframe string, from, to time.Time,
+) ([]*domain.Candle, error) {
+	query := `SELECT
+			time,
+			open,
+			high,
+			low,
+			close,
+			volume
+		FROM success_bot.candles
+		WHERE instrument_id = $1 AND timeframe = $2 AND time BETWEEN $3 AND $4
+		ORDER BY time ASC`
+	rows, err := cr.psql.Query(ctx, query, instrumentID, timeframe, from, to)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	candles := []*domain.Candle{}
+	for rows.Next() {
+		candle := &domain.Candle{}
+		if err := rows.Scan(
+			&candle.Time,
+			&candle.Open,
+			&candle.High,
+			&candle.Low,
+			&candle.Close,
+			&candle.Volume,
+		); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		candles = append(candles, candle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return candles, nil
+}
+
+func (cr *candlesRepository) SaveCandles(
+	ctx context.Context, instrumentID int64, timeframe string, candles []*domain.Candle,
+) error {
+	if len(candles) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO success_bot.candles(instrument_id, timeframe, time, open, high, low, close, volume)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (instrument_id, timeframe, time) DO UPDATE
+		SET open = $4, high = $5, low = $6, close = $7, volume = $8`
+
+	batch := &pgx.Batch{}
+	for _, candle := range candles {
+		batch.Queue(query, instrumentID, timeframe, candle.Time,
+			candle.Open, candle.High, candle.Low, candle.Close, candle.Volume,
+		)
+	}
+
+	if err := cr.psql.SendBatch(ctx, batch).Close(); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+)
+
+type followsRepository struct {
+	psql *pgxpool.Pool
+}
+
+func NewFollowsRepository(pool *pgxpool.Pool) domain.FollowsRepository {
+	return &followsRepository{
+		psql: pool,
+	}
+}
+
+func (fr *followsRepository) Follow(ctx context.Context, followerID, traderID int64) error {
+	query := `INSERT INTO success_bot.users_follows(follower_id, trader_id)
+		VALUES ($1, $2)
+		ON CONFLICT (follower_id, trader_id) DO NOTHING`
+	if _, err := fr.psql.Exec(ctx, query, followerID, traderID); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+func (fr *followsRepository) Unfollow(ctx context.Context, followerID, traderID int64) error {
+	query := `DELETE FROM success_bot.users_follows WHERE follower_id = $1 AND trader_id = $2`
+	if _, err := fr.psql.Exec(ctx, query, followerID, traderID); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+// GetFollowedTraders returns copy percents of the traders followed by the follower by trader IDs.
+func (fr *followsRepository) GetFollowedTraders(ctx context.Context, followerID int64) (map[int64]int, error) {
+	query := `SELECT trader_id, copy_percent FROM success_bot.users_follows WHERE follower_id = $1`
+	rows, err := fr.psql.Query(ctx, query, followerID)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	traders := make(map[int64]int)
+	for rows.Next() {
+		var (
+			traderID int64
+			percent  int
+		)
+		if err := rows.Scan(&traderID, &percent); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		traders[traderID] = percent
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return traders, nil
+}
+
+func (fr *followsRepository) GetFollowers(ctx context.Context, traderID int64) ([]int64, error) {
+	query := `SELECT follower_id FROM success_bot.users_follows WHERE trader_id = $1 ORDER BY created_at`
+	rows, err := fr.psql.Query(ctx, query, traderID)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	followers := []int64{}
+	for rows.Next() {
+		var followerID int64
+		if err := rows.Scan(&followerID); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		followers = append(followers, followerID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return followers, nil
+}
+
+// SetCopyPercent checks the limit of copy percents and sets the percent in one transaction. Follower's row is
+// locked, so concurrent changes of the follower's percents can't exceed the limit together.
+func (fr *followsRepository) SetCopyPercent(ctx context.Context, followerID, traderID int64, percent int) error {
+	tx, err := fr.psql.Begin(ctx)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	query := `SELECT id FROM success_bot.users WHERE id = $1 FOR UPDATE`
+	if _, err = tx.Exec(ctx, query, followerID); err != nil {
+		return errs.NewStack(err)
+	}
+
+	var allocated int
+	query = `SELECT COALESCE(SUM(copy_percent), 0)
+		FROM success_bot.users_follows
+		WHERE follower_id = $1 AND trader_id <> $2`
+	if err = tx.QueryRow(ctx, query, followerID, traderID).Scan(&allocated); err != nil {
+		return errs.NewStack(err)
+	}
+
+	if allocated+percent > domain.MaxCopyPercent {
+		return boterrs.ErrCopyLimitExceeded
+	}
+
+	query = `INSERT INTO success_bot.users_follows(follower_id, trader_id, copy_percent)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (follower_id, trader_id) DO UPDATE
+			SET copy_percent = excluded.copy_percent`
+	if _, err = tx.Exec(ctx, query, followerID, traderID, percent); err != nil {
+		return errs.NewStack(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+func (fr *followsRepository) GetCopiers(ctx context.Context, traderID int64) ([]*domain.Copier, error) {
+	query := `SELECT follower_id, copy_percent
+		FROM success_bot.users_follows
+		WHERE trader_id = $1 AND copy_percent > 0
+		ORDER BY created_at`
+	rows, err := fr.psql.Query(ctx, query, traderID)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	copiers := []*domain.Copier{}
+	for rows.Next() {
+		copier := &domain.Copier{}
+		if err := rows.Scan(&copier.FollowerID, &copier.Percent); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		copiers = append(copiers, copier)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return copiers, nil
+}
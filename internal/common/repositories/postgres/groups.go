@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+)
+
+type groupsRepository struct {
+	psql *pgxpool.Pool
+}
+
+func NewGroupsRepository(pool *pgxpool.Pool) domain.GroupsRepository {
+	return &groupsRepository{
+		psql: pool,
+	}
+}
+
+func (gr *groupsRepository) SaveGroup(ctx context.Context, group *domain.Group) (*domain.Group, error) {
+	query := `INSERT INTO success_bot.groups(id, title, language_code)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE
+			SET title = excluded.title
+		RETURNING id, title, language_code, created_at`
+	saved := &domain.Group{}
+	if err := gr.psql.QueryRow(ctx, query, group.ID, group.Title, group.LanguageCode).Scan(
+		&saved.ID,
+		&saved.Title,
+		&saved.LanguageCode,
+		&saved.CreatedAt,
+	); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return saved, nil
+}
+
+func (gr *groupsRepository) DeleteGroup(ctx context.Context, groupID int64) error {
+	tx, err := gr.psql.Begin(ctx)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	query := `DELETE FROM success_bot.groups_members WHERE group_id = $1`
+	if _, err := tx.Exec(ctx, query, groupID); err != nil {
+		return errs.NewStack(err)
+	}
+
+	query = `DELETE FROM success_bot.groups WHERE id = $1`
+	if _, err := tx.Exec(ctx, query, groupID); err != nil {
+		return errs.NewStack(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+func (gr *groupsRepository) JoinGroup(ctx context.Context, groupID, userID int64) error {
+	query := `INSERT INTO success_bot.groups_members(group_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (group_id, user_id) DO NOTHING`
+	if _, err := gr.psql.Exec(ctx, query, groupID, userID); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+func (gr *groupsRepository) LeaveGroup(ctx context.Context, groupID, userID int64) (bool, error) {
+	query := `DELETE FROM success_bot.groups_members WHERE group_id = $1 AND user_id = $2`
+	tag, err := gr.psql.Exec(ctx, query, groupID, userID)
+	if err != nil {
+		return false, errs.NewStack(err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (gr *groupsRepository) GetGroupMembers(ctx context.Context, groupID int64) ([]int64, error) {
+	query := `SELECT user_id FROM success_bot.groups_members WHERE group_id = $1 ORDER BY created_at`
+	rows, err := gr.psql.Query(ctx, query, groupID)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	members := []int64{}
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		members = append(members, userID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return members, nil
+}
+
+func (gr *groupsRepository) GetUserGroups(ctx context.Context, userID int64) ([]*domain.Group, error) {
+	query := `SELECT g.id, g.title, g.language_code, g.created_at
+		FROM success_bot.groups_members gm
+		JOIN success_bot.groups g ON g.id = gm.group_id
+		WHERE gm.user_id = $1
+		ORDER BY gm.created_at`
+	rows, err := gr.psql.Query(ctx, query, userID)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	groups := []*domain.Group{}
+	for rows.Next() {
+		group := &domain.Group{}
+		if err := rows.Scan(&group.ID, &group.Title, &group.LanguageCode, &group.CreatedAt); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		groups = append(groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return groups, nil
+}
@@ -1,13 +1,19 @@
 package postgres
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"maps"
+	"slices"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leonid6372/success-bot/internal/boterrs"
 	"github.com/leonid6372/success-bot/internal/common/domain"
 	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
 )
 
 type instrumentsRepository struct {
@@ -20,30 +26,70 @@ func NewInstrumentsRepository(pool *pgxpool.Pool) domain.InstrumentsRepository {
 	}
 }
 
-func (ir *instrumentsRepository) CreateInstrument(ctx context.Context, ticker, name string) (*domain.Instrument, error) {
-	query := `INSERT INTO success_bot.instruments (ticker, name)
-		VALUES ($1, $2)
+func (ir *instrumentsRepository) CreateInstrument(
+	ctx context.Context, instrument *domain.Instrument,
+) (*domain.Instrument, error) {
+	query := `INSERT INTO success_bot.instruments
+			(ticker, name, sector, lot_size, min_step, currency, decimals, kind, nominal, listed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, FALSE)
 		RETURNING
 			id,
 			ticker,
-			name`
-	instrument := &Instrument{}
-	if err := ir.psql.QueryRow(ctx, query, ticker, name).Scan(
-		&instrument.ID,
-		&instrument.Ticker,
-		&instrument.Name,
+			name,
+			sector,
+			lot_size,
+			min_step,
+			currency,
+			decimals,
+			active,
+			listed,
+			kind,
+			nominal`
+	created := &Instrument{}
+	if err := ir.psql.QueryRow(ctx, query,
+		instrument.Ticker,
+		instrument.Name,
+		instrument.Sector,
+		max(instrument.LotSize, 1),
+		instrument.MinStep,
+		instrument.Currency,
+		instrument.Decimals,
+		cmp.Or(instrument.Kind, domain.InstrumentKindShare),
+		instrument.Nominal,
+	).Scan(
+		&created.ID,
+		&created.Ticker,
+		&created.Name,
+		&created.Sector,
+		&created.LotSize,
+		&created.MinStep,
+		&created.Currency,
+		&created.Decimals,
+		&created.Active,
+		&created.Listed,
+		&created.Kind,
+		&created.Nominal,
 	); err != nil {
 		return nil, errs.NewStack(err)
 	}
 
-	return instrument.CreateDomain(), nil
+	return created.CreateDomain(), nil
 }
 
 func (ir *instrumentsRepository) GetInstrumentByTicker(ctx context.Context, ticker string) (*domain.Instrument, error) {
 	query := `SELECT
 			id,
 			ticker,
-			name
+			name,
+			sector,
+			lot_size,
+			min_step,
+			currency,
+			decimals,
+			active,
+			listed,
+			kind,
+			nominal
 		FROM success_bot.instruments
 		WHERE ticker = $1`
 	instrument := &Instrument{}
@@ -51,6 +97,15 @@ func (ir *instrumentsRepository) GetInstrumentByTicker(ctx context.Context, tick
 		&instrument.ID,
 		&instrument.Ticker,
 		&instrument.Name,
+		&instrument.Sector,
+		&instrument.LotSize,
+		&instrument.MinStep,
+		&instrument.Currency,
+		&instrument.Decimals,
+		&instrument.Active,
+		&instrument.Listed,
+		&instrument.Kind,
+		&instrument.Nominal,
 	); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, pgx.ErrNoRows
@@ -59,13 +114,51 @@ func (ir *instrumentsRepository) GetInstrumentByTicker(ctx context.Context, tick
 		return nil, errs.NewStack(err)
 	}
 
-	return instrument.CreateDomain(), nil
+	result := instrument.CreateDomain()
+
+	if result.Kind == domain.InstrumentKindBond {
+		coupons, err := ir.getCoupons(ctx, result.ID)
+		if err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		result.Coupons = coupons
+	}
+
+	return result, nil
+}
+
+func (ir *instrumentsRepository) getCoupons(ctx context.Context, instrumentID int64) ([]*domain.Coupon, error) {
+	query := `SELECT date, amount
+		FROM success_bot.bond_coupons
+		WHERE instrument_id = $1
+		ORDER BY date ASC`
+	rows, err := ir.psql.Query(ctx, query, instrumentID)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	coupons := []*domain.Coupon{}
+	for rows.Next() {
+		coupon := &domain.Coupon{}
+		if err := rows.Scan(&coupon.Date, &coupon.Amount); err != nil {
+			return nil, errs.NewStack(err)
+		}
+		coupons = append(coupons, coupon)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return coupons, nil
 }
 
-func (ir *instrumentsRepository) GetInstrumentsPagesCount(ctx context.Context) (int64, error) {
-	query := `SELECT COUNT(*) FROM success_bot.instruments`
+func (ir *instrumentsRepository) GetInstrumentsPagesCount(ctx context.Context, kind string) (int64, error) {
+	query := `SELECT COUNT(*) FROM success_bot.instruments WHERE active AND listed AND ($1 = '' OR kind = $1)`
 	var instrumentsCount int64
-	if err := ir.psql.QueryRow(ctx, query).Scan(&instrumentsCount); err != nil {
+	if err := ir.psql.QueryRow(ctx, query, kind).Scan(&instrumentsCount); err != nil {
 		return 0, errs.NewStack(err)
 	}
 
@@ -74,15 +167,29 @@ func (ir *instrumentsRepository) GetInstrumentsPagesCount(ctx context.Context) (
 	return pagesCount, nil
 }
 
-func (ir *instrumentsRepository) GetInstrumentsByPage(ctx context.Context, page int64) ([]*domain.Instrument, error) {
+func (ir *instrumentsRepository) GetInstrumentsByPage(
+	ctx context.Context, kind string, page int64,
+) ([]*domain.Instrument, error) {
 	query := `SELECT
 			id,
 			ticker,
-			name
+			name,
+			sector,
+			lot_size,
+			min_step,
+			currency,
+			decimals,
+			active,
+			listed,
+			kind,
+			nominal
 		FROM success_bot.instruments
+		WHERE active AND listed AND ($1 = '' OR kind = $1)
 		ORDER BY name ASC
-		LIMIT $1 OFFSET $2`
-	rows, err := ir.psql.Query(ctx, query, domain.ReviewInstrumentsPerPage, (page-1)*domain.ReviewInstrumentsPerPage)
+		LIMIT $2 OFFSET $3`
+	rows, err := ir.psql.Query(ctx, query,
+		kind, domain.ReviewInstrumentsPerPage, (page-1)*domain.ReviewInstrumentsPerPage,
+	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return []*domain.Instrument{}, nil
@@ -99,6 +206,15 @@ func (ir *instrumentsRepository) GetInstrumentsByPage(ctx context.Context, page
 			&instrument.ID,
 			&instrument.Ticker,
 			&instrument.Name,
+			&instrument.Sector,
+			&instrument.LotSize,
+			&instrument.MinStep,
+			&instrument.Currency,
+			&instrument.Decimals,
+			&instrument.Active,
+			&instrument.Listed,
+			&instrument.Kind,
+			&instrument.Nominal,
 		); err != nil {
 			return nil, errs.NewStack(err)
 		}
@@ -107,3 +223,183 @@ func (ir *instrumentsRepository) GetInstrumentsByPage(ctx context.Context, page
 
 	return instruments, nil
 }
+
+// GetInstruments returns all instruments including inactive and hidden ones, bonds are returned without coupons.
+func (ir *instrumentsRepository) GetInstruments(ctx context.Context) ([]*domain.Instrument, error) {
+	query := `SELECT
+			id,
+			ticker,
+			name,
+			sector,
+			lot_size,
+			min_step,
+			currency,
+			decimals,
+			active,
+			listed,
+			kind,
+			nominal
+		FROM success_bot.instruments
+		ORDER BY id`
+	rows, err := ir.psql.Query(ctx, query)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	instruments := []*domain.Instrument{}
+	for rows.Next() {
+		instrument := &Instrument{}
+		if err := rows.Scan(
+			&instrument.ID,
+			&instrument.Ticker,
+			&instrument.Name,
+			&instrument.Sector,
+			&instrument.LotSize,
+			&instrument.MinStep,
+			&instrument.Currency,
+			&instrument.Decimals,
+			&instrument.Active,
+			&instrument.Listed,
+			&instrument.Kind,
+			&instrument.Nominal,
+		); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		instruments = append(instruments, instrument.CreateDomain())
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return instruments, nil
+}
+
+// SyncInstruments adds new instruments hidden from instruments list and keeps names of existing ones.
+// Zero lot size, min step, decimals or nominal, empty sector, currency or kind of synced instrument keeps the stored value,
+// new instruments get RUB currency and share kind by default.
+func (ir *instrumentsRepository) SyncInstruments(
+	ctx context.Context, instruments []*domain.Instrument,
+) (*domain.InstrumentsSyncResult, error) {
+	result := &domain.InstrumentsSyncResult{}
+
+	if len(instruments) == 0 {
+		return result, nil
+	}
+
+	tx, err := ir.psql.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	query := `INSERT INTO success_bot.instruments
+			(ticker, name, sector, lot_size, min_step, currency, decimals, kind, nominal, active, listed, synced_at)
+		VALUES ($1, $2, $3, GREATEST($4, 1), $5, COALESCE(NULLIF($6, ''), $7), $8, COALESCE(NULLIF($9, ''), $11), $10,
+			TRUE, FALSE, NOW())
+		ON CONFLICT (ticker) DO UPDATE SET
+			sector = COALESCE(NULLIF(EXCLUDED.sector, ''), instruments.sector),
+			lot_size = CASE WHEN $4 > 0 THEN EXCLUDED.lot_size ELSE instruments.lot_size END,
+			min_step = CASE WHEN $5 > 0 THEN EXCLUDED.min_step ELSE instruments.min_step END,
+			currency = COALESCE(NULLIF($6, ''), instruments.currency),
+			decimals = CASE WHEN $8 > 0 THEN EXCLUDED.decimals ELSE instruments.decimals END,
+			kind = COALESCE(NULLIF($9, ''), instruments.kind),
+			nominal = CASE WHEN $10 > 0 THEN EXCLUDED.nominal ELSE instruments.nominal END,
+			active = TRUE,
+			synced_at = NOW()
+		RETURNING id, xmax = 0`
+
+	tickers := make([]string, 0, len(instruments))
+	for _, instrument := range instruments {
+		var id int64
+		var inserted bool
+		if err := tx.QueryRow(ctx, query,
+			instrument.Ticker,
+			instrument.Name,
+			instrument.Sector,
+			instrument.LotSize,
+			instrument.MinStep,
+			instrument.Currency,
+			domain.CurrencyRUB,
+			instrument.Decimals,
+			instrument.Kind,
+			instrument.Nominal,
+			domain.InstrumentKindShare,
+		).Scan(&id, &inserted); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		if inserted {
+			result.Added++
+		} else {
+			result.Updated++
+		}
+
+		if len(instrument.Coupons) > 0 {
+			if err := replaceCoupons(ctx, tx, id, instrument.Coupons); err != nil {
+				return nil, errs.NewStack(err)
+			}
+		}
+
+		tickers = append(tickers, instrument.Ticker)
+	}
+
+	// currencies aren't listed by provider and instruments with open positions are kept tradable to be closed
+	query = `UPDATE success_bot.instruments i
+		SET active = FALSE, synced_at = NOW()
+		WHERE i.active AND i.ticker <> ALL($1) AND i.ticker <> ALL($2)
+			AND NOT EXISTS (SELECT 1 FROM success_bot.users_instruments ui WHERE ui.instrument_id = i.id)`
+	tag, err := tx.Exec(ctx, query, tickers, slices.Collect(maps.Values(domain.CurrencyTickers)))
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	result.Deactivated = tag.RowsAffected()
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return result, nil
+}
+
+func replaceCoupons(ctx context.Context, tx pgx.Tx, instrumentID int64, coupons []*domain.Coupon) error {
+	query := `DELETE FROM success_bot.bond_coupons WHERE instrument_id = $1`
+	if _, err := tx.Exec(ctx, query, instrumentID); err != nil {
+		return errs.NewStack(err)
+	}
+
+	batch := &pgx.Batch{}
+	query = `INSERT INTO success_bot.bond_coupons (instrument_id, date, amount)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (instrument_id, date) DO UPDATE SET amount = EXCLUDED.amount`
+	for _, coupon := range coupons {
+		batch.Queue(query, instrumentID, coupon.Date, coupon.Amount)
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+func (ir *instrumentsRepository) SetInstrumentListed(ctx context.Context, ticker string, listed bool) error {
+	query := `UPDATE success_bot.instruments SET listed = $2 WHERE ticker = $1`
+	tag, err := ir.psql.Exec(ctx, query, ticker, listed)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return boterrs.ErrInstrumentNotFound
+	}
+
+	return nil
+}
@@ -37,19 +37,34 @@ func (or *operationsRepository) GetOperationsByPage(ctx context.Context, userID,
 			o.type,
 			CASE
 				WHEN o.type = 'promocode' OR o.type = 'daily_reward' OR o.type = 'dev_assistance' THEN p.value
+				WHEN o.type = 'achievement' THEN a.achievement
 			ELSE i.name END as name,
 			o.count,
 			o.total_amount,
+			CASE
+				WHEN o.type = 'promocode' OR o.type = 'daily_reward' OR o.type = 'dev_assistance'
+					OR o.type = 'achievement' THEN $4
+			ELSE i.currency END as currency,
+			o.copied_operation_id,
+			cu.username,
 			o.created_at
 		FROM success_bot.operations o
 		LEFT JOIN success_bot.instruments i
 			ON o.instrument_id = i.id
 		LEFT JOIN success_bot.promocodes p
 			ON o.instrument_id = p.id
+		LEFT JOIN success_bot.users_achievements a
+			ON o.type = 'achievement' AND o.instrument_id = a.id
+		LEFT JOIN success_bot.operations co
+			ON o.copied_operation_id = co.id
+		LEFT JOIN success_bot.users cu
+			ON co.user_id = cu.id
 		WHERE o.user_id = $1
 		ORDER BY o.created_at DESC
 		LIMIT $2 OFFSET $3`
-	rows, err := or.psql.Query(ctx, query, userID, domain.OperationsPerPage, (page-1)*domain.OperationsPerPage)
+	rows, err := or.psql.Query(ctx, query, userID, domain.OperationsPerPage, (page-1)*domain.OperationsPerPage,
+		domain.BaseCurrency,
+	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return []*domain.Operation{}, nil
@@ -69,6 +84,9 @@ func (or *operationsRepository) GetOperationsByPage(ctx context.Context, userID,
 			&operation.InstrumentName,
 			&operation.Count,
 			&operation.TotalAmount,
+			&operation.Currency,
+			&operation.CopiedOperationID,
+			&operation.CopiedFrom,
 			&operation.CreatedAt,
 		); err != nil {
 			return nil, errs.NewStack(err)
@@ -78,3 +96,15 @@ func (or *operationsRepository) GetOperationsByPage(ctx context.Context, userID,
 
 	return operations, nil
 }
+
+func (or *operationsRepository) IsOperationCopied(ctx context.Context, userID, operationID int64) (bool, error) {
+	query := `SELECT EXISTS(
+			SELECT 1 FROM success_bot.operations WHERE user_id = $1 AND copied_operation_id = $2
+		)`
+	var copied bool
+	if err := or.psql.QueryRow(ctx, query, userID, operationID).Scan(&copied); err != nil {
+		return false, errs.NewStack(err)
+	}
+
+	return copied, nil
+}
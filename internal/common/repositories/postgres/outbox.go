@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+)
+
+type outboxRepository struct {
+	psql *pgxpool.Pool
+}
+
+func NewOutboxRepository(pool *pgxpool.Pool) domain.OutboxRepository {
+	return &outboxRepository{
+		psql: pool,
+	}
+}
+
+func (or *outboxRepository) AddEvent(ctx context.Context, eventType string, payload []byte) error {
+	query := `INSERT INTO success_bot.outbox_events(type, payload) VALUES ($1, $2)`
+	if _, err := or.psql.Exec(ctx, query, eventType, payload); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+// ClaimEvents claims up to limit pending events for lease and returns them in publishing order. Events claimed
+// by another instance are skipped until their lease expires.
+func (or *outboxRepository) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxEvent, error) {
+	query := `UPDATE success_bot.outbox_events
+		SET claimed_until = NOW() + MAKE_INTERVAL(secs => $2)
+		WHERE id IN (
+			SELECT id
+			FROM success_bot.outbox_events
+			WHERE claimed_until IS NULL OR claimed_until < NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, type, payload, attempts, delivered_to, created_at`
+	rows, err := or.psql.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	events := []*domain.OutboxEvent{}
+	for rows.Next() {
+		event := &domain.OutboxEvent{}
+		if err := rows.Scan(
+			&event.ID, &event.Type, &event.Payload, &event.Attempts, &event.DeliveredTo, &event.CreatedAt,
+		); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	slices.SortFunc(events, func(a, b *domain.OutboxEvent) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return events, nil
+}
+
+// ExtendClaims extends the lease of claimed events which are still being delivered.
+func (or *outboxRepository) ExtendClaims(ctx context.Context, ids []int64, lease time.Duration) error {
+	query := `UPDATE success_bot.outbox_events
+		SET claimed_until = NOW() + MAKE_INTERVAL(secs => $2)
+		WHERE id = ANY($1)`
+	if _, err := or.psql.Exec(ctx, query, ids, lease.Seconds()); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+// RetryEvent counts the failed delivery, stores subscribers which handled the event and releases it to be
+// claimed again after delay.
+func (or *outboxRepository) RetryEvent(ctx context.Context, id int64, deliveredTo []string, delay time.Duration) error {
+	query := `UPDATE success_bot.outbox_events
+		SET attempts = attempts + 1, delivered_to = $2, claimed_until = NOW() + MAKE_INTERVAL(secs => $3)
+		WHERE id = $1`
+	if _, err := or.psql.Exec(ctx, query, id, deliveredTo, delay.Seconds()); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+func (or *outboxRepository) DeleteEvent(ctx context.Context, id int64) error {
+	query := `DELETE FROM success_bot.outbox_events WHERE id = $1`
+	if _, err := or.psql.Exec(ctx, query, id); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+// addEvent stores the event in the outbox within the transaction of the change which caused it,
+// so the event is stored if and only if the change is committed.
+func addEvent(ctx context.Context, tx pgx.Tx, event domain.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errs.NewStack(fmt.Errorf("failed to marshal event: %v", err))
+	}
+
+	query := `INSERT INTO success_bot.outbox_events(type, payload) VALUES ($1, $2)`
+	if _, err := tx.Exec(ctx, query, event.EventType(), payload); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
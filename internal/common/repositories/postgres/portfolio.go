@@ -3,13 +3,19 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/leonid6372/success-bot/internal/boterrs"
 	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
+	"github.com/leonid6372/success-bot/internal/common/trading"
 	"github.com/leonid6372/success-bot/pkg/errs"
 	"github.com/leonid6372/success-bot/pkg/log"
+	"github.com/leonid6372/success-bot/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -71,20 +77,25 @@ func (pr *portfolioRepository) GetUserPortfolioPagesCount(ctx context.Context, u
 	return pagesCount, nil
 }
 
-func (pr *portfolioRepository) GetUserPortfolioByPage(ctx context.Context, userID, page int64) ([]*domain.UserInstrument, error) {
-	query := `SELECT
+// userInstrumentsQuery selects user's positions, it is completed by ORDER BY and LIMIT clauses.
+const userInstrumentsQuery = `SELECT
 			ui.user_id,
 			i.ticker,
 			i.name,
 			ui.count,
 			ui.average_price,
+			i.lot_size,
+			i.currency,
 			ui.created_at,
 			ui.updated_at
 		FROM success_bot.users_instruments ui
 		JOIN success_bot.instruments i
 			ON ui.instrument_id = i.id
 		WHERE ui.user_id = $1
-		ORDER BY i.name ASC
+		ORDER BY i.name ASC`
+
+func (pr *portfolioRepository) GetUserPortfolioByPage(ctx context.Context, userID, page int64) ([]*domain.UserInstrument, error) {
+	query := userInstrumentsQuery + `
 		LIMIT $2 OFFSET $3`
 	rows, err := pr.psql.Query(ctx, query, userID, domain.PortfolioInstrumentsPerPage, (page-1)*domain.PortfolioInstrumentsPerPage)
 	if err != nil {
@@ -96,6 +107,21 @@ func (pr *portfolioRepository) GetUserPortfolioByPage(ctx context.Context, userI
 	}
 	defer rows.Close()
 
+	return scanUserInstruments(rows)
+}
+
+// GetUserPortfolio returns all user's positions sorted by instrument name.
+func (pr *portfolioRepository) GetUserPortfolio(ctx context.Context, userID int64) ([]*domain.UserInstrument, error) {
+	rows, err := pr.psql.Query(ctx, userInstrumentsQuery, userID)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	return scanUserInstruments(rows)
+}
+
+func scanUserInstruments(rows pgx.Rows) ([]*domain.UserInstrument, error) {
 	userInstruments := []*domain.UserInstrument{}
 	for rows.Next() {
 		userInstrument := &UserInstrument{}
@@ -105,6 +131,8 @@ func (pr *portfolioRepository) GetUserPortfolioByPage(ctx context.Context, userI
 			&userInstrument.InstrumentName,
 			&userInstrument.Count,
 			&userInstrument.AvgPrice,
+			&userInstrument.LotSize,
+			&userInstrument.Currency,
 			&userInstrument.CreatedAt,
 			&userInstrument.UpdatedAt,
 		); err != nil {
@@ -114,6 +142,10 @@ func (pr *portfolioRepository) GetUserPortfolioByPage(ctx context.Context, userI
 		userInstruments = append(userInstruments, userInstrument.CreateDomain())
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
 	return userInstruments, nil
 }
 
@@ -125,6 +157,8 @@ func (pr *portfolioRepository) GetUserMostExpensiveShort(ctx context.Context, us
 			i.name,
 			ui.count,
 			ui.average_price,
+			i.lot_size,
+			i.currency,
 			ui.created_at,
 			ui.updated_at
 		FROM success_bot.users_instruments ui
@@ -141,6 +175,8 @@ func (pr *portfolioRepository) GetUserMostExpensiveShort(ctx context.Context, us
 		&userInstrument.InstrumentName,
 		&userInstrument.Count,
 		&userInstrument.AvgPrice,
+		&userInstrument.LotSize,
+		&userInstrument.Currency,
 		&userInstrument.CreatedAt,
 		&userInstrument.UpdatedAt,
 	); err != nil {
@@ -153,8 +189,23 @@ func (pr *portfolioRepository) GetUserMostExpensiveShort(ctx context.Context, us
 func (pr *portfolioRepository) GetMaxInstrumentCountToSell(
 	ctx context.Context, userID int64, ticker string, price float64,
 ) (int64, error) {
+	instrument, err := getOrderInstrumentByTicker(ctx, pr.psql, ticker)
+	if err != nil {
+		return 0, errs.NewStack(err)
+	}
+
+	// selling of currency is limited by its cash balance
+	if currency := domain.ExchangeCurrency(ticker); currency != "" {
+		balance, err := getCurrencyBalance(ctx, pr.psql, userID, currency)
+		if err != nil {
+			return 0, errs.NewStack(err)
+		}
+
+		return trading.RoundDownToLot(int64(balance), instrument.lotSize), nil
+	}
+
 	var availableBalance float64
-	var maxCount, longCount int64
+	var longCount int64
 
 	query := `SELECT available_balance FROM success_bot.users WHERE id = $1`
 	if err := pr.psql.QueryRow(ctx, query, userID).Scan(&availableBalance); err != nil {
@@ -165,25 +216,107 @@ func (pr *portfolioRepository) GetMaxInstrumentCountToSell(
 		JOIN success_bot.instruments i
 			ON ui.instrument_id = i.id
 		WHERE ui.user_id = $1 AND i.ticker = $2 AND ui.count > 0`
-	err := pr.psql.QueryRow(ctx, query, userID, ticker).Scan(&longCount)
+	err = pr.psql.QueryRow(ctx, query, userID, ticker).Scan(&longCount)
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return 0, errs.NewStack(err)
 	}
 
-	if longCount > 0 {
-		maxCount += longCount                                  // sell to close longs
-		availableBalance += float64(longCount) * price * 0.997 // 0,3 % fee for selling
+	// instruments in other currencies can't be sold short
+	if instrument.currency != domain.BaseCurrency {
+		return trading.RoundDownToLot(longCount, instrument.lotSize), nil
 	}
 
-	maxCount += int64(availableBalance / (price * 0.503)) // 0,3% fee for selling and only 50% need for guarantee coverage
+	return trading.RoundDownToLot(trading.MaxCountToSell(availableBalance, longCount, price), instrument.lotSize), nil
+}
+
+func (pr *portfolioRepository) SellInstrument(
+	ctx context.Context, userID, instrumentID, countToSell int64, price float64,
+) (*domain.TradeResult, error) {
+	ctx, span := tracing.Start(ctx, "PortfolioRepository.SellInstrument",
+		attribute.Int64("user_id", userID),
+		attribute.Int64("instrument_id", instrumentID),
+		attribute.Int64("count", countToSell),
+	)
+
+	result, err := pr.executeOrder(ctx, userID, instrumentID, -countToSell, price)
+	tracing.End(span, err)
 
-	return maxCount, nil
+	return result, err
 }
 
-func (pr *portfolioRepository) SellInstrument(ctx context.Context, userID, instrumentID, countToSell int64, price float64) error {
+func (pr *portfolioRepository) GetMaxInstrumentCountToBuy(
+	ctx context.Context, userID int64, ticker string, price float64,
+) (int64, error) {
+	instrument, err := getOrderInstrumentByTicker(ctx, pr.psql, ticker)
+	if err != nil {
+		return 0, errs.NewStack(err)
+	}
+
+	var availableBalance float64
+	var shortCount int64
+
+	// instruments in other currencies are bought with the currency cash balance
+	if instrument.currency != domain.BaseCurrency {
+		availableBalance, err = getCurrencyBalance(ctx, pr.psql, userID, instrument.currency)
+		if err != nil {
+			return 0, errs.NewStack(err)
+		}
+
+		return trading.RoundDownToLot(trading.MaxCountToBuy(availableBalance, 0, price), instrument.lotSize), nil
+	}
+
+	query := `SELECT available_balance FROM success_bot.users WHERE id = $1`
+	if err := pr.psql.QueryRow(ctx, query, userID).Scan(&availableBalance); err != nil {
+		return 0, errs.NewStack(err)
+	}
+
+	query = `SELECT ABS(count) FROM success_bot.users_instruments ui
+		JOIN success_bot.instruments i
+			ON ui.instrument_id = i.id
+		WHERE ui.user_id = $1 AND i.ticker = $2 AND ui.count < 0`
+	err = pr.psql.QueryRow(ctx, query, userID, ticker).Scan(&shortCount)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, errs.NewStack(err)
+	}
+
+	return trading.RoundDownToLot(trading.MaxCountToBuy(availableBalance, shortCount, price), instrument.lotSize), nil
+}
+
+func (pr *portfolioRepository) BuyInstrument(
+	ctx context.Context, userID, instrumentID, countToBuy int64, price float64,
+) (*domain.TradeResult, error) {
+	ctx, span := tracing.Start(ctx, "PortfolioRepository.BuyInstrument",
+		attribute.Int64("user_id", userID),
+		attribute.Int64("instrument_id", instrumentID),
+		attribute.Int64("count", countToBuy),
+	)
+
+	result, err := pr.executeOrder(ctx, userID, instrumentID, countToBuy, price)
+	tracing.End(span, err)
+
+	return result, err
+}
+
+// copiedOperationIndex makes copies of trader's operation unique per follower.
+const copiedOperationIndex = "operations_copied_operation_idx"
+
+// tradeSources are sources of executed trades by actors of orders.
+var tradeSources = map[string]string{
+	domain.ActorUser:        metrics.SourceBot,
+	domain.ActorAPI:         metrics.SourceAPI,
+	domain.ActorStopOut:     metrics.SourceStopOut,
+	domain.ActorCopyTrading: metrics.SourceCopy,
+}
+
+// executeOrder buys (positive delta) or sells (negative delta) the instrument by price in one transaction:
+// currency instruments exchange L$ balance to the currency balance, others change user's position.
+// Order and its fee are recorded in operations, TradeExecuted event is stored in the outbox.
+func (pr *portfolioRepository) executeOrder(
+	ctx context.Context, userID, instrumentID, delta int64, price float64,
+) (*domain.TradeResult, error) {
 	tx, err := pr.psql.Begin(ctx)
 	if err != nil {
-		return errs.NewStack(err)
+		return nil, errs.NewStack(err)
 	}
 	defer func() {
 		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
@@ -191,145 +324,425 @@ func (pr *portfolioRepository) SellInstrument(ctx context.Context, userID, instr
 		}
 	}()
 
-	remainsCount := countToSell
+	query := `SELECT ` + orderInstrumentColumns + ` FROM success_bot.instruments WHERE id = $1`
+	instrument, err := scanOrderInstrument(tx.QueryRow(ctx, query, instrumentID))
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	result := &domain.TradeResult{
+		InstrumentID: instrumentID,
+		Ticker:       instrument.ticker,
+		Type:         domain.OperationTypeBuy,
+		Count:        delta,
+		Price:        price,
+	}
+	if delta < 0 {
+		result.Type, result.Count = domain.OperationTypeSell, -delta
+	}
+
+	if currency := domain.ExchangeCurrency(instrument.ticker); currency != "" {
+		if err = exchangeCurrency(ctx, tx, userID, instrument, currency, delta, price); err != nil {
+			return nil, err
+		}
+
+		result.Type = domain.OperationTypeFXBuy
+		if delta < 0 {
+			result.Type = domain.OperationTypeFXSell
+		}
+	} else {
+		trade, err := pr.changePosition(ctx, tx, userID, instrumentID, instrument, delta, price)
+		if err != nil {
+			return nil, err
+		}
+
+		result.ClosedCount = trade.CloseCount
+		result.CloseResult = trade.CloseResult
+		result.OpenedShort = trade.OpenCount > 0 && trade.Position.Count < 0
+		result.Position = trade.Position.Count
+	}
+
+	// copied orders are linked to the trader's operation
+	query = `INSERT INTO success_bot.operations(
+			user_id, instrument_id, type, count, price, total_amount, copied_operation_id
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0)) RETURNING id`
+	if err = tx.QueryRow(ctx, query, userID, instrumentID, result.Type, result.Count, price,
+		float64(result.Count)*price, domain.CopiedOperationFromContext(ctx)).Scan(&result.OperationID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == copiedOperationIndex {
+			return nil, boterrs.ErrOperationCopied
+		}
+
+		return nil, errs.NewStack(err)
+	}
+
+	query = `INSERT INTO success_bot.operations(parent_id, user_id, instrument_id, type, count, price, total_amount)
+		VALUES ($1, $2, $3, 'fee', 1, $4, $4)`
+	if _, err = tx.Exec(ctx, query, result.OperationID, userID, instrumentID,
+		trading.Fee(result.Count, price)); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	// cleared margin call of stopped out user isn't survived
+	if domain.ActorFromContext(ctx) == domain.ActorStopOut {
+		query = `UPDATE success_bot.users SET stopped_out = TRUE WHERE id = $1`
+		if _, err = tx.Exec(ctx, query, userID); err != nil {
+			return nil, errs.NewStack(err)
+		}
+	}
+
+	if err = addEvent(ctx, tx, domain.TradeExecuted{
+		UserID: userID,
+		Trade:  result,
+		Source: tradeSources[domain.ActorFromContext(ctx)],
+	}); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewStack(err)
+	}
 
-	var currentCount int64
-	var avgPrice float64
+	return result, nil
+}
+
+// changePosition changes user's position by delta count (negative for selling) by price. An opposite position
+// is closed first, the rest opens or increases a position in order direction. It returns the executed trade.
+func (pr *portfolioRepository) changePosition(
+	ctx context.Context, tx pgx.Tx, userID, instrumentID int64, instrument *orderInstrument, delta int64, price float64,
+) (*trading.Trade, error) {
+	var current trading.Position
 	query := `SELECT count, average_price
 		FROM success_bot.users_instruments
 		WHERE user_id = $1 AND instrument_id = $2 FOR UPDATE`
-	err = tx.QueryRow(ctx, query, userID, instrumentID).Scan(&currentCount, &avgPrice)
+	err := tx.QueryRow(ctx, query, userID, instrumentID).Scan(&current.Count, &current.AvgPrice)
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		return errs.NewStack(err)
+		return nil, errs.NewStack(err)
 	}
 
-	// close long
-	if currentCount > 0 {
-		count := min(remainsCount, currentCount)
-
-		// balanceDiff := sellAmount - 0,3% fee for selling
-		balanceDiff := float64(count)*price - float64(count)*price*0.003
+	if err = validateOrder(instrument, current, delta, price); err != nil {
+		return nil, err
+	}
 
-		query = `UPDATE success_bot.users
-			SET available_balance = available_balance + $1
-			WHERE id = $2`
-		if _, err = tx.Exec(ctx, query, balanceDiff, userID); err != nil {
-			return errs.NewStack(err)
-		}
+	trade, reason := trading.Buy(current, delta, price), domain.BalanceReasonBuy
+	if delta < 0 {
+		trade, reason = trading.Sell(current, -delta, price), domain.BalanceReasonSell
+	}
 
-		if count == currentCount { // close whole long
-			query = `DELETE FROM success_bot.users_instruments
-			WHERE user_id = $1 AND instrument_id = $2`
-			if _, err = tx.Exec(ctx, query, userID, instrumentID); err != nil {
-				return errs.NewStack(err)
-			}
+	if trade.Position.Count < 0 && instrument.currency != domain.BaseCurrency {
+		return nil, boterrs.ErrForeignShort
+	}
 
-			remainsCount -= currentCount
-			currentCount = 0
-			avgPrice = 0
-		} else { // close part of long
-			query = `UPDATE success_bot.users_instruments
-			SET count = count - $1
-			WHERE user_id = $2 AND instrument_id = $3`
-			if _, err = tx.Exec(ctx, query, count, userID, instrumentID); err != nil {
-				return errs.NewStack(err)
-			}
+	// close opposite position
+	if trade.CloseCount > 0 {
+		if err = pr.closePosition(ctx, tx, userID, instrumentID, instrument.currency, reason, trade); err != nil {
+			return nil, errs.NewStack(err)
+		}
+	}
 
-			remainsCount = 0
-			currentCount -= count
+	// open or increase position
+	if trade.OpenCount > 0 {
+		if err = pr.openPosition(ctx, tx, userID, instrumentID, instrument.currency, reason, trade); err != nil {
+			return nil, err
 		}
 	}
 
-	// make sell
-	if remainsCount > 0 {
-		fee := float64(remainsCount) * price * 0.003         // 0,3% fee for selling
-		amountToBlock := float64(remainsCount) * price * 0.5 // 50% guarantee coverage
+	return trade, nil
+}
+
+// exchangeCurrency buys (positive delta) or sells (negative delta) currency by the currency instrument price:
+// L$ balance is exchanged to the currency cash balance and back. Fee is paid in L$. It returns
+// boterrs.ErrInsufficientFunds if L$ balance isn't enough for buying or the currency balance for selling.
+func exchangeCurrency(
+	ctx context.Context, tx pgx.Tx, userID int64, instrument *orderInstrument, currency string, delta int64, price float64,
+) error {
+	if err := validateOrder(instrument, trading.Position{}, delta, price); err != nil {
+		return err
+	}
 
-		needBalance := amountToBlock + fee
+	amount := float64(delta) * price // negative for selling
+	fee := trading.Fee(max(delta, -delta), price)
 
-		var actualBalance float64
-		query = `SELECT available_balance FROM success_bot.users WHERE id = $1 FOR UPDATE`
-		if err = tx.QueryRow(ctx, query, userID).Scan(&actualBalance); err != nil {
+	if delta > 0 {
+		var availableBalance float64
+		query := `SELECT available_balance FROM success_bot.users WHERE id = $1 FOR UPDATE`
+		if err := tx.QueryRow(ctx, query, userID).Scan(&availableBalance); err != nil {
 			return errs.NewStack(err)
 		}
 
-		if actualBalance < needBalance {
+		if availableBalance < amount+fee {
 			return boterrs.ErrInsufficientFunds
 		}
+	}
 
-		query = `UPDATE success_bot.users
-			SET available_balance = available_balance - $1, blocked_balance = blocked_balance + $2
-			WHERE id = $3`
-		if _, err = tx.Exec(ctx, query, needBalance, amountToBlock, userID); err != nil {
-			return errs.NewStack(err)
+	if err := changeCurrencyBalance(ctx, tx, userID, currency, float64(delta)); err != nil {
+		return err
+	}
+
+	if err := changeBalances(ctx, tx, &balanceChange{
+		userID:         userID,
+		reason:         domain.BalanceReasonExchange,
+		availableDelta: -amount - fee,
+	}); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+// orderInstrument is instrument data used to validate and settle orders.
+type orderInstrument struct {
+	ticker   string
+	kind     string
+	currency string
+	lotSize  int64
+	minStep  float64
+	active   bool
+}
+
+const orderInstrumentColumns = `ticker, kind, currency, lot_size, min_step, active`
+
+func scanOrderInstrument(row pgx.Row) (*orderInstrument, error) {
+	instrument := &orderInstrument{}
+	if err := row.Scan(
+		&instrument.ticker,
+		&instrument.kind,
+		&instrument.currency,
+		&instrument.lotSize,
+		&instrument.minStep,
+		&instrument.active,
+	); err != nil {
+		return nil, err
+	}
+
+	return instrument, nil
+}
+
+func getOrderInstrumentByTicker(ctx context.Context, psql *pgxpool.Pool, ticker string) (*orderInstrument, error) {
+	query := `SELECT ` + orderInstrumentColumns + ` FROM success_bot.instruments WHERE ticker = $1`
+	instrument, err := scanOrderInstrument(psql.QueryRow(ctx, query, ticker))
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return instrument, nil
+}
+
+// validateOrder returns boterrs.ErrInvalidLotCount if the position can't be changed by delta count (negative
+// for selling) with instrument's lot size, boterrs.ErrInvalidPriceStep if the price isn't on its min price step
+// and boterrs.ErrInactiveInstrument if the order opens or increases a position in inactive instrument.
+func validateOrder(instrument *orderInstrument, current trading.Position, delta int64, price float64) error {
+	// positions left in inactive instruments can be closed only, so stop-out isn't blocked
+	if !instrument.active && (current.Count*delta >= 0 || max(delta, -delta) > max(current.Count, -current.Count)) {
+		return boterrs.ErrInactiveInstrument
+	}
+
+	if !trading.ValidCount(current, delta, instrument.lotSize) {
+		return boterrs.ErrInvalidLotCount
+	}
+
+	// bond unit price includes accrued interest, so only its quote in percents is on the step
+	if instrument.kind != domain.InstrumentKindBond && !trading.OnPriceStep(price, instrument.minStep) {
+		return boterrs.ErrInvalidPriceStep
+	}
+
+	return nil
+}
+
+// closePosition applies closing part of the trade: result of the opposite position goes to available balance
+// in the instrument currency.
+func (pr *portfolioRepository) closePosition(
+	ctx context.Context, tx pgx.Tx, userID, instrumentID int64, currency, reason string, trade *trading.Trade,
+) error {
+	if currency != domain.BaseCurrency {
+		if err := changeCurrencyBalance(ctx, tx, userID, currency, trade.CloseAvailableDelta); err != nil {
+			return err
 		}
+	} else if err := changeBalances(ctx, tx, &balanceChange{
+		userID:         userID,
+		reason:         reason,
+		availableDelta: trade.CloseAvailableDelta,
+	}); err != nil {
+		return errs.NewStack(err)
+	}
 
-		newCount := currentCount - remainsCount
-		newAvgPrice := (avgPrice*float64(-currentCount) + price*float64(remainsCount)) / float64(-newCount)
+	if trade.OpenCount > 0 {
+		return nil // position row is rewritten by openPosition
+	}
 
-		query = `INSERT INTO success_bot.users_instruments(user_id, instrument_id, count, average_price)
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (user_id, instrument_id) DO UPDATE
-			SET count = $3, average_price = $4`
-		if _, err = tx.Exec(ctx, query, userID, instrumentID, newCount, newAvgPrice); err != nil {
+	if trade.Position.Count == 0 { // close whole position
+		query := `DELETE FROM success_bot.users_instruments
+			WHERE user_id = $1 AND instrument_id = $2`
+		if _, err := tx.Exec(ctx, query, userID, instrumentID); err != nil {
 			return errs.NewStack(err)
 		}
+
+		return nil
 	}
 
-	var opID int64
-	query = `INSERT INTO success_bot.operations(user_id, instrument_id, type, count, price, total_amount)
-		VALUES ($1, $2, 'sell', $3, $4, $5) RETURNING id`
-	if err = tx.QueryRow(ctx, query, userID, instrumentID, countToSell, price, float64(countToSell)*price).
-		Scan(&opID); err != nil {
+	// close part of position
+	query := `UPDATE success_bot.users_instruments
+		SET count = $1
+		WHERE user_id = $2 AND instrument_id = $3`
+	if _, err := tx.Exec(ctx, query, trade.Position.Count, userID, instrumentID); err != nil {
 		return errs.NewStack(err)
 	}
 
-	query = `INSERT INTO success_bot.operations(parent_id, user_id, instrument_id, type, count, price, total_amount)
-		VALUES ($1, $2, $3, 'fee', 1, $4, $4)`
-	if _, err = tx.Exec(ctx, query, opID, userID, instrumentID, float64(countToSell)*price*0.003); err != nil {
-		return errs.NewStack(err)
+	return nil
+}
+
+// openPosition applies opening part of the trade. It returns boterrs.ErrInsufficientFunds if available balance
+// in the instrument currency after closing part isn't enough.
+func (pr *portfolioRepository) openPosition(
+	ctx context.Context, tx pgx.Tx, userID, instrumentID int64, currency, reason string, trade *trading.Trade,
+) error {
+	if currency != domain.BaseCurrency {
+		if err := changeCurrencyBalance(ctx, tx, userID, currency, trade.OpenAvailableDelta); err != nil {
+			return err
+		}
+	} else {
+		var actualBalance float64
+		query := `SELECT available_balance FROM success_bot.users WHERE id = $1 FOR UPDATE`
+		if err := tx.QueryRow(ctx, query, userID).Scan(&actualBalance); err != nil {
+			return errs.NewStack(err)
+		}
+
+		if actualBalance < -trade.OpenAvailableDelta {
+			return boterrs.ErrInsufficientFunds
+		}
+
+		if err := changeBalances(ctx, tx, &balanceChange{
+			userID:         userID,
+			reason:         reason,
+			availableDelta: trade.OpenAvailableDelta,
+			blockedDelta:   trade.OpenBlockedDelta,
+		}); err != nil {
+			return errs.NewStack(err)
+		}
 	}
 
-	if err := tx.Commit(ctx); err != nil {
+	query := `INSERT INTO success_bot.users_instruments(user_id, instrument_id, count, average_price)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, instrument_id) DO UPDATE
+		SET count = $3, average_price = $4`
+	if _, err := tx.Exec(ctx, query, userID, instrumentID, trade.Position.Count, trade.Position.AvgPrice); err != nil {
 		return errs.NewStack(err)
 	}
 
 	return nil
 }
 
-func (pr *portfolioRepository) GetMaxInstrumentCountToBuy(
-	ctx context.Context, userID int64, ticker string, price float64,
-) (int64, error) {
-	var availableBalance float64
-	var maxCount, shortCount int64
+func (pr *portfolioRepository) GetUserCurrencyBalances(ctx context.Context, userID int64) ([]*domain.CurrencyBalance, error) {
+	query := `SELECT user_id, currency, amount
+		FROM success_bot.users_currency_balances
+		WHERE user_id = $1 AND amount <> 0
+		ORDER BY currency`
+	return pr.getCurrencyBalances(ctx, query, userID)
+}
 
-	query := `SELECT available_balance FROM success_bot.users WHERE id = $1`
-	if err := pr.psql.QueryRow(ctx, query, userID).Scan(&availableBalance); err != nil {
-		return 0, errs.NewStack(err)
+func (pr *portfolioRepository) GetCurrencyBalances(ctx context.Context) ([]*domain.CurrencyBalance, error) {
+	query := `SELECT user_id, currency, amount
+		FROM success_bot.users_currency_balances
+		WHERE amount <> 0`
+	return pr.getCurrencyBalances(ctx, query)
+}
+
+func (pr *portfolioRepository) getCurrencyBalances(
+	ctx context.Context, query string, args ...any,
+) ([]*domain.CurrencyBalance, error) {
+	rows, err := pr.psql.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errs.NewStack(err)
 	}
+	defer rows.Close()
 
-	query = `SELECT ABS(count) FROM success_bot.users_instruments ui
+	balances := []*domain.CurrencyBalance{}
+	for rows.Next() {
+		balance := &domain.CurrencyBalance{}
+		if err := rows.Scan(&balance.UserID, &balance.Currency, &balance.Amount); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		balances = append(balances, balance)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return balances, nil
+}
+
+func (pr *portfolioRepository) PayCoupons(ctx context.Context, since, until time.Time) (int64, error) {
+	ctx, span := tracing.Start(ctx, "PortfolioRepository.PayCoupons")
+
+	paid, err := pr.payCoupons(ctx, since, until)
+	tracing.End(span, err)
+
+	return paid, err
+}
+
+// bondCoupon is a coupon of a bond to pay.
+type bondCoupon struct {
+	instrumentID int64
+	currency     string
+	date         time.Time
+	amount       float64
+}
+
+func (pr *portfolioRepository) payCoupons(ctx context.Context, since, until time.Time) (int64, error) {
+	query := `SELECT c.instrument_id, i.currency, c.date, c.amount
+		FROM success_bot.bond_coupons c
 		JOIN success_bot.instruments i
-			ON ui.instrument_id = i.id
-		WHERE ui.user_id = $1 AND i.ticker = $2 AND ui.count < 0`
-	err := pr.psql.QueryRow(ctx, query, userID, ticker).Scan(&shortCount)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			ON c.instrument_id = i.id
+		WHERE c.date > $1::date AND c.date <= $2::date AND NOT EXISTS (
+			SELECT 1 FROM success_bot.bond_coupon_payments p
+			WHERE p.instrument_id = c.instrument_id AND p.date = c.date
+		)
+		ORDER BY c.date, c.instrument_id`
+	rows, err := pr.psql.Query(ctx, query, since, until)
+	if err != nil {
 		return 0, errs.NewStack(err)
 	}
+	defer rows.Close()
+
+	var coupons []*bondCoupon
+	for rows.Next() {
+		coupon := &bondCoupon{}
+		if err := rows.Scan(&coupon.instrumentID, &coupon.currency, &coupon.date, &coupon.amount); err != nil {
+			return 0, errs.NewStack(err)
+		}
+
+		coupons = append(coupons, coupon)
+	}
 
-	if shortCount > 0 {
-		maxCount += shortCount // buy to close shorts
+	if err := rows.Err(); err != nil {
+		return 0, errs.NewStack(err)
 	}
+	rows.Close() // coupons are paid in own transactions
+
+	var paid int64
+	for _, coupon := range coupons {
+		ok, err := pr.payCoupon(ctx, coupon)
+		if err != nil {
+			return paid, err
+		}
 
-	maxCount += int64(availableBalance / (price * 1.003))
+		if ok {
+			paid++
+		}
+	}
 
-	return maxCount, nil
+	return paid, nil
 }
 
-func (pr *portfolioRepository) BuyInstrument(ctx context.Context, userID, instrumentID, countToBuy int64, price float64) error {
+// payCoupon pays the coupon to holders of the bond in one transaction. It returns false if the coupon
+// is already paid, e.g. by another instance.
+func (pr *portfolioRepository) payCoupon(ctx context.Context, coupon *bondCoupon) (bool, error) {
 	tx, err := pr.psql.Begin(ctx)
 	if err != nil {
-		return errs.NewStack(err)
+		return false, errs.NewStack(err)
 	}
 	defer func() {
 		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
@@ -337,105 +750,100 @@ func (pr *portfolioRepository) BuyInstrument(ctx context.Context, userID, instru
 		}
 	}()
 
-	remainsCount := countToBuy
-
-	var currentCount int64
-	var avgPrice float64
-	query := `SELECT count, average_price
-		FROM success_bot.users_instruments
-		WHERE user_id = $1 AND instrument_id = $2 FOR UPDATE`
-	err = tx.QueryRow(ctx, query, userID, instrumentID).Scan(&currentCount, &avgPrice)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		return errs.NewStack(err)
+	query := `INSERT INTO success_bot.bond_coupon_payments(instrument_id, date)
+		VALUES ($1, $2)
+		ON CONFLICT (instrument_id, date) DO NOTHING`
+	tag, err := tx.Exec(ctx, query, coupon.instrumentID, coupon.date)
+	if err != nil {
+		return false, errs.NewStack(err)
 	}
 
-	// close short
-	if currentCount < 0 {
-		count := min(countToBuy, -currentCount)
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
 
-		// balanceDiff := shortResult - 0,3% fee for buying
-		balanceDiff := float64(-count)*(price-avgPrice) - float64(count)*price*0.003
+	query = `SELECT user_id, count
+		FROM success_bot.users_instruments
+		WHERE instrument_id = $1 AND count <> 0
+		FOR UPDATE`
+	rows, err := tx.Query(ctx, query, coupon.instrumentID)
+	if err != nil {
+		return false, errs.NewStack(err)
+	}
+	defer rows.Close()
 
-		query = `UPDATE success_bot.users
-			SET available_balance = available_balance + $1
-			WHERE id = $2`
-		if _, err = tx.Exec(ctx, query, balanceDiff, userID); err != nil {
-			return errs.NewStack(err)
+	var positions []*domain.UserInstrument
+	for rows.Next() {
+		position := &domain.UserInstrument{}
+		if err := rows.Scan(&position.UserID, &position.Count); err != nil {
+			return false, errs.NewStack(err)
 		}
 
-		if count == -currentCount { // close whole short
-			query = `DELETE FROM success_bot.users_instruments
-			WHERE user_id = $1 AND instrument_id = $2`
-			if _, err = tx.Exec(ctx, query, userID, instrumentID); err != nil {
-				return errs.NewStack(err)
-			}
-
-			remainsCount += currentCount
-			currentCount = 0
-			avgPrice = 0
-		} else { // close part of short
-			query = `UPDATE success_bot.users_instruments
-			SET count = count + $1
-			WHERE user_id = $2 AND instrument_id = $3`
-			if _, err = tx.Exec(ctx, query, count, userID, instrumentID); err != nil {
-				return errs.NewStack(err)
-			}
+		positions = append(positions, position)
+	}
 
-			remainsCount = 0
-			currentCount += count
-		}
+	if err := rows.Err(); err != nil {
+		return false, errs.NewStack(err)
 	}
+	rows.Close() // the transaction is used by balance changes below
 
-	// make buy
-	if remainsCount > 0 {
-		// balanceDiff := buyAmount + 0,3% fee for buying
-		balanceDiff := float64(remainsCount) * price * 1.003
+	for _, position := range positions {
+		amount := float64(position.Count) * coupon.amount // negative for shorts
 
-		var actualBalance float64
-		query = `SELECT available_balance FROM success_bot.users WHERE id = $1 FOR UPDATE`
-		if err = tx.QueryRow(ctx, query, userID).Scan(&actualBalance); err != nil {
-			return errs.NewStack(err)
+		// shorts can't be opened in other currencies, so the currency balance is only credited
+		if coupon.currency != domain.BaseCurrency {
+			if err := changeCurrencyBalance(ctx, tx, position.UserID, coupon.currency, amount); err != nil {
+				return false, err
+			}
+		} else if err := changeBalances(ctx, tx, &balanceChange{
+			userID:         position.UserID,
+			reason:         domain.BalanceReasonCoupon,
+			availableDelta: amount,
+		}); err != nil {
+			return false, errs.NewStack(err)
 		}
 
-		if actualBalance < balanceDiff {
-			return boterrs.ErrInsufficientFunds
+		query = `INSERT INTO success_bot.operations(user_id, instrument_id, type, count, price, total_amount)
+			VALUES ($1, $2, $3, $4, $5, $6)`
+		if _, err := tx.Exec(ctx, query, position.UserID, coupon.instrumentID, domain.OperationTypeCoupon,
+			max(position.Count, -position.Count), coupon.amount, amount); err != nil {
+			return false, errs.NewStack(err)
 		}
+	}
 
-		query = `UPDATE success_bot.users
-			SET available_balance = available_balance - $1
-			WHERE id = $2`
-		if _, err = tx.Exec(ctx, query, balanceDiff, userID); err != nil {
-			return errs.NewStack(err)
-		}
+	if err := tx.Commit(ctx); err != nil {
+		return false, errs.NewStack(err)
+	}
 
-		newCount := currentCount + remainsCount
-		newAvgPrice := (avgPrice*float64(currentCount) + price*float64(remainsCount)) / float64(newCount)
+	return true, nil
+}
 
-		query = `INSERT INTO success_bot.users_instruments(user_id, instrument_id, count, average_price)
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (user_id, instrument_id) DO UPDATE
-			SET count = $3, average_price = $4`
-		if _, err = tx.Exec(ctx, query, userID, instrumentID, newCount, newAvgPrice); err != nil {
-			return errs.NewStack(err)
-		}
+// getCurrencyBalance returns user's cash balance in the currency, zero if there is no balance.
+func getCurrencyBalance(ctx context.Context, psql *pgxpool.Pool, userID int64, currency string) (float64, error) {
+	var amount float64
+	query := `SELECT amount FROM success_bot.users_currency_balances WHERE user_id = $1 AND currency = $2`
+	if err := psql.QueryRow(ctx, query, userID, currency).Scan(&amount); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, errs.NewStack(err)
 	}
 
-	var opID int64
-	query = `INSERT INTO success_bot.operations(user_id, instrument_id, type, count, price, total_amount)
-		VALUES ($1, $2, 'buy', $3, $4, $5) RETURNING id`
-	if err = tx.QueryRow(ctx, query, userID, instrumentID, countToBuy, price, float64(countToBuy)*price).
-		Scan(&opID); err != nil {
-		return errs.NewStack(err)
-	}
+	return amount, nil
+}
 
-	query = `INSERT INTO success_bot.operations(parent_id, user_id, instrument_id, type, count, price, total_amount)
-		VALUES ($1, $2, $3, 'fee', 1, $4, $4)`
-	if _, err = tx.Exec(ctx, query, opID, userID, instrumentID, float64(countToBuy)*price*0.003); err != nil {
+// changeCurrencyBalance adds delta to user's cash balance in the currency. It returns boterrs.ErrInsufficientFunds
+// if the balance becomes negative, the transaction must be rolled back then.
+func changeCurrencyBalance(ctx context.Context, tx pgx.Tx, userID int64, currency string, delta float64) error {
+	var amount float64
+	query := `INSERT INTO success_bot.users_currency_balances AS b(user_id, currency, amount)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, currency) DO UPDATE
+		SET amount = b.amount + $3
+		RETURNING amount`
+	if err := tx.QueryRow(ctx, query, userID, currency, delta).Scan(&amount); err != nil {
 		return errs.NewStack(err)
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return errs.NewStack(err)
+	if amount < 0 {
+		return boterrs.ErrInsufficientFunds
 	}
 
 	return nil
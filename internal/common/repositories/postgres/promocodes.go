@@ -70,8 +70,11 @@ func (pr *promocodesRepository) ApplyPromocode(ctx context.Context, value string
 		return nil, errs.NewStack(err)
 	}
 
-	query = `UPDATE success_bot.users SET available_balance = available_balance + $1, updated_at = NOW() WHERE id = $2`
-	if _, err = tx.Exec(ctx, query, promocode.BonusAmount, userID); err != nil {
+	if err = changeBalances(ctx, tx, &balanceChange{
+		userID:         userID,
+		reason:         domain.BalanceReasonPromocode,
+		availableDelta: promocode.BonusAmount,
+	}); err != nil {
 		return nil, errs.NewStack(err)
 	}
 
@@ -81,6 +84,14 @@ func (pr *promocodesRepository) ApplyPromocode(ctx context.Context, value string
 		return nil, errs.NewStack(err)
 	}
 
+	if err = addEvent(ctx, tx, domain.PromocodeApplied{
+		UserID:      userID,
+		Promocode:   promocode.Value,
+		BonusAmount: promocode.BonusAmount,
+	}); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return nil, errs.NewStack(err)
 	}
@@ -19,7 +19,14 @@ type User struct {
 	BlockedBalance   float64 `db:"blocked_balance"`
 	MarginCall       bool    `db:"margin_call"`
 
-	DailyReward bool `db:"daily_reward"`
+	DailyReward       bool `db:"daily_reward"`
+	DailyRewardStreak int  `db:"daily_reward_streak"`
+
+	TimeZone           string   `db:"time_zone"`
+	MutedNotifications []string `db:"muted_notifications"`
+	QuietHoursFrom     int      `db:"quiet_hours_from"`
+	QuietHoursTo       int      `db:"quiet_hours_to"`
+	PublicProfile      bool     `db:"public_profile"`
 
 	UpdatedAt time.Time `db:"updated_at"`
 	CreatedAt time.Time `db:"created_at"`
@@ -27,18 +34,26 @@ type User struct {
 
 func (u *User) CreateDomain() *domain.User {
 	user := &domain.User{
-		ID:               u.ID,
-		Username:         u.Username,
-		FirstName:        u.FirstName,
-		LastName:         u.LastName,
-		LanguageCode:     u.LanguageCode,
-		IsPremium:        u.IsPremium,
-		AvailableBalance: u.AvailableBalance,
-		BlockedBalance:   u.BlockedBalance,
-		MarginCall:       u.MarginCall,
-		DailyReward:      u.DailyReward,
-		CreatedAt:        u.CreatedAt,
-		UpdatedAt:        u.UpdatedAt,
+		ID:                u.ID,
+		Username:          u.Username,
+		FirstName:         u.FirstName,
+		LastName:          u.LastName,
+		LanguageCode:      u.LanguageCode,
+		IsPremium:         u.IsPremium,
+		AvailableBalance:  u.AvailableBalance,
+		BlockedBalance:    u.BlockedBalance,
+		MarginCall:        u.MarginCall,
+		DailyReward:       u.DailyReward,
+		DailyRewardStreak: u.DailyRewardStreak,
+		CreatedAt:         u.CreatedAt,
+		UpdatedAt:         u.UpdatedAt,
+		Settings: domain.UserSettings{
+			TimeZone:           u.TimeZone,
+			MutedNotifications: u.MutedNotifications,
+			QuietHoursFrom:     u.QuietHoursFrom,
+			QuietHoursTo:       u.QuietHoursTo,
+			PublicProfile:      u.PublicProfile,
+		},
 	}
 
 	return user
@@ -51,7 +66,9 @@ type TopUserData struct {
 	AvailableBalance float64 `db:"available_balance"`
 	BlockedBalance   float64 `db:"blocked_balance"`
 	MarginCall       bool    `db:"margin_call"`
+	PublicProfile    bool    `db:"public_profile"`
 	Ticker           *string `db:"ticker"`
+	Currency         *string `db:"currency"`
 	Count            *int64  `db:"count"`
 }
 
@@ -64,12 +81,16 @@ func (d *TopUserData) CreateDomain() *domain.TopUserData {
 			AvailableBalance: d.AvailableBalance,
 			BlockedBalance:   d.BlockedBalance,
 			MarginCall:       d.MarginCall,
+			PublicProfile:    d.PublicProfile,
 		},
 	}
 
 	if d.Ticker != nil {
 		data.Ticker = *d.Ticker
 	}
+	if d.Currency != nil {
+		data.Currency = *d.Currency
+	}
 	if d.Count != nil {
 		data.Count = *d.Count
 	}
@@ -78,9 +99,18 @@ func (d *TopUserData) CreateDomain() *domain.TopUserData {
 }
 
 type Instrument struct {
-	ID     int64  `db:"id"`
-	Ticker string `db:"ticker"`
-	Name   string `db:"name"`
+	ID       int64   `db:"id"`
+	Ticker   string  `db:"ticker"`
+	Name     string  `db:"name"`
+	Sector   string  `db:"sector"`
+	LotSize  int64   `db:"lot_size"`
+	MinStep  float64 `db:"min_step"`
+	Currency string  `db:"currency"`
+	Decimals int32   `db:"decimals"`
+	Active   bool    `db:"active"`
+	Listed   bool    `db:"listed"`
+	Kind     string  `db:"kind"`
+	Nominal  float64 `db:"nominal"`
 }
 
 func (i *Instrument) CreateDomain() *domain.Instrument {
@@ -90,6 +120,15 @@ func (i *Instrument) CreateDomain() *domain.Instrument {
 			Ticker: i.Ticker,
 			Name:   i.Name,
 		},
+		Decimals: i.Decimals,
+		LotSize:  i.LotSize,
+		MinStep:  i.MinStep,
+		Sector:   i.Sector,
+		Currency: i.Currency,
+		Active:   i.Active,
+		Listed:   i.Listed,
+		Kind:     i.Kind,
+		Nominal:  i.Nominal,
 	}
 
 	return instrument
@@ -122,7 +161,11 @@ type HistoryOperation struct {
 	InstrumentName string    `db:"instrument_name"`
 	Count          int64     `db:"count"`
 	TotalAmount    float64   `db:"total_amount"`
+	Currency       string    `db:"currency"`
 	CreatedAt      time.Time `db:"created_at"`
+
+	CopiedOperationID *int64  `db:"copied_operation_id"`
+	CopiedFrom        *string `db:"copied_from"`
 }
 
 func (ho *HistoryOperation) CreateDomain() *domain.Operation {
@@ -132,12 +175,19 @@ func (ho *HistoryOperation) CreateDomain() *domain.Operation {
 		InstrumentName: ho.InstrumentName,
 		Count:          ho.Count,
 		TotalAmount:    ho.TotalAmount,
+		Currency:       ho.Currency,
 		CreatedAt:      ho.CreatedAt,
 	}
 
 	if ho.ParentID != nil {
 		operation.ParentID = *ho.ParentID
 	}
+	if ho.CopiedOperationID != nil {
+		operation.CopiedOperationID = *ho.CopiedOperationID
+	}
+	if ho.CopiedFrom != nil {
+		operation.CopiedFrom = *ho.CopiedFrom
+	}
 
 	return operation
 }
@@ -149,6 +199,8 @@ type UserInstrument struct {
 	InstrumentName   string    `db:"instrument_name"`
 	Count            int64     `db:"count"`
 	AvgPrice         float64   `db:"average_price"`
+	LotSize          int64     `db:"lot_size"`
+	Currency         string    `db:"currency"`
 	CreatedAt        time.Time `db:"created_at"`
 	UpdatedAt        time.Time `db:"updated_at"`
 }
@@ -163,6 +215,8 @@ func (ui *UserInstrument) CreateDomain() *domain.UserInstrument {
 		},
 		Count:     ui.Count,
 		AvgPrice:  ui.AvgPrice,
+		LotSize:   ui.LotSize,
+		Currency:  ui.Currency,
 		CreatedAt: ui.CreatedAt,
 		UpdatedAt: ui.UpdatedAt,
 	}
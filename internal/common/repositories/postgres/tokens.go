@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/pkg/errs"
+)
+
+type tokensRepository struct {
+	psql *pgxpool.Pool
+}
+
+func NewTokensRepository(pool *pgxpool.Pool) domain.TokensRepository {
+	return &tokensRepository{
+		psql: pool,
+	}
+}
+
+// SetUserToken stores hash of a new user's API token. Previous user's token becomes invalid.
+func (tr *tokensRepository) SetUserToken(ctx context.Context, userID int64, tokenHash string) error {
+	query := `INSERT INTO success_bot.api_tokens(user_id, token_hash)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET token_hash = $2, created_at = NOW()`
+	if _, err := tr.psql.Exec(ctx, query, userID, tokenHash); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+func (tr *tokensRepository) GetUserIDByToken(ctx context.Context, tokenHash string) (int64, error) {
+	query := `SELECT user_id FROM success_bot.api_tokens WHERE token_hash = $1`
+	var userID int64
+	if err := tr.psql.QueryRow(ctx, query, tokenHash).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, boterrs.ErrInvalidToken
+		}
+
+		return 0, errs.NewStack(err)
+	}
+
+	return userID, nil
+}
@@ -3,7 +3,7 @@ package postgres
 import (
 	"context"
 	"errors"
-	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -24,7 +24,17 @@ func NewUsersRepository(pool *pgxpool.Pool) domain.UsersRepository {
 	}
 }
 
-func (ur *usersRepository) CreateUser(ctx context.Context, user *domain.User) error {
+func (ur *usersRepository) CreateUser(ctx context.Context, user *domain.User, languageCode string) error {
+	tx, err := ur.psql.Begin(ctx)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
 	query := `INSERT INTO success_bot.users(
 			id,
 			username,
@@ -32,16 +42,36 @@ func (ur *usersRepository) CreateUser(ctx context.Context, user *domain.User) er
 			last_name,
 			is_premium
 		)
-		VALUES ($1, $2, $3, $4, $5)`
-	_, err := ur.psql.Exec(ctx,
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING available_balance, blocked_balance`
+	var availableBalance, blockedBalance float64
+	if err = tx.QueryRow(ctx,
 		query,
 		user.ID,
 		user.Username,
 		user.FirstName,
 		user.LastName,
 		user.IsPremium,
-	)
-	if err != nil {
+	).Scan(&availableBalance, &blockedBalance); err != nil {
+		return errs.NewStack(err)
+	}
+
+	// initial balances are set by column defaults
+	query = `INSERT INTO success_bot.balance_events(
+			user_id, reason, actor, available_before, blocked_before, available_after, blocked_after
+		)
+		VALUES ($1, $2, $3, 0, 0, $4, $5)`
+	if _, err = tx.Exec(ctx, query,
+		user.ID, domain.BalanceReasonInitial, domain.ActorFromContext(ctx), availableBalance, blockedBalance,
+	); err != nil {
+		return errs.NewStack(err)
+	}
+
+	if err = addEvent(ctx, tx, domain.UserRegistered{UserID: user.ID, LanguageCode: languageCode}); err != nil {
+		return errs.NewStack(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
 		return errs.NewStack(err)
 	}
 
@@ -60,6 +90,12 @@ func (ur *usersRepository) GetUserByID(ctx context.Context, id int64) (*domain.U
 			blocked_balance,
 			margin_call,
 			daily_reward,
+    		daily_reward_streak,
+    		time_zone,
+    		muted_notifications,
+    		quiet_hours_from,
+    		quiet_hours_to,
+    		public_profile,
     		created_at,
     		updated_at
 		FROM success_bot.users WHERE id = $1`
@@ -75,6 +111,12 @@ func (ur *usersRepository) GetUserByID(ctx context.Context, id int64) (*domain.U
 		&user.BlockedBalance,
 		&user.MarginCall,
 		&user.DailyReward,
+		&user.DailyRewardStreak,
+		&user.TimeZone,
+		&user.MutedNotifications,
+		&user.QuietHoursFrom,
+		&user.QuietHoursTo,
+		&user.PublicProfile,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	); err != nil {
@@ -110,6 +152,12 @@ func (ur *usersRepository) GetAllUsers(ctx context.Context) ([]*domain.User, err
 			blocked_balance,
 			margin_call,
 			daily_reward,
+			daily_reward_streak,
+			time_zone,
+			muted_notifications,
+			quiet_hours_from,
+			quiet_hours_to,
+			public_profile,
 			created_at,
 			updated_at
 		FROM success_bot.users`
@@ -133,6 +181,12 @@ func (ur *usersRepository) GetAllUsers(ctx context.Context) ([]*domain.User, err
 			&user.BlockedBalance,
 			&user.MarginCall,
 			&user.DailyReward,
+			&user.DailyRewardStreak,
+			&user.TimeZone,
+			&user.MutedNotifications,
+			&user.QuietHoursFrom,
+			&user.QuietHoursTo,
+			&user.PublicProfile,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		); err != nil {
@@ -153,8 +207,10 @@ func (ur *usersRepository) GetTopUsersData(ctx context.Context) ([]*domain.TopUs
 			u.available_balance,
 			u.blocked_balance,
 			u.margin_call,
+			u.public_profile,
 			i.ticker,
-			ui.count 
+			i.currency,
+			ui.count
 		FROM success_bot.users u
 		LEFT JOIN success_bot.users_instruments ui
 			ON u.id = ui.user_id
@@ -176,7 +232,9 @@ func (ur *usersRepository) GetTopUsersData(ctx context.Context) ([]*domain.TopUs
 			&data.AvailableBalance,
 			&data.BlockedBalance,
 			&data.MarginCall,
+			&data.PublicProfile,
 			&data.Ticker,
+			&data.Currency,
 			&data.Count,
 		); err != nil {
 			return nil, errs.NewStack(err)
@@ -188,61 +246,45 @@ func (ur *usersRepository) GetTopUsersData(ctx context.Context) ([]*domain.TopUs
 	return topUsersData, nil
 }
 
-func (ur *usersRepository) GetUsersClaimedDailyReward(ctx context.Context) ([]*domain.User, error) {
-	query := `SELECT
-			id,
-			username,
-			first_name,
-			last_name,
-			language_code,
-			is_premium,
-			available_balance,
-			blocked_balance,
-			margin_call,
-			daily_reward,
-			created_at,
-			updated_at
-		FROM success_bot.users WHERE daily_reward = FALSE`
-	rows, err := ur.psql.Query(ctx, query)
+// ResetDailyReward makes daily reward available again for the users on their local days (user ID -> day),
+// streaks of the users who haven't claimed the current reward are reset. Users already reset on the day
+// or later are skipped. It returns IDs of the reset users.
+func (ur *usersRepository) ResetDailyReward(ctx context.Context, days map[int64]time.Time) ([]int64, error) {
+	userIDs := make([]int64, 0, len(days))
+	dates := make([]time.Time, 0, len(days))
+	for userID, day := range days {
+		userIDs = append(userIDs, userID)
+		dates = append(dates, time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC))
+	}
+
+	query := `UPDATE success_bot.users u
+		SET daily_reward_streak = CASE WHEN u.daily_reward THEN 0 ELSE u.daily_reward_streak END,
+			daily_reward = TRUE,
+			daily_reward_reset_on = r.day
+		FROM UNNEST($1::bigint[], $2::date[]) AS r(id, day)
+		WHERE u.id = r.id AND (u.daily_reward_reset_on IS NULL OR u.daily_reward_reset_on < r.day)
+		RETURNING u.id`
+	rows, err := ur.psql.Query(ctx, query, userIDs, dates)
 	if err != nil {
 		return nil, errs.NewStack(err)
 	}
 	defer rows.Close()
 
-	users := []*domain.User{}
+	resetIDs := []int64{}
 	for rows.Next() {
-		user := &User{}
-		if err := rows.Scan(
-			&user.ID,
-			&user.Username,
-			&user.FirstName,
-			&user.LastName,
-			&user.LanguageCode,
-			&user.IsPremium,
-			&user.AvailableBalance,
-			&user.BlockedBalance,
-			&user.MarginCall,
-			&user.DailyReward,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		); err != nil {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
 			return nil, errs.NewStack(err)
 		}
 
-		users = append(users, user.CreateDomain())
+		resetIDs = append(resetIDs, userID)
 	}
 
-	return users, nil
-}
-
-func (ur *usersRepository) ResetDailyReward(ctx context.Context) error {
-	query := `UPDATE success_bot.users SET daily_reward = TRUE`
-	_, err := ur.psql.Exec(ctx, query)
-	if err != nil {
-		return errs.NewStack(err)
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
 	}
 
-	return nil
+	return resetIDs, nil
 }
 
 // UpdateUserTGData updates username, first name, last name and is_premium fields of the user.
@@ -273,41 +315,140 @@ func (ur *usersRepository) UpdateUserLanguage(ctx context.Context, userID int64,
 	return nil
 }
 
-// UpdateUserBalancesAndMarginCall updates available_balance and margin_call by gotten values.
-// Set blocked_balance = blocked_balance - blockedBalanceDelta. Nil values will be ignored to update.
-func (ur *usersRepository) UpdateUserBalancesAndMarginCall(
-	ctx context.Context, userID int64, availableBalance float64, blockedBalanceDelta *float64, marginCall *bool) error {
-	args := make([]any, 0, 4)
-
-	args = append(args, availableBalance)
+func (ur *usersRepository) UpdateUserSettings(ctx context.Context, userID int64, settings *domain.UserSettings) error {
 	query := `UPDATE success_bot.users
-		SET available_balance = $1`
+		SET time_zone = $1,
+			muted_notifications = $2,
+			quiet_hours_from = $3,
+			quiet_hours_to = $4,
+			public_profile = $5
+		WHERE id = $6`
+	// muted_notifications isn't nullable
+	muted := settings.MutedNotifications
+	if muted == nil {
+		muted = []string{}
+	}
+
+	_, err := ur.psql.Exec(ctx, query,
+		settings.TimeZone, muted, settings.QuietHoursFrom, settings.QuietHoursTo, settings.PublicProfile, userID,
+	)
+	if err != nil {
+		return errs.NewStack(err)
+	}
 
-	if blockedBalanceDelta != nil {
-		args = append(args, *blockedBalanceDelta)
-		query += fmt.Sprintf(`, blocked_balance = blocked_balance - $%d`, len(args))
+	return nil
+}
+
+// UpdateUserBalancesAndMarginCall moves blockedDiff from blocked to available balance and sets margin call
+// if available balance becomes negative. Change of margin call stores MarginCallEntered or MarginCallCleared event.
+func (ur *usersRepository) UpdateUserBalancesAndMarginCall(
+	ctx context.Context, userID int64, blockedDiff float64,
+) (*domain.User, error) {
+	tx, err := ur.psql.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	if err = changeBalances(ctx, tx, &balanceChange{
+		userID:         userID,
+		reason:         domain.BalanceReasonRevaluation,
+		availableDelta: blockedDiff,
+		blockedDelta:   -blockedDiff,
+	}); err != nil {
+		return nil, errs.NewStack(err)
 	}
 
-	if marginCall != nil {
-		args = append(args, *marginCall)
-		query += fmt.Sprintf(`, margin_call = $%d`, len(args))
+	var (
+		user       = &domain.User{ID: userID}
+		stoppedOut bool
+	)
+	query := `SELECT available_balance, blocked_balance, stopped_out FROM success_bot.users WHERE id = $1`
+	if err = tx.QueryRow(ctx, query, userID).Scan(&user.AvailableBalance, &user.BlockedBalance, &stoppedOut); err != nil {
+		return nil, errs.NewStack(err)
 	}
 
-	args = append(args, userID)
-	query += fmt.Sprintf(` WHERE id = $%d`, len(args))
+	user.MarginCall = user.AvailableBalance < 0
 
-	_, err := ur.psql.Exec(ctx, query, args...)
+	// stop-out mark and deferred warning belong to the current margin call, so they're reset with its every change
+	query = `UPDATE success_bot.users
+		SET margin_call = $1, stopped_out = FALSE, margin_call_warning_deferred = FALSE
+		WHERE id = $2 AND margin_call <> $1`
+	tag, err := tx.Exec(ctx, query, user.MarginCall, userID)
 	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	// events are stored only when margin call is changed
+	if tag.RowsAffected() > 0 {
+		var event domain.Event = domain.MarginCallCleared{UserID: userID, StoppedOut: stoppedOut}
+		if user.MarginCall {
+			event = domain.MarginCallEntered{UserID: userID}
+		}
+
+		if err := addEvent(ctx, tx, event); err != nil {
+			return nil, errs.NewStack(err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return user, nil
+}
+
+// DeferMarginCallWarning marks the user's margin call warning to be sent after quiet hours, the mark is dropped
+// with any change of margin call.
+func (ur *usersRepository) DeferMarginCallWarning(ctx context.Context, userID int64) error {
+	query := `UPDATE success_bot.users SET margin_call_warning_deferred = TRUE WHERE id = $1 AND margin_call`
+	if _, err := ur.psql.Exec(ctx, query, userID); err != nil {
 		return errs.NewStack(err)
 	}
 
 	return nil
 }
 
-func (ur *usersRepository) ClaimDailyReward(ctx context.Context, userID int64, amount float64) error {
+// TakeDeferredMarginCallWarnings drops deferred margin call warnings of the users and returns IDs of the users
+// who had them, so every deferred warning is taken once.
+func (ur *usersRepository) TakeDeferredMarginCallWarnings(ctx context.Context, userIDs []int64) ([]int64, error) {
+	query := `UPDATE success_bot.users
+		SET margin_call_warning_deferred = FALSE
+		WHERE id = ANY($1) AND margin_call_warning_deferred
+		RETURNING id`
+	rows, err := ur.psql.Query(ctx, query, userIDs)
+	if err != nil {
+		return nil, errs.NewStack(err)
+	}
+	defer rows.Close()
+
+	takenIDs := []int64{}
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, errs.NewStack(err)
+		}
+
+		takenIDs = append(takenIDs, userID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	return takenIDs, nil
+}
+
+func (ur *usersRepository) ClaimDailyReward(
+	ctx context.Context, userID int64, tiers domain.DailyRewardTiers,
+) (*domain.DailyRewardClaim, error) {
 	tx, err := ur.psql.Begin(ctx)
 	if err != nil {
-		return errs.NewStack(err)
+		return nil, errs.NewStack(err)
 	}
 	defer func() {
 		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
@@ -315,33 +456,50 @@ func (ur *usersRepository) ClaimDailyReward(ctx context.Context, userID int64, a
 		}
 	}()
 
-	query := `SELECT daily_reward FROM success_bot.users WHERE id = $1 FOR UPDATE`
-	var dailyReward bool
-	if err := tx.QueryRow(ctx, query, userID).Scan(&dailyReward); err != nil {
-		return errs.NewStack(err)
+	query := `SELECT daily_reward, daily_reward_streak FROM success_bot.users WHERE id = $1 FOR UPDATE`
+	var (
+		dailyReward bool
+		streak      int
+	)
+	if err := tx.QueryRow(ctx, query, userID).Scan(&dailyReward, &streak); err != nil {
+		return nil, errs.NewStack(err)
 	}
 
 	if !dailyReward {
-		return errs.NewStack(boterrs.ErrUnavailableDailyReward)
+		return nil, errs.NewStack(boterrs.ErrUnavailableDailyReward)
 	}
 
-	query = `UPDATE success_bot.users
-		SET available_balance = available_balance + $1, daily_reward = FALSE
-		WHERE id = $2`
-	_, err = tx.Exec(ctx, query, amount, userID)
-	if err != nil {
-		return errs.NewStack(err)
+	// Streak is reset by ResetDailyReward if the previous reward wasn't claimed
+	claim := &domain.DailyRewardClaim{Streak: streak + 1}
+	claim.Tier, claim.Amount = tiers.Reward(claim.Streak)
+
+	query = `UPDATE success_bot.users SET daily_reward = FALSE, daily_reward_streak = $2 WHERE id = $1`
+	if _, err = tx.Exec(ctx, query, userID, claim.Streak); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	if err = changeBalances(ctx, tx, &balanceChange{
+		userID:         userID,
+		reason:         domain.BalanceReasonDailyReward,
+		availableDelta: claim.Amount,
+	}); err != nil {
+		return nil, errs.NewStack(err)
 	}
 
+	// Count of daily reward operation is the reward tier
 	query = `INSERT INTO success_bot.operations(user_id, instrument_id, type, count, price, total_amount)
-		VALUES ($1, -1, 'daily_reward', 1, $2, $2)`
-	if _, err = tx.Exec(ctx, query, userID, amount); err != nil {
-		return errs.NewStack(err)
+		VALUES ($1, -1, 'daily_reward', $2, $3, $3)`
+	if _, err = tx.Exec(ctx, query, userID, claim.Tier, claim.Amount); err != nil {
+		return nil, errs.NewStack(err)
+	}
+
+	if err = addEvent(ctx, tx, domain.RewardClaimed{UserID: userID, Claim: *claim}); err != nil {
+		return nil, errs.NewStack(err)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		return errs.NewStack(err)
+		return nil, errs.NewStack(err)
 	}
 
-	return nil
+	return claim, nil
 }
@@ -0,0 +1,246 @@
+// Package trading contains fee, guarantee coverage and margin rules shared by the bot, repositories and backtests.
+package trading
+
+import "math"
+
+const (
+	FeeRate       = 0.003 // 0,3% fee for buying and selling
+	GuaranteeRate = 0.5   // 50% guarantee coverage of shorts
+)
+
+// Position is a count and an average price of user's instrument, negative count is a short.
+type Position struct {
+	Count    int64
+	AvgPrice float64
+}
+
+type Balances struct {
+	Available float64
+	Blocked   float64
+}
+
+// Trade describes balances and position changes of an order. The order is executed in two steps:
+// closing of an opposite position and opening (or increasing) a position in order direction.
+type Trade struct {
+	CloseCount          int64
+	CloseAvailableDelta float64
+	CloseResult         float64 // result of closing with fee, negative for loss
+
+	OpenCount          int64
+	OpenAvailableDelta float64 // negative, funds required for opening are (-OpenAvailableDelta)
+	OpenBlockedDelta   float64
+
+	Position Position // resulting position
+}
+
+// Fee returns fee for count by price.
+func Fee(count int64, price float64) float64 {
+	return float64(count) * price * FeeRate
+}
+
+// Guarantee returns funds to block for the short by the last price. Zero for longs.
+func Guarantee(count int64, last float64) float64 {
+	if count >= 0 {
+		return 0
+	}
+
+	return float64(-count) * last * GuaranteeRate
+}
+
+// Buy calculates buying of count by price: a short is closed first, the rest opens or increases a long.
+func Buy(pos Position, count int64, price float64) *Trade {
+	trade := &Trade{Position: pos}
+	remainsCount := count
+
+	// close short
+	if pos.Count < 0 {
+		closeCount := min(count, -pos.Count)
+
+		// shortResult - 0,3% fee for buying
+		trade.CloseCount = closeCount
+		trade.CloseAvailableDelta = float64(-closeCount)*(price-pos.AvgPrice) - Fee(closeCount, price)
+		trade.CloseResult = trade.CloseAvailableDelta
+
+		remainsCount -= closeCount
+		trade.Position.Count += closeCount
+
+		if trade.Position.Count == 0 {
+			trade.Position.AvgPrice = 0
+		}
+	}
+
+	// make buy
+	if remainsCount > 0 {
+		current := trade.Position
+
+		// buyAmount + 0,3% fee for buying
+		trade.OpenCount = remainsCount
+		trade.OpenAvailableDelta = -float64(remainsCount) * price * (1 + FeeRate)
+
+		trade.Position.Count = current.Count + remainsCount
+		trade.Position.AvgPrice = (current.AvgPrice*float64(current.Count) + price*float64(remainsCount)) /
+			float64(trade.Position.Count)
+	}
+
+	return trade
+}
+
+// Sell calculates selling of count by price: a long is closed first, the rest opens or increases a short.
+func Sell(pos Position, count int64, price float64) *Trade {
+	trade := &Trade{Position: pos}
+	remainsCount := count
+
+	// close long
+	if pos.Count > 0 {
+		closeCount := min(count, pos.Count)
+
+		// sellAmount - 0,3% fee for selling
+		trade.CloseCount = closeCount
+		trade.CloseAvailableDelta = float64(closeCount)*price - Fee(closeCount, price)
+		trade.CloseResult = float64(closeCount)*(price-pos.AvgPrice) - Fee(closeCount, price)
+
+		remainsCount -= closeCount
+		trade.Position.Count -= closeCount
+
+		if trade.Position.Count == 0 {
+			trade.Position.AvgPrice = 0
+		}
+	}
+
+	// make sell
+	if remainsCount > 0 {
+		current := trade.Position
+		amountToBlock := Guarantee(-remainsCount, price)
+
+		trade.OpenCount = remainsCount
+		trade.OpenAvailableDelta = -(amountToBlock + Fee(remainsCount, price))
+		trade.OpenBlockedDelta = amountToBlock
+
+		trade.Position.Count = current.Count - remainsCount
+		trade.Position.AvgPrice = (current.AvgPrice*float64(-current.Count) + price*float64(remainsCount)) /
+			float64(-trade.Position.Count)
+	}
+
+	return trade
+}
+
+// MaxCountToBuy returns max count which can be bought by price with available balance and a short of shortCount.
+func MaxCountToBuy(available float64, shortCount int64, price float64) int64 {
+	var maxCount int64
+
+	if shortCount > 0 {
+		maxCount += shortCount // buy to close shorts
+	}
+
+	return maxCount + int64(available/(price*(1+FeeRate)))
+}
+
+// MaxCountToSell returns max count which can be sold by price with available balance and a long of longCount.
+func MaxCountToSell(available float64, longCount int64, price float64) int64 {
+	var maxCount int64
+
+	if longCount > 0 {
+		maxCount += longCount                                   // sell to close longs
+		available += float64(longCount) * price * (1 - FeeRate) // 0,3 % fee for selling
+	}
+
+	// 0,3% fee for selling and only 50% need for guarantee coverage
+	return maxCount + int64(available/(price*(GuaranteeRate+FeeRate)))
+}
+
+// PricedPosition is a position with the last instrument price.
+type PricedPosition struct {
+	Position
+	Last float64
+}
+
+type Revaluation struct {
+	Balances
+
+	BlockedDiff float64 // funds moved from blocked to available balance, negative if more funds are blocked
+	Total       float64 // available and blocked balances with longs value
+	MarginCall  bool
+}
+
+// Revalue blocks guarantee coverage of shorts by the last prices and calculates total balance.
+// Margin call happens when available balance becomes negative.
+func Revalue(balances Balances, positions []PricedPosition) *Revaluation {
+	var required, longsValue float64
+
+	for _, position := range positions {
+		if position.Count >= 0 {
+			longsValue += position.Last * float64(position.Count)
+			continue
+		}
+
+		required += Guarantee(position.Count, position.Last)
+	}
+
+	rev := &Revaluation{
+		BlockedDiff: balances.Blocked - required,
+	}
+
+	rev.Available = balances.Available + rev.BlockedDiff
+	rev.Blocked = balances.Blocked - rev.BlockedDiff
+	rev.Total = longsValue + rev.Available + rev.Blocked
+	rev.MarginCall = rev.Available < 0
+
+	return rev
+}
+
+// StopOutCount returns count of the short to buy by the last price so that released guarantee and short result
+// cover negative available balance. Count is a multiple of lot size, the whole short is closed if it isn't enough.
+func StopOutCount(short Position, last, available float64, lotSize int64) int64 {
+	lotSize = max(lotSize, 1)
+
+	for i := lotSize; i < -short.Count; i += lotSize {
+		// released 50% guarantee coverage without 0,3% fee for buying and short result
+		if last*(GuaranteeRate-FeeRate)*float64(i)-(last-short.AvgPrice)*float64(i) >= -available {
+			return i
+		}
+	}
+
+	return -short.Count
+}
+
+// ValidCount reports whether the position may be changed by delta count (negative for selling) with the lot size.
+// Count must be a multiple of lot size, but the whole position may be closed by any count, because lot size
+// of the instrument may be changed after the position was opened.
+func ValidCount(pos Position, delta, lotSize int64) bool {
+	if delta == 0 {
+		return false
+	}
+
+	return delta%max(lotSize, 1) == 0 || pos.Count+delta == 0
+}
+
+// RoundDownToLot returns the greatest multiple of lot size not exceeding count.
+func RoundDownToLot(count, lotSize int64) int64 {
+	lotSize = max(lotSize, 1)
+
+	return count / lotSize * lotSize
+}
+
+// priceStepTolerance is a relative tolerance of float price to the price step.
+const priceStepTolerance = 1e-6
+
+// OnPriceStep reports whether price is a multiple of the instrument's min price step. Zero step allows any price.
+func OnPriceStep(price, step float64) bool {
+	if step <= 0 {
+		return true
+	}
+
+	steps := price / step
+
+	return math.Abs(steps-math.Round(steps)) < priceStepTolerance
+}
+
+// RoundToStep rounds price to the nearest multiple of min price step. Zero step keeps the price.
+func RoundToStep(price, step float64) float64 {
+	if step <= 0 {
+		return price
+	}
+
+	// rounding to 10 decimals removes float noise of the multiplication, e.g. 0.1*3
+	return math.Round(math.Round(price/step)*step*1e10) / 1e10
+}
@@ -0,0 +1,385 @@
+package trading
+
+import (
+	"math"
+	"testing"
+)
+
+const epsilon = 1e-9
+
+func equal(a, b float64) bool {
+	return math.Abs(a-b) < epsilon
+}
+
+func tradeEqual(a, b *Trade) bool {
+	return a.CloseCount == b.CloseCount &&
+		equal(a.CloseAvailableDelta, b.CloseAvailableDelta) &&
+		equal(a.CloseResult, b.CloseResult) &&
+		a.OpenCount == b.OpenCount &&
+		equal(a.OpenAvailableDelta, b.OpenAvailableDelta) &&
+		equal(a.OpenBlockedDelta, b.OpenBlockedDelta) &&
+		a.Position.Count == b.Position.Count &&
+		equal(a.Position.AvgPrice, b.Position.AvgPrice)
+}
+
+func TestBuy(t *testing.T) {
+	tests := []struct {
+		name  string
+		pos   Position
+		count int64
+		price float64
+		want  Trade
+	}{
+		{
+			name:  "open long",
+			count: 10,
+			price: 100,
+			want: Trade{
+				OpenCount:          10,
+				OpenAvailableDelta: -1003,
+				Position:           Position{Count: 10, AvgPrice: 100},
+			},
+		},
+		{
+			name:  "increase long",
+			pos:   Position{Count: 10, AvgPrice: 100},
+			count: 10,
+			price: 110,
+			want: Trade{
+				OpenCount:          10,
+				OpenAvailableDelta: -1103.3,
+				Position:           Position{Count: 20, AvgPrice: 105},
+			},
+		},
+		{
+			name:  "close part of short with profit",
+			pos:   Position{Count: -10, AvgPrice: 100},
+			count: 4,
+			price: 90,
+			want: Trade{
+				CloseCount:          4,
+				CloseAvailableDelta: 38.92,
+				CloseResult:         38.92,
+				Position:            Position{Count: -6, AvgPrice: 100},
+			},
+		},
+		{
+			name:  "close whole short",
+			pos:   Position{Count: -10, AvgPrice: 100},
+			count: 10,
+			price: 100,
+			want: Trade{
+				CloseCount:          10,
+				CloseAvailableDelta: -3,
+				CloseResult:         -3,
+			},
+		},
+		{
+			name:  "reverse short with loss to long",
+			pos:   Position{Count: -10, AvgPrice: 100},
+			count: 15,
+			price: 110,
+			want: Trade{
+				CloseCount:          10,
+				CloseAvailableDelta: -103.3,
+				CloseResult:         -103.3,
+				OpenCount:           5,
+				OpenAvailableDelta:  -551.65,
+				Position:            Position{Count: 5, AvgPrice: 110},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Buy(tt.pos, tt.count, tt.price); !tradeEqual(got, &tt.want) {
+				t.Errorf("Buy() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSell(t *testing.T) {
+	tests := []struct {
+		name  string
+		pos   Position
+		count int64
+		price float64
+		want  Trade
+	}{
+		{
+			name:  "close part of long with profit",
+			pos:   Position{Count: 10, AvgPrice: 100},
+			count: 4,
+			price: 110,
+			want: Trade{
+				CloseCount:          4,
+				CloseAvailableDelta: 438.68,
+				CloseResult:         38.68,
+				Position:            Position{Count: 6, AvgPrice: 100},
+			},
+		},
+		{
+			name:  "open short",
+			count: 10,
+			price: 100,
+			want: Trade{
+				OpenCount:          10,
+				OpenAvailableDelta: -503,
+				OpenBlockedDelta:   500,
+				Position:           Position{Count: -10, AvgPrice: 100},
+			},
+		},
+		{
+			name:  "increase short",
+			pos:   Position{Count: -10, AvgPrice: 100},
+			count: 10,
+			price: 120,
+			want: Trade{
+				OpenCount:          10,
+				OpenAvailableDelta: -603.6,
+				OpenBlockedDelta:   600,
+				Position:           Position{Count: -20, AvgPrice: 110},
+			},
+		},
+		{
+			name:  "reverse long with loss to short",
+			pos:   Position{Count: 10, AvgPrice: 100},
+			count: 15,
+			price: 90,
+			want: Trade{
+				CloseCount:          10,
+				CloseAvailableDelta: 897.3,
+				CloseResult:         -102.7,
+				OpenCount:           5,
+				OpenAvailableDelta:  -226.35,
+				OpenBlockedDelta:    225,
+				Position:            Position{Count: -5, AvgPrice: 90},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sell(tt.pos, tt.count, tt.price); !tradeEqual(got, &tt.want) {
+				t.Errorf("Sell() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxCountToBuy(t *testing.T) {
+	tests := []struct {
+		name       string
+		available  float64
+		shortCount int64
+		price      float64
+		want       int64
+	}{
+		{name: "exact funds with fee", available: 1003, price: 100, want: 10},
+		{name: "not enough for fee", available: 1002, price: 100, want: 9},
+		{name: "no funds", price: 100, want: 0},
+		{name: "close short and open long", available: 1003, shortCount: 5, price: 100, want: 15},
+		{name: "close short without funds", shortCount: 5, price: 100, want: 5},
+		{name: "negative short count is ignored", available: 1003, shortCount: -5, price: 100, want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaxCountToBuy(tt.available, tt.shortCount, tt.price); got != tt.want {
+				t.Errorf("MaxCountToBuy() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxCountToSell(t *testing.T) {
+	tests := []struct {
+		name      string
+		available float64
+		longCount int64
+		price     float64
+		want      int64
+	}{
+		{name: "open short", available: 600, price: 100, want: 11},
+		{name: "no funds", price: 100, want: 0},
+		// 997 of selling the long covers 19 more shares of the short
+		{name: "close long and open short", longCount: 10, price: 100, want: 29},
+		{name: "negative long count is ignored", available: 600, longCount: -5, price: 100, want: 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaxCountToSell(tt.available, tt.longCount, tt.price); got != tt.want {
+				t.Errorf("MaxCountToSell() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevalue(t *testing.T) {
+	tests := []struct {
+		name      string
+		balances  Balances
+		positions []PricedPosition
+		want      Revaluation
+	}{
+		{
+			name:     "no positions",
+			balances: Balances{Available: 1000},
+			want:     Revaluation{Balances: Balances{Available: 1000}, Total: 1000},
+		},
+		{
+			name:     "long",
+			balances: Balances{Available: 1000},
+			positions: []PricedPosition{
+				{Position: Position{Count: 10, AvgPrice: 100}, Last: 110},
+			},
+			want: Revaluation{Balances: Balances{Available: 1000}, Total: 2100},
+		},
+		{
+			name:     "short price rises",
+			balances: Balances{Available: 500, Blocked: 500},
+			positions: []PricedPosition{
+				{Position: Position{Count: -10, AvgPrice: 100}, Last: 120},
+			},
+			want: Revaluation{
+				Balances:    Balances{Available: 400, Blocked: 600},
+				BlockedDiff: -100,
+				Total:       1000,
+			},
+		},
+		{
+			name:     "short price falls",
+			balances: Balances{Available: 500, Blocked: 500},
+			positions: []PricedPosition{
+				{Position: Position{Count: -10, AvgPrice: 100}, Last: 80},
+			},
+			want: Revaluation{
+				Balances:    Balances{Available: 600, Blocked: 400},
+				BlockedDiff: 100,
+				Total:       1000,
+			},
+		},
+		{
+			name:     "margin call",
+			balances: Balances{Available: 50, Blocked: 500},
+			positions: []PricedPosition{
+				{Position: Position{Count: -10, AvgPrice: 100}, Last: 120},
+			},
+			want: Revaluation{
+				Balances:    Balances{Available: -50, Blocked: 600},
+				BlockedDiff: -100,
+				Total:       550,
+				MarginCall:  true,
+			},
+		},
+		{
+			name:     "longs value doesn't prevent margin call",
+			balances: Balances{Available: 50, Blocked: 500},
+			positions: []PricedPosition{
+				{Position: Position{Count: 10, AvgPrice: 100}, Last: 100},
+				{Position: Position{Count: -10, AvgPrice: 100}, Last: 120},
+			},
+			want: Revaluation{
+				Balances:    Balances{Available: -50, Blocked: 600},
+				BlockedDiff: -100,
+				Total:       1550,
+				MarginCall:  true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Revalue(tt.balances, tt.positions)
+
+			if !equal(got.Available, tt.want.Available) || !equal(got.Blocked, tt.want.Blocked) ||
+				!equal(got.BlockedDiff, tt.want.BlockedDiff) || !equal(got.Total, tt.want.Total) ||
+				got.MarginCall != tt.want.MarginCall {
+				t.Errorf("Revalue() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopOutCount(t *testing.T) {
+	short := Position{Count: -10, AvgPrice: 100}
+
+	tests := []struct {
+		name      string
+		short     Position
+		last      float64
+		available float64
+		lotSize   int64
+		want      int64
+	}{
+		// every share releases 120*0,497 - 20 = 39,64
+		{name: "part of short", short: short, last: 120, available: -50, lotSize: 1, want: 2},
+		{name: "part of short by lots", short: short, last: 120, available: -50, lotSize: 5, want: 5},
+		{name: "zero lot size", short: short, last: 120, available: -50, want: 2},
+		{name: "whole short", short: short, last: 120, available: -1000, lotSize: 1, want: 10},
+		{
+			name:      "lot size greater than short",
+			short:     Position{Count: -3, AvgPrice: 100},
+			last:      120,
+			available: -50,
+			lotSize:   5,
+			want:      3,
+		},
+		// every share costs 300*0,497 - 200 = -50,9, buying doesn't help
+		{name: "closing increases loss", short: short, last: 300, available: -50, lotSize: 1, want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StopOutCount(tt.short, tt.last, tt.available, tt.lotSize); got != tt.want {
+				t.Errorf("StopOutCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundDownToLot(t *testing.T) {
+	tests := []struct {
+		count   int64
+		lotSize int64
+		want    int64
+	}{
+		{count: 17, lotSize: 5, want: 15},
+		{count: 15, lotSize: 5, want: 15},
+		{count: 4, lotSize: 5, want: 0},
+		{count: 17, lotSize: 1, want: 17},
+		{count: 17, lotSize: 0, want: 17},
+		{count: 0, lotSize: 5, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := RoundDownToLot(tt.count, tt.lotSize); got != tt.want {
+			t.Errorf("RoundDownToLot(%d, %d) = %d, want %d", tt.count, tt.lotSize, got, tt.want)
+		}
+	}
+}
+
+func TestOnPriceStep(t *testing.T) {
+	tests := []struct {
+		price float64
+		step  float64
+		want  bool
+	}{
+		{price: 100.5, step: 0.5, want: true},
+		{price: 100.3, step: 0.5, want: false},
+		{price: 0.3, step: 0.1, want: true}, // 0.3/0.1 isn't exactly 3 in floats
+		{price: 0.15, step: 0.1, want: false},
+		{price: 123.456, step: 0.001, want: true},
+		{price: 123.4565, step: 0.001, want: false},
+		{price: 1.23, step: 0, want: true},
+		{price: 1.23, step: -1, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := OnPriceStep(tt.price, tt.step); got != tt.want {
+			t.Errorf("OnPriceStep(%v, %v) = %v, want %v", tt.price, tt.step, got, tt.want)
+		}
+	}
+}
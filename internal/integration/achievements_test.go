@@ -0,0 +1,157 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/leonid6372/success-bot/internal/common/achievements"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+)
+
+func TestAchievementsTrades(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	engine := achievements.New(postgres.NewAchievementsRepository(pool), map[string]float64{
+		domain.AchievementFirstTrade: 1000,
+	})
+	portfolios := postgres.NewPortfolioRepository(pool)
+
+	user := createUser(t, 1)
+	sberID := instrumentID(t, ticker)
+
+	evaluate := func(trade *domain.TradeResult) []string {
+		t.Helper()
+
+		unlocked, err := engine.Evaluate(ctx, user.ID, domain.TradeExecuted{UserID: user.ID, Trade: trade})
+		if err != nil {
+			t.Fatalf("failed to evaluate achievements: %v", err)
+		}
+
+		ids := make([]string, 0, len(unlocked))
+		for _, u := range unlocked {
+			ids = append(ids, u.Achievement.ID)
+		}
+
+		return ids
+	}
+
+	trade, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 10, 100)
+	if err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	if got := evaluate(trade); len(got) != 1 || got[0] != domain.AchievementFirstTrade {
+		t.Errorf("unlocked by first buy = %v, want [%s]", got, domain.AchievementFirstTrade)
+	}
+
+	available, _ := balances(t, user.ID)
+
+	// profitable closing doesn't unlock anything before the target, the first trade isn't unlocked twice
+	trade, err = portfolios.SellInstrument(ctx, user.ID, sberID, 10, 120)
+	if err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	if trade.CloseResult <= 0 {
+		t.Errorf("close result = %v, want profit", trade.CloseResult)
+	}
+
+	if got := evaluate(trade); len(got) != 0 {
+		t.Errorf("unlocked by profitable sell = %v, want none", got)
+	}
+
+	trade, err = portfolios.SellInstrument(ctx, user.ID, sberID, 10, 120)
+	if err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	if got := evaluate(trade); len(got) != 1 || got[0] != domain.AchievementFirstShort {
+		t.Errorf("unlocked by short = %v, want [%s]", got, domain.AchievementFirstShort)
+	}
+
+	userAchievements, err := postgres.NewAchievementsRepository(pool).GetUserAchievements(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("failed to get user achievements: %v", err)
+	}
+
+	for _, a := range userAchievements {
+		if a.AchievementID == domain.AchievementProfitableTrades && (a.Progress != 1 || a.UnlockedAt != nil) {
+			t.Errorf("profitable trades progress = %d, unlocked = %v, want 1 and locked", a.Progress, a.UnlockedAt)
+		}
+	}
+
+	// only the first trade has a bonus, balance before the sells includes it
+	var bonuses int64
+	query := `SELECT COUNT(*) FROM success_bot.operations WHERE user_id = $1 AND type = 'achievement'`
+	if err := pool.QueryRow(ctx, query, user.ID).Scan(&bonuses); err != nil {
+		t.Fatalf("failed to count achievement operations: %v", err)
+	}
+
+	if bonuses != 1 {
+		t.Errorf("achievement operations = %d, want 1", bonuses)
+	}
+
+	assertMoney(t, "available balance after first trade", available, initialBalance-10*100*1.003+1000)
+	assertJournal(t)
+}
+
+func TestAchievementsConcurrentUnlock(t *testing.T) {
+	resetDB(t)
+
+	const callsCount = 10
+
+	ctx := context.Background()
+	engine := achievements.New(postgres.NewAchievementsRepository(pool), map[string]float64{
+		domain.AchievementMarginCallSurvivor: 2500,
+	})
+
+	user := createUser(t, 1)
+
+	// margin call cleared by stop-out isn't survived
+	got, err := engine.Evaluate(ctx, user.ID, domain.MarginCallCleared{UserID: user.ID, StoppedOut: true})
+	if err != nil {
+		t.Fatalf("failed to evaluate achievements: %v", err)
+	}
+
+	if len(got) != 0 {
+		t.Errorf("unlocked by stop-out = %d, want 0", len(got))
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		unlocked int
+	)
+
+	for range callsCount {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			got, err := engine.Evaluate(ctx, user.ID, domain.MarginCallCleared{UserID: user.ID})
+			if err != nil {
+				t.Errorf("failed to evaluate achievements: %v", err)
+				return
+			}
+
+			mu.Lock()
+			unlocked += len(got)
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if unlocked != 1 {
+		t.Errorf("unlocked = %d, want 1", unlocked)
+	}
+
+	available, _ := balances(t, user.ID)
+	assertMoney(t, "available balance", available, initialBalance+2500)
+	assertJournal(t)
+}
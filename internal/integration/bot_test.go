@@ -0,0 +1,263 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/bot"
+	"github.com/leonid6372/success-bot/internal/common/clients/fake"
+	"github.com/leonid6372/success-bot/internal/common/config"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/events"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+	"github.com/leonid6372/success-bot/pkg/cache"
+	"github.com/leonid6372/success-bot/pkg/dictionary"
+	"github.com/leonid6372/success-bot/pkg/leader"
+	"github.com/leonid6372/success-bot/pkg/token"
+	"gopkg.in/telebot.v4"
+)
+
+// settleDelay lets middlewares finish after handler's reply, e.g. save user to cache.
+const settleDelay = 50 * time.Millisecond
+
+type testBot struct {
+	*bot.Bot
+
+	telegram   *fakeTelegram
+	dictionary *dictionary.Dictionary
+
+	lastUpdateID atomic.Int64
+}
+
+// newTestBot returns bot which uses fake Telegram API and fake market data provider.
+// Periodic jobs are not run because elector is never started.
+func newTestBot(t *testing.T) *testBot {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	telegram := newFakeTelegram(t)
+
+	dict, err := dictionary.New(dictionary.DefaultPath)
+	if err != nil {
+		t.Fatalf("failed to init dictionary: %v", err)
+	}
+
+	cfg := &config.Bot{
+		APIKey:      fakeBotToken,
+		APIURL:      telegram.URL(),
+		Timeout:     5 * time.Second,
+		Languages:   []string{"ru", "en"},
+		DailyReward: 1000,
+	}
+
+	b, err := bot.New(ctx,
+		cfg,
+		fake.NewProvider(),
+		dict,
+		postgres.NewUsersRepository(pool),
+		postgres.NewInstrumentsRepository(pool),
+		postgres.NewPromocodesRepository(pool),
+		postgres.NewOperationsRepository(pool),
+		postgres.NewPortfolioRepository(pool),
+		postgres.NewTokensRepository(pool),
+		postgres.NewCandlesRepository(pool),
+		postgres.NewAchievementsRepository(pool),
+		postgres.NewFollowsRepository(pool),
+		postgres.NewGroupsRepository(pool),
+		cache.NewMemoryBackend(time.Minute),
+		leader.NewElector(pool, 1),
+		events.NewBus(postgres.NewOutboxRepository(pool)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create bot: %v", err)
+	}
+
+	return &testBot{
+		Bot:        b,
+		telegram:   telegram,
+		dictionary: dict,
+	}
+}
+
+func (tb *testBot) sendText(userID int64, text string) {
+	tb.Telebot.ProcessUpdate(telebot.Update{
+		ID: int(tb.lastUpdateID.Add(1)),
+		Message: &telebot.Message{
+			ID:     int(tb.lastUpdateID.Load()),
+			Sender: &telebot.User{ID: userID, Username: "tester"},
+			Chat:   &telebot.Chat{ID: userID, Type: telebot.ChatPrivate},
+			Text:   text,
+		},
+	})
+}
+
+func (tb *testBot) sendCallback(userID int64, unique, data string) {
+	tb.Telebot.ProcessUpdate(telebot.Update{
+		ID: int(tb.lastUpdateID.Add(1)),
+		Callback: &telebot.Callback{
+			ID:     "callback",
+			Sender: &telebot.User{ID: userID, Username: "tester"},
+			Message: &telebot.Message{
+				ID:   int(tb.lastUpdateID.Load()),
+				Chat: &telebot.Chat{ID: userID, Type: telebot.ChatPrivate},
+			},
+			Data: "\f" + unique + "|" + data,
+		},
+	})
+}
+
+// expect waits for the reply to the user containing substring.
+func (tb *testBot) expect(t *testing.T, userID int64, substring string) string {
+	t.Helper()
+
+	message := tb.telegram.waitMessage(t, userID, substring)
+	time.Sleep(settleDelay)
+
+	return message.Text
+}
+
+func TestBotOnboardingAndPromocode(t *testing.T) {
+	resetDB(t)
+	createPromocode(t, "WELCOME", 10, 1000)
+
+	const userID = 100
+
+	tb := newTestBot(t)
+
+	// new user is created and asked for a language
+	tb.sendText(userID, "/start")
+	tb.expect(t, userID, tb.dictionary.Text(dictionary.DefaultLanguage, "select_language"))
+
+	available, _ := balances(t, userID)
+	assertMoney(t, "initial balance", available, initialBalance)
+
+	tb.sendCallback(userID, "language", "en")
+	tb.expect(t, userID, "")
+
+	var languageCode string
+	query := `SELECT language_code FROM success_bot.users WHERE id = $1`
+	if err := pool.QueryRow(context.Background(), query, userID).Scan(&languageCode); err != nil {
+		t.Fatalf("failed to get user language: %v", err)
+	}
+
+	if languageCode != "en" {
+		t.Errorf("language code = %q, want en", languageCode)
+	}
+
+	// promocode is entered after the menu button
+	tb.sendText(userID, tb.dictionary.Text("en", "button_enter_promocode"))
+	tb.expect(t, userID, "")
+
+	tb.sendText(userID, "WELCOME")
+	tb.expect(t, userID, "L$ 1,000.00")
+
+	available, _ = balances(t, userID)
+	assertMoney(t, "balance after promocode", available, initialBalance+1000)
+
+	// the same promocode can't be applied twice
+	tb.sendText(userID, tb.dictionary.Text("en", "button_enter_promocode"))
+	tb.expect(t, userID, "")
+
+	tb.sendText(userID, "WELCOME")
+	tb.expect(t, userID, "")
+
+	available, _ = balances(t, userID)
+	assertMoney(t, "balance after second promocode", available, initialBalance+1000)
+
+	assertJournal(t)
+}
+
+func TestBotAPIToken(t *testing.T) {
+	resetDB(t)
+
+	const userID = 200
+
+	tb := newTestBot(t)
+
+	tb.sendText(userID, "/start")
+	tb.expect(t, userID, "")
+
+	tb.sendText(userID, "/token")
+	text := tb.expect(t, userID, "<code>")
+
+	start := strings.Index(text, "<code>") + len("<code>")
+	end := strings.Index(text[start:], "</code>")
+	if end < 0 {
+		t.Fatalf("token not found in message %q", text)
+	}
+
+	apiToken := text[start : start+end]
+
+	gotUserID, err := postgres.NewTokensRepository(pool).GetUserIDByToken(context.Background(), token.Hash(apiToken))
+	if err != nil {
+		t.Fatalf("GetUserIDByToken: %v", err)
+	}
+
+	if gotUserID != userID {
+		t.Errorf("token user id = %d, want %d", gotUserID, userID)
+	}
+}
+
+func TestBotSettings(t *testing.T) {
+	resetDB(t)
+
+	const userID = 300
+
+	tb := newTestBot(t)
+
+	tb.sendText(userID, "/start")
+	tb.expect(t, userID, "")
+
+	tb.sendCallback(userID, "language", "en")
+	tb.expect(t, userID, "")
+
+	tb.sendText(userID, tb.dictionary.Text("en", "button_settings"))
+	tb.expect(t, userID, "UTC")
+
+	tb.sendCallback(userID, "settings_notification", "margin_call")
+	tb.expect(t, userID, "")
+
+	tb.sendCallback(userID, "settings_time_zone", "Asia/Vladivostok")
+	tb.expect(t, userID, "Asia/Vladivostok")
+
+	tb.sendCallback(userID, "settings_quiet_hours", "")
+	tb.expect(t, userID, "22:00–08:00")
+
+	user, err := postgres.NewUsersRepository(pool).GetUserByID(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+
+	settings := user.Settings
+	if settings.TimeZone != "Asia/Vladivostok" {
+		t.Errorf("time zone = %q, want Asia/Vladivostok", settings.TimeZone)
+	}
+
+	if !settings.IsMuted(domain.NotificationMarginCall) || settings.IsMuted(domain.NotificationDailyReward) {
+		t.Errorf("muted notifications = %v, want [margin_call]", settings.MutedNotifications)
+	}
+
+	if settings.QuietHoursFrom != 22 || settings.QuietHoursTo != 8 {
+		t.Errorf("quiet hours = %d-%d, want 22-8", settings.QuietHoursFrom, settings.QuietHoursTo)
+	}
+
+	// muting again unmutes the category
+	tb.sendCallback(userID, "settings_notification", "margin_call")
+	tb.expect(t, userID, "")
+
+	user, err = postgres.NewUsersRepository(pool).GetUserByID(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+
+	if len(user.Settings.MutedNotifications) != 0 {
+		t.Errorf("muted notifications = %v, want none", user.Settings.MutedNotifications)
+	}
+}
@@ -0,0 +1,100 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+)
+
+func TestPayCoupons(t *testing.T) {
+	resetDB(t)
+
+	ctx := domain.ContextWithActor(context.Background(), domain.ActorCouponPayout)
+	portfolios := postgres.NewPortfolioRepository(pool)
+
+	var bondID int64
+	query := `INSERT INTO success_bot.instruments(ticker, name, kind, nominal, listed)
+		VALUES ('BOND@MISX', '📜 Bond', 'bond', 1000, TRUE) RETURNING id`
+	if err := pool.QueryRow(ctx, query).Scan(&bondID); err != nil {
+		t.Fatalf("failed to create bond: %v", err)
+	}
+
+	t.Cleanup(func() {
+		for _, query := range []string{
+			`DELETE FROM success_bot.bond_coupon_payments WHERE instrument_id = $1`,
+			`DELETE FROM success_bot.bond_coupons WHERE instrument_id = $1`,
+			`DELETE FROM success_bot.instruments WHERE id = $1`,
+		} {
+			if _, err := pool.Exec(context.Background(), query, bondID); err != nil {
+				t.Errorf("failed to delete bond: %v", err)
+			}
+		}
+	})
+
+	now := time.Now().UTC()
+	query = `INSERT INTO success_bot.bond_coupons(instrument_id, date, amount)
+		VALUES ($1, $2, 30), ($1, $3, 30), ($1, $4, 30)`
+	if _, err := pool.Exec(ctx, query, bondID,
+		now.AddDate(0, 0, -30), // before the payout period
+		now.AddDate(0, 0, -1),
+		now.AddDate(0, 0, 30),
+	); err != nil {
+		t.Fatalf("failed to create coupons: %v", err)
+	}
+
+	holder := createUser(t, 1)
+	shortSeller := createUser(t, 2)
+
+	if _, err := portfolios.BuyInstrument(ctx, holder.ID, bondID, 10, 1000); err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	if _, err := portfolios.SellInstrument(ctx, shortSeller.ID, bondID, 5, 1000); err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	holderAvailable, _ := balances(t, holder.ID)
+	shortAvailable, _ := balances(t, shortSeller.ID)
+
+	paid, err := portfolios.PayCoupons(ctx, now.AddDate(0, 0, -7), now)
+	if err != nil {
+		t.Fatalf("PayCoupons: %v", err)
+	}
+
+	if paid != 1 {
+		t.Errorf("paid coupons = %d, want 1", paid)
+	}
+
+	available, _ := balances(t, holder.ID)
+	assertMoney(t, "holder available", available, holderAvailable+300)
+
+	available, _ = balances(t, shortSeller.ID)
+	assertMoney(t, "short seller available", available, shortAvailable-150)
+
+	// every coupon is paid once
+	paid, err = portfolios.PayCoupons(ctx, now.AddDate(0, 0, -7), now)
+	if err != nil {
+		t.Fatalf("PayCoupons again: %v", err)
+	}
+
+	if paid != 0 {
+		t.Errorf("paid coupons again = %d, want 0", paid)
+	}
+
+	var operationsCount int64
+	query = `SELECT COUNT(*) FROM success_bot.operations WHERE type = $1`
+	if err := pool.QueryRow(ctx, query, domain.OperationTypeCoupon).Scan(&operationsCount); err != nil {
+		t.Fatalf("failed to count coupon operations: %v", err)
+	}
+
+	if operationsCount != 2 {
+		t.Errorf("coupon operations = %d, want 2", operationsCount)
+	}
+
+	assertJournal(t)
+}
@@ -0,0 +1,100 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+)
+
+func TestDailyRewardStreak(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	users := postgres.NewUsersRepository(pool)
+	tiers := domain.NewDailyRewardTiers(1000, []domain.DailyRewardTier{{MinStreak: 2, Amount: 1500}})
+
+	createUser(t, 1)
+
+	claim := func(wantStreak, wantTier int, wantAmount float64) {
+		t.Helper()
+
+		got, err := users.ClaimDailyReward(ctx, 1, tiers)
+		if err != nil {
+			t.Fatalf("failed to claim daily reward: %v", err)
+		}
+
+		if got.Streak != wantStreak || got.Tier != wantTier || got.Amount != wantAmount {
+			t.Errorf("claim = %+v, want streak %d, tier %d, amount %v", got, wantStreak, wantTier, wantAmount)
+		}
+	}
+
+	today := time.Now()
+
+	reset := func(day int) {
+		t.Helper()
+
+		resetIDs, err := users.ResetDailyReward(ctx, map[int64]time.Time{1: today.AddDate(0, 0, day)})
+		if err != nil {
+			t.Fatalf("failed to reset daily reward: %v", err)
+		}
+
+		if len(resetIDs) != 1 {
+			t.Errorf("reset users = %v, want [1]", resetIDs)
+		}
+	}
+
+	// new users get the reward on the first reset
+	reset(0)
+	claim(1, 1, 1000)
+
+	if _, err := users.ClaimDailyReward(ctx, 1, tiers); !errors.Is(err, boterrs.ErrUnavailableDailyReward) {
+		t.Errorf("second claim error = %v, want %v", err, boterrs.ErrUnavailableDailyReward)
+	}
+
+	// reset due again on the same local day, e.g. after a change of time zone, is skipped
+	resetIDs, err := users.ResetDailyReward(ctx, map[int64]time.Time{1: today})
+	if err != nil {
+		t.Fatalf("failed to reset daily reward: %v", err)
+	}
+
+	if len(resetIDs) != 0 {
+		t.Errorf("reset users on the same day = %v, want none", resetIDs)
+	}
+
+	if _, err := users.ClaimDailyReward(ctx, 1, tiers); !errors.Is(err, boterrs.ErrUnavailableDailyReward) {
+		t.Errorf("claim after the same day reset error = %v, want %v", err, boterrs.ErrUnavailableDailyReward)
+	}
+
+	reset(1)
+	claim(2, 2, 1500)
+
+	reset(2)
+	claim(3, 2, 1500)
+
+	// the reward of the next day is missed
+	reset(3)
+	reset(4)
+	claim(1, 1, 1000)
+
+	available, _ := balances(t, 1)
+	assertMoney(t, "available balance", available, initialBalance+1000+1500+1500+1000)
+
+	var tier int64
+	query := `SELECT count FROM success_bot.operations WHERE user_id = 1 AND type = 'daily_reward' ORDER BY id DESC LIMIT 1`
+	if err := pool.QueryRow(ctx, query).Scan(&tier); err != nil {
+		t.Fatalf("failed to get operation: %v", err)
+	}
+
+	if tier != 1 {
+		t.Errorf("operation tier = %d, want 1", tier)
+	}
+
+	assertJournal(t)
+}
@@ -0,0 +1,167 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/events"
+	"github.com/leonid6372/success-bot/internal/common/metrics"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+)
+
+func TestEventBusDeliversStoredEvents(t *testing.T) {
+	resetDB(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// events published before the bus runs, e.g. before a crash, are stored in the outbox
+	publisher := events.NewBus(postgres.NewOutboxRepository(pool))
+
+	for i := range 3 {
+		if err := publisher.Publish(ctx, domain.RewardClaimed{
+			UserID: int64(i + 1),
+			Claim:  domain.DailyRewardClaim{Streak: 3, Tier: 1, Amount: 1500},
+		}); err != nil {
+			t.Fatalf("failed to publish event: %v", err)
+		}
+	}
+
+	if got := outboxEventsCount(t); got != 3 {
+		t.Fatalf("outbox events = %d, want 3", got)
+	}
+
+	bus := events.NewBus(postgres.NewOutboxRepository(pool))
+
+	claims := make(chan domain.RewardClaimed, 3)
+	events.Subscribe(bus, "test", func(_ context.Context, e domain.RewardClaimed) error {
+		claims <- e
+		return nil
+	})
+
+	// a failing subscriber doesn't block delivery to other subscribers, it gets the events again after a backoff
+	var failures atomic.Int64
+	events.Subscribe(bus, "failing", func(_ context.Context, _ domain.RewardClaimed) error {
+		if failures.Add(1) <= 3 {
+			return errors.New("failed")
+		}
+
+		return nil
+	})
+
+	go bus.Run(ctx)
+
+	for i := range 3 {
+		select {
+		case e := <-claims:
+			if e.UserID != int64(i+1) || e.Claim.Amount != 1500 {
+				t.Errorf("event %d = %+v, want user %d with 1500 reward", i, e, i+1)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %d isn't delivered", i)
+		}
+	}
+
+	// failed events are kept with the subscribers which handled them
+	deadline := time.Now().Add(5 * time.Second)
+	for retriedEventsCount(t) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("failed events aren't released for retry")
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// skip the backoff
+	if _, err := pool.Exec(ctx, `UPDATE success_bot.outbox_events SET claimed_until = NOW()`); err != nil {
+		t.Fatalf("failed to expire claims: %v", err)
+	}
+
+	bus.Notify()
+
+	// retried events are deleted after delivery to the failed subscriber only
+	deadline = time.Now().Add(5 * time.Second)
+	for outboxEventsCount(t) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("retried events aren't deleted from outbox")
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	select {
+	case e := <-claims:
+		t.Errorf("event %+v is delivered again to the subscriber which handled it", e)
+	default:
+	}
+
+	if got := failures.Load(); got != 6 {
+		t.Errorf("deliveries to failing subscriber = %d, want 6", got)
+	}
+}
+
+func TestRepositoriesStoreEventsWithChanges(t *testing.T) {
+	resetDB(t)
+
+	ctx := domain.ContextWithActor(context.Background(), domain.ActorAPI)
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+	sberID := instrumentID(t, ticker)
+
+	// rolled back order stores no event
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 1_000_000, 100); !errors.Is(err, boterrs.ErrInsufficientFunds) {
+		t.Fatalf("BuyInstrument error = %v, want %v", err, boterrs.ErrInsufficientFunds)
+	}
+
+	trade, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 10, 100)
+	if err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	var (
+		eventType string
+		event     domain.TradeExecuted
+	)
+	query := `SELECT type, payload FROM success_bot.outbox_events WHERE type <> $1`
+	if err := pool.QueryRow(ctx, query, domain.EventTypeUserRegistered).Scan(&eventType, &event); err != nil {
+		t.Fatalf("failed to get stored event: %v", err)
+	}
+
+	if eventType != domain.EventTypeTradeExecuted || event.UserID != user.ID ||
+		event.Trade.OperationID != trade.OperationID || event.Source != metrics.SourceAPI {
+		t.Errorf("stored event = %s %+v, want trade %d of user %d from api", eventType, event, trade.OperationID, user.ID)
+	}
+}
+
+func retriedEventsCount(t *testing.T) int {
+	t.Helper()
+
+	var count int
+	if err := pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM success_bot.outbox_events WHERE attempts = 1 AND delivered_to = '{test}'`,
+	).Scan(&count); err != nil {
+		t.Fatalf("failed to count retried events: %v", err)
+	}
+
+	return count
+}
+
+func outboxEventsCount(t *testing.T) int {
+	t.Helper()
+
+	var count int
+	if err := pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM success_bot.outbox_events`,
+	).Scan(&count); err != nil {
+		t.Fatalf("failed to count outbox events: %v", err)
+	}
+
+	return count
+}
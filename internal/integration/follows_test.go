@@ -0,0 +1,277 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+)
+
+func TestFollows(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	users := postgres.NewUsersRepository(pool)
+	follows := postgres.NewFollowsRepository(pool)
+
+	follower := createUser(t, 1)
+	trader := createUser(t, 2)
+
+	if err := users.UpdateUserSettings(ctx, trader.ID, &domain.UserSettings{PublicProfile: true}); err != nil {
+		t.Fatalf("UpdateUserSettings: %v", err)
+	}
+
+	dbTrader, err := users.GetUserByID(ctx, trader.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+
+	if !dbTrader.Settings.PublicProfile {
+		t.Error("trader profile isn't public")
+	}
+
+	topUsersData, err := users.GetTopUsersData(ctx)
+	if err != nil {
+		t.Fatalf("GetTopUsersData: %v", err)
+	}
+
+	for _, data := range topUsersData {
+		if want := data.ID == trader.ID; data.PublicProfile != want {
+			t.Errorf("user %d public profile = %v, want %v", data.ID, data.PublicProfile, want)
+		}
+	}
+
+	// following twice isn't an error
+	for range 2 {
+		if err := follows.Follow(ctx, follower.ID, trader.ID); err != nil {
+			t.Fatalf("Follow: %v", err)
+		}
+	}
+
+	followedTraders, err := follows.GetFollowedTraders(ctx, follower.ID)
+	if err != nil {
+		t.Fatalf("GetFollowedTraders: %v", err)
+	}
+
+	if percent, ok := followedTraders[trader.ID]; !ok || percent != 0 {
+		t.Errorf("followed traders = %v, want %d without copying", followedTraders, trader.ID)
+	}
+
+	followers, err := follows.GetFollowers(ctx, trader.ID)
+	if err != nil {
+		t.Fatalf("GetFollowers: %v", err)
+	}
+
+	if !slices.Equal(followers, []int64{follower.ID}) {
+		t.Errorf("followers = %v, want [%d]", followers, follower.ID)
+	}
+
+	if err := follows.Unfollow(ctx, follower.ID, trader.ID); err != nil {
+		t.Fatalf("Unfollow: %v", err)
+	}
+
+	followers, err = follows.GetFollowers(ctx, trader.ID)
+	if err != nil {
+		t.Fatalf("GetFollowers: %v", err)
+	}
+
+	if len(followers) != 0 {
+		t.Errorf("followers after unfollow = %v, want none", followers)
+	}
+}
+
+func TestTradeResultInstrument(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	portfolios := postgres.NewPortfolioRepository(pool)
+
+	user := createUser(t, 1)
+	sberID := instrumentID(t, ticker)
+
+	trade, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 10, 100)
+	if err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	// followers are notified with the instrument of the trade
+	if trade.InstrumentID != sberID || trade.Ticker != ticker {
+		t.Errorf("trade instrument = %d %s, want %d %s", trade.InstrumentID, trade.Ticker, sberID, ticker)
+	}
+
+	positions, err := portfolios.GetUserPortfolio(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserPortfolio: %v", err)
+	}
+
+	if len(positions) != 1 || positions[0].Ticker != ticker || positions[0].Count != 10 {
+		t.Errorf("portfolio = %+v, want 10 of %s", positions, ticker)
+	}
+}
+
+func TestCopyTrading(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	follows := postgres.NewFollowsRepository(pool)
+	portfolios := postgres.NewPortfolioRepository(pool)
+	operations := postgres.NewOperationsRepository(pool)
+
+	follower := createUser(t, 1)
+	trader := createUser(t, 2)
+
+	// copying follows the trader
+	if err := follows.SetCopyPercent(ctx, follower.ID, trader.ID, 25); err != nil {
+		t.Fatalf("SetCopyPercent: %v", err)
+	}
+
+	copiers, err := follows.GetCopiers(ctx, trader.ID)
+	if err != nil {
+		t.Fatalf("GetCopiers: %v", err)
+	}
+
+	if len(copiers) != 1 || copiers[0].FollowerID != follower.ID || copiers[0].Percent != 25 {
+		t.Errorf("copiers = %+v, want %d with 25%%", copiers, follower.ID)
+	}
+
+	sberID := instrumentID(t, ticker)
+
+	trade, err := portfolios.BuyInstrument(ctx, trader.ID, sberID, 10, 100)
+	if err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	if trade.Position != 10 {
+		t.Errorf("trade position = %d, want 10", trade.Position)
+	}
+
+	copyCtx := domain.ContextWithCopiedOperation(ctx, trade.OperationID)
+	if _, err := portfolios.BuyInstrument(copyCtx, follower.ID, sberID, 2, 100); err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	ops, err := operations.GetOperationsByPage(ctx, follower.ID, 1)
+	if err != nil {
+		t.Fatalf("GetOperationsByPage: %v", err)
+	}
+
+	if len(ops) == 0 || ops[0].CopiedOperationID != trade.OperationID || ops[0].CopiedFrom != trader.Username {
+		t.Errorf("copied operation = %+v, want copy of %d from %s", ops, trade.OperationID, trader.Username)
+	}
+
+	// the trade event delivered again doesn't copy the operation twice
+	copied, err := operations.IsOperationCopied(ctx, follower.ID, trade.OperationID)
+	if err != nil {
+		t.Fatalf("IsOperationCopied: %v", err)
+	}
+
+	if !copied {
+		t.Error("operation isn't copied")
+	}
+
+	if _, err := portfolios.BuyInstrument(copyCtx, follower.ID, sberID, 2, 100); !errors.Is(err, boterrs.ErrOperationCopied) {
+		t.Errorf("BuyInstrument copy again error = %v, want %v", err, boterrs.ErrOperationCopied)
+	}
+
+	if count, _ := position(t, follower.ID, sberID); count != 2 {
+		t.Errorf("follower position = %d, want 2", count)
+	}
+
+	// stopping copying keeps following
+	if err := follows.SetCopyPercent(ctx, follower.ID, trader.ID, 0); err != nil {
+		t.Fatalf("SetCopyPercent: %v", err)
+	}
+
+	copiers, err = follows.GetCopiers(ctx, trader.ID)
+	if err != nil {
+		t.Fatalf("GetCopiers: %v", err)
+	}
+
+	if len(copiers) != 0 {
+		t.Errorf("copiers after stop = %+v, want none", copiers)
+	}
+
+	followedTraders, err := follows.GetFollowedTraders(ctx, follower.ID)
+	if err != nil {
+		t.Fatalf("GetFollowedTraders: %v", err)
+	}
+
+	if _, ok := followedTraders[trader.ID]; !ok {
+		t.Error("follower doesn't follow the trader after stopping copying")
+	}
+}
+
+func TestCopyPercentLimit(t *testing.T) {
+	resetDB(t)
+
+	const tradersCount = 5
+
+	ctx := context.Background()
+	follows := postgres.NewFollowsRepository(pool)
+	follower := createUser(t, 1)
+
+	traders := make([]*domain.User, 0, tradersCount)
+	for i := range tradersCount {
+		traders = append(traders, createUser(t, int64(i+2)))
+	}
+
+	// concurrent allocations of 50% to different traders don't exceed the limit together
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+
+	for _, trader := range traders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := follows.SetCopyPercent(ctx, follower.ID, trader.ID, 50)
+			if err != nil && !errors.Is(err, boterrs.ErrCopyLimitExceeded) {
+				t.Errorf("SetCopyPercent: %v", err)
+				return
+			}
+
+			if err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if allowed != domain.MaxCopyPercent/50 {
+		t.Errorf("allowed allocations = %d, want %d", allowed, domain.MaxCopyPercent/50)
+	}
+
+	followed, err := follows.GetFollowedTraders(ctx, follower.ID)
+	if err != nil {
+		t.Fatalf("GetFollowedTraders: %v", err)
+	}
+
+	var allocated int
+	for traderID, percent := range followed {
+		allocated += percent
+
+		// the allocated percent of a trader can be changed within the limit
+		if percent > 0 {
+			if err := follows.SetCopyPercent(ctx, follower.ID, traderID, 25); err != nil {
+				t.Errorf("SetCopyPercent: %v", err)
+			}
+		}
+	}
+
+	if allocated != domain.MaxCopyPercent {
+		t.Errorf("allocated = %d, want %d", allocated, domain.MaxCopyPercent)
+	}
+}
@@ -0,0 +1,152 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+	"gopkg.in/telebot.v4"
+)
+
+const groupTitle = "Traders"
+
+func (tb *testBot) sendGroupText(groupID, userID int64, text string) {
+	tb.Telebot.ProcessUpdate(telebot.Update{
+		ID: int(tb.lastUpdateID.Add(1)),
+		Message: &telebot.Message{
+			ID:     int(tb.lastUpdateID.Load()),
+			Sender: &telebot.User{ID: userID, Username: "tester"},
+			Chat:   &telebot.Chat{ID: groupID, Type: telebot.ChatSuperGroup, Title: groupTitle},
+			Text:   text,
+		},
+	})
+}
+
+func TestGroups(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	groups := postgres.NewGroupsRepository(pool)
+
+	const groupID = -100
+
+	member := createUser(t, 1)
+
+	group, err := groups.SaveGroup(ctx, &domain.Group{ID: groupID, Title: "Old", LanguageCode: "en"})
+	if err != nil {
+		t.Fatalf("SaveGroup: %v", err)
+	}
+
+	// saving again updates the title only
+	group, err = groups.SaveGroup(ctx, &domain.Group{ID: groupID, Title: groupTitle, LanguageCode: "ru"})
+	if err != nil {
+		t.Fatalf("SaveGroup: %v", err)
+	}
+
+	if group.Title != groupTitle || group.LanguageCode != "en" {
+		t.Errorf("group = %+v, want title %s and language en", group, groupTitle)
+	}
+
+	// joining twice isn't an error
+	for range 2 {
+		if err := groups.JoinGroup(ctx, groupID, member.ID); err != nil {
+			t.Fatalf("JoinGroup: %v", err)
+		}
+	}
+
+	members, err := groups.GetGroupMembers(ctx, groupID)
+	if err != nil {
+		t.Fatalf("GetGroupMembers: %v", err)
+	}
+
+	if !slices.Equal(members, []int64{member.ID}) {
+		t.Errorf("members = %v, want [%d]", members, member.ID)
+	}
+
+	userGroups, err := groups.GetUserGroups(ctx, member.ID)
+	if err != nil {
+		t.Fatalf("GetUserGroups: %v", err)
+	}
+
+	if len(userGroups) != 1 || userGroups[0].ID != groupID {
+		t.Errorf("user groups = %+v, want %d", userGroups, groupID)
+	}
+
+	for _, want := range []bool{true, false} {
+		left, err := groups.LeaveGroup(ctx, groupID, member.ID)
+		if err != nil {
+			t.Fatalf("LeaveGroup: %v", err)
+		}
+
+		if left != want {
+			t.Errorf("left = %v, want %v", left, want)
+		}
+	}
+
+	if err := groups.JoinGroup(ctx, groupID, member.ID); err != nil {
+		t.Fatalf("JoinGroup: %v", err)
+	}
+
+	// the league is deleted with the group
+	if err := groups.DeleteGroup(ctx, groupID); err != nil {
+		t.Fatalf("DeleteGroup: %v", err)
+	}
+
+	userGroups, err = groups.GetUserGroups(ctx, member.ID)
+	if err != nil {
+		t.Fatalf("GetUserGroups: %v", err)
+	}
+
+	if len(userGroups) != 0 {
+		t.Errorf("user groups after delete = %+v, want none", userGroups)
+	}
+}
+
+func TestBotGroupLeague(t *testing.T) {
+	resetDB(t)
+
+	const (
+		groupID      = -200
+		memberID     = 400
+		unregistered = 401
+	)
+
+	tb := newTestBot(t)
+	member := createUser(t, memberID)
+
+	// registration is done in private chat
+	tb.sendGroupText(groupID, unregistered, "/join")
+	tb.expect(t, groupID, "@fake_bot")
+
+	tb.sendGroupText(groupID, memberID, "/join@fake_bot")
+	tb.expect(t, groupID, groupTitle)
+
+	members, err := postgres.NewGroupsRepository(pool).GetGroupMembers(context.Background(), groupID)
+	if err != nil {
+		t.Fatalf("GetGroupMembers: %v", err)
+	}
+
+	if !slices.Equal(members, []int64{member.ID}) {
+		t.Errorf("members = %v, want [%d]", members, member.ID)
+	}
+
+	tb.sendGroupText(groupID, memberID, "/top")
+	tb.expect(t, groupID, groupTitle)
+
+	// the username is updated from the sender
+	tb.sendGroupText(groupID, memberID, "/leave")
+	tb.expect(t, groupID, "tester")
+
+	members, err = postgres.NewGroupsRepository(pool).GetGroupMembers(context.Background(), groupID)
+	if err != nil {
+		t.Fatalf("GetGroupMembers: %v", err)
+	}
+
+	if len(members) != 0 {
+		t.Errorf("members after leave = %v, want none", members)
+	}
+}
@@ -0,0 +1,106 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+)
+
+const initialBalance = 250000
+
+func createUser(t *testing.T, id int64) *domain.User {
+	t.Helper()
+
+	user := &domain.User{
+		ID:       id,
+		Username: "user",
+	}
+
+	if err := postgres.NewUsersRepository(pool).CreateUser(context.Background(), user, "en"); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	return user
+}
+
+func instrumentID(t *testing.T, ticker string) int64 {
+	t.Helper()
+
+	instrument, err := postgres.NewInstrumentsRepository(pool).GetInstrumentByTicker(context.Background(), ticker)
+	if err != nil {
+		t.Fatalf("failed to get instrument %s: %v", ticker, err)
+	}
+
+	return instrument.ID
+}
+
+func balances(t *testing.T, userID int64) (available, blocked float64) {
+	t.Helper()
+
+	query := `SELECT available_balance, blocked_balance FROM success_bot.users WHERE id = $1`
+	if err := pool.QueryRow(context.Background(), query, userID).Scan(&available, &blocked); err != nil {
+		t.Fatalf("failed to get user balances: %v", err)
+	}
+
+	return available, blocked
+}
+
+// position returns count and average price of user's instrument, zero count if there is no position.
+func position(t *testing.T, userID, instrumentID int64) (int64, float64) {
+	t.Helper()
+
+	var count int64
+	var avgPrice float64
+
+	query := `SELECT count, average_price FROM success_bot.users_instruments WHERE user_id = $1 AND instrument_id = $2`
+	err := pool.QueryRow(context.Background(), query, userID, instrumentID).Scan(&count, &avgPrice)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("failed to get user position: %v", err)
+	}
+
+	return count, avgPrice
+}
+
+// currencyBalance returns user's cash balance in the currency, zero if there is no balance.
+func currencyBalance(t *testing.T, userID int64, currency string) float64 {
+	t.Helper()
+
+	var amount float64
+
+	query := `SELECT amount FROM success_bot.users_currency_balances WHERE user_id = $1 AND currency = $2`
+	err := pool.QueryRow(context.Background(), query, userID, currency).Scan(&amount)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("failed to get user currency balance: %v", err)
+	}
+
+	return amount
+}
+
+func assertMoney(t *testing.T, name string, got, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 0.005 {
+		t.Errorf("%s = %.2f, want %.2f", name, got, want)
+	}
+}
+
+// assertJournal checks that balances of all users are equal to replayed balance_events journal.
+func assertJournal(t *testing.T) {
+	t.Helper()
+
+	mismatches, err := postgres.NewBalanceEventsRepository(pool).GetBalanceMismatches(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get balance mismatches: %v", err)
+	}
+
+	for _, mismatch := range mismatches {
+		t.Errorf("balance mismatch: %+v", *mismatch)
+	}
+}
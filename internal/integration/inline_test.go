@@ -0,0 +1,54 @@
+//go:build integration
+
+package integration
+
+import (
+	"strconv"
+	"testing"
+
+	"gopkg.in/telebot.v4"
+)
+
+func (tb *testBot) sendQuery(userID int64, text string) string {
+	id := strconv.FormatInt(tb.lastUpdateID.Add(1), 10)
+
+	tb.Telebot.ProcessUpdate(telebot.Update{
+		ID: int(tb.lastUpdateID.Load()),
+		Query: &telebot.Query{
+			ID:     id,
+			Sender: &telebot.User{ID: userID, Username: "tester"},
+			Text:   text,
+		},
+	})
+
+	return id
+}
+
+func TestBotInlineMode(t *testing.T) {
+	resetDB(t)
+
+	const (
+		userID       = 500
+		unregistered = 501
+	)
+
+	tb := newTestBot(t)
+	createUser(t, userID)
+
+	queryID := tb.sendQuery(userID, "sber")
+	tb.telegram.waitAnswer(t, queryID, `"id":"quote:`+ticker+`"`)
+
+	queryID = tb.sendQuery(userID, "Portfolio")
+	tb.telegram.waitAnswer(t, queryID, `"id":"portfolio"`)
+
+	// empty query suggests the card even without positions
+	queryID = tb.sendQuery(userID, "")
+	tb.telegram.waitAnswer(t, queryID, `"id":"portfolio"`)
+
+	queryID = tb.sendQuery(userID, "UNKNOWN")
+	tb.telegram.waitAnswer(t, queryID, `"results":[]`)
+
+	// unregistered users are offered to start the bot
+	queryID = tb.sendQuery(unregistered, "sber")
+	tb.telegram.waitAnswer(t, queryID, `"start_parameter":"inline"`)
+}
@@ -0,0 +1,188 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+)
+
+// syncInstruments syncs instruments and reactivates instruments deactivated by the sync after the test,
+// because instruments from migrations are shared by all tests.
+func syncInstruments(t *testing.T, instruments []*domain.Instrument) *domain.InstrumentsSyncResult {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var active []string
+	query := `SELECT array_agg(ticker) FROM success_bot.instruments WHERE active`
+	if err := pool.QueryRow(ctx, query).Scan(&active); err != nil {
+		t.Fatalf("failed to get active instruments: %v", err)
+	}
+
+	t.Cleanup(func() {
+		query := `UPDATE success_bot.instruments SET active = TRUE WHERE ticker = ANY($1)`
+		if _, err := pool.Exec(context.Background(), query, active); err != nil {
+			t.Errorf("failed to reactivate instruments: %v", err)
+		}
+	})
+
+	result, err := postgres.NewInstrumentsRepository(pool).SyncInstruments(ctx, instruments)
+	if err != nil {
+		t.Fatalf("SyncInstruments: %v", err)
+	}
+
+	return result
+}
+
+func TestSyncInstrumentsKeepsCurrencyAndKind(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	instruments := postgres.NewInstrumentsRepository(pool)
+
+	query := `INSERT INTO success_bot.instruments(ticker, name, currency, kind, decimals)
+		VALUES ('USDETF@MISX', 'USD ETF', 'USD', 'etf', 4)
+		ON CONFLICT (ticker) DO UPDATE SET currency = EXCLUDED.currency, kind = EXCLUDED.kind, decimals = EXCLUDED.decimals`
+	if _, err := pool.Exec(ctx, query); err != nil {
+		t.Fatalf("failed to create instrument: %v", err)
+	}
+
+	t.Cleanup(func() {
+		query := `DELETE FROM success_bot.instruments WHERE ticker = 'NEWSHARE@MISX'`
+		if _, err := pool.Exec(context.Background(), query); err != nil {
+			t.Errorf("failed to delete instrument: %v", err)
+		}
+	})
+
+	// provider reports neither currency, kind nor decimals
+	syncInstruments(t, []*domain.Instrument{
+		{InstrumentIdentifiers: domain.InstrumentIdentifiers{Ticker: "USDETF@MISX", Name: "USD ETF"}},
+		{InstrumentIdentifiers: domain.InstrumentIdentifiers{Ticker: "NEWSHARE@MISX", Name: "New share"}},
+	})
+
+	etf, err := instruments.GetInstrumentByTicker(ctx, "USDETF@MISX")
+	if err != nil {
+		t.Fatalf("GetInstrumentByTicker: %v", err)
+	}
+
+	if etf.Currency != domain.CurrencyUSD || etf.Kind != domain.InstrumentKindETF {
+		t.Errorf("synced instrument = %s %s, want %s %s", etf.Currency, etf.Kind, domain.CurrencyUSD, domain.InstrumentKindETF)
+	}
+
+	if etf.Decimals != 4 {
+		t.Errorf("synced instrument decimals = %d, want 4", etf.Decimals)
+	}
+
+	stored, err := instruments.GetInstruments(ctx)
+	if err != nil {
+		t.Fatalf("GetInstruments: %v", err)
+	}
+
+	if !slices.ContainsFunc(stored, func(i *domain.Instrument) bool { return i.Ticker == "NEWSHARE@MISX" }) {
+		t.Error("GetInstruments() has no added instrument")
+	}
+
+	added, err := instruments.GetInstrumentByTicker(ctx, "NEWSHARE@MISX")
+	if err != nil {
+		t.Fatalf("GetInstrumentByTicker: %v", err)
+	}
+
+	if added.Currency != domain.CurrencyRUB || added.Kind != domain.InstrumentKindShare {
+		t.Errorf("added instrument = %s %s, want %s %s", added.Currency, added.Kind, domain.CurrencyRUB, domain.InstrumentKindShare)
+	}
+}
+
+func TestSyncInstrumentsDeactivation(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	instruments := postgres.NewInstrumentsRepository(pool)
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+
+	usdTicker := domain.CurrencyTickers[domain.CurrencyUSD]
+	sberID := instrumentID(t, ticker)
+	usdID := instrumentID(t, usdTicker)
+
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 10, 100); err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	// the list has neither currencies nor the instrument with open position
+	syncInstruments(t, []*domain.Instrument{
+		{InstrumentIdentifiers: domain.InstrumentIdentifiers{Ticker: "GAZP@MISX", Name: "Газпром"}},
+	})
+
+	for _, tc := range []struct {
+		ticker string
+		active bool
+	}{
+		{ticker: ticker, active: true},
+		{ticker: usdTicker, active: true},
+		{ticker: "GAZP@MISX", active: true},
+		{ticker: "LKOH@MISX", active: false},
+	} {
+		instrument, err := instruments.GetInstrumentByTicker(ctx, tc.ticker)
+		if err != nil {
+			t.Fatalf("GetInstrumentByTicker %s: %v", tc.ticker, err)
+		}
+
+		if instrument.Active != tc.active {
+			t.Errorf("%s active = %t, want %t", tc.ticker, instrument.Active, tc.active)
+		}
+	}
+
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, usdID, 1, 90); err != nil {
+		t.Errorf("BuyInstrument currency: %v", err)
+	}
+
+	lkohID := instrumentID(t, "LKOH@MISX")
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, lkohID, 1, 100); !errors.Is(err, boterrs.ErrInactiveInstrument) {
+		t.Errorf("BuyInstrument inactive error = %v, want %v", err, boterrs.ErrInactiveInstrument)
+	}
+}
+
+func TestCloseInactiveInstrumentPosition(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+	sberID := instrumentID(t, ticker)
+
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 10, 100); err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	query := `UPDATE success_bot.instruments SET active = FALSE WHERE id = $1`
+	if _, err := pool.Exec(ctx, query, sberID); err != nil {
+		t.Fatalf("failed to deactivate instrument: %v", err)
+	}
+
+	t.Cleanup(func() {
+		query := `UPDATE success_bot.instruments SET active = TRUE WHERE id = $1`
+		if _, err := pool.Exec(context.Background(), query, sberID); err != nil {
+			t.Errorf("failed to reactivate instrument: %v", err)
+		}
+	})
+
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 1, 100); !errors.Is(err, boterrs.ErrInactiveInstrument) {
+		t.Errorf("BuyInstrument error = %v, want %v", err, boterrs.ErrInactiveInstrument)
+	}
+
+	// selling more than the position opens a short
+	if _, err := portfolios.SellInstrument(ctx, user.ID, sberID, 11, 100); !errors.Is(err, boterrs.ErrInactiveInstrument) {
+		t.Errorf("SellInstrument over position error = %v, want %v", err, boterrs.ErrInactiveInstrument)
+	}
+
+	if _, err := portfolios.SellInstrument(ctx, user.ID, sberID, 10, 100); err != nil {
+		t.Errorf("SellInstrument closing: %v", err)
+	}
+}
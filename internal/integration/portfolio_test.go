@@ -0,0 +1,387 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/domain"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+)
+
+const ticker = "SBER@MISX"
+
+func TestPortfolioLong(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+	sberID := instrumentID(t, ticker)
+
+	// buy 10 by 100 with 0,3% fee
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 10, 100); err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	available, blocked := balances(t, user.ID)
+	assertMoney(t, "available after buy", available, initialBalance-1003)
+	assertMoney(t, "blocked after buy", blocked, 0)
+
+	count, avgPrice := position(t, user.ID, sberID)
+	if count != 10 || avgPrice != 100 {
+		t.Errorf("position after buy = %d by %v, want 10 by 100", count, avgPrice)
+	}
+
+	// buy 10 more by 120, average price is 110
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 10, 120); err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	count, avgPrice = position(t, user.ID, sberID)
+	if count != 20 || avgPrice != 110 {
+		t.Errorf("position after second buy = %d by %v, want 20 by 110", count, avgPrice)
+	}
+
+	// close part of long
+	if _, err := portfolios.SellInstrument(ctx, user.ID, sberID, 5, 130); err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	count, _ = position(t, user.ID, sberID)
+	if count != 15 {
+		t.Errorf("position after partial close = %d, want 15", count)
+	}
+
+	// close whole long
+	if _, err := portfolios.SellInstrument(ctx, user.ID, sberID, 15, 130); err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	if count, _ = position(t, user.ID, sberID); count != 0 {
+		t.Errorf("position after close = %d, want 0", count)
+	}
+
+	available, _ = balances(t, user.ID)
+	assertMoney(t, "available after close", available, initialBalance-1003-1203.6+2600-7.8)
+
+	assertJournal(t)
+}
+
+func TestPortfolioShort(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+	sberID := instrumentID(t, ticker)
+
+	// open short: 50% guarantee coverage is blocked and 0,3% fee is paid
+	if _, err := portfolios.SellInstrument(ctx, user.ID, sberID, 10, 100); err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	available, blocked := balances(t, user.ID)
+	assertMoney(t, "available after short", available, initialBalance-503)
+	assertMoney(t, "blocked after short", blocked, 500)
+
+	count, avgPrice := position(t, user.ID, sberID)
+	if count != -10 || avgPrice != 100 {
+		t.Errorf("position after short = %d by %v, want -10 by 100", count, avgPrice)
+	}
+
+	// close whole short with profit 10 per share and 0,3% fee
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 10, 90); err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	if count, _ = position(t, user.ID, sberID); count != 0 {
+		t.Errorf("position after close = %d, want 0", count)
+	}
+
+	available, _ = balances(t, user.ID)
+	assertMoney(t, "available after close", available, initialBalance-503+100-2.7)
+
+	assertJournal(t)
+}
+
+func TestPortfolioFlipLongToShort(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+	sberID := instrumentID(t, ticker)
+
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 5, 100); err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	// close long of 5 and open short of 3
+	if _, err := portfolios.SellInstrument(ctx, user.ID, sberID, 8, 100); err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	count, avgPrice := position(t, user.ID, sberID)
+	if count != -3 || avgPrice != 100 {
+		t.Errorf("position after flip = %d by %v, want -3 by 100", count, avgPrice)
+	}
+
+	available, blocked := balances(t, user.ID)
+	assertMoney(t, "available after flip", available, initialBalance-501.5+498.5-150.9)
+	assertMoney(t, "blocked after flip", blocked, 150)
+
+	assertJournal(t)
+}
+
+func TestPortfolioInsufficientFunds(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+	sberID := instrumentID(t, ticker)
+
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, sberID, 10000, 100); !errors.Is(err, boterrs.ErrInsufficientFunds) {
+		t.Fatalf("BuyInstrument error = %v, want %v", err, boterrs.ErrInsufficientFunds)
+	}
+
+	if _, err := portfolios.SellInstrument(ctx, user.ID, sberID, 10000, 100); !errors.Is(err, boterrs.ErrInsufficientFunds) {
+		t.Fatalf("SellInstrument error = %v, want %v", err, boterrs.ErrInsufficientFunds)
+	}
+
+	available, blocked := balances(t, user.ID)
+	assertMoney(t, "available", available, initialBalance)
+	assertMoney(t, "blocked", blocked, 0)
+
+	if count, _ := position(t, user.ID, sberID); count != 0 {
+		t.Errorf("position = %d, want 0", count)
+	}
+
+	assertJournal(t)
+}
+
+func TestPortfolioCurrencyExchange(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+	cnyID := instrumentID(t, domain.CurrencyTickers[domain.CurrencyCNY])
+
+	// buy 100 CNY by 12,5 with 0,3% fee in L$
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, cnyID, 100, 12.5); err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	assertMoney(t, "CNY after buy", currencyBalance(t, user.ID, domain.CurrencyCNY), 100)
+
+	if count, _ := position(t, user.ID, cnyID); count != 0 {
+		t.Errorf("position after exchange = %d, want 0", count)
+	}
+
+	if _, err := portfolios.SellInstrument(ctx, user.ID, cnyID, 150, 13); !errors.Is(err, boterrs.ErrInsufficientFunds) {
+		t.Fatalf("SellInstrument error = %v, want %v", err, boterrs.ErrInsufficientFunds)
+	}
+
+	if _, err := portfolios.SellInstrument(ctx, user.ID, cnyID, 40, 13); err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	assertMoney(t, "CNY after sell", currencyBalance(t, user.ID, domain.CurrencyCNY), 60)
+
+	available, _ := balances(t, user.ID)
+	assertMoney(t, "available after exchange", available, initialBalance-1253.75+520-1.56)
+
+	assertJournal(t)
+}
+
+func TestPortfolioForeignInstrument(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+	usdID := instrumentID(t, domain.CurrencyTickers[domain.CurrencyUSD])
+
+	query := `INSERT INTO success_bot.instruments(ticker, name, currency) VALUES ('USDETF@MISX', 'USD ETF', 'USD')
+		ON CONFLICT (ticker) DO NOTHING`
+	if _, err := pool.Exec(ctx, query); err != nil {
+		t.Fatalf("failed to create instrument: %v", err)
+	}
+	etfID := instrumentID(t, "USDETF@MISX")
+
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, etfID, 1, 40); !errors.Is(err, boterrs.ErrInsufficientFunds) {
+		t.Fatalf("BuyInstrument without USD error = %v, want %v", err, boterrs.ErrInsufficientFunds)
+	}
+
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, usdID, 100, 90); err != nil {
+		t.Fatalf("BuyInstrument USD: %v", err)
+	}
+
+	// buy 2 by 40 USD with 0,3% fee in USD
+	if _, err := portfolios.BuyInstrument(ctx, user.ID, etfID, 2, 40); err != nil {
+		t.Fatalf("BuyInstrument: %v", err)
+	}
+
+	assertMoney(t, "USD after buy", currencyBalance(t, user.ID, domain.CurrencyUSD), 100-80.24)
+
+	if _, err := portfolios.SellInstrument(ctx, user.ID, etfID, 3, 50); !errors.Is(err, boterrs.ErrForeignShort) {
+		t.Fatalf("SellInstrument short error = %v, want %v", err, boterrs.ErrForeignShort)
+	}
+
+	if _, err := portfolios.SellInstrument(ctx, user.ID, etfID, 2, 50); err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	assertMoney(t, "USD after sell", currencyBalance(t, user.ID, domain.CurrencyUSD), 100-80.24+99.7)
+
+	available, _ := balances(t, user.ID)
+	assertMoney(t, "available", available, initialBalance-9000-27)
+
+	assertJournal(t)
+}
+
+func TestRevaluationKeepsConcurrentChanges(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	users := postgres.NewUsersRepository(pool)
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+	sberID := instrumentID(t, ticker)
+
+	if _, err := portfolios.SellInstrument(ctx, user.ID, sberID, 10, 100); err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	// the reward is claimed after the cache updater has read balances, price of the short grew to 120
+	if _, err := users.ResetDailyReward(ctx, map[int64]time.Time{user.ID: time.Now()}); err != nil {
+		t.Fatalf("ResetDailyReward: %v", err)
+	}
+
+	if _, err := users.ClaimDailyReward(ctx, user.ID, domain.NewDailyRewardTiers(1000, nil)); err != nil {
+		t.Fatalf("ClaimDailyReward: %v", err)
+	}
+
+	updated, err := users.UpdateUserBalancesAndMarginCall(ctx, user.ID, -100)
+	if err != nil {
+		t.Fatalf("UpdateUserBalancesAndMarginCall: %v", err)
+	}
+
+	available, blocked := balances(t, user.ID)
+	assertMoney(t, "available", available, initialBalance-503+1000-100)
+	assertMoney(t, "blocked", blocked, 600)
+	assertMoney(t, "returned available", updated.AvailableBalance, available)
+
+	if updated.MarginCall {
+		t.Error("margin call is set with positive available balance")
+	}
+
+	assertJournal(t)
+}
+
+func TestStopOutMarksClearedMarginCall(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	users := postgres.NewUsersRepository(pool)
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+	sberID := instrumentID(t, ticker)
+
+	if _, err := portfolios.SellInstrument(ctx, user.ID, sberID, 10, 100); err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	for _, stopOut := range []bool{true, false} {
+		// the whole available balance is blocked by the grown short
+		if _, err := users.UpdateUserBalancesAndMarginCall(ctx, user.ID, -initialBalance); err != nil {
+			t.Fatalf("UpdateUserBalancesAndMarginCall: %v", err)
+		}
+
+		if stopOut {
+			stopOutCtx := domain.ContextWithActor(ctx, domain.ActorStopOut)
+			if _, err := portfolios.BuyInstrument(stopOutCtx, user.ID, sberID, 5, 100); err != nil {
+				t.Fatalf("BuyInstrument: %v", err)
+			}
+		}
+
+		if _, err := users.UpdateUserBalancesAndMarginCall(ctx, user.ID, initialBalance); err != nil {
+			t.Fatalf("UpdateUserBalancesAndMarginCall: %v", err)
+		}
+
+		var event domain.MarginCallCleared
+		query := `SELECT payload FROM success_bot.outbox_events WHERE type = $1 ORDER BY id DESC LIMIT 1`
+		if err := pool.QueryRow(ctx, query, domain.EventTypeMarginCallCleared).Scan(&event); err != nil {
+			t.Fatalf("failed to get stored event: %v", err)
+		}
+
+		if event.UserID != user.ID || event.StoppedOut != stopOut {
+			t.Errorf("stored event = %+v, want user %d stopped out %v", event, user.ID, stopOut)
+		}
+	}
+
+	assertJournal(t)
+}
+
+func TestDeferredMarginCallWarning(t *testing.T) {
+	resetDB(t)
+
+	ctx := context.Background()
+	users := postgres.NewUsersRepository(pool)
+	portfolios := postgres.NewPortfolioRepository(pool)
+	user := createUser(t, 1)
+
+	take := func(want int) {
+		t.Helper()
+
+		taken, err := users.TakeDeferredMarginCallWarnings(ctx, []int64{user.ID})
+		if err != nil {
+			t.Fatalf("TakeDeferredMarginCallWarnings: %v", err)
+		}
+
+		if len(taken) != want {
+			t.Errorf("taken warnings = %v, want %d", taken, want)
+		}
+	}
+
+	// warning without margin call isn't deferred
+	if err := users.DeferMarginCallWarning(ctx, user.ID); err != nil {
+		t.Fatalf("DeferMarginCallWarning: %v", err)
+	}
+
+	take(0)
+
+	if _, err := portfolios.SellInstrument(ctx, user.ID, instrumentID(t, ticker), 10, 100); err != nil {
+		t.Fatalf("SellInstrument: %v", err)
+	}
+
+	if _, err := users.UpdateUserBalancesAndMarginCall(ctx, user.ID, -initialBalance); err != nil {
+		t.Fatalf("UpdateUserBalancesAndMarginCall: %v", err)
+	}
+
+	// deferred warning is taken once
+	if err := users.DeferMarginCallWarning(ctx, user.ID); err != nil {
+		t.Fatalf("DeferMarginCallWarning: %v", err)
+	}
+
+	take(1)
+	take(0)
+
+	// warning of the cleared margin call is dropped
+	if err := users.DeferMarginCallWarning(ctx, user.ID); err != nil {
+		t.Fatalf("DeferMarginCallWarning: %v", err)
+	}
+
+	if _, err := users.UpdateUserBalancesAndMarginCall(ctx, user.ID, initialBalance); err != nil {
+		t.Fatalf("UpdateUserBalancesAndMarginCall: %v", err)
+	}
+
+	take(0)
+	assertJournal(t)
+}
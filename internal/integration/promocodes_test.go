@@ -0,0 +1,126 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/leonid6372/success-bot/internal/boterrs"
+	"github.com/leonid6372/success-bot/internal/common/repositories/postgres"
+)
+
+func createPromocode(t *testing.T, value string, availableCount int64, bonusAmount float64) {
+	t.Helper()
+
+	query := `INSERT INTO success_bot.promocodes(available_count, value, bonus_amount) VALUES ($1, $2, $3)`
+	if _, err := pool.Exec(context.Background(), query, availableCount, value, bonusAmount); err != nil {
+		t.Fatalf("failed to create promocode: %v", err)
+	}
+}
+
+func TestApplyPromocodeConcurrentUsers(t *testing.T) {
+	resetDB(t)
+
+	const usersCount, availableCount = 20, 5
+
+	promocodes := postgres.NewPromocodesRepository(pool)
+	createPromocode(t, "LIMITED", availableCount, 1000)
+
+	for i := int64(1); i <= usersCount; i++ {
+		createUser(t, i)
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, usersCount)
+
+	for i := int64(1); i <= usersCount; i++ {
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+
+			_, err := promocodes.ApplyPromocode(context.Background(), "LIMITED", userID)
+			errCh <- err
+		}(i)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	var applied, invalid int
+	for err := range errCh {
+		switch {
+		case err == nil:
+			applied++
+		case errors.Is(err, boterrs.ErrInvalidPromocode):
+			invalid++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	if applied != availableCount || invalid != usersCount-availableCount {
+		t.Errorf("applied = %d, invalid = %d, want %d and %d", applied, invalid, availableCount, usersCount-availableCount)
+	}
+
+	var left int64
+	query := `SELECT available_count FROM success_bot.promocodes WHERE value = 'LIMITED'`
+	if err := pool.QueryRow(context.Background(), query).Scan(&left); err != nil {
+		t.Fatalf("failed to get promocode: %v", err)
+	}
+
+	if left != 0 {
+		t.Errorf("available count = %d, want 0", left)
+	}
+
+	assertJournal(t)
+}
+
+func TestApplyPromocodeConcurrentSameUser(t *testing.T) {
+	resetDB(t)
+
+	const attempts = 10
+
+	promocodes := postgres.NewPromocodesRepository(pool)
+	createPromocode(t, "ONCE", 100, 1000)
+	user := createUser(t, 1)
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, attempts)
+
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := promocodes.ApplyPromocode(context.Background(), "ONCE", user.ID)
+			errCh <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	var applied, used int
+	for err := range errCh {
+		switch {
+		case err == nil:
+			applied++
+		case errors.Is(err, boterrs.ErrUsedPromocode):
+			used++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	if applied != 1 || used != attempts-1 {
+		t.Errorf("applied = %d, used = %d, want 1 and %d", applied, used, attempts-1)
+	}
+
+	available, _ := balances(t, user.ID)
+	assertMoney(t, "available", available, initialBalance+1000)
+
+	assertJournal(t)
+}
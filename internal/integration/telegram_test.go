@@ -0,0 +1,160 @@
+//go:build integration
+
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	fakeBotToken  = "1:fake"
+	waitTimeout   = 5 * time.Second
+	waitFrequency = 20 * time.Millisecond
+)
+
+type sentMessage struct {
+	ChatID int64
+	Text   string
+}
+
+// inlineAnswer is an answer to inline query, Body is JSON of the request parameters.
+type inlineAnswer struct {
+	QueryID string
+	Body    string
+}
+
+// fakeTelegram is a fake Telegram Bot API server which records sent messages.
+type fakeTelegram struct {
+	server *httptest.Server
+
+	mu            sync.Mutex
+	messages      []sentMessage
+	answers       []inlineAnswer
+	nextMessageID int
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	ft := &fakeTelegram{}
+	ft.server = httptest.NewServer(http.HandlerFunc(ft.handle))
+	t.Cleanup(ft.server.Close)
+
+	return ft
+}
+
+func (ft *fakeTelegram) URL() string {
+	return ft.server.URL
+}
+
+func (ft *fakeTelegram) handle(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	params := map[string]any{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		_ = json.NewDecoder(r.Body).Decode(&params)
+	}
+
+	var result any = true
+
+	switch method {
+	case "getMe":
+		result = map[string]any{"id": 1, "is_bot": true, "first_name": "Fake", "username": "fake_bot"}
+
+	case "getWebhookInfo":
+		result = map[string]any{"url": ""}
+
+	case "sendMessage", "editMessageText":
+		chatID, _ := strconv.ParseInt(toString(params["chat_id"]), 10, 64)
+		text := toString(params["text"])
+
+		ft.mu.Lock()
+		ft.nextMessageID++
+		messageID := ft.nextMessageID
+		ft.messages = append(ft.messages, sentMessage{ChatID: chatID, Text: text})
+		ft.mu.Unlock()
+
+		result = map[string]any{
+			"message_id": messageID,
+			"date":       time.Now().Unix(),
+			"chat":       map[string]any{"id": chatID, "type": "private"},
+			"text":       text,
+		}
+
+	case "answerInlineQuery":
+		body, _ := json.Marshal(params)
+
+		ft.mu.Lock()
+		ft.answers = append(ft.answers, inlineAnswer{QueryID: toString(params["inline_query_id"]), Body: string(body)})
+		ft.mu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+// waitMessage waits for the next message to the chat which is not taken yet and contains substring.
+func (ft *fakeTelegram) waitMessage(t *testing.T, chatID int64, substring string) sentMessage {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+	for time.Now().Before(deadline) {
+		ft.mu.Lock()
+		for i, message := range ft.messages {
+			if message.ChatID == chatID && strings.Contains(message.Text, substring) {
+				ft.messages = append(ft.messages[:i], ft.messages[i+1:]...)
+				ft.mu.Unlock()
+
+				return message
+			}
+		}
+		ft.mu.Unlock()
+
+		time.Sleep(waitFrequency)
+	}
+
+	t.Fatalf("message to chat %d containing %q wasn't sent", chatID, substring)
+
+	return sentMessage{}
+}
+
+// waitAnswer waits for the answer to the inline query which contains substring.
+func (ft *fakeTelegram) waitAnswer(t *testing.T, queryID, substring string) inlineAnswer {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+	for time.Now().Before(deadline) {
+		ft.mu.Lock()
+		for i, answer := range ft.answers {
+			if answer.QueryID == queryID && strings.Contains(answer.Body, substring) {
+				ft.answers = append(ft.answers[:i], ft.answers[i+1:]...)
+				ft.mu.Unlock()
+
+				return answer
+			}
+		}
+		ft.mu.Unlock()
+
+		time.Sleep(waitFrequency)
+	}
+
+	t.Fatalf("answer to inline query %s containing %q wasn't sent", queryID, substring)
+
+	return inlineAnswer{}
+}
+
+func toString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatInt(int64(v), 10)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists success_bot.api_tokens
+(
+    user_id                 bigint          primary key,
+    token_hash              varchar(64)     not null unique,
+
+    created_at              timestamptz     default now()   not null
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists success_bot.api_tokens;
+
+-- +goose StatementEnd
@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists success_bot.balance_events
+(
+    id                      bigserial       primary key,
+
+    user_id                 bigint                          not null,
+    reason                  varchar(32)                     not null, -- e.g., 'initial', 'buy', 'sell', 'daily_reward', 'promocode', 'revaluation'
+    actor                   varchar(32)                     not null, -- e.g., 'user', 'api', 'cache_updater', 'stop_out'
+
+    available_before        numeric(15, 2)                  not null,
+    blocked_before          numeric(15, 2)                  not null,
+    available_after         numeric(15, 2)                  not null,
+    blocked_after           numeric(15, 2)                  not null,
+
+    created_at              timestamptz     default now()   not null
+);
+
+create index if not exists balance_events_user_id_idx on success_bot.balance_events(user_id, id);
+
+create or replace function success_bot.forbid_balance_events_change()
+    returns trigger as $$
+begin
+    raise exception 'balance_events is append-only';
+end;
+$$ language plpgsql;
+
+create trigger forbid_balance_events_change
+    before update or delete on success_bot.balance_events
+    for each row
+    execute function success_bot.forbid_balance_events_change();
+
+-- journal starts from current balances of existing users
+insert into success_bot.balance_events(
+    user_id, reason, actor, available_before, blocked_before, available_after, blocked_after
+)
+select id, 'snapshot', 'migration', 0, 0, available_balance, blocked_balance
+from success_bot.users;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists success_bot.balance_events;
+drop function if exists success_bot.forbid_balance_events_change();
+
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists success_bot.candles
+(
+    instrument_id           bigint                          not null,
+    timeframe               varchar(8)                      not null, -- e.g., '1m', '1h', '1d'
+    time                    timestamptz                     not null, -- candle start time
+
+    open                    numeric(15, 6)                  not null,
+    high                    numeric(15, 6)                  not null,
+    low                     numeric(15, 6)                  not null,
+    close                   numeric(15, 6)                  not null,
+    volume                  numeric(20, 2)  default 0       not null,
+
+    primary key (instrument_id, timeframe, time)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists success_bot.candles;
+
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table success_bot.instruments
+    add column if not exists sector         varchar(64)     default ''      not null,
+    add column if not exists lot_size       int             default 1       not null,
+    add column if not exists currency       varchar(8)      default 'RUB'   not null,
+    add column if not exists decimals       int             default 2       not null,
+    add column if not exists active         boolean         default true    not null, -- false for delisted instruments
+    add column if not exists listed         boolean         default true    not null, -- shown in instruments list
+    add column if not exists synced_at      timestamptz;
+
+-- instruments found by users' search were marked with ❔, now they are shown only after admin's curation
+update success_bot.instruments
+    set listed = false, name = trim(leading '❔ ' from name)
+    where name like '❔%';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table success_bot.instruments
+    drop column if exists sector,
+    drop column if exists lot_size,
+    drop column if exists currency,
+    drop column if exists decimals,
+    drop column if exists active,
+    drop column if exists listed,
+    drop column if exists synced_at;
+
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- zero min step allows any price, it is filled by instruments sync
+alter table success_bot.instruments
+    add column if not exists min_step       numeric(15, 6)  default 0       not null;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table success_bot.instruments
+    drop column if exists min_step;
+
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table success_bot.instruments
+    add column if not exists kind           varchar(16)     default 'share' not null, -- share, bond, etf or currency
+    add column if not exists nominal        numeric(15, 6)  default 0       not null; -- bond nominal
+
+create index if not exists instruments_kind_idx on success_bot.instruments (kind);
+
+create table if not exists success_bot.bond_coupons
+(
+    instrument_id           bigint                          not null,
+    date                    date                            not null,
+    amount                  numeric(15, 6)                  not null, -- payment per bond
+
+    primary key (instrument_id, date)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists success_bot.bond_coupons;
+
+drop index if exists success_bot.instruments_kind_idx;
+
+alter table success_bot.instruments
+    drop column if exists kind,
+    drop column if exists nominal;
+
+-- +goose StatementEnd
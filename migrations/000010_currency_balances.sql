@@ -0,0 +1,141 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists success_bot.users_currency_balances
+(
+    user_id                 bigint                          not null,
+    currency                varchar(8)                      not null, -- e.g., 'USD', 'CNY', L$ balance is kept in users
+    amount                  numeric(15, 2)  default 0       not null,
+
+    created_at              timestamptz     default now()   not null,
+    updated_at              timestamptz     default now()   not null,
+
+    primary key (user_id, currency)
+);
+
+create trigger update_users_currency_balances_updated_at
+    before update on success_bot.users_currency_balances
+    for each row
+    execute function success_bot.update_updated_at();
+
+-- currency instruments used for conversion of L$ balance
+insert into success_bot.instruments(ticker, name, sector, lot_size, currency, kind, listed) values
+    ('USD000UTSTOM@MISX', '💵 Доллар США', 'Валюта', 1, 'RUB', 'currency', true),
+    ('CNYRUB_TOM@MISX', '💴 Юань', 'Валюта', 1, 'RUB', 'currency', true)
+on conflict (ticker) do update
+    set kind = excluded.kind, active = true;
+
+-- positions of currency instruments are moved out of users_instruments, they are kept to restore them on down
+create table if not exists success_bot.currency_positions_migrated
+(
+    user_id                 bigint                          not null,
+    instrument_id           bigint                          not null,
+    count                   int                             not null,
+    average_price           numeric(15, 6)                  not null,
+    settle_price            numeric(15, 6)  default 0       not null, -- last known price of a settled short
+    operation_id            bigint, -- id of buy operation of a settled short
+
+    primary key (user_id, instrument_id)
+);
+
+insert into success_bot.currency_positions_migrated(user_id, instrument_id, count, average_price, settle_price)
+select ui.user_id, ui.instrument_id, ui.count, ui.average_price,
+    case when ui.count < 0 then coalesce((
+        select c.close
+        from success_bot.candles c
+        where c.instrument_id = ui.instrument_id
+        order by c.time desc
+        limit 1
+    ), ui.average_price) else 0 end
+from success_bot.users_instruments ui
+join success_bot.instruments i on ui.instrument_id = i.id
+where i.ticker in ('USD000UTSTOM@MISX', 'CNYRUB_TOM@MISX');
+
+-- longs of currency instruments become cash balances, buying and selling of them is an exchange now
+insert into success_bot.users_currency_balances(user_id, currency, amount)
+select m.user_id, case when i.ticker = 'USD000UTSTOM@MISX' then 'USD' else 'CNY' end, m.count
+from success_bot.currency_positions_migrated m
+join success_bot.instruments i on m.instrument_id = i.id
+where m.count > 0;
+
+-- shorts can't be closed by exchange, so they are bought back by the last known price with 0,3% fee,
+-- their guarantee coverage is released by the next revaluation
+with buys as (
+    insert into success_bot.operations(user_id, instrument_id, type, count, price, total_amount)
+    select user_id, instrument_id, 'buy', -count, settle_price, -count * settle_price
+    from success_bot.currency_positions_migrated
+    where count < 0
+    returning id, user_id, instrument_id, count, price
+), fees as (
+    insert into success_bot.operations(parent_id, user_id, instrument_id, type, count, price, total_amount)
+    select id, user_id, instrument_id, 'fee', 1, count * price * 0.003, count * price * 0.003
+    from buys
+)
+update success_bot.currency_positions_migrated m
+set operation_id = b.id
+from buys b
+where m.user_id = b.user_id and m.instrument_id = b.instrument_id;
+
+with settled as (
+    select user_id, sum(round(count * (settle_price - average_price) + count * settle_price * 0.003, 2)) as amount
+    from success_bot.currency_positions_migrated
+    where count < 0
+    group by user_id
+), changed as (
+    update success_bot.users u
+    set available_balance = u.available_balance + s.amount
+    from settled s
+    where u.id = s.user_id
+    returning u.id, u.available_balance - s.amount as available_before, u.available_balance as available_after,
+        u.blocked_balance
+)
+insert into success_bot.balance_events(
+    user_id, reason, actor, available_before, blocked_before, available_after, blocked_after
+)
+select id, 'buy', 'migration', available_before, blocked_balance, available_after, blocked_balance
+from changed
+where available_before <> available_after;
+
+delete from success_bot.users_instruments ui
+using success_bot.currency_positions_migrated m
+where ui.user_id = m.user_id and ui.instrument_id = m.instrument_id;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- migrated positions are restored, results of settled shorts are returned
+with settled as (
+    select user_id, sum(round(count * (settle_price - average_price) + count * settle_price * 0.003, 2)) as amount
+    from success_bot.currency_positions_migrated
+    where count < 0
+    group by user_id
+), changed as (
+    update success_bot.users u
+    set available_balance = u.available_balance - s.amount
+    from settled s
+    where u.id = s.user_id
+    returning u.id, u.available_balance + s.amount as available_before, u.available_balance as available_after,
+        u.blocked_balance
+)
+insert into success_bot.balance_events(
+    user_id, reason, actor, available_before, blocked_before, available_after, blocked_after
+)
+select id, 'sell', 'migration', available_before, blocked_balance, available_after, blocked_balance
+from changed
+where available_before <> available_after;
+
+delete from success_bot.operations o
+using success_bot.currency_positions_migrated m
+where o.id = m.operation_id or o.parent_id = m.operation_id;
+
+insert into success_bot.users_instruments(user_id, instrument_id, count, average_price)
+select user_id, instrument_id, count, average_price
+from success_bot.currency_positions_migrated
+on conflict (user_id, instrument_id) do nothing;
+
+drop table if exists success_bot.currency_positions_migrated;
+drop table if exists success_bot.users_currency_balances;
+
+-- +goose StatementEnd
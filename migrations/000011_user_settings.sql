@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table success_bot.users
+    add column if not exists time_zone           varchar(64)     default ''      not null, -- IANA name, '' uses language time zone
+    add column if not exists muted_notifications varchar(16)[]   default '{}'    not null, -- daily_reward, margin_call, alerts, broadcasts
+    add column if not exists quiet_hours_from    smallint        default 0       not null,
+    add column if not exists quiet_hours_to      smallint        default 0       not null; -- equal to from when disabled
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table success_bot.users
+    drop column if exists time_zone,
+    drop column if exists muted_notifications,
+    drop column if exists quiet_hours_from,
+    drop column if exists quiet_hours_to;
+
+-- +goose StatementEnd
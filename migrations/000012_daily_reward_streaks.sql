@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- count of daily_reward operations is the reward tier, previous flat rewards have tier 1 already
+alter table success_bot.users
+    add column if not exists daily_reward_streak int             default 0       not null; -- consecutive days of claims
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table success_bot.users drop column if exists daily_reward_streak;
+
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists success_bot.users_achievements
+(
+    id                      bigserial       primary key, -- instrument_id of achievement operations
+    user_id                 bigint                          not null,
+    achievement             varchar(32)                     not null, -- e.g., 'first_trade', 'top_10'
+    progress                int             default 0       not null,
+    unlocked_at             timestamptz, -- null while the achievement is locked
+
+    created_at              timestamptz     default now()   not null,
+    updated_at              timestamptz     default now()   not null,
+
+    unique (user_id, achievement)
+);
+
+create trigger update_users_achievements_updated_at
+    before update on success_bot.users_achievements
+    for each row
+    execute function success_bot.update_updated_at();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists success_bot.users_achievements;
+
+-- +goose StatementEnd
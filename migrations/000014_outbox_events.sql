@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- events published to the event bus, they are deleted after delivery to subscribers
+create table if not exists success_bot.outbox_events
+(
+    id                      bigserial       primary key,
+    type                    varchar(32)                     not null, -- e.g., 'trade_executed', 'reward_claimed'
+    payload                 jsonb                           not null, -- event fields
+
+    created_at              timestamptz     default now()   not null
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists success_bot.outbox_events;
+
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table success_bot.users
+    add column if not exists public_profile      boolean         default false   not null; -- opt-in, others can view and follow the user
+
+create table if not exists success_bot.users_follows
+(
+    follower_id             bigint                          not null,
+    trader_id               bigint                          not null, -- followed user with public profile
+
+    created_at              timestamptz     default now()   not null,
+
+    primary key (follower_id, trader_id)
+);
+
+create index if not exists users_follows_trader_id_idx on success_bot.users_follows (trader_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists success_bot.users_follows;
+
+alter table success_bot.users drop column if exists public_profile;
+
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table success_bot.users_follows
+    add column if not exists copy_percent        smallint        default 0       not null; -- percent of follower's total balance mirroring trader's trades, 0 without copying
+
+alter table success_bot.operations
+    add column if not exists copied_operation_id bigint; -- trader's operation copied by the order, null for own orders
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table success_bot.operations drop column if exists copied_operation_id;
+
+alter table success_bot.users_follows drop column if exists copy_percent;
+
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists success_bot.groups
+(
+    id                      bigint                          not null, -- telegram chat id of the group
+    title                   text                            not null,
+    language_code           text                            not null, -- language of the group messages, taken from the user who added the bot
+
+    created_at              timestamptz     default now()   not null,
+
+    primary key (id)
+);
+
+create table if not exists success_bot.groups_members
+(
+    group_id                bigint                          not null,
+    user_id                 bigint                          not null, -- member of the group league
+
+    created_at              timestamptz     default now()   not null,
+
+    primary key (group_id, user_id)
+);
+
+create index if not exists groups_members_user_id_idx on success_bot.groups_members (user_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists success_bot.groups_members;
+
+drop table if exists success_bot.groups;
+
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- events are claimed by an instance for delivery and deleted one by one after handling,
+-- events of an instance which crashed are claimed again after claimed_until
+alter table success_bot.outbox_events
+    add column if not exists claimed_until  timestamptz; -- null for events which aren't claimed
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table success_bot.outbox_events
+    drop column if exists claimed_until;
+
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- copies were executed by separate closing and opening orders, only the first order keeps the link
+update success_bot.operations o
+set copied_operation_id = null
+where o.copied_operation_id is not null
+    and exists (
+        select 1
+        from success_bot.operations d
+        where d.user_id = o.user_id
+            and d.copied_operation_id = o.copied_operation_id
+            and d.id < o.id
+    );
+
+-- trader's operation is copied once per follower even if the trade event is delivered again
+create unique index if not exists operations_copied_operation_idx
+    on success_bot.operations (user_id, copied_operation_id)
+    where copied_operation_id is not null;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop index if exists success_bot.operations_copied_operation_idx;
+
+-- +goose StatementEnd
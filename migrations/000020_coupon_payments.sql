@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- paid coupons are kept apart from bond_coupons, because coupon schedules are replaced by instruments sync
+create table if not exists success_bot.bond_coupon_payments
+(
+    instrument_id           bigint                          not null,
+    date                    date                            not null,
+
+    created_at              timestamptz     default now()   not null,
+
+    primary key (instrument_id, date)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop table if exists success_bot.bond_coupon_payments;
+
+-- +goose StatementEnd
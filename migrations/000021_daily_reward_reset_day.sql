@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- daily reward is reset once per user's local day, so changes of time zone or quiet hours don't reset it again
+alter table success_bot.users
+    add column if not exists daily_reward_reset_on date; -- local day of the last reset
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table success_bot.users drop column if exists daily_reward_reset_on;
+
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- events failed by a subscriber are delivered again after a backoff only to subscribers which haven't handled them
+alter table success_bot.outbox_events
+    add column if not exists attempts     int    not null default 0,   -- failed deliveries
+    add column if not exists delivered_to text[] not null default '{}'; -- subscribers which handled the event
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table success_bot.outbox_events
+    drop column if exists attempts,
+    drop column if exists delivered_to;
+
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- stop-out during a margin call is stored with the user, so clearing of the margin call isn't survived by it
+-- even after cache loss or restart
+alter table success_bot.users
+    add column if not exists stopped_out boolean not null default false; -- stopped out during current margin call
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table success_bot.users drop column if exists stopped_out;
+
+-- +goose StatementEnd
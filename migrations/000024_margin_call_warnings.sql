@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- margin call warnings of quiet hours are sent at their end if the margin call isn't cleared before
+alter table success_bot.users
+    add column if not exists margin_call_warning_deferred boolean not null default false;
+
+-- alerts category had no notifications, so muting it is meaningless
+update success_bot.users set muted_notifications = array_remove(muted_notifications, 'alerts');
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+alter table success_bot.users drop column if exists margin_call_warning_deferred;
+
+-- +goose StatementEnd
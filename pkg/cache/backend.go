@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var ErrInvalidDestination = errors.New("destination must be a non-nil pointer")
+
+// Backend is a key-value storage which can be shared between bot instances.
+// Zero ttl means that the value never expires.
+type Backend interface {
+	// Get stores value by key into dst. Returns false if key is not found or expired.
+	Get(ctx context.Context, key string, dst any) (bool, error)
+	Set(ctx context.Context, key string, value any, ttl time.Duration) error
+	// Update replaces value only if key exists and keeps its expiration. Returns false if key is not found.
+	Update(ctx context.Context, key string, value any) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type memoryBackend struct {
+	cache *Cache[string]
+}
+
+// NewMemoryBackend returns in-process Backend. Values are stored as is, so pointers
+// gotten by Get refer to the same objects. It must not be used with several bot instances.
+func NewMemoryBackend(cleanupInterval time.Duration) Backend {
+	return &memoryBackend{
+		cache: New[string](NoExpiration, cleanupInterval),
+	}
+}
+
+func (mb *memoryBackend) Get(_ context.Context, key string, dst any) (bool, error) {
+	value, ok := mb.cache.Get(key)
+	observeGet("memory", key, ok)
+	if !ok {
+		return false, nil
+	}
+
+	dstValue := reflect.ValueOf(dst)
+	if dstValue.Kind() != reflect.Pointer || dstValue.IsNil() {
+		return false, ErrInvalidDestination
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(dstValue.Elem().Type()) {
+		return false, fmt.Errorf("value of type %s is not assignable to %s", v.Type(), dstValue.Elem().Type())
+	}
+
+	dstValue.Elem().Set(v)
+
+	return true, nil
+}
+
+func (mb *memoryBackend) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = NoExpiration
+	}
+
+	mb.cache.Set(key, value, ttl)
+
+	return nil
+}
+
+func (mb *memoryBackend) Update(_ context.Context, key string, value any) (bool, error) {
+	_, expiration, ok := mb.cache.GetWithExpiration(key)
+	if !ok {
+		return false, nil
+	}
+
+	ttl := NoExpiration
+	if !expiration.IsZero() {
+		ttl = time.Until(expiration)
+	}
+
+	if err := mb.cache.Replace(key, value, ttl); err != nil {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (mb *memoryBackend) Delete(_ context.Context, key string) error {
+	mb.cache.Delete(key)
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package cache
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var requests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_requests_total",
+	Help: "Count of cache reads by key group (key part before the first colon) and result.",
+}, []string{"backend", "group", "result"})
+
+func observeGet(backend, key string, ok bool) {
+	group, _, _ := strings.Cut(key, ":")
+
+	result := "miss"
+	if ok {
+		result = "hit"
+	}
+
+	requests.WithLabelValues(backend, group, result).Inc()
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/redis/go-redis/v9"
+)
+
+type redisBackend struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisBackend returns Backend over Redis-compatible storage. Values are stored as JSON,
+// so only exported fields survive and every Get returns a new copy.
+func NewRedisBackend(client *redis.Client, prefix string) Backend {
+	return &redisBackend{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (rb *redisBackend) Get(ctx context.Context, key string, dst any) (bool, error) {
+	data, err := rb.client.Get(ctx, rb.prefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			observeGet("redis", key, false)
+			return false, nil
+		}
+
+		return false, errs.NewStack(err)
+	}
+
+	observeGet("redis", key, true)
+
+	if err := json.Unmarshal(data, dst); err != nil {
+		return false, errs.NewStack(err)
+	}
+
+	return true, nil
+}
+
+func (rb *redisBackend) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	if err := rb.client.Set(ctx, rb.prefix+key, data, ttl).Err(); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
+
+func (rb *redisBackend) Update(ctx context.Context, key string, value any) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, errs.NewStack(err)
+	}
+
+	err = rb.client.SetArgs(ctx, rb.prefix+key, data, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+
+		return false, errs.NewStack(err)
+	}
+
+	return true, nil
+}
+
+func (rb *redisBackend) Delete(ctx context.Context, key string) error {
+	if err := rb.client.Del(ctx, rb.prefix+key).Err(); err != nil {
+		return errs.NewStack(err)
+	}
+
+	return nil
+}
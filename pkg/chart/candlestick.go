@@ -0,0 +1,169 @@
+// Package chart renders price charts to PNG.
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	width  = 960
+	height = 540
+
+	marginTop    = 32
+	marginBottom = 28
+	marginLeft   = 12
+	marginRight  = 84
+
+	gridLines = 6
+	timeMarks = 6
+)
+
+var (
+	colorBackground = color.RGBA{0x13, 0x17, 0x22, 0xff}
+	colorGrid       = color.RGBA{0x2a, 0x2e, 0x39, 0xff}
+	colorText       = color.RGBA{0xb2, 0xb5, 0xbe, 0xff}
+	colorUp         = color.RGBA{0x26, 0xa6, 0x9a, 0xff}
+	colorDown       = color.RGBA{0xef, 0x53, 0x50, 0xff}
+)
+
+var ErrNoCandles = errors.New("no candles to render")
+
+type Candle struct {
+	Time   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Candlestick renders candles sorted by time to PNG with price axis on the right and time axis at the bottom.
+// Time labels are formatted by timeLayout in the time zone of loc.
+func Candlestick(title string, candles []Candle, timeLayout string, loc *time.Location) ([]byte, error) {
+	if len(candles) == 0 {
+		return nil, ErrNoCandles
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(colorBackground), image.Point{}, draw.Src)
+
+	plot := image.Rect(marginLeft, marginTop, width-marginRight, height-marginBottom)
+
+	low, high := candles[0].Low, candles[0].High
+	for _, candle := range candles {
+		low = math.Min(low, candle.Low)
+		high = math.Max(high, candle.High)
+	}
+
+	// keep candles off the plot borders
+	padding := (high - low) * 0.05
+	if padding == 0 {
+		padding = math.Max(high*0.01, 0.01)
+	}
+	low -= padding
+	high += padding
+
+	y := func(price float64) int {
+		return plot.Max.Y - int(math.Round((price-low)/(high-low)*float64(plot.Dy())))
+	}
+
+	decimals := priceDecimals(high - low)
+
+	// price grid
+	for i := 0; i <= gridLines; i++ {
+		price := low + (high-low)*float64(i)/gridLines
+		py := y(price)
+
+		fillRect(img, image.Rect(plot.Min.X, py, plot.Max.X, py+1), colorGrid)
+		drawText(img, plot.Max.X+6, py+4, strconv.FormatFloat(price, 'f', decimals, 64), colorText)
+	}
+
+	slot := float64(plot.Dx()) / float64(len(candles))
+	bodyWidth := max(1, int(slot*0.7))
+
+	x := func(i int) int {
+		return plot.Min.X + int(slot*float64(i)+slot/2)
+	}
+
+	// time grid
+	step := max(1, len(candles)/timeMarks)
+	for i := step / 2; i < len(candles); i += step {
+		px := x(i)
+		label := candles[i].Time.In(loc).Format(timeLayout)
+
+		fillRect(img, image.Rect(px, plot.Min.Y, px+1, plot.Max.Y), colorGrid)
+		drawText(img, px-len(label)*7/2, plot.Max.Y+18, label, colorText)
+	}
+
+	// candles
+	for i, candle := range candles {
+		c := colorUp
+		if candle.Close < candle.Open {
+			c = colorDown
+		}
+
+		px := x(i)
+		fillRect(img, image.Rect(px, y(candle.High), px+1, y(candle.Low)+1), c)
+
+		top, bottom := y(math.Max(candle.Open, candle.Close)), y(math.Min(candle.Open, candle.Close))
+		fillRect(img, image.Rect(px-bodyWidth/2, top, px-bodyWidth/2+bodyWidth, bottom+1), c)
+	}
+
+	// last price marker
+	last := candles[len(candles)-1]
+	c := colorUp
+	if last.Close < last.Open {
+		c = colorDown
+	}
+
+	py := y(last.Close)
+	fillRect(img, image.Rect(plot.Max.X, py-8, width, py+8), c)
+	drawText(img, plot.Max.X+6, py+4, strconv.FormatFloat(last.Close, 'f', decimals, 64), colorBackground)
+
+	drawText(img, marginLeft, 20, title, colorText)
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func priceDecimals(priceRange float64) int {
+	switch {
+	case priceRange >= 100:
+		return 0
+	case priceRange >= 1:
+		return 2
+	default:
+		return 4
+	}
+}
+
+func fillRect(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func drawText(img draw.Image, x, y int, text string, c color.Color) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+
+	d.DrawString(text)
+}
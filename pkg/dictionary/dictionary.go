@@ -3,84 +3,288 @@ package dictionary
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/leonid6372/success-bot/pkg/format"
 	"github.com/leonid6372/success-bot/pkg/log"
 	"go.uber.org/zap"
 )
 
-const DefaultLanguage = "ru"
+const (
+	DefaultLanguage = "ru"
+	DefaultPath     = "dictionary.json"
+
+	// localeKey is a key of language formatting settings (format.Locale) in dictionary file.
+	localeKey = "locale"
+)
 
 type Dictionary struct {
-	dictionary map[string]map[string]string // map[language_code]map[key]value
+	path string
+
+	mu        sync.RWMutex
+	templates map[string]map[string]*template.Template // map[language_code]map[key]template
+	locales   map[string]format.Locale                 // map[language_code]locale
+	modTime   time.Time                                // modification time of the loaded file
 
-	digitSeparator   string
-	decimalSeparator string
+	// texts required by Require, they are checked on every reload
+	requiredLangs []string
+	requiredKeys  []string
 }
 
-func New() (*Dictionary, error) {
-	file, err := os.ReadFile("dictionary.json")
-	if err != nil {
+// New loads dictionary from JSON file at path, DefaultPath is used if path is empty.
+// All texts are parsed as templates, so a broken template fails loading.
+func New(path string) (*Dictionary, error) {
+	if path == "" {
+		path = DefaultPath
+	}
+
+	d := &Dictionary{
+		path: path,
+	}
+
+	if err := d.Reload(); err != nil {
 		return nil, err
 	}
 
-	var dictionary map[string]map[string]string
-	if err := json.Unmarshal(file, &dictionary); err != nil {
+	return d, nil
+}
+
+// Reload reads the file again and replaces texts if they are valid. Current texts are kept on error.
+func (d *Dictionary) Reload() error {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.ReadFile(d.path)
+	if err != nil {
+		return err
+	}
+
+	var texts map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(file, &texts); err != nil {
+		return err
+	}
+
+	locales, err := parseLocales(texts)
+	if err != nil {
+		return err
+	}
+
+	templates, err := parseTemplates(texts, locales)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := validate(templates, d.requiredLangs, d.requiredKeys); err != nil {
+		return err
+	}
+
+	d.templates = templates
+	d.locales = locales
+	d.modTime = info.ModTime()
+
+	return nil
+}
+
+// ReloadIfChanged reloads the file if its modification time differs from the loaded one.
+// It returns true if texts were replaced.
+func (d *Dictionary) ReloadIfChanged() (bool, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return false, err
+	}
+
+	d.mu.RLock()
+	changed := !info.ModTime().Equal(d.modTime)
+	d.mu.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	if err := d.Reload(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Require checks that every key exists in every language and remembers the requirement for reloads.
+func (d *Dictionary) Require(langs, keys []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := validate(d.templates, langs, keys); err != nil {
+		return err
+	}
+
+	d.requiredLangs = langs
+	d.requiredKeys = keys
+
+	return nil
+}
+
+func validate(templates map[string]map[string]*template.Template, langs, keys []string) error {
+	var errs []error
+
+	for _, lang := range langs {
+		if _, ok := templates[lang]; !ok {
+			errs = append(errs, fmt.Errorf("language %q not found", lang))
+			continue
+		}
+
+		for _, key := range keys {
+			if _, ok := templates[lang][key]; !ok {
+				errs = append(errs, fmt.Errorf("key %q not found in language %q", key, lang))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseLocales returns formatting settings of every language, DefaultLocale is used if they aren't set.
+func parseLocales(texts map[string]map[string]json.RawMessage) (map[string]format.Locale, error) {
+	var errs []error
+
+	locales := make(map[string]format.Locale, len(texts))
+	for lang, langTexts := range texts {
+		locale := format.DefaultLocale
+
+		if raw, ok := langTexts[localeKey]; ok {
+			if err := json.Unmarshal(raw, &locale); err != nil {
+				errs = append(errs, fmt.Errorf("failed to parse locale of language %q: %v", lang, err))
+				continue
+			}
+		}
+
+		if err := locale.Init(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid locale of language %q: %v", lang, err))
+			continue
+		}
+
+		locales[lang] = locale
+	}
+
+	return locales, errors.Join(errs...)
+}
+
+func parseTemplates(
+	texts map[string]map[string]json.RawMessage, locales map[string]format.Locale,
+) (map[string]map[string]*template.Template, error) {
+	var errs []error
+
+	templates := make(map[string]map[string]*template.Template, len(texts))
+	for lang, langTexts := range texts {
+		funcs := template.FuncMap{"plural": pluralFunc(lang, locales[lang])}
+
+		templates[lang] = make(map[string]*template.Template, len(langTexts))
+		for key, raw := range langTexts {
+			if key == localeKey {
+				continue
+			}
+
+			var text string
+			if err := json.Unmarshal(raw, &text); err != nil {
+				errs = append(errs, fmt.Errorf("failed to parse %q in language %q: %v", key, lang, err))
+				continue
+			}
+
+			tmpl, err := template.New(key).Funcs(funcs).Parse(text)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to parse %q in language %q: %v", key, lang, err))
+				continue
+			}
+
+			templates[lang][key] = tmpl
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
 		return nil, err
 	}
 
-	return &Dictionary{
-		dictionary:       dictionary,
-		digitSeparator:   " ",
-		decimalSeparator: ",",
-	}, nil
+	return templates, nil
 }
 
 func (d *Dictionary) Languages() []string {
-	langs := make([]string, 0, len(d.dictionary))
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	for lang := range d.dictionary {
+	langs := make([]string, 0, len(d.templates))
+
+	for lang := range d.templates {
 		langs = append(langs, lang)
 	}
 
 	return langs
 }
 
+// Locale returns formatting settings of the language or of DefaultLanguage if the language isn't found.
+func (d *Dictionary) Locale(lang string) format.Locale {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if locale, ok := d.locales[lang]; ok {
+		return locale
+	}
+
+	return d.locales[DefaultLanguage]
+}
+
+// Text executes template of the key in the language with values. Numbers, format.Money, format.Price and
+// time.Time values are formatted by the language locale, times are formatted in their own location.
+// The text of DefaultLanguage is used if the key isn't found in the language.
 func (d *Dictionary) Text(lang, key string, values ...map[string]any) string {
-	text, ok := d.dictionary[lang][key]
+	locale := d.Locale(lang)
+
+	d.mu.RLock()
+	tmpl, ok := d.templates[lang][key]
 	if !ok {
-		log.Error("Text: value not found", zap.String("lang", lang), zap.String("key", key))
-		return ""
+		tmpl, ok = d.templates[DefaultLanguage][key]
 	}
+	d.mu.RUnlock()
 
-	tmpl, err := template.New(key).Parse(text)
-	if err != nil {
-		return text
+	if !ok {
+		log.Error("Text: value not found", zap.String("lang", lang), zap.String("key", key))
+		return ""
 	}
 
 	var valuesMap map[string]any
 	if len(values) > 0 {
-		valuesMap = values[0]
-	}
+		valuesMap = make(map[string]any, len(values[0]))
 
-	// Format numeric types in values
-	for key, value := range valuesMap {
-		switch v := value.(type) {
-		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
-			valuesMap[key] = format.PrettyNumber(v, d.digitSeparator, d.decimalSeparator, false)
-		default:
-			valuesMap[key] = value
+		// Format numeric, money and time types in values
+		for key, value := range values[0] {
+			switch v := value.(type) {
+			case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+				valuesMap[key] = locale.Number(v)
+			case format.Money:
+				valuesMap[key] = locale.Money(v)
+			case format.Price:
+				valuesMap[key] = locale.Price(v)
+			case time.Time:
+				valuesMap[key] = locale.DateTime(v)
+			default:
+				valuesMap[key] = value
+			}
 		}
 	}
 
 	byteText := new(bytes.Buffer)
-	if err = tmpl.Execute(byteText, valuesMap); err != nil {
-		log.Error("Text: failed to execute template", zap.Error(err))
-		return text
+	if err := tmpl.Execute(byteText, valuesMap); err != nil {
+		log.Error("Text: failed to execute template", zap.String("key", key), zap.Error(err))
+		return ""
 	}
-	text = byteText.String()
 
-	return text
+	return byteText.String()
 }
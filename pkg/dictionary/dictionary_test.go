@@ -0,0 +1,148 @@
+package dictionary
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testTexts = `{
+	"ru": {"shares": "{{.Count}} {{plural .Count \"акция\" \"акции\" \"акций\"}}", "hello": "Привет"},
+	"en": {"shares": "{{.Count}} {{plural .Count \"share\" \"shares\"}}", "hello": "Hello"}
+}`
+
+func writeTexts(t *testing.T, path, texts string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(texts), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func newTestDictionary(t *testing.T) (*Dictionary, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "dictionary.json")
+	writeTexts(t, path, testTexts)
+
+	d, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return d, path
+}
+
+func TestText(t *testing.T) {
+	d, _ := newTestDictionary(t)
+
+	tests := []struct {
+		lang  string
+		count int
+		want  string
+	}{
+		{lang: "ru", count: 21, want: "21 акция"},
+		{lang: "ru", count: 1002, want: "1 002 акции"},
+		{lang: "en", count: 1, want: "1 share"},
+		{lang: "en", count: 111, want: "111 shares"},
+		{lang: "de", count: 5, want: "5 акций"}, // unknown language falls back to DefaultLanguage
+	}
+
+	for _, tt := range tests {
+		if got := d.Text(tt.lang, "shares", map[string]any{"Count": tt.count}); got != tt.want {
+			t.Errorf("Text(%q, %d) = %q, want %q", tt.lang, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestNewBrokenTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dictionary.json")
+	writeTexts(t, path, `{"ru": {"hello": "{{.Name"}}`)
+
+	if _, err := New(path); err == nil {
+		t.Error("New() error = nil, want error")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	tests := []struct {
+		name    string
+		langs   []string
+		keys    []string
+		wantErr bool
+	}{
+		{name: "all keys", langs: []string{"ru", "en"}, keys: []string{"shares", "hello"}},
+		{name: "missing key", langs: []string{"ru", "en"}, keys: []string{"hello", "bye"}, wantErr: true},
+		{name: "missing language", langs: []string{"ru", "de"}, keys: []string{"hello"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := newTestDictionary(t)
+
+			if err := d.Require(tt.langs, tt.keys); (err != nil) != tt.wantErr {
+				t.Errorf("Require() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name    string
+		texts   string
+		wantErr bool
+	}{
+		{
+			name:  "changed text",
+			texts: `{"ru": {"shares": "", "hello": "Здравствуйте"}, "en": {"shares": "", "hello": "Hi"}}`,
+		},
+		{
+			name:    "missing required key",
+			texts:   `{"ru": {"shares": "", "hello": "Здравствуйте"}, "en": {"shares": ""}}`,
+			wantErr: true,
+		},
+		{
+			name:    "broken template",
+			texts:   `{"ru": {"shares": "", "hello": "{{.Name"}, "en": {"shares": "", "hello": "Hi"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid locale",
+			texts:   `{"ru": {"locale": {"digit_separator": ",", "decimal_separator": ","}, "hello": ""}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			texts:   `{"ru": `,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, path := newTestDictionary(t)
+
+			if err := d.Require([]string{"ru", "en"}, []string{"hello"}); err != nil {
+				t.Fatalf("Require: %v", err)
+			}
+
+			writeTexts(t, path, tt.texts)
+
+			err := d.Reload()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// current texts are kept if the new ones are rejected
+			want := "Hello"
+			if !tt.wantErr {
+				want = "Hi"
+			}
+
+			if got := d.Text("en", "hello"); got != want {
+				t.Errorf("Text() = %q, want %q", got, want)
+			}
+		})
+	}
+}
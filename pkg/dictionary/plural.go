@@ -0,0 +1,79 @@
+package dictionary
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/leonid6372/success-bot/pkg/format"
+)
+
+// pluralFunc returns template function choosing word form by CLDR plural rules of the language:
+//
+//	{{plural .Count "акция" "акции" "акций"}} for ru (one, few, many)
+//	{{plural .Count "share" "shares"}} for en and other languages (one, other)
+//
+// Count may be a number or a string already formatted by Text.
+func pluralFunc(lang string, locale format.Locale) func(count any, forms ...string) (string, error) {
+	return func(count any, forms ...string) (string, error) {
+		n, err := pluralNumber(count, locale)
+		if err != nil {
+			return "", err
+		}
+
+		switch lang {
+		case "ru":
+			if len(forms) != 3 {
+				return "", fmt.Errorf("plural: 3 forms expected, got %d", len(forms))
+			}
+
+			return forms[pluralRU(n)], nil
+		default:
+			if len(forms) != 2 {
+				return "", fmt.Errorf("plural: 2 forms expected, got %d", len(forms))
+			}
+
+			if n == 1 {
+				return forms[0], nil
+			}
+
+			return forms[1], nil
+		}
+	}
+}
+
+// pluralRU returns index of form for ru: 0 – one, 1 – few, 2 – many. Fractions use few form ("1,5 акции").
+func pluralRU(n float64) int {
+	if n != math.Trunc(n) {
+		return 1
+	}
+
+	i := int64(math.Abs(n))
+	switch {
+	case i%10 == 1 && i%100 != 11:
+		return 0
+	case i%10 >= 2 && i%10 <= 4 && (i%100 < 12 || i%100 > 14):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func pluralNumber(count any, locale format.Locale) (float64, error) {
+	switch v := count.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		n, err := locale.ParseNumber(v)
+		if err != nil {
+			return 0, fmt.Errorf("plural: %v", err)
+		}
+
+		return n, nil
+	default:
+		return 0, fmt.Errorf("plural: unsupported count type %T", count)
+	}
+}
@@ -0,0 +1,70 @@
+package dictionary
+
+import (
+	"testing"
+
+	"github.com/leonid6372/success-bot/pkg/format"
+)
+
+func TestPlural(t *testing.T) {
+	ruForms := []string{"акция", "акции", "акций"}
+	enForms := []string{"share", "shares"}
+
+	tests := []struct {
+		count  any
+		wantRU string
+		wantEN string
+	}{
+		{count: 0, wantRU: "акций", wantEN: "shares"},
+		{count: 1, wantRU: "акция", wantEN: "share"},
+		{count: 2, wantRU: "акции", wantEN: "shares"},
+		{count: 5, wantRU: "акций", wantEN: "shares"},
+		{count: 11, wantRU: "акций", wantEN: "shares"},
+		{count: 12, wantRU: "акций", wantEN: "shares"},
+		{count: 21, wantRU: "акция", wantEN: "shares"},
+		{count: 22, wantRU: "акции", wantEN: "shares"},
+		{count: 25, wantRU: "акций", wantEN: "shares"},
+		{count: 111, wantRU: "акций", wantEN: "shares"},
+		{count: int64(-21), wantRU: "акция", wantEN: "shares"},
+		{count: 1.5, wantRU: "акции", wantEN: "shares"},
+		{count: "1 001", wantRU: "акция", wantEN: "shares"}, // formatted by the default locale
+		{count: "2,5", wantRU: "акции", wantEN: "shares"},
+	}
+
+	ru := pluralFunc("ru", format.DefaultLocale)
+	en := pluralFunc("en", format.DefaultLocale)
+
+	for _, tt := range tests {
+		got, err := ru(tt.count, ruForms...)
+		if err != nil || got != tt.wantRU {
+			t.Errorf("ru plural(%v) = %q, %v, want %q", tt.count, got, err, tt.wantRU)
+		}
+
+		got, err = en(tt.count, enForms...)
+		if err != nil || got != tt.wantEN {
+			t.Errorf("en plural(%v) = %q, %v, want %q", tt.count, got, err, tt.wantEN)
+		}
+	}
+}
+
+func TestPluralErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		lang  string
+		count any
+		forms []string
+	}{
+		{name: "ru with 2 forms", lang: "ru", count: 1, forms: []string{"акция", "акции"}},
+		{name: "en with 3 forms", lang: "en", count: 1, forms: []string{"share", "shares", "shares"}},
+		{name: "invalid number", lang: "en", count: "one", forms: []string{"share", "shares"}},
+		{name: "unsupported type", lang: "en", count: uint(1), forms: []string{"share", "shares"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := pluralFunc(tt.lang, format.DefaultLocale)(tt.count, tt.forms...); err == nil {
+				t.Error("plural() error = nil, want error")
+			}
+		})
+	}
+}
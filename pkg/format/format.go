@@ -2,6 +2,7 @@ package format
 
 import (
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/leonid6372/success-bot/pkg/log"
@@ -82,6 +83,25 @@ func PrettyNumber(number any, separator, decimalSeparator string, originalDecima
 	return intPart.String() + decimalPart
 }
 
+// PrettyPrice formats price rounded to exactly decimals digits after decimal separator,
+// e.g. 1234.5 with 2 decimals is "1 234,50" with " " and "," separators.
+func PrettyPrice(price float64, decimals int32, separator, decimalSeparator string) string {
+	pow := math.Pow10(int(decimals))
+	str := PrettyNumber(math.Round(price*pow)/pow, separator, decimalSeparator, true)
+
+	if decimals <= 0 {
+		return str
+	}
+
+	if !strings.Contains(str, decimalSeparator) {
+		str += decimalSeparator
+	}
+
+	current := len(str) - strings.LastIndex(str, decimalSeparator) - len(decimalSeparator)
+
+	return str + strings.Repeat("0", max(int(decimals)-current, 0))
+}
+
 func decimalsCount(f any) int {
 	str := fmt.Sprintf("%f", f)
 
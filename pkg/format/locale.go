@@ -0,0 +1,137 @@
+package format
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // locales load time zones by name
+)
+
+// Locale describes language specific formatting of numbers, money and dates.
+type Locale struct {
+	DigitSeparator   string `json:"digit_separator"`
+	DecimalSeparator string `json:"decimal_separator"`
+	MoneyPattern     string `json:"money_pattern"`    // {amount} and {currency} are replaced, e.g. "{amount} {currency}"
+	DateLayout       string `json:"date_layout"`      // Go time layout, e.g. "02.01.2006"
+	DateTimeLayout   string `json:"date_time_layout"` // Go time layout, e.g. "02.01.2006 15:04"
+	TimeZone         string `json:"time_zone"`        // IANA time zone of dates for users without own time zone
+
+	location *time.Location
+}
+
+// DefaultLocale is used for languages without own locale, empty fields of other locales are taken from it.
+var DefaultLocale = Locale{
+	DigitSeparator:   " ",
+	DecimalSeparator: ",",
+	MoneyPattern:     "{amount} {currency}",
+	DateLayout:       "02.01.2006",
+	DateTimeLayout:   "02.01.2006 15:04",
+	TimeZone:         "Europe/Moscow",
+}
+
+// Money is an amount in a currency, currency is a sign shown to users, e.g. "L$" or "USD".
+type Money struct {
+	Amount   float64
+	Currency string
+}
+
+// Price is a price rounded to instrument precision.
+type Price struct {
+	Value    float64
+	Decimals int32
+}
+
+// Init fills empty fields from DefaultLocale and loads time zone.
+func (l *Locale) Init() error {
+	if l.DigitSeparator == "" {
+		l.DigitSeparator = DefaultLocale.DigitSeparator
+	}
+
+	if l.DecimalSeparator == "" {
+		l.DecimalSeparator = DefaultLocale.DecimalSeparator
+	}
+
+	if l.MoneyPattern == "" {
+		l.MoneyPattern = DefaultLocale.MoneyPattern
+	}
+
+	if l.DateLayout == "" {
+		l.DateLayout = DefaultLocale.DateLayout
+	}
+
+	if l.DateTimeLayout == "" {
+		l.DateTimeLayout = DefaultLocale.DateTimeLayout
+	}
+
+	if l.TimeZone == "" {
+		l.TimeZone = DefaultLocale.TimeZone
+	}
+
+	if l.DigitSeparator == l.DecimalSeparator {
+		return fmt.Errorf("digit and decimal separators are the same: %q", l.DigitSeparator)
+	}
+
+	if !strings.Contains(l.MoneyPattern, "{amount}") {
+		return fmt.Errorf("money pattern %q has no {amount}", l.MoneyPattern)
+	}
+
+	location, err := time.LoadLocation(l.TimeZone)
+	if err != nil {
+		return fmt.Errorf("failed to load time zone %q: %v", l.TimeZone, err)
+	}
+
+	l.location = location
+
+	return nil
+}
+
+// Location returns time zone of the locale, UTC is returned if the locale isn't initialized.
+func (l Locale) Location() *time.Location {
+	if l.location == nil {
+		return time.UTC
+	}
+
+	return l.location
+}
+
+// Number formats integers as is and floats with 2 decimals, e.g. "1 234,50".
+func (l Locale) Number(number any) string {
+	return PrettyNumber(number, l.DigitSeparator, l.DecimalSeparator, false)
+}
+
+// Price formats price with exactly decimals digits after decimal separator.
+func (l Locale) Price(price Price) string {
+	return PrettyPrice(price.Value, price.Decimals, l.DigitSeparator, l.DecimalSeparator)
+}
+
+// Money formats amount with 2 decimals and places currency by the money pattern, e.g. "1 234,50 L$".
+func (l Locale) Money(money Money) string {
+	return strings.NewReplacer(
+		"{amount}", l.Number(money.Amount),
+		"{currency}", money.Currency,
+	).Replace(l.MoneyPattern)
+}
+
+// Date formats date of time by the locale layout in time's own location.
+func (l Locale) Date(t time.Time) string {
+	return t.Format(l.DateLayout)
+}
+
+// DateTime formats time by the locale layout in time's own location, callers convert it to user's time zone.
+func (l Locale) DateTime(t time.Time) string {
+	return t.Format(l.DateTimeLayout)
+}
+
+// ParseNumber parses number formatted by Number or Price.
+func (l Locale) ParseNumber(s string) (float64, error) {
+	s = strings.ReplaceAll(s, l.DigitSeparator, "")
+	s = strings.ReplaceAll(s, l.DecimalSeparator, ".")
+
+	number, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+
+	return number, nil
+}
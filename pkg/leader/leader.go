@@ -0,0 +1,108 @@
+package leader
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leonid6372/success-bot/pkg/errs"
+	"github.com/leonid6372/success-bot/pkg/log"
+	"go.uber.org/zap"
+)
+
+// Elector elects a single leader between instances by Postgres session advisory lock.
+// The lock is held by a dedicated connection, so it is released by Postgres if the instance dies.
+type Elector struct {
+	pool *pgxpool.Pool
+	key  int64
+
+	conn     *pgxpool.Conn
+	isLeader atomic.Bool
+}
+
+func NewElector(pool *pgxpool.Pool, key int64) *Elector {
+	return &Elector{
+		pool: pool,
+		key:  key,
+	}
+}
+
+func (e *Elector) IsLeader() bool {
+	return e.isLeader.Load()
+}
+
+// Run tries to acquire the lock or checks the held one every interval until ctx is done.
+func (e *Elector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.tick(ctx); err != nil {
+			log.Error("leader election failed", zap.Int64("key", e.key), zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return
+
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) tick(ctx context.Context) error {
+	if e.conn != nil {
+		// check that the session with the lock is still alive
+		if _, err := e.conn.Exec(ctx, `SELECT 1`); err != nil {
+			log.Warn("leadership lost", zap.Int64("key", e.key))
+			e.isLeader.Store(false)
+			e.conn.Conn().Close(context.Background())
+			e.conn.Release()
+			e.conn = nil
+
+			return errs.NewStack(err)
+		}
+
+		return nil
+	}
+
+	conn, err := e.pool.Acquire(ctx)
+	if err != nil {
+		return errs.NewStack(err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, e.key).Scan(&acquired); err != nil {
+		conn.Release()
+		return errs.NewStack(err)
+	}
+
+	if !acquired {
+		conn.Release()
+		return nil
+	}
+
+	log.Info("leadership acquired", zap.Int64("key", e.key))
+
+	e.conn = conn
+	e.isLeader.Store(true)
+
+	return nil
+}
+
+func (e *Elector) resign() {
+	if e.conn == nil {
+		return
+	}
+
+	e.isLeader.Store(false)
+
+	if _, err := e.conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, e.key); err != nil {
+		log.Error("failed to release advisory lock", zap.Int64("key", e.key), zap.Error(err))
+	}
+
+	e.conn.Release()
+	e.conn = nil
+}
@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"net/http"
+	"path"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+type transport struct {
+	base   http.RoundTripper
+	prefix string
+	skip   []string
+}
+
+// NewTransport returns http.RoundTripper which starts a span "<prefix>.<last path element>" for every request,
+// e.g. "telegram.sendMessage". Full URL isn't recorded because it may contain credentials.
+// Requests with last path element from skip are not traced.
+func NewTransport(base http.RoundTripper, prefix string, skip ...string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{
+		base:   base,
+		prefix: prefix,
+		skip:   skip,
+	}
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	method := path.Base(r.URL.Path)
+	if slices.Contains(t.skip, method) {
+		return t.base.RoundTrip(r)
+	}
+
+	ctx, span := Start(r.Context(), t.prefix+"."+method,
+		semconv.HTTPRequestMethodKey.String(r.Method),
+		semconv.ServerAddress(r.URL.Hostname()),
+	)
+
+	res, err := t.base.RoundTrip(r.WithContext(ctx))
+	if err == nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+	}
+
+	End(span, err)
+
+	return res, err
+}
@@ -0,0 +1,33 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type queryTracer struct{}
+
+// NewQueryTracer returns pgx tracer which starts a span for every query. Query arguments aren't recorded.
+func NewQueryTracer() pgx.QueryTracer {
+	return &queryTracer{}
+}
+
+func (qt *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = Start(ctx, "pgx.query",
+		semconv.DBSystemPostgreSQL,
+		semconv.DBQueryText(data.SQL),
+	)
+
+	return ctx
+}
+
+func (qt *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+
+	End(span, data.Err)
+}
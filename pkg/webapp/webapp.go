@@ -0,0 +1,102 @@
+package webapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingHash = errors.New("init data hash is missing")
+	ErrInvalidHash = errors.New("init data hash is invalid")
+	ErrExpired     = errors.New("init data is expired")
+	ErrMissingUser = errors.New("init data user is missing")
+)
+
+type User struct {
+	ID           int64  `json:"id"`
+	Username     string `json:"username"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	LanguageCode string `json:"language_code"`
+	IsPremium    bool   `json:"is_premium"`
+}
+
+// InitData is a parsed Telegram.WebApp.initData string.
+type InitData struct {
+	QueryID  string
+	User     User
+	AuthDate time.Time
+}
+
+// Validate checks initData signature by the bot token as described in
+// https://core.telegram.org/bots/webapps#validating-data-received-via-the-mini-app.
+// Zero ttl disables auth_date expiration check.
+func Validate(initData, botToken string, ttl time.Duration) (*InitData, error) {
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse init data: %w", err)
+	}
+
+	hash := values.Get("hash")
+	if hash == "" {
+		return nil, ErrMissingHash
+	}
+
+	pairs := make([]string, 0, len(values))
+	for key := range values {
+		if key == "hash" {
+			continue
+		}
+
+		pairs = append(pairs, key+"="+values.Get(key))
+	}
+	sort.Strings(pairs)
+
+	secret := hmacSHA256([]byte("WebAppData"), []byte(botToken))
+	expected := hex.EncodeToString(hmacSHA256(secret, []byte(strings.Join(pairs, "\n"))))
+
+	if !hmac.Equal([]byte(expected), []byte(hash)) {
+		return nil, ErrInvalidHash
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse auth_date: %w", err)
+	}
+
+	data := &InitData{
+		QueryID:  values.Get("query_id"),
+		AuthDate: time.Unix(authDate, 0),
+	}
+
+	if ttl > 0 && time.Since(data.AuthDate) > ttl {
+		return nil, ErrExpired
+	}
+
+	rawUser := values.Get("user")
+	if rawUser == "" {
+		return nil, ErrMissingUser
+	}
+
+	if err := json.Unmarshal([]byte(rawUser), &data.User); err != nil {
+		return nil, fmt.Errorf("failed to parse user: %w", err)
+	}
+
+	return data, nil
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,116 @@
+package webapp
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const (
+	testBotToken = "123456:test-token"
+	testUser     = `{"id":42,"first_name":"Ivan","username":"ivan","language_code":"ru"}`
+	testAuthDate = "1700000000"
+	testHash     = "a51b021aaeaa872e95ade26af088ce574f5133564e50e22e0c5129eeb600a837"
+)
+
+func testInitData(change func(values url.Values)) string {
+	values := url.Values{
+		"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"user":      {testUser},
+		"auth_date": {testAuthDate},
+		"hash":      {testHash},
+	}
+
+	if change != nil {
+		change(values)
+	}
+
+	return values.Encode()
+}
+
+func TestValidate(t *testing.T) {
+	data, err := Validate(testInitData(nil), testBotToken, 0)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	want := InitData{
+		QueryID: "AAHdF6IQAAAAAN0XohDhrOrc",
+		User: User{
+			ID:           42,
+			Username:     "ivan",
+			FirstName:    "Ivan",
+			LanguageCode: "ru",
+		},
+		AuthDate: time.Unix(1700000000, 0),
+	}
+
+	if data.QueryID != want.QueryID || data.User != want.User || !data.AuthDate.Equal(want.AuthDate) {
+		t.Errorf("Validate() = %+v, want %+v", *data, want)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		initData string
+		botToken string
+		ttl      time.Duration
+		wantErr  error
+	}{
+		{
+			name: "tampered field",
+			initData: testInitData(func(values url.Values) {
+				values.Set("user", `{"id":43,"first_name":"Ivan","username":"ivan","language_code":"ru"}`)
+			}),
+			botToken: testBotToken,
+			wantErr:  ErrInvalidHash,
+		},
+		{
+			name: "added field",
+			initData: testInitData(func(values url.Values) {
+				values.Set("start_param", "ref")
+			}),
+			botToken: testBotToken,
+			wantErr:  ErrInvalidHash,
+		},
+		{
+			name: "wrong hash",
+			initData: testInitData(func(values url.Values) {
+				values.Set("hash", "b"+testHash[1:])
+			}),
+			botToken: testBotToken,
+			wantErr:  ErrInvalidHash,
+		},
+		{
+			name:     "other bot token",
+			initData: testInitData(nil),
+			botToken: "654321:other-token",
+			wantErr:  ErrInvalidHash,
+		},
+		{
+			name:     "expired auth_date",
+			initData: testInitData(nil),
+			botToken: testBotToken,
+			ttl:      time.Hour,
+			wantErr:  ErrExpired,
+		},
+		{
+			name: "missing hash",
+			initData: testInitData(func(values url.Values) {
+				values.Del("hash")
+			}),
+			botToken: testBotToken,
+			wantErr:  ErrMissingHash,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Validate(tt.initData, tt.botToken, tt.ttl); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}